@@ -0,0 +1,63 @@
+"""Reading and labelling the result files, shared by the report and plot scripts."""
+
+import gzip
+import json
+from pathlib import Path
+from typing import List, Dict, Any, Optional
+
+def load_results(file_path: str) -> Dict[str, Any]:
+    """Load a result file, which is gzip-compressed when it was large."""
+    opener = gzip.open if file_path.endswith('.gz') else open
+    with opener(file_path, 'rt') as f:
+        return json.load(f)
+
+def series_label(data: Dict[str, Any], file_path: str) -> str:
+    """Label a result file by dbType, plus the schema and dataset options that were set."""
+    label = data.get('dbType', Path(file_path).stem)
+    if data.get('schemaVariant'):
+        label = f"{label} [{data['schemaVariant']}]"
+    if data.get('chunkInterval'):
+        label = f"{label} [chunk {data['chunkInterval']}]"
+    if data.get('shards'):
+        label = f"{label} [{data['shards']} shards]"
+    if data.get('ingestMethod'):
+        label = f"{label} [{data['ingestMethod']}]"
+    if data.get('scale'):
+        label = f"{label} [x{data['scale']:g}]"
+    if data.get('cardinalityFactor'):
+        label = f"{label} [card x{data['cardinalityFactor']}]"
+    if data.get('chunkStart') or data.get('chunkEnd'):
+        label = f"{label} [chunks {data.get('chunkStart', 0)}-{data.get('chunkEnd', 'end')}]"
+    return label
+
+# Capabilities that a query of the category needs, as declared by the backend
+# in the results, with the name the report gives a missing one.
+CATEGORY_CAPABILITIES = {
+    'percentile': ('percentiles', 'percentiles'),
+    'window': ('windowFunctions', 'window functions'),
+    'sessionization': ('windowFunctions', 'window functions'),
+    'gap-fill': ('gapFill', 'gap fill'),
+    'approximate': ('approxDistinct', 'approximate distinct'),
+}
+
+def load_capabilities(benchmark_files: List[str]) -> Dict[str, Dict[str, Any]]:
+    """Collect the capabilities each series declares; results written before they were recorded have none."""
+    capabilities = {}
+    for file_path in benchmark_files:
+        data = load_results(file_path)
+        if data.get('capabilities'):
+            capabilities[series_label(data, file_path)] = data['capabilities']
+    return capabilities
+
+def unsupported_reason(capabilities: Optional[Dict[str, Any]], categories: List[str]) -> Optional[str]:
+    """Explain a query recorded as -1 with the capabilities it needs and the engine lacks, or else with the note of the engine; None when nothing explains it."""
+    if not capabilities:
+        return None
+    missing = []
+    for category in categories:
+        key, name = CATEGORY_CAPABILITIES.get(category, (None, None))
+        if key and not capabilities.get(key, True) and name not in missing:
+            missing.append(name)
+    if missing:
+        return "no " + " or ".join(missing)
+    return capabilities.get('note')
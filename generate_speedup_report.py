@@ -1,16 +1,78 @@
 #!/usr/bin/env python3
 
 import argparse
-import json
 import statistics
 from pathlib import Path
 from typing import List, Dict, Any, Optional
 
-def parse_benchmark_file(file_path: str) -> Dict[str, Any]:
-    """Parse a single benchmark JSON file."""
-    with open(file_path, 'r') as f:
-        data = json.load(f)
-    return data
+from benchmark_results import load_results, series_label, load_capabilities, unsupported_reason
+
+def load_calibrations(benchmark_files: List[str]) -> Dict[str, Dict[str, float]]:
+    """Median client overhead of each series over the runs recorded with -calibrate."""
+    runs = {}
+    for file_path in benchmark_files:
+        data = load_results(file_path)
+        calibration = data.get('calibration')
+        if calibration:
+            runs.setdefault(series_label(data, file_path), []).append(calibration)
+    calibrations = {}
+    for label, calibration_runs in runs.items():
+        calibrations[label] = {
+            'timer_ns': statistics.median(c['timerOverheadNs'] for c in calibration_runs),
+            'decode_rate': statistics.median(c['decodeReadingsPerSec'] for c in calibration_runs),
+            'loopback_p50_ms': statistics.median(c['loopback']['p50Ms'] for c in calibration_runs),
+            'loopback_p99_ms': statistics.median(c['loopback']['p99Ms'] for c in calibration_runs),
+            'runs': len(calibration_runs),
+        }
+    return calibrations
+
+def load_energy(benchmark_files: List[str]) -> Dict[str, Dict[str, float]]:
+    """Median energy of the database host per phase for each series over the runs recorded with -energy."""
+    runs = {}
+    for file_path in benchmark_files:
+        data = load_results(file_path)
+        energy = data.get('energy')
+        if energy:
+            phases = {phase['phase']: phase for phase in energy.get('phases', [])}
+            runs.setdefault(series_label(data, file_path), []).append((energy.get('source', ''), phases))
+    stats = {}
+    for label, series_runs in runs.items():
+        ingestion = [phases['ingestion'] for _, phases in series_runs if 'ingestion' in phases]
+        queries = [phases['queries'] for _, phases in series_runs if 'queries' in phases]
+        stats[label] = {
+            'source': series_runs[0][0],
+            'ingestion_joules': statistics.median(p['joules'] for p in ingestion) if ingestion else None,
+            'rows_per_joule': statistics.median(p.get('rowsPerJoule', 0) for p in ingestion) if ingestion else None,
+            'query_joules': statistics.median(p['joules'] for p in queries) if queries else None,
+            'query_watts': statistics.median(p['meanWatts'] for p in queries) if queries else None,
+            'runs': len(series_runs),
+        }
+    return stats
+
+def format_optional(value: Optional[float], spec: str) -> str:
+    """Format a statistic that some series do not record."""
+    return "N/A" if value is None else format(value, spec)
+
+def load_server_settings(benchmark_files: List[str]) -> Dict[str, Dict[str, str]]:
+    """Collect the server settings each series recorded; a later run of the series overrides an earlier one."""
+    settings = {}
+    for file_path in benchmark_files:
+        data = load_results(file_path)
+        if data.get('serverSettings'):
+            settings.setdefault(series_label(data, file_path), {}).update(data['serverSettings'])
+    return settings
+
+def differing_settings(settings: Dict[str, Dict[str, str]]) -> List[str]:
+    """Names of the settings recorded by several series with different values."""
+    names = set()
+    for series_settings in settings.values():
+        names.update(series_settings)
+    differing = []
+    for name in sorted(names):
+        values = [s[name] for s in settings.values() if name in s]
+        if len(values) > 1 and len(set(values)) > 1:
+            differing.append(name)
+    return differing
 
 def calculate_ingestion_stats(benchmark_files: List[str]) -> Dict[str, Dict[str, float]]:
     """Calculate averaged ingestion statistics for each database type."""
@@ -18,8 +80,8 @@ def calculate_ingestion_stats(benchmark_files: List[str]) -> Dict[str, Dict[str,
     grouped_data = {}
     
     for file_path in benchmark_files:
-        data = parse_benchmark_file(file_path)
-        db_type = data.get('dbType', Path(file_path).stem)
+        data = load_results(file_path)
+        db_type = series_label(data, file_path)
         ingestion_data = data.get('ingestion', [])
         
         if not ingestion_data:
@@ -43,6 +105,7 @@ def calculate_ingestion_stats(benchmark_files: List[str]) -> Dict[str, Dict[str,
         for ingestion_data in ingestion_data_list:
             durations = [entry['durationMs'] for entry in ingestion_data]
             records = [entry['nRecords'] for entry in ingestion_data]
+            failed = [entry.get('failed', False) for entry in ingestion_data]
             
             # Calculate records per batch (incremental)
             records_per_batch = []
@@ -52,10 +115,10 @@ def calculate_ingestion_stats(benchmark_files: List[str]) -> Dict[str, Dict[str,
                 else:
                     records_per_batch.append(records[i] - records[i-1])
             
-            # Calculate ingestion rate (records per second)
+            # Calculate ingestion rate (records per second), skipping failed batches
             ingestion_rates = []
-            for duration, batch_records in zip(durations, records_per_batch):
-                if duration > 0:
+            for duration, batch_records, batch_failed in zip(durations, records_per_batch, failed):
+                if duration > 0 and not batch_failed:
                     rate = (batch_records * 1000) / duration  # Convert ms to seconds
                     ingestion_rates.append(rate)
             
@@ -84,8 +147,8 @@ def calculate_query_stats(benchmark_files: List[str]) -> Dict[int, Dict[str, Any
     grouped_data = {}
     
     for file_path in benchmark_files:
-        data = parse_benchmark_file(file_path)
-        db_type = data.get('dbType', Path(file_path).stem)
+        data = load_results(file_path)
+        db_type = series_label(data, file_path)
         queries = data.get('queries', [])
         
         if db_type not in grouped_data:
@@ -99,6 +162,7 @@ def calculate_query_stats(benchmark_files: List[str]) -> Dict[int, Dict[str, Any
             if query_id not in grouped_data[db_type]:
                 grouped_data[db_type][query_id] = {
                     'description': description,
+                    'categories': query.get('categories', []),
                     'durations': []
                 }
             
@@ -112,6 +176,7 @@ def calculate_query_stats(benchmark_files: List[str]) -> Dict[int, Dict[str, Any
             if query_id not in query_stats:
                 query_stats[query_id] = {
                     'description': query_info['description'],
+                    'categories': query_info['categories'],
                     'databases': {}
                 }
             
@@ -163,6 +228,10 @@ def generate_speedup_report(benchmark_files: List[str], output_file: str = "spee
     # Calculate statistics
     ingestion_stats = calculate_ingestion_stats(benchmark_files)
     query_stats = calculate_query_stats(benchmark_files)
+    capabilities = load_capabilities(benchmark_files)
+    calibrations = load_calibrations(benchmark_files)
+    server_settings = load_server_settings(benchmark_files)
+    energy = load_energy(benchmark_files)
     
     # Determine baseline database - use the one with most completed queries and slowest ingestion
     baseline_db = None
@@ -238,6 +307,11 @@ def generate_speedup_report(benchmark_files: List[str], output_file: str = "spee
         # Calculate median speedups across all queries
         # Include 0 for failed/incomplete queries to ensure fair comparison
         all_speedups = {db: [] for db in ingestion_stats.keys() if db != baseline_db}
+        # Speedups of the successful queries per category, from results that
+        # record the categories of the catalog queries
+        category_speedups = {}
+        # Queries recorded as -1 that the capabilities of the engine explain
+        unsupported = []
         
         report_lines.append("### Query Execution Times (Averaged)")
         report_lines.append("")
@@ -257,12 +331,29 @@ def generate_speedup_report(benchmark_files: List[str], output_file: str = "spee
                     else:
                         row += f" {duration:.1f}ms |"
                 else:
-                    row += " N/A |"
+                    # A query missing from the results was not run at all
+                    reason = unsupported_reason(capabilities.get(db), query_data['categories']) if db in query_data['databases'] else None
+                    if reason:
+                        row += " unsupported |"
+                        unsupported.append((query_id, description, db, reason))
+                    else:
+                        row += " N/A |"
             
             report_lines.append(row)
         
         report_lines.append("")
         
+        if unsupported:
+            report_lines.append("### Unsupported Queries")
+            report_lines.append("")
+            report_lines.append("Queries recorded as -1 that the capabilities declared by the engine explain.")
+            report_lines.append("")
+            report_lines.append("| Query ID | Description | Database | Reason |")
+            report_lines.append("|----------|-------------|----------|--------|")
+            for query_id, description, db, reason in unsupported:
+                report_lines.append(f"| {query_id} | {description} | {db} | {reason} |")
+            report_lines.append("")
+        
         # Calculate and display speedups for each query
         report_lines.append("### Query Speedups")
         report_lines.append("")
@@ -293,6 +384,8 @@ def generate_speedup_report(benchmark_files: List[str], output_file: str = "spee
                         if speedup > 0:
                             row += f" {speedup:.2f}x |"
                             all_speedups[db].append(speedup)
+                            for category in query_data['categories']:
+                                category_speedups.setdefault(category, {}).setdefault(db, []).append(speedup)
                         else:
                             row += " N/A |"
                             # Add 0 for queries that didn't complete
@@ -328,7 +421,62 @@ def generate_speedup_report(benchmark_files: List[str], output_file: str = "spee
                 report_lines.append(f"| {db} | {median_speedup:.2f}x | {min_speedup:.2f}x | {max_speedup:.2f}x | {successful_count} |")
         
         report_lines.append("")
+        
+        if category_speedups:
+            report_lines.append("### Median Query Speedups by Category")
+            report_lines.append("")
+            other_dbs = sorted(all_speedups.keys())
+            report_lines.append("| Category | " + " | ".join(f"{db} Speedup" for db in other_dbs) + " |")
+            report_lines.append("|----------|" + "|".join(["-" * 12 for _ in other_dbs]) + "|")
+            for category in sorted(category_speedups):
+                row = f"| {category} |"
+                for db in other_dbs:
+                    speedups_list = category_speedups[category].get(db, [])
+                    if speedups_list:
+                        row += f" {statistics.median(speedups_list):.2f}x ({len(speedups_list)}) |"
+                    else:
+                        row += " N/A |"
+                report_lines.append(row)
+            report_lines.append("")
     
+    # Energy of the database host, from the runs recorded with -energy
+    if energy:
+        report_lines.append("## Energy Consumption")
+        report_lines.append("")
+        report_lines.append("Energy drawn by the database host during the measured ingestion and the query catalog, idle consumption included.")
+        report_lines.append("")
+        report_lines.append("| Database | Source | Ingestion (J) | Rows/J | Queries (J) | Queries Mean Power (W) | Runs |")
+        report_lines.append("|----------|--------|---------------|--------|-------------|------------------------|------|")
+        for db_type, stats in energy.items():
+            report_lines.append(f"| {db_type} | {stats['source']} | {format_optional(stats['ingestion_joules'], ',.0f')} | {format_optional(stats['rows_per_joule'], ',.1f')} | {format_optional(stats['query_joules'], ',.0f')} | {format_optional(stats['query_watts'], '.1f')} | {stats['runs']} |")
+        report_lines.append("")
+
+    # Client noise floor, from the runs recorded with -calibrate
+    if calibrations:
+        report_lines.append("## Client Noise Floor")
+        report_lines.append("")
+        report_lines.append("Overhead of the benchmark client itself, measured against no database. Query times within a few loopback round trips of each other are not told apart by the client.")
+        report_lines.append("")
+        report_lines.append("| Database | Clock Read (ns) | Decoding (readings/s) | Loopback p50 (ms) | Loopback p99 (ms) | Runs |")
+        report_lines.append("|----------|-----------------|-----------------------|-------------------|-------------------|------|")
+        for db_type, calibration in calibrations.items():
+            report_lines.append(f"| {db_type} | {calibration['timer_ns']:.0f} | {calibration['decode_rate']:,.0f} | {calibration['loopback_p50_ms']:.3f} | {calibration['loopback_p99_ms']:.3f} | {calibration['runs']} |")
+        report_lines.append("")
+
+    # Server settings that differ between series of the same engine
+    differing = differing_settings(server_settings)
+    if differing:
+        series = list(server_settings.keys())
+        report_lines.append("## Server Settings")
+        report_lines.append("")
+        report_lines.append("Settings recorded by more than one series with different values; timings of these series are not measured under the same tuning.")
+        report_lines.append("")
+        report_lines.append("| Setting | " + " | ".join(series) + " |")
+        report_lines.append("|---------|" + "|".join("-" * (len(s) + 2) for s in series) + "|")
+        for name in differing:
+            report_lines.append(f"| {name} | " + " | ".join(server_settings[s].get(name, "-") for s in series) + " |")
+        report_lines.append("")
+
     # Summary Section
     report_lines.append("## Summary")
     report_lines.append("")
@@ -1,22 +1,22 @@
 #!/usr/bin/env python3
 
-import json
 import matplotlib.pyplot as plt
 import numpy as np
 from pathlib import Path
 from typing import List, Dict, Any
 import os
 
+from benchmark_results import load_results, series_label, load_capabilities, unsupported_reason
+
 def parse_benchmark_files(file_paths: List[str]) -> Dict[str, Any]:
     """Parse benchmark JSON files and organize data by query ID, averaging results by dbType."""
     # First, collect all data grouped by dbType and queryId
     grouped_data = {}
     
     for file_path in file_paths:
-        with open(file_path, 'r') as f:
-            benchmark_data = json.load(f)
+        benchmark_data = load_results(file_path)
         
-        db_type = benchmark_data.get('dbType', Path(file_path).stem)
+        db_type = series_label(benchmark_data, file_path)
         queries = benchmark_data.get('queries', [])
         
         if db_type not in grouped_data:
@@ -30,6 +30,7 @@ def parse_benchmark_files(file_paths: List[str]) -> Dict[str, Any]:
             if query_id not in grouped_data[db_type]:
                 grouped_data[db_type][query_id] = {
                     'description': description,
+                    'categories': query.get('categories', []),
                     'durations': []
                 }
             
@@ -43,6 +44,7 @@ def parse_benchmark_files(file_paths: List[str]) -> Dict[str, Any]:
             if query_id not in data:
                 data[query_id] = {
                     'description': query_info['description'],
+                    'categories': query_info['categories'],
                     'databases': {}
                 }
             
@@ -66,13 +68,13 @@ def create_query_barplots(benchmark_files: List[str], output_dir: str = "query_p
     
     # Parse all benchmark data (now returns averaged results)
     query_data = parse_benchmark_files(benchmark_files)
+    capabilities = load_capabilities(benchmark_files)
     
     # Count files per database type for display
     db_file_counts = {}
     for file_path in benchmark_files:
-        with open(file_path, 'r') as f:
-            benchmark_data = json.load(f)
-        db_type = benchmark_data.get('dbType', Path(file_path).stem)
+        benchmark_data = load_results(file_path)
+        db_type = series_label(benchmark_data, file_path)
         db_file_counts[db_type] = db_file_counts.get(db_type, 0) + 1
     
     # Get all database types for consistent ordering
@@ -90,6 +92,8 @@ def create_query_barplots(benchmark_files: List[str], output_dir: str = "query_p
         databases = []
         durations = []
         colors = []
+        # Engines whose capabilities explain a -1, shown as an annotated gap
+        unsupported = {}
         
         color_map = {
             'postgres': '#336791',
@@ -108,9 +112,16 @@ def create_query_barplots(benchmark_files: List[str], output_dir: str = "query_p
                     databases.append(db)
                     durations.append(duration)
                     colors.append(color_map.get(db, '#888888'))
+                else:
+                    reason = unsupported_reason(capabilities.get(db), data['categories'])
+                    if reason:
+                        databases.append(db)
+                        durations.append(0)
+                        colors.append('#DDDDDD')
+                        unsupported[db] = reason
         
         # Skip empty queries (all failed)
-        if not databases:
+        if len(databases) == len(unsupported):
             print(f"Skipping Query {query_id}: No valid data")
             continue
         
@@ -119,7 +130,12 @@ def create_query_barplots(benchmark_files: List[str], output_dir: str = "query_p
         bars = plt.bar(databases, durations, color=colors, alpha=0.8, edgecolor='black', linewidth=0.5)
         
         # Add value labels on bars
-        for bar, duration in zip(bars, durations):
+        for bar, db, duration in zip(bars, databases, durations):
+            if db in unsupported:
+                plt.text(bar.get_x() + bar.get_width()/2., 0.02, f'unsupported:\n{unsupported[db]}',
+                        transform=plt.gca().get_xaxis_transform(), ha='center', va='bottom',
+                        rotation=90, fontsize=8, style='italic')
+                continue
             height = bar.get_height()
             if duration >= 1000:
                 label = f'{duration/1000:.1f}s'
@@ -143,6 +159,126 @@ def create_query_barplots(benchmark_files: List[str], output_dir: str = "query_p
         
         print(f"Created plot for Query {query_id}: {output_file}")
 
+def create_concurrency_plot(benchmark_files: List[str], output_dir: str = "query_plots"):
+    """Plot the throughput and p95 latency of the concurrency sweep against the number of clients, one line per series."""
+    curves = {}
+    for file_path in benchmark_files:
+        benchmark_data = load_results(file_path)
+        sweep = benchmark_data.get('concurrencySweep')
+        if not sweep:
+            continue
+        label = series_label(benchmark_data, file_path)
+        steps = [step for step in sweep['steps'] if step['queries'] > 0]
+        if steps:
+            curves[label] = (sweep, steps)
+
+    if not curves:
+        return
+
+    Path(output_dir).mkdir(exist_ok=True)
+    fig, (throughput_ax, latency_ax) = plt.subplots(1, 2, figsize=(14, 6))
+    for label, (sweep, steps) in sorted(curves.items()):
+        clients = [step['clients'] for step in steps]
+        throughput_ax.plot(clients, [step['throughput'] for step in steps], marker='o', label=label)
+        latency_ax.plot(clients, [step['p95Ms'] for step in steps], marker='o', label=label)
+
+    queries = {f"Query {sweep['queryId']}: {sweep['description']}" for sweep, _ in curves.values()}
+    fig.suptitle(f"Concurrency scaling ({', '.join(sorted(queries))})", fontsize=14, fontweight='bold')
+    throughput_ax.set_ylabel('Throughput (queries/s)', fontsize=12, fontweight='bold')
+    latency_ax.set_ylabel('p95 Latency (ms)', fontsize=12, fontweight='bold')
+    latency_ax.set_yscale('log')
+    for ax in (throughput_ax, latency_ax):
+        ax.set_xscale('log', base=2)
+        ax.set_xlabel('Concurrent Clients', fontsize=12, fontweight='bold')
+        ax.grid(True, alpha=0.3)
+        ax.legend()
+    fig.tight_layout()
+
+    output_file = f"{output_dir}/concurrency_scaling.png"
+    fig.savefig(output_file, dpi=300, bbox_inches='tight')
+    plt.close(fig)
+    print(f"Created concurrency scaling plot: {output_file}")
+
+def create_size_sweep_plots(benchmark_files: List[str], output_dir: str = "query_plots"):
+    """Plot the latency of every query against the share of the data ingested, one line per series."""
+    # query id -> series label -> [(records, duration)]
+    curves = {}
+    descriptions = {}
+    for file_path in benchmark_files:
+        benchmark_data = load_results(file_path)
+        label = series_label(benchmark_data, file_path)
+        for checkpoint in benchmark_data.get('sizeSweep', []):
+            for query in checkpoint['queries']:
+                if query['durationMs'] < 0:
+                    continue
+                descriptions[query['queryId']] = query.get('description', f"Query {query['queryId']}")
+                points = curves.setdefault(query['queryId'], {}).setdefault(label, [])
+                points.append((checkpoint['nRecords'], query['durationMs']))
+
+    if not curves:
+        return
+
+    Path(output_dir).mkdir(exist_ok=True)
+    for query_id in sorted(curves):
+        plt.figure(figsize=(12, 6))
+        for label, points in sorted(curves[query_id].items()):
+            points.sort()
+            plt.plot([p[0] for p in points], [p[1] for p in points], marker='o', label=label)
+
+        plt.title(f'Query {query_id}: {descriptions[query_id]} (Latency vs Data Volume)', fontsize=14, fontweight='bold', pad=20)
+        plt.xlabel('Records Ingested', fontsize=12, fontweight='bold')
+        plt.ylabel('Execution Time (ms)', fontsize=12, fontweight='bold')
+        plt.grid(True, alpha=0.3)
+        plt.legend()
+        plt.tight_layout()
+
+        output_file = f"{output_dir}/size_sweep_query_{query_id:02d}.png"
+        plt.savefig(output_file, dpi=300, bbox_inches='tight')
+        plt.close()
+        print(f"Created size sweep plot for Query {query_id}: {output_file}")
+
+def create_ingestion_timeline_plot(benchmark_files: List[str], output_dir: str = "query_plots"):
+    """Plot the write throughput of every ingestion batch against the time it started, one line per result file."""
+    timelines = []
+    for file_path in benchmark_files:
+        benchmark_data = load_results(file_path)
+        batches = benchmark_data.get('ingestion') or []
+        # Result files of earlier versions have no batch start times.
+        if not batches or 'offsetMs' not in batches[0]:
+            continue
+        offsets = []
+        rates = []
+        previous = 0
+        for batch in batches:
+            records = batch['nRecords'] - previous
+            previous = batch['nRecords']
+            if batch.get('failed') or batch['durationMs'] <= 0:
+                continue
+            offsets.append(batch['offsetMs'] / 1000)
+            rates.append(records / (batch['durationMs'] / 1000))
+        if offsets:
+            timelines.append((f"{series_label(benchmark_data, file_path)} ({Path(file_path).name})", offsets, rates))
+
+    if not timelines:
+        return
+
+    Path(output_dir).mkdir(exist_ok=True)
+    plt.figure(figsize=(14, 6))
+    for label, offsets, rates in sorted(timelines):
+        plt.plot(offsets, rates, marker='.', linewidth=1, label=label)
+
+    plt.title('Ingestion Throughput over Time', fontsize=14, fontweight='bold', pad=20)
+    plt.xlabel('Time since the first batch (s)', fontsize=12, fontweight='bold')
+    plt.ylabel('Batch Throughput (records/s)', fontsize=12, fontweight='bold')
+    plt.grid(True, alpha=0.3)
+    plt.legend(fontsize=8)
+    plt.tight_layout()
+
+    output_file = f"{output_dir}/ingestion_timeline.png"
+    plt.savefig(output_file, dpi=300, bbox_inches='tight')
+    plt.close()
+    print(f"Created ingestion timeline plot: {output_file}")
+
 def main():
     import argparse
     
@@ -166,6 +302,9 @@ def main():
     
     print(f"Processing {len(valid_files)} benchmark files (averaging by dbType)...")
     create_query_barplots(valid_files, args.output)
+    create_concurrency_plot(valid_files, args.output)
+    create_size_sweep_plots(valid_files, args.output)
+    create_ingestion_timeline_plot(valid_files, args.output)
     print(f"All averaged query comparison plots created successfully in {args.output}/!")
     
     return 0
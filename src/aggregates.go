@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"src/results"
+)
+
+// rollupDialect is a backend's set of pre-aggregated tables or views, e.g.
+// continuous aggregates or an hourly downsample. create builds and materializes
+// them; queries are the catalog queries they answer, rewritten to read from the
+// rollups and keeping their catalog id.
+type rollupDialect struct {
+	create  []string
+	queries []querySpec
+}
+
+// benchmarkContinuousAggregates ingests the full dataset, materializes the
+// continuous aggregates and times the hourly and daily catalog queries against
+// them. The same queries on the raw table are in the regular benchmark results.
+func benchmarkContinuousAggregates(info backendInfo, connStr string, outFile string, opts benchmarkOptions) error {
+	aggregates := info.continuousAggregates
+	if len(aggregates.create) == 0 {
+		return fmt.Errorf("continuous-aggregates scenario is not available for %s", info.name)
+	}
+
+	b, err := info.open(connStr)
+	if err != nil {
+		return err
+	}
+	defer b.close()
+
+	ctx := context.Background()
+	scenario := ScenarioResult{Name: "continuous-aggregates"}
+	if err := configureBackend(info, b, opts); err != nil {
+		return err
+	}
+	if err := loadScenarioData(ctx, info, b, opts, &scenario); err != nil {
+		return err
+	}
+
+	infof("Continuous aggregates: materializing\n")
+	start := time.Now()
+	for _, stmt := range aggregates.create {
+		if err := b.exec(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	scenario.addPhase("materialize", time.Since(start))
+
+	boundsQuery, _ := info.lookupQuery(1)
+	minTime, maxTime, err := b.timeBounds(ctx, boundsQuery.text)
+	if err != nil {
+		return err
+	}
+	bounds := newQueryBounds(minTime, maxTime)
+
+	scenario.Queries, err = timeCatalogQueries(ctx, info, b, opts, aggregates.queries, bounds)
+	if err != nil {
+		return err
+	}
+
+	return writeResults(outFile, BenchmarkResults{
+		DbType:        info.name,
+		SchemaVariant: opts.SchemaVariant,
+		IngestMethod:  opts.IngestMethod,
+		ChunkInterval: opts.ChunkInterval,
+		Shards:        opts.Shards,
+		Scenarios:     []ScenarioResult{scenario},
+	})
+}
+
+// timeCatalogQueries times a set of queries that stand in for catalog queries,
+// e.g. rewritten for a rollup, and records them under their catalog id.
+func timeCatalogQueries(ctx context.Context, info backendInfo, b backend, opts benchmarkOptions, queries []querySpec, bounds queryBounds) ([]QueryResult, error) {
+	var timed []QueryResult
+	for _, q := range queries {
+		infof("Running query %d: %s\n", q.id, queryDescriptions[q.id])
+		samples, err := repeatQuery(opts.QueryRepeats, func() error {
+			return b.query(ctx, q.text, q.arguments(bounds)...)
+		})
+		duration := results.MedianMs(samples)
+		if err != nil {
+			if !info.lenientQueries {
+				return nil, err
+			}
+			duration = -1
+			samples = nil
+		}
+
+		result := catalogQueryResult(q.id)
+		result.DurationMs = duration
+		if len(samples) > 1 {
+			result.SamplesMs = samples
+		}
+		if opts.Explain && err == nil {
+			result.Plan = capturePlan(ctx, info, b, q, bounds)
+		}
+		timed = append(timed, result)
+	}
+	return timed, nil
+}
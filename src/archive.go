@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// archiveOptions configures the optional archival phase. Target names the cold
+// tier: a tablespace for TimescaleDB, a storage policy disk for ClickHouse.
+type archiveOptions struct {
+	Target   string
+	Fraction float64
+}
+
+type ArchiveResult struct {
+	Target         string        `json:"target"`
+	Cutoff         time.Time     `json:"cutoff"`
+	Partitions     int           `json:"partitions"`
+	MoveDurationMs int64         `json:"moveDurationMs"`
+	Queries        []QueryResult `json:"queries"`
+}
+
+// tieredBackend is implemented by backends that can move old data to a cold
+// storage tier. It returns the number of chunks or partitions moved.
+type tieredBackend interface {
+	moveToColdTier(ctx context.Context, cutoff time.Time, target string) (int, error)
+}
+
+// historicalQueryIds are the catalog queries that only touch data before the
+// middle time. They are re-timed after the old partitions have been archived.
+var historicalQueryIds = []int{5, 15}
+
+// runArchivalPhase moves everything older than the configured fraction of the
+// time range to the cold tier and re-runs the historical queries against it.
+func runArchivalPhase(ctx context.Context, info backendInfo, b backend, opts archiveOptions, bounds queryBounds) (*ArchiveResult, error) {
+	tiered, ok := b.(tieredBackend)
+	if !ok {
+		return nil, fmt.Errorf("backend does not support tiered storage")
+	}
+
+	result := &ArchiveResult{
+		Target: opts.Target,
+		Cutoff: bounds.min.Add(time.Duration(float64(bounds.max.Sub(bounds.min)) * opts.Fraction)),
+	}
+
+	infof("Moving data older than %s to %s\n", result.Cutoff.Format(time.RFC3339), opts.Target)
+	start := time.Now()
+	moved, err := tiered.moveToColdTier(ctx, result.Cutoff, opts.Target)
+	if err != nil {
+		return nil, err
+	}
+	result.MoveDurationMs = time.Since(start).Milliseconds()
+	result.Partitions = moved
+
+	for _, id := range historicalQueryIds {
+		q, ok := info.lookupQuery(id)
+		if !ok {
+			continue
+		}
+		infof("Running archived query %d: %s\n", id, queryDescriptions[id])
+		start = time.Now()
+		if err := b.query(ctx, q.text, q.arguments(bounds)...); err != nil {
+			return nil, err
+		}
+		query := catalogQueryResult(id)
+		query.DurationMs = time.Since(start).Milliseconds()
+		result.Queries = append(result.Queries, query)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// AsyncWriteResult counts the points of the writes whose outcome the client
+// learns after the fact, e.g. from the error channel of a non-blocking write
+// API. The ingestion time of such a write path only covers handing the points
+// to the client, so the points the server rejected, or that the client gave
+// up on, would otherwise inflate the throughput.
+type AsyncWriteResult struct {
+	Sent     int64 `json:"sent"`
+	Rejected int64 `json:"rejected"`
+	// Errors is the number of failed write requests, each of which may have
+	// rejected many points.
+	Errors int64 `json:"errors"`
+	// AcceptanceRate is the share of the sent points the server accepted.
+	AcceptanceRate float64 `json:"acceptanceRate"`
+	LastError      string  `json:"lastError,omitempty"`
+}
+
+// asyncWriteReporter is implemented by backends with an asynchronous write
+// path; asyncWrites returns nil when the run did not use it.
+type asyncWriteReporter interface {
+	asyncWrites() *AsyncWriteResult
+}
+
+// writeTracker counts the points of the asynchronous writes of a backend, as
+// the writers send them and the error handlers reject them.
+type writeTracker struct {
+	sent     atomic.Int64
+	rejected atomic.Int64
+	errors   atomic.Int64
+
+	mu        sync.Mutex
+	lastError error
+}
+
+func (t *writeTracker) sending(points int) {
+	t.sent.Add(int64(points))
+}
+
+// reject records a failed request that lost the given number of points.
+func (t *writeTracker) reject(points int, err error) {
+	t.rejected.Add(int64(points))
+	t.errors.Add(1)
+	t.mu.Lock()
+	t.lastError = err
+	t.mu.Unlock()
+}
+
+// result returns nil when no points were sent.
+func (t *writeTracker) result() *AsyncWriteResult {
+	sent := t.sent.Load()
+	if sent == 0 {
+		return nil
+	}
+	// A request that failed after part of it was counted as rejected already
+	// cannot reject more than was sent.
+	rejected := min(t.rejected.Load(), sent)
+	result := &AsyncWriteResult{
+		Sent:           sent,
+		Rejected:       rejected,
+		Errors:         t.errors.Load(),
+		AcceptanceRate: float64(sent-rejected) / float64(sent),
+	}
+	t.mu.Lock()
+	if t.lastError != nil {
+		result.LastError = t.lastError.Error()
+	}
+	t.mu.Unlock()
+	return result
+}
+
+// collectAsyncWrites returns the counts of the backend's asynchronous writes,
+// warning when the server did not accept all of them.
+func collectAsyncWrites(info backendInfo, b backend) *AsyncWriteResult {
+	reporter, ok := b.(asyncWriteReporter)
+	if !ok {
+		return nil
+	}
+	result := reporter.asyncWrites()
+	if result != nil && result.Rejected > 0 {
+		fmt.Printf("[WARN] %s rejected %d of %d asynchronously written points (%.2f%% accepted), last with: %s\n",
+			info.name, result.Rejected, result.Sent, 100*result.AcceptanceRate, result.LastError)
+	}
+	return result
+}
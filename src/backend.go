@@ -0,0 +1,325 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+var errUnsupportedDatabase = errors.New("unsupported database type")
+
+// backend holds the driver-specific pieces of a database under test: schema
+// creation, ingestion of a batch of readings and execution of statements in
+// the native dialect.
+type backend interface {
+	createSchema(ctx context.Context) error
+	ingest(ctx context.Context, readings []Reading) error
+	exec(ctx context.Context, stmt string) error
+	query(ctx context.Context, q string, args ...any) error
+	// timeBounds runs the time bounds query q and returns the oldest and the
+	// newest timestamp.
+	timeBounds(ctx context.Context, q string) (time.Time, time.Time, error)
+	close()
+}
+
+// writeBatchSizer is implemented by backends that split a chunk into several
+// write requests of a configurable number of rows.
+type writeBatchSizer interface {
+	setWriteBatchSize(rows int)
+}
+
+// backendInfo is what a backend registers about itself. Every backend lives in
+// its own file behind a build tag (e.g. noinfluxdb), so a binary only links the
+// drivers it is built with.
+type backendInfo struct {
+	name        string
+	description string
+	open        func(connStr string) (backend, error)
+	ping        func(ctx context.Context, connStr string) error
+	// isTransient recognises driver errors worth retrying, on top of the
+	// generic network errors.
+	isTransient func(err error) bool
+	queries     []querySpec
+	// bucketQuery renders the occupancy aggregation of the bucket sweep for
+	// one bucket width; nil when the dialect cannot express it.
+	bucketQuery func(w bucketWidth) string
+	// capabilities declares what the engine can express, to explain the
+	// queries it records as -1; nil when the dialect is defined at run time.
+	capabilities *Capabilities
+	// lenientQueries records a failing query as -1 instead of aborting the run.
+	lenientQueries bool
+	// lenientIngestion marks a batch that still fails after its retries as
+	// failed instead of aborting the run.
+	lenientIngestion bool
+	// explainPrefix turns a catalog query into its EXPLAIN statement; empty when
+	// the backend cannot explain queries.
+	explainPrefix string
+	// schemaVariants are the alternative table layouts selectable with
+	// -schema-variant; the first one is the default.
+	schemaVariants []schemaVariant
+	// ingestMethods are the alternative write paths selectable with
+	// -ingest-method, for backends that implement ingestMethodSetter; the
+	// first one is the default.
+	ingestMethods []string
+	// tiered is set for backends that implement tieredBackend.
+	tiered bool
+	// retention, export and fidelity are set for backends that implement
+	// retentionBackend, exportingBackend and readingLookup.
+	retention bool
+	export    bool
+	fidelity  bool
+	// schemaProbe is a cheap statement that fails when the table the readings
+	// are written to, or one of its columns, is missing; it checks a database
+	// provisioned outside the tool under -no-create.
+	schemaProbe string
+	// serverTiming is set for backends that implement serverTimer.
+	serverTiming  bool
+	container     containerSpec
+	timeToInsight timeToInsightDialect
+	// compression enables native columnar compression of the ingested data;
+	// empty when the engine has none.
+	compression []string
+	// maintenance are the vacuum, merge and compaction operations that
+	// -maintenance times after the query catalog; empty when the engine has
+	// none to trigger.
+	maintenance []maintenanceOp
+	// continuousAggregates are incrementally maintained rollups that replace
+	// the hourly and daily catalog queries.
+	continuousAggregates rollupDialect
+	// downsampling builds an hourly rollup of the readings per SSID and
+	// answers catalog queries from it.
+	downsampling rollupDialect
+	// hourOfDayQuery counts the readings per UTC hour of the day for
+	// -verify-hours; empty when the engine has no hour-of-day function.
+	hourOfDayQuery string
+	// reconciliation counts the rows stored by the ingestion; it runs after
+	// the queries so the count does not warm the caches they are timed on.
+	reconciliation reconciliationDialect
+	// dedup is the deduplicating schema of the duplicates scenario.
+	dedup dedupDialect
+	// joins are the dimension tables and join queries of -joins.
+	joins joinDialect
+	// buildings is the one-table-per-building layout of the per-building
+	// scenario.
+	buildings buildingDialect
+	// locations is the location-enriched layout of the locations scenario.
+	locations locationDialect
+	// extraColumns adds the columns of -extra-columns; the writers of the
+	// backend append their values to every row.
+	extraColumns extraColumnDialect
+	// durability sets the durability level of -durability and reads the
+	// effective settings back.
+	durability durabilityDialect
+	// serverSettings returns the memory, parallelism and commit settings of
+	// the server as name and value rows, recorded with every run; empty when
+	// the backend implements settingsReporter or has none to read.
+	serverSettings string
+	// cluster is how the backend runs on the nodes of a -topology.
+	cluster clusterDialect
+}
+
+var backends = map[string]backendInfo{}
+
+// loadHTTPSQLDialect is registered by the http-sql backend; nil when the binary
+// is built with the nohttpsql tag.
+var loadHTTPSQLDialect func(path string) error
+
+// loadSQLDialect is registered by the custom-sql backend; nil when the binary
+// is built with the nocustomsql tag.
+var loadSQLDialect func(dir string) error
+
+func registerBackend(info backendInfo) {
+	backends[info.name] = info
+}
+
+func backendNames() []string {
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func lookupBackend(dbType string) (backendInfo, error) {
+	info, ok := backends[dbType]
+	if !ok {
+		return backendInfo{}, fmt.Errorf("%w: %s", errUnsupportedDatabase, dbType)
+	}
+	return info, nil
+}
+
+func newBackend(dbType string, connStr string) (backend, error) {
+	info, err := lookupBackend(dbType)
+	if err != nil {
+		return nil, err
+	}
+	return info.open(connStr)
+}
+
+// queryBounds are the time bounds returned by query 1. The parameters of the
+// time range queries are derived from them.
+type queryBounds struct {
+	min    time.Time
+	max    time.Time
+	middle time.Time
+}
+
+func newQueryBounds(minTime time.Time, maxTime time.Time) queryBounds {
+	return queryBounds{min: minTime, max: maxTime, middle: minTime.Add(maxTime.Sub(minTime) / 2)}
+}
+
+// querySpec is a backend's implementation of one of the benchmark queries.
+// Queries a backend does not implement are left out and recorded as -1.
+type querySpec struct {
+	id   int
+	text string
+	args func(b queryBounds) []any
+}
+
+func (q querySpec) arguments(b queryBounds) []any {
+	if q.args == nil {
+		return nil
+	}
+	return q.args(b)
+}
+
+func (info backendInfo) lookupQuery(id int) (querySpec, bool) {
+	for _, q := range info.queries {
+		if q.id == id {
+			return q, true
+		}
+	}
+	return querySpec{}, false
+}
+
+var queryDescriptions = []string{
+	1:  "Get time bounds",
+	2:  "Count all records",
+	3:  "Count distinct users",
+	4:  "Average RSSI",
+	5:  "Records before middle time",
+	6:  "Records after middle time",
+	7:  "Records around middle time (±1 hour)",
+	8:  "24 hours aggregation from middle time",
+	9:  "Top 10 users by activity",
+	10: "Records with strong signal",
+	11: "Records with weak signal",
+	12: "Top SSIDs",
+	13: "RSSI statistics by user",
+	14: "RSSI percentiles",
+	15: "Records in first half",
+	16: "Records in second half",
+	17: "Hourly user activity patterns",
+	18: "Daily RSSI variance",
+	19: "Peak usage hours",
+	20: "User sessions split on 30-minute gaps",
+	21: "15-minute moving average of RSSI per user",
+	22: "Gap-filled hourly counts per SSID",
+	23: "Hourly average RSSI per SSID with last value carried forward",
+	24: "Occupancy: distinct users per SSID per 15 minutes",
+	25: "Occupancy: approximate distinct users per SSID per 15 minutes",
+}
+
+// queryCatalogVersion is recorded in the results and increases whenever a
+// catalog query starts computing something else, so that analyses do not
+// compare a query with a different one of the same name.
+const queryCatalogVersion = 1
+
+// Categories of the catalog queries, to group them in analyses.
+const (
+	categoryAggregation   = "aggregation"
+	categoryTimeRange     = "time-range"
+	categoryTopK          = "top-k"
+	categoryFilter        = "filter"
+	categoryDistinct      = "distinct"
+	categoryPercentile    = "percentile"
+	categoryTimeBucket    = "time-bucket"
+	categoryWindow        = "window"
+	categoryGapFill       = "gap-fill"
+	categorySessionize    = "sessionization"
+	categoryApproximation = "approximate"
+)
+
+// queryMeta identifies a catalog query in the results: its name is stable
+// across versions of the catalog and, unlike the number, says what the query
+// computes.
+type queryMeta struct {
+	name       string
+	categories []string
+}
+
+var queryMetadata = []queryMeta{
+	1:  {"time_bounds", []string{categoryAggregation}},
+	2:  {"count_all", []string{categoryAggregation}},
+	3:  {"count_distinct_users", []string{categoryAggregation, categoryDistinct}},
+	4:  {"avg_rssi", []string{categoryAggregation}},
+	5:  {"count_before_middle", []string{categoryAggregation, categoryTimeRange}},
+	6:  {"count_after_middle", []string{categoryAggregation, categoryTimeRange}},
+	7:  {"count_around_middle", []string{categoryAggregation, categoryTimeRange}},
+	8:  {"hourly_counts_24h", []string{categoryTimeRange, categoryTimeBucket}},
+	9:  {"top_users", []string{categoryAggregation, categoryTopK}},
+	10: {"count_strong_signal", []string{categoryAggregation, categoryFilter}},
+	11: {"count_weak_signal", []string{categoryAggregation, categoryFilter}},
+	12: {"top_ssids", []string{categoryAggregation, categoryTopK}},
+	13: {"rssi_stats_by_user", []string{categoryAggregation, categoryTopK}},
+	14: {"rssi_percentiles", []string{categoryAggregation, categoryPercentile}},
+	15: {"count_first_half", []string{categoryAggregation, categoryTimeRange}},
+	16: {"count_second_half", []string{categoryAggregation, categoryTimeRange}},
+	17: {"counts_by_hour_of_day", []string{categoryAggregation, categoryTimeBucket}},
+	18: {"daily_rssi_variance", []string{categoryAggregation, categoryTimeBucket}},
+	19: {"peak_hours", []string{categoryTimeBucket, categoryTopK}},
+	20: {"user_sessions", []string{categoryWindow, categorySessionize}},
+	21: {"moving_avg_rssi", []string{categoryTimeRange, categoryWindow}},
+	22: {"gap_filled_hourly_counts", []string{categoryTimeRange, categoryTimeBucket, categoryGapFill}},
+	23: {"hourly_rssi_locf", []string{categoryTimeRange, categoryTimeBucket, categoryGapFill}},
+	24: {"occupancy", []string{categoryTimeRange, categoryTimeBucket, categoryDistinct}},
+	25: {"occupancy_approx", []string{categoryTimeRange, categoryTimeBucket, categoryDistinct, categoryApproximation}},
+}
+
+// queryIdByName returns the number of the catalog query with the name.
+func queryIdByName(name string) (int, bool) {
+	for id, meta := range queryMetadata {
+		if id > 0 && meta.name == name {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// catalogQueryResult is the result of catalog query id with its metadata,
+// before the query is timed.
+func catalogQueryResult(id int) QueryResult {
+	return QueryResult{
+		QueryId:     id,
+		Query:       queryMetadata[id].name,
+		Categories:  queryMetadata[id].categories,
+		Description: queryDescriptions[id],
+	}
+}
+
+func atMiddle(b queryBounds) []any {
+	return []any{b.middle}
+}
+
+func aroundMiddle(b queryBounds) []any {
+	return []any{b.middle.Add(-time.Hour), b.middle.Add(time.Hour)}
+}
+
+func dayFromMiddle(b queryBounds) []any {
+	return []any{b.middle, b.middle.Add(24 * time.Hour)}
+}
+
+func firstHalf(b queryBounds) []any {
+	return []any{b.min, b.middle}
+}
+
+func secondHalf(b queryBounds) []any {
+	return []any{b.middle, b.max}
+}
+
+func wholeRange(b queryBounds) []any {
+	return []any{b.min, b.max}
+}
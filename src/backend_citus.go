@@ -0,0 +1,101 @@
+//go:build !nocitus
+
+package main
+
+import "fmt"
+
+func init() {
+	registerBackend(backendInfo{
+		name:        "citus",
+		description: "PostgreSQL with Citus, user_events distributed over shards (pgx, COPY ingestion)",
+		open: func(connStr string) (backend, error) {
+			return newPostgresBackend(connStr, citusSchema("user_id"))
+		},
+		ping:        pingPostgres,
+		isTransient: isTransientPostgres,
+		queries: []querySpec{
+			{id: 1, text: "SELECT MIN(timestamp), MAX(timestamp) FROM user_events"},
+			{id: 2, text: "SELECT COUNT(*) FROM user_events"},
+			{id: 3, text: "SELECT COUNT(DISTINCT user_id) FROM user_events"},
+			{id: 4, text: "SELECT AVG(rssi) FROM user_events"},
+			{id: 5, text: "SELECT COUNT(*) FROM user_events WHERE timestamp < $1", args: atMiddle},
+			{id: 6, text: "SELECT COUNT(*) FROM user_events WHERE timestamp > $1", args: atMiddle},
+			{id: 7, text: "SELECT COUNT(*) FROM user_events WHERE timestamp BETWEEN $1 AND $2", args: aroundMiddle},
+			{id: 9, text: "SELECT user_id, COUNT(*) as count FROM user_events GROUP BY user_id ORDER BY count DESC LIMIT 10"},
+			{id: 10, text: "SELECT COUNT(*) FROM user_events WHERE rssi > -50"},
+			{id: 11, text: "SELECT COUNT(*) FROM user_events WHERE rssi < -80"},
+			{id: 12, text: "SELECT ssid, COUNT(*) as count FROM user_events GROUP BY ssid ORDER BY count DESC LIMIT 10"},
+			{id: 13, text: "SELECT user_id, AVG(rssi), MIN(rssi), MAX(rssi) FROM user_events GROUP BY user_id ORDER BY AVG(rssi) DESC LIMIT 100"},
+			{id: 15, text: "SELECT COUNT(*) FROM user_events WHERE timestamp BETWEEN $1 AND $2", args: firstHalf},
+			{id: 16, text: "SELECT COUNT(*) FROM user_events WHERE timestamp BETWEEN $1 AND $2", args: secondHalf},
+			{id: 21, text: "SELECT AVG(moving_rssi) FROM (SELECT AVG(rssi) OVER (PARTITION BY user_id ORDER BY timestamp RANGE BETWEEN INTERVAL '15 minutes' PRECEDING AND CURRENT ROW) AS moving_rssi FROM user_events WHERE timestamp BETWEEN $1 AND $2) w", args: dayFromMiddle},
+			{id: 22, text: "SELECT h.hour, s.ssid, COUNT(e.rssi) FROM generate_series(date_trunc('hour', $1::timestamptz), $2::timestamptz - INTERVAL '1 hour', INTERVAL '1 hour') AS h(hour) CROSS JOIN (SELECT DISTINCT ssid FROM user_events WHERE timestamp >= $1 AND timestamp < $2) s LEFT JOIN user_events e ON e.ssid = s.ssid AND e.timestamp >= h.hour AND e.timestamp < h.hour + INTERVAL '1 hour' GROUP BY h.hour, s.ssid ORDER BY h.hour, s.ssid", args: dayFromMiddle},
+			{id: 24, text: "SELECT date_bin('15 minutes', timestamp, TIMESTAMPTZ '2000-01-01') AS bucket, ssid, COUNT(DISTINCT user_id) FROM user_events WHERE timestamp >= $1 AND timestamp < $2 GROUP BY bucket, ssid ORDER BY bucket, ssid", args: dayFromMiddle},
+		},
+		capabilities: &Capabilities{
+			Percentiles:     true,
+			WindowFunctions: true,
+			GapFill:         false,
+			ApproxDistinct:  false,
+			Updates:         true,
+			Deletes:         true,
+			Note:            "the percentile, hour-of-day and sessionization queries are not ported to Citus yet",
+		},
+		bucketQuery: func(w bucketWidth) string {
+			return fmt.Sprintf("SELECT date_bin('%s', timestamp, TIMESTAMPTZ '2000-01-01') AS bucket, ssid, COUNT(DISTINCT user_id) FROM user_events GROUP BY bucket, ssid ORDER BY bucket", w.interval)
+		},
+		// Citus pushes a window function down to the shards only when it is
+		// partitioned by the distribution column, so query 21 fails when the
+		// table is distributed by timestamp.
+		lenientQueries: true,
+		explainPrefix:  "EXPLAIN (ANALYZE, BUFFERS) ",
+		serverTiming:   true,
+		schemaProbe:    "SELECT user_id, timestamp, rssi, ssid FROM user_events LIMIT 1",
+		schemaVariants: []schemaVariant{
+			{name: "by-user", ddl: citusSchema("user_id")},
+			{name: "by-timestamp", ddl: citusSchema("timestamp")},
+		},
+		hourOfDayQuery: "SELECT EXTRACT(hour FROM timestamp AT TIME ZONE 'UTC')::int AS hour, COUNT(*) FROM user_events GROUP BY hour",
+		reconciliation: reconciliationDialect{
+			countQuery: "SELECT COUNT(*) FROM user_events",
+			duplicates: "every point is stored as its own row, identical ones included",
+		},
+		extraColumns: sqlExtraColumns,
+		// synchronous_commit of the coordinator does not reach the workers'
+		// own transactions, so the settings are only recorded.
+		durability: durabilityDialect{show: pgDurability.show},
+		// VACUUM and ANALYZE of a distributed table run on every shard.
+		maintenance: []maintenanceOp{
+			{name: "vacuum-analyze", statements: []string{"VACUUM ANALYZE user_events"}},
+		},
+		serverSettings: pgSettings("citus.shard_count", "citus.shard_replication_factor", "citus.max_adaptive_executor_pool_size"),
+		retention:      true,
+		export:         true,
+		fidelity:       true,
+		container: containerSpec{
+			image: "citusdata/citus:12.1",
+			ports: []string{"5435:5432"},
+			env:   []string{"POSTGRES_PASSWORD=example", "POSTGRES_USER=postgres", "POSTGRES_DB=cdb"},
+			// Replaces the image's setting, which only preloads citus.
+			timingArgs: []string{"-c", "shared_preload_libraries=citus,pg_stat_statements"},
+		},
+	})
+}
+
+// citusSchema creates the PostgreSQL table and hash-distributes it over the
+// shards by the given column. By user_id, the queries that group by user run
+// on each shard independently; by timestamp, the readings of a time range are
+// spread over every shard.
+func citusSchema(column string) string {
+	return `
+		CREATE EXTENSION IF NOT EXISTS citus;
+		CREATE TABLE user_events (
+			id BIGSERIAL,
+			user_id VARCHAR(255) NOT NULL,
+			timestamp TIMESTAMP WITH TIME ZONE NOT NULL,
+			rssi REAL NOT NULL,
+			ssid VARCHAR(255) NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_user_events_timestamp ON user_events (timestamp);
+		SELECT create_distributed_table('user_events', '` + column + `');`
+}
@@ -0,0 +1,610 @@
+//go:build !noclickhouse
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+func init() {
+	registerBackend(backendInfo{
+		name:        "clickhouse",
+		description: "ClickHouse MergeTree (clickhouse-go, tiered storage)",
+		open: func(connStr string) (backend, error) {
+			return newClickHouseBackend(connStr)
+		},
+		ping: func(ctx context.Context, connStr string) error {
+			opts, err := clickHouseOptions(connStr)
+			if err != nil {
+				return err
+			}
+			conn := clickhouse.OpenDB(opts)
+			defer conn.Close()
+			return conn.PingContext(ctx)
+		},
+		queries: []querySpec{
+			{id: 1, text: "SELECT MIN(timestamp), MAX(timestamp) FROM user_events"},
+			{id: 2, text: "SELECT COUNT(*) FROM user_events"},
+			{id: 3, text: "SELECT COUNT(DISTINCT user_id) FROM user_events"},
+			{id: 4, text: "SELECT AVG(rssi) FROM user_events"},
+			{id: 5, text: "SELECT COUNT(*) FROM user_events WHERE timestamp < ?", args: atMiddle},
+			{id: 6, text: "SELECT COUNT(*) FROM user_events WHERE timestamp > ?", args: atMiddle},
+			{id: 7, text: "SELECT COUNT(*) FROM user_events WHERE timestamp BETWEEN ? AND ?", args: aroundMiddle},
+			{id: 8, text: "SELECT toStartOfHour(timestamp) as hour, COUNT(*) FROM user_events WHERE timestamp BETWEEN ? AND ? GROUP BY hour ORDER BY hour", args: dayFromMiddle},
+			{id: 9, text: "SELECT user_id, COUNT(*) as count FROM user_events GROUP BY user_id ORDER BY count DESC LIMIT 10"},
+			{id: 10, text: "SELECT COUNT(*) FROM user_events WHERE rssi > -50"},
+			{id: 11, text: "SELECT COUNT(*) FROM user_events WHERE rssi < -80"},
+			{id: 12, text: "SELECT ssid, COUNT(*) as count FROM user_events GROUP BY ssid ORDER BY count DESC LIMIT 10"},
+			{id: 13, text: "SELECT user_id, AVG(rssi), MIN(rssi), MAX(rssi) FROM user_events GROUP BY user_id ORDER BY AVG(rssi) DESC LIMIT 100"},
+			{id: 14, text: "SELECT quantile(0.25)(rssi) as q1, quantile(0.5)(rssi) as median, quantile(0.75)(rssi) as q3 FROM user_events"},
+			{id: 15, text: "SELECT COUNT(*) FROM user_events WHERE timestamp BETWEEN ? AND ?", args: firstHalf},
+			{id: 16, text: "SELECT COUNT(*) FROM user_events WHERE timestamp BETWEEN ? AND ?", args: secondHalf},
+			{id: 17, text: "SELECT toHour(timestamp) as hour, COUNT(*) as count FROM user_events GROUP BY hour ORDER BY hour"},
+			{id: 18, text: "SELECT toStartOfDay(timestamp) as day, varSamp(rssi) as rssi_variance FROM user_events GROUP BY day ORDER BY day LIMIT 30"},
+			{id: 19, text: "SELECT toStartOfHour(timestamp) as hour, COUNT(*) as count FROM user_events GROUP BY hour ORDER BY count DESC LIMIT 5"},
+			{id: 20, text: "SELECT user_id, count() AS sessions, sum(session_end - session_start) AS session_duration FROM (SELECT user_id, min(timestamp) AS session_start, max(timestamp) AS session_end FROM (SELECT user_id, timestamp, sum(new_session) OVER (PARTITION BY user_id ORDER BY timestamp ROWS UNBOUNDED PRECEDING) AS session_id FROM (SELECT user_id, timestamp, timestamp - lagInFrame(timestamp) OVER (PARTITION BY user_id ORDER BY timestamp ROWS BETWEEN 1 PRECEDING AND CURRENT ROW) > 1800 AS new_session FROM user_events)) GROUP BY user_id, session_id) GROUP BY user_id ORDER BY session_duration DESC LIMIT 10"},
+			{id: 21, text: "SELECT avg(moving_rssi) FROM (SELECT avg(rssi) OVER (PARTITION BY user_id ORDER BY timestamp RANGE BETWEEN 900 PRECEDING AND CURRENT ROW) AS moving_rssi FROM user_events WHERE timestamp BETWEEN ? AND ?)", args: dayFromMiddle},
+			{id: 22, text: "SELECT toStartOfHour(timestamp) AS hour, ssid, count() AS count FROM user_events WHERE timestamp >= ? AND timestamp < ? GROUP BY ssid, hour ORDER BY ssid, hour WITH FILL STEP toIntervalHour(1)", args: dayFromMiddle},
+			{id: 23, text: "SELECT toStartOfHour(timestamp) AS hour, ssid, avg(rssi) AS rssi FROM user_events WHERE timestamp >= ? AND timestamp < ? GROUP BY ssid, hour ORDER BY ssid, hour WITH FILL STEP toIntervalHour(1) INTERPOLATE (rssi AS rssi)", args: dayFromMiddle},
+			{id: 24, text: "SELECT toStartOfFifteenMinutes(timestamp) AS bucket, ssid, uniqExact(user_id) FROM user_events WHERE timestamp >= ? AND timestamp < ? GROUP BY bucket, ssid ORDER BY bucket, ssid", args: dayFromMiddle},
+			{id: 25, text: "SELECT toStartOfFifteenMinutes(timestamp) AS bucket, ssid, uniq(user_id) FROM user_events WHERE timestamp >= ? AND timestamp < ? GROUP BY bucket, ssid ORDER BY bucket, ssid", args: dayFromMiddle},
+		},
+		capabilities: &Capabilities{
+			Percentiles:     true,
+			WindowFunctions: true,
+			GapFill:         true,
+			ApproxDistinct:  true,
+			Updates:         true,
+			Deletes:         true,
+			Note:            "updates and deletes are asynchronous mutations",
+		},
+		bucketQuery: func(w bucketWidth) string {
+			return fmt.Sprintf("SELECT toStartOfInterval(timestamp, INTERVAL %s) AS bucket, ssid, uniqExact(user_id) FROM user_events GROUP BY bucket, ssid ORDER BY bucket", w.interval)
+		},
+		// ClickHouse has no EXPLAIN ANALYZE; the index analysis shows how many
+		// parts and granules a query has to read.
+		explainPrefix: "EXPLAIN indexes = 1 ",
+		schemaVariants: []schemaVariant{
+			{name: "timestamp", ddl: clickHouseSchema(clickHouseColumns, "", "timestamp")},
+			{name: "user-timestamp", ddl: clickHouseSchema(clickHouseColumns, "", "(user_id, timestamp)")},
+			{name: "ssid-timestamp", ddl: clickHouseSchema(clickHouseColumns, "", "(ssid, timestamp)")},
+			{name: "daily-partitions", ddl: clickHouseSchema(clickHouseColumns, "PARTITION BY toDate(timestamp)", "timestamp")},
+			{name: "low-cardinality", ddl: clickHouseSchema(clickHouseLowCardinalityColumns, "", "timestamp")},
+			{name: "codecs", ddl: clickHouseSchema(clickHouseCodecColumns, "", "timestamp")},
+			{name: "recommended", ddl: clickHouseSchema(clickHouseRecommendedColumns, "PARTITION BY toDate(timestamp)", "(ssid, timestamp)")},
+		},
+		dedup: dedupDialect{
+			schema: `
+		CREATE TABLE IF NOT EXISTS user_events (` + clickHouseColumns + `
+		) ENGINE = ReplacingMergeTree()
+		ORDER BY (user_id, timestamp)`,
+			// Replacing merges run in the background; OPTIMIZE FINAL forces
+			// them and FINAL deduplicates whatever is left at read time.
+			finalize:   []string{"OPTIMIZE TABLE user_events FINAL"},
+			countQuery: "SELECT count() FROM user_events FINAL",
+		},
+		joins: joinDialect{
+			schema: []string{
+				"CREATE TABLE IF NOT EXISTS users (user_id String, department String, role String) ENGINE = MergeTree ORDER BY user_id",
+				"CREATE TABLE IF NOT EXISTS access_points (ssid String, building String, floor_number UInt8) ENGINE = MergeTree ORDER BY ssid",
+			},
+			backslashEscapes: true,
+			queries: []querySpec{
+				{id: 1, text: "SELECT a.building, count() AS count FROM user_events e JOIN access_points a ON a.ssid = e.ssid GROUP BY a.building ORDER BY count DESC"},
+				{id: 2, text: "SELECT toStartOfHour(e.timestamp) AS hour, a.building, uniqExact(e.user_id) FROM user_events e JOIN access_points a ON a.ssid = e.ssid WHERE e.timestamp >= ? AND e.timestamp < ? GROUP BY hour, a.building ORDER BY hour, a.building", args: dayFromMiddle},
+				{id: 3, text: "SELECT u.department, a.building, avg(e.rssi) FROM user_events e JOIN users u ON u.user_id = e.user_id JOIN access_points a ON a.ssid = e.ssid GROUP BY u.department, a.building ORDER BY u.department, a.building"},
+			},
+		},
+		// The materialized view keeps the SummingMergeTree rollup up to date
+		// for new inserts; the existing rows are backfilled by hand, as
+		// POPULATE cannot be combined with TO.
+		downsampling: rollupDialect{
+			create: []string{
+				"CREATE TABLE user_events_hourly (hour DateTime('UTC'), ssid String, readings UInt64, rssi_sum Float64) ENGINE = SummingMergeTree ORDER BY (hour, ssid)",
+				"CREATE MATERIALIZED VIEW user_events_hourly_mv TO user_events_hourly AS SELECT toStartOfHour(timestamp) AS hour, ssid, count() AS readings, sum(rssi) AS rssi_sum FROM user_events GROUP BY hour, ssid",
+				"INSERT INTO user_events_hourly SELECT toStartOfHour(timestamp) AS hour, ssid, count() AS readings, sum(rssi) AS rssi_sum FROM user_events GROUP BY hour, ssid",
+			},
+			queries: []querySpec{
+				{id: 2, text: "SELECT sum(readings) FROM user_events_hourly"},
+				{id: 4, text: "SELECT sum(rssi_sum) / sum(readings) FROM user_events_hourly"},
+				{id: 8, text: "SELECT hour, sum(readings) FROM user_events_hourly WHERE hour BETWEEN ? AND ? GROUP BY hour ORDER BY hour", args: dayFromMiddle},
+				{id: 12, text: "SELECT ssid, sum(readings) AS count FROM user_events_hourly GROUP BY ssid ORDER BY count DESC LIMIT 10"},
+				{id: 17, text: "SELECT toHour(hour) AS hour_of_day, sum(readings) AS count FROM user_events_hourly GROUP BY hour_of_day ORDER BY hour_of_day"},
+				{id: 19, text: "SELECT hour, sum(readings) AS count FROM user_events_hourly GROUP BY hour ORDER BY count DESC LIMIT 5"},
+			},
+		},
+		buildings: buildingDialect{
+			table: func(name string) []string {
+				return []string{"CREATE TABLE " + name + " (" + clickHouseColumns + ") ENGINE = MergeTree() ORDER BY timestamp"}
+			},
+			queries: func(tables []string) []querySpec {
+				return sqlBuildingQueries(tables, "toStartOfHour(timestamp)", "timestamp >= ? AND timestamp < ?", dayFromMiddle)
+			},
+		},
+		locations: locationDialect{
+			schema: []string{
+				"CREATE TABLE " + locatedTable + " (" + clickHouseColumns + `,
+			building LowCardinality(String),
+			floor_number Int16,
+			latitude Nullable(Float64),
+			longitude Nullable(Float64)) ENGINE = MergeTree() ORDER BY (building, timestamp)`,
+			},
+			queries: sqlLocationQueries("toStartOfHour(timestamp)", "timestamp >= ? AND timestamp < ?", "uniqExact(user_id)", dayFromMiddle),
+		},
+		extraColumns: extraColumnDialect{
+			addColumn: "ALTER TABLE user_events ADD COLUMN %s %s",
+			types:     map[string]string{extraText: "Nullable(String)", extraInteger: "Nullable(Int64)", extraReal: "Nullable(Float64)"},
+			groupBy:   sqlGroupByExtra("uniqExact(user_id)"),
+		},
+		tiered:         true,
+		serverTiming:   true,
+		schemaProbe:    "SELECT id, user_id, timestamp, rssi, ssid FROM user_events LIMIT 1",
+		hourOfDayQuery: "SELECT toHour(timestamp, 'UTC') AS hour, count() FROM user_events GROUP BY hour",
+		reconciliation: reconciliationDialect{
+			countQuery: "SELECT count() FROM user_events",
+			duplicates: "MergeTree stores every point as its own row, identical ones included",
+		},
+		cluster: clusterDialect{
+			note: "user_events is a Distributed table over a MergeTree user_events_local on every shard; the readings are written to the local tables of the nodes in turn and queried through the Distributed table",
+			schema: func(cluster string) []string {
+				return []string{
+					`CREATE TABLE IF NOT EXISTS user_events_local ON CLUSTER '` + cluster + `' (` + clickHouseColumns + `
+					) ENGINE = MergeTree()
+					ORDER BY timestamp`,
+					`CREATE TABLE IF NOT EXISTS user_events ON CLUSTER '` + cluster + `' AS user_events_local
+					ENGINE = Distributed('` + cluster + `', currentDatabase(), user_events_local, rand())`,
+				}
+			},
+			writeTable: "user_events_local",
+		},
+		// Relaxed acknowledges inserts once they are buffered server-side;
+		// strict waits for the quorum of replicas and fsyncs every part.
+		durability: durabilityDialect{
+			params: map[string]map[string]string{
+				durabilityRelaxed: {"async_insert": "1", "wait_for_async_insert": "0"},
+				durabilityStrict:  {"async_insert": "0", "insert_quorum": "auto", "insert_quorum_parallel": "0"},
+			},
+			statements: map[string][]string{
+				durabilityStrict: {"ALTER TABLE user_events MODIFY SETTING fsync_after_insert = 1, fsync_part_directory = 1"},
+			},
+			show: "SELECT name, value FROM system.settings WHERE name IN ('async_insert', 'wait_for_async_insert', 'insert_quorum', 'insert_quorum_parallel', 'fsync_metadata')",
+		},
+		// Parts are merged in the background; FINAL merges them all into one
+		// per partition before returning.
+		maintenance: []maintenanceOp{
+			{name: "optimize-final", statements: []string{"OPTIMIZE TABLE user_events FINAL"}},
+		},
+		// The query settings are the defaults of the connection's profile,
+		// the caches and pools settings of the server.
+		serverSettings: "SELECT name, value FROM system.settings WHERE name IN ('max_threads', 'max_insert_threads', 'max_memory_usage', 'max_block_size', 'max_insert_block_size', 'use_uncompressed_cache') " +
+			"UNION ALL SELECT name, value FROM system.server_settings WHERE name IN ('max_server_memory_usage', 'mark_cache_size', 'uncompressed_cache_size', 'background_pool_size', 'background_merges_mutations_concurrency_ratio')",
+		retention: true,
+		export:    true,
+		fidelity:  true,
+		container: containerSpec{
+			image: "clickhouse/clickhouse-server:24.8",
+			ports: []string{"8123:8123", "9001:9000"},
+			env:   []string{"CLICKHOUSE_DB=default", "CLICKHOUSE_USER=default", "CLICKHOUSE_DEFAULT_ACCESS_MANAGEMENT=1"},
+			extra: []string{"--ulimit", "nofile=262144:262144"},
+		},
+		timeToInsight: timeToInsightDialect{
+			rollup: []string{
+				"CREATE TABLE user_events_hourly ENGINE = MergeTree ORDER BY (hour, ssid) AS SELECT toStartOfHour(timestamp) AS hour, ssid, count() AS readings, uniqExact(user_id) AS users, avg(rssi) AS avg_rssi FROM user_events GROUP BY hour, ssid",
+			},
+			dashboard: sqlDashboardBundle,
+		},
+	})
+}
+
+// Column definitions of the ClickHouse schema variants. The plain columns are
+// the historical schema; the others apply the recommended practice of
+// dictionary-encoding the low-cardinality strings and delta-encoding the
+// monotonic columns before ZSTD.
+const (
+	clickHouseColumns = `
+			id UInt64,
+			user_id String,
+			timestamp DateTime('UTC'),
+			rssi Float32,
+			ssid String`
+	clickHouseLowCardinalityColumns = `
+			id UInt64,
+			user_id LowCardinality(String),
+			timestamp DateTime('UTC'),
+			rssi Float32,
+			ssid LowCardinality(String)`
+	clickHouseCodecColumns = `
+			id UInt64 CODEC(Delta, ZSTD),
+			user_id String CODEC(ZSTD),
+			timestamp DateTime('UTC') CODEC(Delta, ZSTD),
+			rssi Float32 CODEC(ZSTD),
+			ssid String CODEC(ZSTD)`
+	clickHouseRecommendedColumns = `
+			id UInt64 CODEC(Delta, ZSTD),
+			user_id LowCardinality(String),
+			timestamp DateTime('UTC') CODEC(Delta, ZSTD),
+			rssi Float32 CODEC(ZSTD),
+			ssid LowCardinality(String)`
+)
+
+// clickHouseSchema builds the MergeTree DDL for a column set, partitioning and
+// sort key.
+func clickHouseSchema(columns string, partitionBy string, orderBy string) string {
+	return `
+		CREATE TABLE IF NOT EXISTS user_events (` + columns + `
+		) ENGINE = MergeTree()
+		` + partitionBy + `
+		ORDER BY ` + orderBy
+}
+
+type clickHouseBackend struct {
+	conn     *sql.DB
+	schema   string
+	nRecords int
+	// maxIdle is the number of idle connections database/sql keeps open.
+	maxIdle int
+	// timingSince is the server time of the last markServerTime.
+	timingSince time.Time
+}
+
+// clickHouseOptions connects to the default database as the default user of
+// the container unless the credential flags are given.
+func clickHouseOptions(connStr string) (*clickhouse.Options, error) {
+	opts := &clickhouse.Options{
+		Addr: []string{connStr},
+		Auth: clickhouse.Auth{
+			Database: "default",
+			Username: "default",
+			Password: dbCredentials.Password,
+		},
+	}
+	if dbCredentials.User != "" {
+		opts.Auth.Username = dbCredentials.User
+	}
+	host, _, err := net.SplitHostPort(connStr)
+	if err != nil {
+		host = connStr
+	}
+	tlsConfig, err := tlsSettings.config(host)
+	if err != nil {
+		return nil, err
+	}
+	opts.TLS = tlsConfig
+	if len(durabilityParams) > 0 {
+		opts.Settings = clickhouse.Settings{}
+		for name, value := range durabilityParams {
+			opts.Settings[name] = value
+		}
+	}
+	return opts, nil
+}
+
+func newClickHouseBackend(connStr string) (*clickHouseBackend, error) {
+	opts, err := clickHouseOptions(connStr)
+	if err != nil {
+		return nil, err
+	}
+	conn := clickhouse.OpenDB(opts)
+
+	// database/sql keeps 2 idle connections and opens any number by default.
+	maxIdle := 2
+	if poolTuning.MaxConns > 0 {
+		conn.SetMaxOpenConns(poolTuning.MaxConns)
+	}
+	if poolTuning.MinConns > 0 {
+		maxIdle = poolTuning.MinConns
+		conn.SetMaxIdleConns(maxIdle)
+	}
+
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &clickHouseBackend{conn: conn, schema: clickHouseSchema(clickHouseColumns, "", "timestamp"), maxIdle: maxIdle}, nil
+}
+
+func (b *clickHouseBackend) createSchema(ctx context.Context) error {
+	_, err := b.conn.ExecContext(ctx, b.schema)
+	return err
+}
+
+func (b *clickHouseBackend) setSchema(ddl string) {
+	b.schema = ddl
+}
+
+func (b *clickHouseBackend) ingest(ctx context.Context, readings []Reading) error {
+	return b.ingestInto(ctx, "user_events", readings)
+}
+
+func (b *clickHouseBackend) ingestInto(ctx context.Context, table string, readings []Reading) error {
+	tx, err := b.conn.Begin()
+	if err != nil {
+		return err
+	}
+	// Rolls back a batch that failed half way so it can be retried as a whole.
+	defer tx.Rollback()
+
+	columns, placeholders := extraInsertColumns(5, false)
+	stmt, err := tx.Prepare("INSERT INTO " + table + " (id, user_id, timestamp, rssi, ssid" + columns + ") VALUES (?, ?, ?, ?, ?" + placeholders + ")")
+	if err != nil {
+		return err
+	}
+
+	// One argument slice for the whole batch; Exec copies the values into the
+	// block it builds.
+	args := make([]any, 5+len(extraColumns))
+	for i := range readings {
+		reading := &readings[i]
+		args[0] = uint64(b.nRecords + i + 1)
+		args[1] = reading.UserId
+		args[2] = readingTime(reading.LastUpdatedTime)
+		args[3] = reading.Connection.Rssi
+		args[4] = reading.Connection.Ssid
+		copy(args[5:], extraValues(reading))
+		_, err = stmt.Exec(args...)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	b.nRecords += len(readings)
+	return nil
+}
+
+func (b *clickHouseBackend) ingestLocated(ctx context.Context, readings []Reading) error {
+	tx, err := b.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare("INSERT INTO " + locatedTable + " (id, user_id, timestamp, rssi, ssid, building, floor_number, latitude, longitude) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+
+	args := make([]any, 9)
+	for i := range readings {
+		reading := &readings[i]
+		args[0] = uint64(b.nRecords + i + 1)
+		args[1] = reading.UserId
+		args[2] = readingTime(reading.LastUpdatedTime)
+		args[3] = reading.Connection.Rssi
+		args[4] = reading.Connection.Ssid
+		building, floor, lat, lon := locationValues(reading)
+		args[5], args[6], args[7], args[8] = building, int16(floor), lat, lon
+		if _, err = stmt.Exec(args...); err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	b.nRecords += len(readings)
+	return nil
+}
+
+// expireBefore runs the delete as a synchronous mutation, so its duration covers
+// rewriting the affected parts.
+func (b *clickHouseBackend) expireBefore(ctx context.Context, cutoff time.Time) error {
+	_, err := b.conn.ExecContext(ctx, "ALTER TABLE user_events DELETE WHERE timestamp < ? SETTINGS mutations_sync = 2", cutoff)
+	return err
+}
+
+// export writes the table in the Native format to a file in the server's
+// user_files directory with the file table function.
+func (b *clickHouseBackend) export(ctx context.Context, w io.Writer) error {
+	_, err := b.conn.ExecContext(ctx, "INSERT INTO FUNCTION file('user_events_export.native', 'Native') SELECT * FROM user_events SETTINGS engine_file_truncate_on_insert = 1")
+	return err
+}
+
+func (b *clickHouseBackend) skipRowIds(n int) {
+	b.nRecords += n
+}
+
+func (b *clickHouseBackend) count(ctx context.Context, q string) (int64, error) {
+	var n uint64
+	err := b.conn.QueryRowContext(ctx, q).Scan(&n)
+	return int64(n), err
+}
+
+func (b *clickHouseBackend) serverVersion(ctx context.Context) (string, error) {
+	var version string
+	err := b.conn.QueryRowContext(ctx, "SELECT version()").Scan(&version)
+	return "ClickHouse " + version, err
+}
+
+func (b *clickHouseBackend) dropTable(ctx context.Context, table string) error {
+	return b.exec(ctx, "DROP TABLE IF EXISTS "+table)
+}
+
+func (b *clickHouseBackend) exec(ctx context.Context, stmt string) error {
+	_, err := b.conn.ExecContext(ctx, stmt)
+	return err
+}
+
+func (b *clickHouseBackend) query(ctx context.Context, q string, args ...any) error {
+	rows, err := b.conn.QueryContext(ctx, q, args...)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+	}
+	rows.Close()
+	return rows.Err()
+}
+
+func (b *clickHouseBackend) scanRows(ctx context.Context, visit func(values []any), q string, args ...any) error {
+	rows, err := b.conn.QueryContext(ctx, q, args...)
+	if err != nil {
+		return err
+	}
+	return scanSQLRows(rows, visit)
+}
+
+func (b *clickHouseBackend) timeBounds(ctx context.Context, q string) (time.Time, time.Time, error) {
+	var minTime, maxTime time.Time
+	err := b.conn.QueryRowContext(ctx, q).Scan(&minTime, &maxTime)
+	return minTime, maxTime, err
+}
+
+func (b *clickHouseBackend) plan(ctx context.Context, stmt string, args ...any) (string, error) {
+	rows, err := b.conn.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	var lines []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return "", err
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n"), rows.Err()
+}
+
+// poolSettings reports the idle connections kept by database/sql as MinConns;
+// the driver has no prepared statement cache.
+func (b *clickHouseBackend) poolSettings() PoolSettings {
+	return PoolSettings{MaxConns: b.conn.Stats().MaxOpenConnections, MinConns: b.maxIdle}
+}
+
+func (b *clickHouseBackend) close() {
+	b.conn.Close()
+}
+
+// moveToColdTier moves every partition whose newest row is older than the
+// cutoff to the given disk of the table's storage policy. An unpartitioned
+// table has a single partition, which is moved as a whole.
+func (b *clickHouseBackend) moveToColdTier(ctx context.Context, cutoff time.Time, target string) (int, error) {
+	rows, err := b.conn.QueryContext(ctx, `
+		SELECT DISTINCT partition_id FROM system.parts
+		WHERE database = currentDatabase() AND table = 'user_events' AND active AND max_time < ?`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	var partitions []string
+	for rows.Next() {
+		var partition string
+		if err := rows.Scan(&partition); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		partitions = append(partitions, partition)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	disk := strings.ReplaceAll(target, "'", "''")
+	for _, partition := range partitions {
+		stmt := fmt.Sprintf("ALTER TABLE user_events MOVE PARTITION ID '%s' TO DISK '%s'", partition, disk)
+		if _, err := b.conn.ExecContext(ctx, stmt); err != nil {
+			return 0, err
+		}
+	}
+	return len(partitions), nil
+}
+
+func (b *clickHouseBackend) lookupReading(ctx context.Context, userId string, at time.Time) ([]storedReading, error) {
+	rows, err := b.conn.QueryContext(ctx, "SELECT rssi, ssid FROM user_events WHERE user_id = ? AND timestamp = ?", userId, at)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stored []storedReading
+	for rows.Next() {
+		var rssi float32
+		var ssid string
+		if err := rows.Scan(&rssi, &ssid); err != nil {
+			return nil, err
+		}
+		stored = append(stored, storedReading{Rssi: float64(rssi), Ssid: ssid})
+	}
+	return stored, rows.Err()
+}
+
+func (b *clickHouseBackend) countByHour(ctx context.Context, q string) (map[int]int64, error) {
+	rows, err := b.conn.QueryContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[int]int64{}
+	for rows.Next() {
+		var hour uint8
+		var count uint64
+		if err := rows.Scan(&hour, &count); err != nil {
+			return nil, err
+		}
+		counts[int(hour)] = int64(count)
+	}
+	return counts, rows.Err()
+}
+
+type clickHouseProbeReader struct {
+	conn *sql.Conn
+}
+
+func (b *clickHouseBackend) newProbeReader(ctx context.Context) (probeReader, error) {
+	conn, err := b.conn.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &clickHouseProbeReader{conn: conn}, nil
+}
+
+func (r *clickHouseProbeReader) visible(ctx context.Context, userId string) (bool, error) {
+	var count uint64
+	err := r.conn.QueryRowContext(ctx, "SELECT count() FROM user_events WHERE user_id = ?", userId).Scan(&count)
+	return count > 0, err
+}
+
+func (r *clickHouseProbeReader) release() {
+	r.conn.Close()
+}
+
+// markServerTime starts a window of system.query_log.
+func (b *clickHouseBackend) markServerTime(ctx context.Context) error {
+	return b.conn.QueryRowContext(ctx, "SELECT now64(6)").Scan(&b.timingSince)
+}
+
+// serverTimeMs flushes the query log, which is otherwise written every few
+// seconds, and measures the finished SELECTs with microsecond precision.
+func (b *clickHouseBackend) serverTimeMs(ctx context.Context) (float64, int, error) {
+	if _, err := b.conn.ExecContext(ctx, "SYSTEM FLUSH LOGS"); err != nil {
+		return 0, 0, err
+	}
+	var mean float64
+	var statements uint64
+	err := b.conn.QueryRowContext(ctx, `SELECT avgOrDefault(dateDiff('microsecond', query_start_time_microseconds, event_time_microseconds)) / 1000, count()
+		FROM system.query_log
+		WHERE type = 'QueryFinish' AND is_initial_query AND query_kind = 'Select'
+		AND query_start_time_microseconds >= ?
+		AND query NOT ILIKE '%system.query_log%' AND query NOT ILIKE '%now64%'`, b.timingSince).Scan(&mean, &statements)
+	if err != nil {
+		return 0, 0, err
+	}
+	return mean, int(statements), nil
+}
@@ -0,0 +1,149 @@
+//go:build !nocockroachdb
+
+package main
+
+import "context"
+
+func init() {
+	registerBackend(backendInfo{
+		name:        "cockroachdb",
+		description: "CockroachDB (pgx, COPY or multi-row INSERT ingestion, hash-sharded index)",
+		open: func(connStr string) (backend, error) {
+			return newCockroachBackend(connStr)
+		},
+		ping:        pingPostgres,
+		isTransient: isTransientPostgres,
+		queries: []querySpec{
+			{id: 1, text: "SELECT MIN(timestamp), MAX(timestamp) FROM user_events"},
+			{id: 2, text: "SELECT COUNT(*) FROM user_events"},
+			{id: 3, text: "SELECT COUNT(DISTINCT user_id) FROM user_events"},
+			{id: 4, text: "SELECT AVG(rssi) FROM user_events"},
+			{id: 5, text: "SELECT COUNT(*) FROM user_events WHERE timestamp < $1", args: atMiddle},
+			{id: 6, text: "SELECT COUNT(*) FROM user_events WHERE timestamp > $1", args: atMiddle},
+			{id: 7, text: "SELECT COUNT(*) FROM user_events WHERE timestamp BETWEEN $1 AND $2", args: aroundMiddle},
+			{id: 8, text: "SELECT date_trunc('hour', timestamp) as hour, COUNT(*) FROM user_events WHERE timestamp BETWEEN $1 AND $2 GROUP BY hour ORDER BY hour", args: dayFromMiddle},
+			{id: 9, text: "SELECT user_id, COUNT(*) as count FROM user_events GROUP BY user_id ORDER BY count DESC LIMIT 10"},
+			{id: 10, text: "SELECT COUNT(*) FROM user_events WHERE rssi > -50"},
+			{id: 11, text: "SELECT COUNT(*) FROM user_events WHERE rssi < -80"},
+			{id: 12, text: "SELECT ssid, COUNT(*) as count FROM user_events GROUP BY ssid ORDER BY count DESC LIMIT 10"},
+			{id: 13, text: "SELECT user_id, AVG(rssi), MIN(rssi), MAX(rssi) FROM user_events GROUP BY user_id ORDER BY AVG(rssi) DESC LIMIT 100"},
+			{id: 14, text: "SELECT percentile_cont(0.25) WITHIN GROUP (ORDER BY rssi) as q1, percentile_cont(0.5) WITHIN GROUP (ORDER BY rssi) as median, percentile_cont(0.75) WITHIN GROUP (ORDER BY rssi) as q3 FROM user_events"},
+			{id: 15, text: "SELECT COUNT(*) FROM user_events WHERE timestamp BETWEEN $1 AND $2", args: firstHalf},
+			{id: 16, text: "SELECT COUNT(*) FROM user_events WHERE timestamp BETWEEN $1 AND $2", args: secondHalf},
+			{id: 17, text: "SELECT EXTRACT(hour FROM timestamp) as hour, COUNT(*) as count FROM user_events GROUP BY hour ORDER BY hour"},
+			{id: 18, text: "SELECT timestamp::DATE as day, VARIANCE(rssi) as rssi_variance FROM user_events GROUP BY day ORDER BY day LIMIT 30"},
+			{id: 19, text: "SELECT date_trunc('hour', timestamp) as hour, COUNT(*) as count FROM user_events GROUP BY hour ORDER BY count DESC LIMIT 5"},
+			{id: 20, text: "SELECT user_id, COUNT(*) AS sessions, SUM(session_end - session_start) AS session_duration FROM (SELECT user_id, MIN(timestamp) AS session_start, MAX(timestamp) AS session_end FROM (SELECT user_id, timestamp, SUM(new_session) OVER (PARTITION BY user_id ORDER BY timestamp) AS session_id FROM (SELECT user_id, timestamp, CASE WHEN timestamp - LAG(timestamp) OVER (PARTITION BY user_id ORDER BY timestamp) > INTERVAL '30 minutes' THEN 1 ELSE 0 END AS new_session FROM user_events) gaps) numbered GROUP BY user_id, session_id) sessions GROUP BY user_id ORDER BY session_duration DESC LIMIT 10"},
+			{id: 21, text: "SELECT AVG(moving_rssi) FROM (SELECT AVG(rssi) OVER (PARTITION BY user_id ORDER BY timestamp RANGE BETWEEN INTERVAL '15 minutes' PRECEDING AND CURRENT ROW) AS moving_rssi FROM user_events WHERE timestamp BETWEEN $1 AND $2) w", args: dayFromMiddle},
+			{id: 22, text: "SELECT h.hour, s.ssid, COUNT(e.rssi) FROM generate_series(date_trunc('hour', $1::timestamptz), $2::timestamptz - INTERVAL '1 hour', INTERVAL '1 hour') AS h(hour) CROSS JOIN (SELECT DISTINCT ssid FROM user_events WHERE timestamp >= $1 AND timestamp < $2) s LEFT JOIN user_events e ON e.ssid = s.ssid AND e.timestamp >= h.hour AND e.timestamp < h.hour + INTERVAL '1 hour' GROUP BY h.hour, s.ssid ORDER BY h.hour, s.ssid", args: dayFromMiddle},
+			// CockroachDB has no date_bin; the 15-minute buckets are computed
+			// from the epoch.
+			{id: 24, text: "SELECT to_timestamp(floor(extract(epoch FROM timestamp) / 900) * 900) AS bucket, ssid, COUNT(DISTINCT user_id) FROM user_events WHERE timestamp >= $1 AND timestamp < $2 GROUP BY bucket, ssid ORDER BY bucket, ssid", args: dayFromMiddle},
+		},
+		capabilities: &Capabilities{
+			Percentiles:     true,
+			WindowFunctions: true,
+			GapFill:         false,
+			ApproxDistinct:  false,
+			Updates:         true,
+			Deletes:         true,
+		},
+		explainPrefix: "EXPLAIN ANALYZE ",
+		schemaProbe:   "SELECT user_id, timestamp, rssi, ssid FROM user_events LIMIT 1",
+		schemaVariants: []schemaVariant{
+			{name: "hash-sharded", ddl: cockroachSchema},
+			{name: "btree", ddl: cockroachTable + " CREATE INDEX IF NOT EXISTS idx_user_events_timestamp ON user_events (timestamp);"},
+			{name: "noindex", ddl: cockroachTable},
+		},
+		ingestMethods:  []string{"copy", "insert"},
+		hourOfDayQuery: "SELECT EXTRACT(hour FROM timestamp AT TIME ZONE 'UTC')::INT AS hour, COUNT(*) FROM user_events GROUP BY hour",
+		reconciliation: reconciliationDialect{
+			countQuery: "SELECT COUNT(*) FROM user_events",
+			duplicates: "every point is stored as its own row, identical ones included",
+		},
+		extraColumns: sqlExtraColumns,
+		// Every write is replicated and synced through the raft log; relaxed
+		// turns the fsync of the log off, which is unsafe outside benchmarks.
+		durability: durabilityDialect{
+			statements: map[string][]string{
+				durabilityRelaxed: {"SET CLUSTER SETTING kv.raft_log.disable_synchronization_unsafe = true"},
+				durabilityStrict:  {"SET CLUSTER SETTING kv.raft_log.disable_synchronization_unsafe = false"},
+			},
+			show: "SELECT variable, value FROM [SHOW ALL CLUSTER SETTINGS] WHERE variable = 'kv.raft_log.disable_synchronization_unsafe'",
+		},
+		// Garbage collection and compactions run on their own; the statistics
+		// of the optimizer are the one thing to refresh.
+		maintenance: []maintenanceOp{
+			{name: "analyze", statements: []string{"ANALYZE user_events"}},
+		},
+		// The cache and SQL memory are flags of the node, not settings.
+		serverSettings: "SELECT variable, value FROM [SHOW ALL CLUSTER SETTINGS] WHERE variable IN ('admission.kv.enabled', 'kv.range_merge.queue_enabled', 'kv.snapshot_rebalance.max_rate', 'kv.transaction.max_intents_bytes', 'sql.defaults.vectorize', 'sql.distsql.temp_storage.workmem')",
+		retention:      true,
+		export:         true,
+		fidelity:       true,
+		container: containerSpec{
+			image: "cockroachdb/cockroach:v24.3.5",
+			ports: []string{"26257:26257", "8090:8080"},
+			args:  []string{"start-single-node", "--insecure"},
+		},
+		timeToInsight: timeToInsightDialect{
+			rollup: []string{
+				"CREATE TABLE user_events_hourly AS SELECT date_trunc('hour', timestamp) AS hour, ssid, COUNT(*) AS readings, COUNT(DISTINCT user_id) AS users, AVG(rssi) AS avg_rssi FROM user_events GROUP BY 1, 2",
+			},
+			dashboard: sqlDashboardBundle,
+		},
+		joins: joinDialect{
+			schema: sqlDimensionTables,
+			queries: []querySpec{
+				{id: 1, text: "SELECT a.building, COUNT(*) AS count FROM user_events e JOIN access_points a ON a.ssid = e.ssid GROUP BY a.building ORDER BY count DESC"},
+				{id: 2, text: "SELECT date_trunc('hour', e.timestamp) AS hour, a.building, COUNT(DISTINCT e.user_id) FROM user_events e JOIN access_points a ON a.ssid = e.ssid WHERE e.timestamp >= $1 AND e.timestamp < $2 GROUP BY hour, a.building ORDER BY hour, a.building", args: dayFromMiddle},
+				{id: 3, text: "SELECT u.department, a.building, AVG(e.rssi) FROM user_events e JOIN users u ON u.user_id = e.user_id JOIN access_points a ON a.ssid = e.ssid GROUP BY u.department, a.building ORDER BY u.department, a.building"},
+			},
+		},
+	})
+}
+
+const cockroachTable = `
+		CREATE TABLE user_events (
+			id BIGSERIAL,
+			user_id VARCHAR(255) NOT NULL,
+			timestamp TIMESTAMP WITH TIME ZONE NOT NULL,
+			rssi REAL NOT NULL,
+			ssid VARCHAR(255) NOT NULL
+		);`
+
+// cockroachSchema spreads the timestamp index over hash buckets: with a plain
+// index every insert of a time series lands on the range holding the newest
+// timestamps, which becomes the hot spot of the cluster.
+const cockroachSchema = cockroachTable + " CREATE INDEX IF NOT EXISTS idx_user_events_timestamp ON user_events (timestamp) USING HASH;"
+
+type cockroachBackend struct {
+	postgresBackend
+	// method is copy (COPY FROM STDIN) or insert (multi-row INSERTs of
+	// the writer's batch size).
+	method string
+	writer multiRowWriter
+}
+
+func newCockroachBackend(connStr string) (*cockroachBackend, error) {
+	pg, err := newPostgresBackend(connStr, cockroachSchema)
+	if err != nil {
+		return nil, err
+	}
+	return &cockroachBackend{postgresBackend: *pg, method: "copy", writer: multiRowWriter{batchSize: 1000}}, nil
+}
+
+func (b *cockroachBackend) setIngestMethod(method string) {
+	b.method = method
+}
+
+func (b *cockroachBackend) setWriteBatchSize(rows int) {
+	b.writer.batchSize = rows
+}
+
+func (b *cockroachBackend) ingest(ctx context.Context, readings []Reading) error {
+	if b.method == "copy" {
+		return b.postgresBackend.ingest(ctx, readings)
+	}
+	return b.writer.write(ctx, b.pool, "user_events", []string{"user_id", "timestamp", "rssi", "ssid"}, readings)
+}
@@ -0,0 +1,300 @@
+//go:build !nocratedb
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+func init() {
+	registerBackend(backendInfo{
+		name:        "cratedb",
+		description: "CrateDB (pgx, multi-row INSERT ingestion)",
+		open: func(connStr string) (backend, error) {
+			return newCrateBackend(connStr)
+		},
+		ping:        pingPostgres,
+		isTransient: isTransientPostgres,
+		queries: []querySpec{
+			{id: 1, text: "SELECT MIN(ts), MAX(ts) FROM user_events"},
+			{id: 2, text: "SELECT COUNT(*) FROM user_events"},
+			{id: 3, text: "SELECT COUNT(DISTINCT user_id) FROM user_events"},
+			{id: 4, text: "SELECT AVG(rssi) FROM user_events"},
+			{id: 5, text: "SELECT COUNT(*) FROM user_events WHERE ts < $1", args: atMiddle},
+			{id: 6, text: "SELECT COUNT(*) FROM user_events WHERE ts > $1", args: atMiddle},
+			{id: 7, text: "SELECT COUNT(*) FROM user_events WHERE ts BETWEEN $1 AND $2", args: aroundMiddle},
+			{id: 8, text: "SELECT date_trunc('hour', ts) as hour, COUNT(*) FROM user_events WHERE ts BETWEEN $1 AND $2 GROUP BY hour ORDER BY hour", args: dayFromMiddle},
+			{id: 9, text: "SELECT user_id, COUNT(*) as count FROM user_events GROUP BY user_id ORDER BY count DESC LIMIT 10"},
+			{id: 10, text: "SELECT COUNT(*) FROM user_events WHERE rssi > -50"},
+			{id: 11, text: "SELECT COUNT(*) FROM user_events WHERE rssi < -80"},
+			{id: 12, text: "SELECT ssid, COUNT(*) as count FROM user_events GROUP BY ssid ORDER BY count DESC LIMIT 10"},
+			{id: 13, text: "SELECT user_id, AVG(rssi), MIN(rssi), MAX(rssi) FROM user_events GROUP BY user_id ORDER BY AVG(rssi) DESC LIMIT 100"},
+			{id: 14, text: "SELECT percentile(rssi, 0.25), percentile(rssi, 0.5), percentile(rssi, 0.75) FROM user_events"},
+			{id: 15, text: "SELECT COUNT(*) FROM user_events WHERE ts BETWEEN $1 AND $2", args: firstHalf},
+			{id: 16, text: "SELECT COUNT(*) FROM user_events WHERE ts BETWEEN $1 AND $2", args: secondHalf},
+			{id: 17, text: "SELECT extract(hour from ts) as hour, COUNT(*) as count FROM user_events GROUP BY hour ORDER BY hour"},
+			{id: 18, text: "SELECT date_trunc('day', ts) as day, variance(rssi) as rssi_variance FROM user_events GROUP BY day ORDER BY day LIMIT 30"},
+			{id: 19, text: "SELECT date_trunc('hour', ts) as hour, COUNT(*) as count FROM user_events GROUP BY hour ORDER BY count DESC LIMIT 5"},
+			{id: 20, text: "SELECT user_id, COUNT(*) AS sessions, SUM(session_end - session_start) AS session_duration FROM (SELECT user_id, MIN(ts) AS session_start, MAX(ts) AS session_end FROM (SELECT user_id, ts, SUM(new_session) OVER (PARTITION BY user_id ORDER BY ts) AS session_id FROM (SELECT user_id, ts, CASE WHEN ts > LAG(ts) OVER (PARTITION BY user_id ORDER BY ts) + INTERVAL '30 minutes' THEN 1 ELSE 0 END AS new_session FROM user_events) gaps) numbered GROUP BY user_id, session_id) sessions GROUP BY user_id ORDER BY session_duration DESC LIMIT 10"},
+			{id: 21, text: "SELECT AVG(moving_rssi) FROM (SELECT AVG(rssi) OVER (PARTITION BY user_id ORDER BY ts RANGE BETWEEN INTERVAL '15 minutes' PRECEDING AND CURRENT ROW) AS moving_rssi FROM user_events WHERE ts BETWEEN $1 AND $2) w", args: dayFromMiddle},
+			{id: 22, text: "SELECT h.hour, s.ssid, COUNT(e.rssi) FROM generate_series(date_trunc('hour', $1::TIMESTAMP), $2::TIMESTAMP - INTERVAL '1 hour', INTERVAL '1 hour') AS h(hour) CROSS JOIN (SELECT DISTINCT ssid FROM user_events WHERE ts >= $1 AND ts < $2) s LEFT JOIN user_events e ON e.ssid = s.ssid AND e.ts >= h.hour AND e.ts < h.hour + INTERVAL '1 hour' GROUP BY h.hour, s.ssid ORDER BY h.hour, s.ssid", args: dayFromMiddle},
+			{id: 24, text: "SELECT date_bin('15 minutes'::INTERVAL, ts, 0) AS bucket, ssid, COUNT(DISTINCT user_id) FROM user_events WHERE ts >= $1 AND ts < $2 GROUP BY bucket, ssid ORDER BY bucket, ssid", args: dayFromMiddle},
+			{id: 25, text: "SELECT date_bin('15 minutes'::INTERVAL, ts, 0) AS bucket, ssid, hyperloglog_distinct(user_id) FROM user_events WHERE ts >= $1 AND ts < $2 GROUP BY bucket, ssid ORDER BY bucket, ssid", args: dayFromMiddle},
+		},
+		capabilities: &Capabilities{
+			Percentiles:     true,
+			WindowFunctions: true,
+			GapFill:         false,
+			ApproxDistinct:  true,
+			Updates:         true,
+			Deletes:         true,
+		},
+		bucketQuery: func(w bucketWidth) string {
+			return fmt.Sprintf("SELECT date_bin('%s'::INTERVAL, ts, 0) AS bucket, ssid, COUNT(DISTINCT user_id) FROM user_events GROUP BY bucket, ssid ORDER BY bucket", w.interval)
+		},
+		explainPrefix:  "EXPLAIN ANALYZE ",
+		serverTiming:   true,
+		schemaProbe:    "SELECT user_id, ts, rssi, ssid FROM user_events LIMIT 1",
+		ingestMethods:  []string{"insert", "batch"},
+		hourOfDayQuery: "SELECT extract(hour FROM ts) AS hour, COUNT(*) FROM user_events GROUP BY hour",
+		schemaVariants: []schemaVariant{
+			{name: "sharded", ddl: crateSchema},
+			{name: "daily-partitions", ddl: crateTable + crateDayColumn + ") CLUSTERED BY (ts) INTO %d SHARDS PARTITIONED BY (day)"},
+			{name: "refresh-10s", ddl: crateTable + ") CLUSTERED BY (ts) INTO %d SHARDS WITH (refresh_interval = 10000)"},
+			{name: "recommended", ddl: crateTable + crateDayColumn + ") CLUSTERED BY (ts) INTO %d SHARDS PARTITIONED BY (day) WITH (refresh_interval = 10000)"},
+		},
+		reconciliation: reconciliationDialect{
+			refresh:    []string{"REFRESH TABLE user_events"},
+			countQuery: "SELECT COUNT(*) FROM user_events",
+			duplicates: "every point is stored as its own row, identical ones included",
+		},
+		cluster: clusterDialect{
+			note: "user_events is sharded over the nodes of the cluster; every node accepts writes and the readings are sent to the nodes in turn",
+		},
+		extraColumns: sqlExtraColumns,
+		// The translog is fsynced before every write is acknowledged with
+		// request durability, in the background with async.
+		durability: durabilityDialect{
+			statements: map[string][]string{
+				durabilityRelaxed: {`ALTER TABLE user_events SET ("translog.durability" = 'async')`},
+				durabilityStrict:  {`ALTER TABLE user_events SET ("translog.durability" = 'request')`},
+			},
+			show: "SELECT 'translog.durability', settings['translog']['durability'] FROM information_schema.tables WHERE table_name = 'user_events'",
+		},
+		maintenance: []maintenanceOp{
+			{name: "optimize", statements: []string{"OPTIMIZE TABLE user_events"}},
+			{name: "analyze", statements: []string{"ANALYZE"}},
+		},
+		serverSettings: crateServerSettings,
+		retention:      true,
+		export:         true,
+		fidelity:       true,
+		container: containerSpec{
+			image: "crate:5.9.4",
+			ports: []string{"4200:4200", "5434:5432"},
+			env:   []string{"CRATE_HEAP_SIZE=10g"},
+			args:  []string{"crate", "-Cnetwork.host=0.0.0.0", "-Cdiscovery.type=single-node"},
+		},
+		joins: joinDialect{
+			schema:   sqlDimensionTables,
+			finalize: []string{"REFRESH TABLE users, access_points"},
+			queries: []querySpec{
+				{id: 1, text: "SELECT a.building, COUNT(*) AS count FROM user_events e JOIN access_points a ON a.ssid = e.ssid GROUP BY a.building ORDER BY count DESC"},
+				{id: 2, text: "SELECT date_trunc('hour', e.ts) AS hour, a.building, COUNT(DISTINCT e.user_id) FROM user_events e JOIN access_points a ON a.ssid = e.ssid WHERE e.ts >= $1 AND e.ts < $2 GROUP BY hour, a.building ORDER BY hour, a.building", args: dayFromMiddle},
+				{id: 3, text: "SELECT u.department, a.building, AVG(e.rssi) FROM user_events e JOIN users u ON u.user_id = e.user_id JOIN access_points a ON a.ssid = e.ssid GROUP BY u.department, a.building ORDER BY u.department, a.building"},
+			},
+		},
+		// CrateDB has no materialized views; the rollup is an INSERT ... SELECT
+		// into a table of its own.
+		downsampling: rollupDialect{
+			create: []string{
+				"REFRESH TABLE user_events",
+				"CREATE TABLE user_events_hourly (hour TIMESTAMP WITHOUT TIME ZONE, ssid TEXT, readings BIGINT, rssi_sum DOUBLE PRECISION)",
+				"INSERT INTO user_events_hourly (hour, ssid, readings, rssi_sum) (SELECT date_trunc('hour', ts), ssid, COUNT(*), SUM(rssi) FROM user_events GROUP BY date_trunc('hour', ts), ssid)",
+				"REFRESH TABLE user_events_hourly",
+			},
+			queries: sqlHourlyRollupQueries,
+		},
+		buildings: buildingDialect{
+			table: func(name string) []string {
+				return []string{"CREATE TABLE " + name + " (user_id TEXT NOT NULL, ts TIMESTAMP WITHOUT TIME ZONE NOT NULL, rssi FLOAT NOT NULL, ssid TEXT NOT NULL) CLUSTERED BY (ts) INTO 4 SHARDS"}
+			},
+			queries: func(tables []string) []querySpec {
+				return sqlBuildingQueries(tables, "date_trunc('hour', ts)", "ts >= $1 AND ts < $2", dayFromMiddle)
+			},
+		},
+		locations: locationDialect{
+			schema: []string{
+				"CREATE TABLE " + locatedTable + " (user_id TEXT NOT NULL, ts TIMESTAMP WITHOUT TIME ZONE NOT NULL, rssi FLOAT NOT NULL, ssid TEXT NOT NULL, building TEXT NOT NULL, floor_number INTEGER NOT NULL, latitude DOUBLE PRECISION, longitude DOUBLE PRECISION) CLUSTERED BY (ts) INTO 4 SHARDS",
+			},
+			finalize: []string{"REFRESH TABLE " + locatedTable},
+			queries:  sqlLocationQueries("date_trunc('hour', ts)", "ts >= $1 AND ts < $2", "COUNT(DISTINCT user_id)", dayFromMiddle),
+		},
+		timeToInsight: timeToInsightDialect{
+			rollup: []string{
+				"REFRESH TABLE user_events",
+				"CREATE TABLE user_events_hourly (hour TIMESTAMP WITHOUT TIME ZONE, ssid TEXT, readings BIGINT, users BIGINT, avg_rssi DOUBLE PRECISION)",
+				"INSERT INTO user_events_hourly (hour, ssid, readings, users, avg_rssi) (SELECT date_trunc('hour', ts), ssid, COUNT(*), COUNT(DISTINCT user_id), AVG(rssi) FROM user_events GROUP BY date_trunc('hour', ts), ssid)",
+				"REFRESH TABLE user_events_hourly",
+			},
+			dashboard: sqlDashboardBundle,
+		},
+	})
+}
+
+type crateBackend struct {
+	postgresBackend
+	// method is insert (multi-row INSERTs of the writer's batch size) or
+	// batch (one INSERT per row, queued in a pgx batch).
+	method string
+	writer multiRowWriter
+	// shards is filled into the CLUSTERED clause of the schema.
+	shards int
+	// timingSince is the server time of the last markServerTime.
+	timingSince time.Time
+}
+
+func newCrateBackend(connStr string) (*crateBackend, error) {
+	pool, err := newPgPool(connStr)
+	if err != nil {
+		return nil, err
+	}
+	return &crateBackend{
+		postgresBackend: postgresBackend{pool: pool, schema: crateSchema},
+		method:          "insert",
+		writer:          multiRowWriter{batchSize: 1000},
+		shards:          4,
+	}, nil
+}
+
+// crateTable is the start of the DDL of user_events, open for more columns;
+// the variants close it and add the CLUSTERED clause, whose shard count is
+// filled in by createSchema.
+const crateTable = `
+		CREATE TABLE IF NOT EXISTS user_events (
+			user_id TEXT NOT NULL,
+			ts TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+			rssi FLOAT NOT NULL,
+			ssid TEXT NOT NULL`
+
+const crateSchema = crateTable + ") CLUSTERED BY (ts) INTO %d SHARDS"
+
+// crateServerSettings reads the heap of the nodes, the query circuit breaker
+// and the layout settings of user_events, which the schema variants change.
+const crateServerSettings = `SELECT 'heap.max', CAST(MAX(heap['max']) AS TEXT) FROM sys.nodes
+	UNION ALL SELECT 'indices.breaker.query.limit', settings['indices']['breaker']['query']['limit'] FROM sys.cluster
+	UNION ALL SELECT 'user_events.number_of_shards', CAST(number_of_shards AS TEXT) FROM information_schema.tables WHERE table_name = 'user_events'
+	UNION ALL SELECT 'user_events.number_of_replicas', number_of_replicas FROM information_schema.tables WHERE table_name = 'user_events'
+	UNION ALL SELECT 'user_events.refresh_interval', CAST(settings['refresh_interval'] AS TEXT) FROM information_schema.tables WHERE table_name = 'user_events'`
+
+// crateDayColumn is the partition column of the partitioned variants.
+// CrateDB derives it on insert and prunes the partitions of queries on ts
+// through it.
+const crateDayColumn = `,
+			day TIMESTAMP WITHOUT TIME ZONE GENERATED ALWAYS AS date_trunc('day', ts)`
+
+func (b *crateBackend) createSchema(ctx context.Context) error {
+	return b.exec(ctx, fmt.Sprintf(b.schema, b.shards))
+}
+
+func (b *crateBackend) setShardCount(shards int) {
+	b.shards = shards
+}
+
+func (b *crateBackend) setIngestMethod(method string) {
+	b.method = method
+}
+
+func (b *crateBackend) setWriteBatchSize(rows int) {
+	b.writer.batchSize = rows
+}
+
+// ingest uses INSERTs as CrateDB does not support COPY FROM STDIN.
+func (b *crateBackend) ingest(ctx context.Context, readings []Reading) error {
+	return b.ingestInto(ctx, "user_events", readings)
+}
+
+func (b *crateBackend) ingestInto(ctx context.Context, table string, readings []Reading) error {
+	if b.method == "insert" {
+		return b.writer.write(ctx, b.pool, table, []string{"user_id", "ts", "rssi", "ssid"}, readings)
+	}
+	columns, placeholders := extraInsertColumns(4, true)
+	insert := "INSERT INTO " + table + " (user_id, ts, rssi, ssid" + columns + ") VALUES ($1, $2, $3, $4" + placeholders + ")"
+	batch := getBatch(len(readings))
+	defer putBatch(batch)
+	for i := range readings {
+		reading := &readings[i]
+		batch.Queue(
+			insert,
+			append([]any{
+				reading.UserId,
+				readingTime(reading.LastUpdatedTime),
+				reading.Connection.Rssi,
+				reading.Connection.Ssid,
+			}, extraValues(reading)...)...,
+		)
+	}
+
+	return b.pool.SendBatch(ctx, batch).Close()
+}
+
+func (b *crateBackend) ingestLocated(ctx context.Context, readings []Reading) error {
+	insert := "INSERT INTO " + locatedTable + " (user_id, ts, rssi, ssid, building, floor_number, latitude, longitude) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)"
+	batch := getBatch(len(readings))
+	defer putBatch(batch)
+	for i := range readings {
+		reading := &readings[i]
+		building, floor, lat, lon := locationValues(reading)
+		batch.Queue(
+			insert,
+			reading.UserId,
+			readingTime(reading.LastUpdatedTime),
+			reading.Connection.Rssi,
+			reading.Connection.Ssid,
+			building,
+			floor,
+			lat,
+			lon,
+		)
+	}
+
+	return b.pool.SendBatch(ctx, batch).Close()
+}
+
+func (b *crateBackend) lookupReading(ctx context.Context, userId string, at time.Time) ([]storedReading, error) {
+	return lookupPgReadings(ctx, b.pool, "SELECT rssi, ssid FROM user_events WHERE user_id = $1 AND ts = $2", userId, at)
+}
+
+// export uses COPY TO DIRECTORY, which writes the table as JSON files on the
+// node's own disk; CrateDB cannot stream COPY to the client.
+func (b *crateBackend) export(ctx context.Context, w io.Writer) error {
+	_, err := b.pool.Exec(ctx, "COPY user_events TO DIRECTORY '/tmp/user_events_export'")
+	return err
+}
+
+func (b *crateBackend) expireBefore(ctx context.Context, cutoff time.Time) error {
+	_, err := b.pool.Exec(ctx, "DELETE FROM user_events WHERE ts < $1", cutoff)
+	return err
+}
+
+// markServerTime starts a window of sys.jobs_log, which keeps the recent
+// statements with millisecond start and end times.
+func (b *crateBackend) markServerTime(ctx context.Context) error {
+	return b.pool.QueryRow(ctx, "SELECT CURRENT_TIMESTAMP(3)").Scan(&b.timingSince)
+}
+
+func (b *crateBackend) serverTimeMs(ctx context.Context) (float64, int, error) {
+	var mean *float64
+	var statements int
+	err := b.pool.QueryRow(ctx, `SELECT AVG(CAST(ended AS BIGINT) - CAST(started AS BIGINT)), COUNT(*)
+		FROM sys.jobs_log
+		WHERE started >= $1 AND error IS NULL
+		AND stmt NOT ILIKE '%sys.jobs_log%' AND stmt NOT ILIKE '%CURRENT_TIMESTAMP%'`, b.timingSince).Scan(&mean, &statements)
+	if err != nil || mean == nil {
+		return 0, 0, err
+	}
+	return *mean, statements, nil
+}
@@ -0,0 +1,464 @@
+//go:build !nocustomsql
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// The custom-sql backend talks the PostgreSQL or the MySQL wire protocol to
+// an engine whose DDL, insert and catalog queries are read from a dialect
+// directory (-sql-dialect), so SQL databases such as CockroachDB, YugabyteDB
+// or TiDB are benchmarked without Go changes. The directory holds:
+//
+//	dialect.json      {"name": ..., "protocol": "pgwire" | "mysql", "batchSize": ...}
+//	schema.sql        the DDL, statements separated by a ; at the end of a line
+//	insert.sql        a one-row INSERT of user_id, timestamp, rssi and ssid
+//	query_<id>.sql    the catalog queries, query_1.sql being the time bounds
+//
+// A query taking parameters names them in a first line of the form
+// "-- args: <kind>", see customSQLArgs.
+
+func init() {
+	registerBackend(backendInfo{
+		name:        "custom-sql",
+		description: "Generic PostgreSQL or MySQL protocol database, configured with -sql-dialect",
+		open: func(connStr string) (backend, error) {
+			return nil, fmt.Errorf("the custom-sql backend needs -sql-dialect")
+		},
+		ping: func(ctx context.Context, connStr string) error {
+			return fmt.Errorf("the custom-sql backend needs -sql-dialect")
+		},
+		// Engines added through a dialect rarely support the whole catalog.
+		lenientQueries: true,
+	})
+	loadSQLDialect = readSQLDialect
+}
+
+type customSQLDialect struct {
+	// Name is recorded as the database type, custom-sql:<name>.
+	Name string `json:"name"`
+	// Protocol is pgwire or mysql; the connection string is a PostgreSQL URL
+	// or a go-sql-driver DSN accordingly.
+	Protocol string `json:"protocol"`
+	// BatchSize is the number of rows of an insert request; 1000 when unset.
+	BatchSize int `json:"batchSize"`
+	// Probe checks a pre-provisioned table for -no-create; empty when the
+	// dialect does not support it.
+	Probe string `json:"probe"`
+
+	schema string
+	insert string
+}
+
+// customSQLArgs are the parameter kinds of the "-- args:" header of a query.
+var customSQLArgs = map[string]func(b queryBounds) []any{
+	"middle":          atMiddle,
+	"around-middle":   aroundMiddle,
+	"day-from-middle": dayFromMiddle,
+	"first-half":      firstHalf,
+	"second-half":     secondHalf,
+	"whole-range":     wholeRange,
+}
+
+var (
+	customSQLQueryFile = regexp.MustCompile(`^query_(\d+)\.sql$`)
+	statementEnd       = regexp.MustCompile(`;\s*(\n|$)`)
+)
+
+// customSQLSettings is the dialect of the run, loaded by main.
+var customSQLSettings *customSQLDialect
+
+// readSQLDialect loads a dialect directory and registers its protocol and
+// queries with the custom-sql backend.
+func readSQLDialect(dir string) error {
+	encoded, err := os.ReadFile(filepath.Join(dir, "dialect.json"))
+	if err != nil {
+		return err
+	}
+	if encoded, err = expandJSONEnv(encoded); err != nil {
+		return fmt.Errorf("%s: %w", dir, err)
+	}
+	dialect := &customSQLDialect{}
+	if err := json.Unmarshal(encoded, dialect); err != nil {
+		return fmt.Errorf("%s: %w", dir, err)
+	}
+	if dialect.Name == "" {
+		return fmt.Errorf("%s: the dialect needs a name", dir)
+	}
+	if dialect.BatchSize <= 0 {
+		dialect.BatchSize = 1000
+	}
+	for _, file := range []struct {
+		name   string
+		target *string
+	}{{"schema.sql", &dialect.schema}, {"insert.sql", &dialect.insert}} {
+		text, err := os.ReadFile(filepath.Join(dir, file.name))
+		if err != nil {
+			return err
+		}
+		*file.target = strings.TrimSpace(string(text))
+	}
+
+	info := backends["custom-sql"]
+	info.name = "custom-sql:" + dialect.Name
+	info.schemaProbe = dialect.Probe
+	switch dialect.Protocol {
+	case "pgwire":
+		info.open = func(connStr string) (backend, error) {
+			return newCustomPgBackend(connStr, dialect)
+		}
+		info.ping = pingPostgres
+		info.isTransient = isTransientPostgres
+	case "mysql":
+		if _, _, err := splitInsertValues(dialect.insert); err != nil {
+			return fmt.Errorf("%s: %w", dir, err)
+		}
+		info.open = func(connStr string) (backend, error) {
+			return newCustomMySQLBackend(connStr, dialect)
+		}
+		info.ping = pingMySQL
+		info.isTransient = isTransientMySQL
+	default:
+		return fmt.Errorf("%s: unknown protocol %q, expected pgwire or mysql", dir, dialect.Protocol)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	info.queries = nil
+	for _, entry := range entries {
+		match := customSQLQueryFile.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		id, _ := strconv.Atoi(match[1])
+		if id < 1 || id >= len(queryDescriptions) {
+			return fmt.Errorf("%s: unknown query id %d", entry.Name(), id)
+		}
+		text, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+		spec, err := parseCustomSQLQuery(id, string(text))
+		if err != nil {
+			return fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		info.queries = append(info.queries, spec)
+	}
+	slices.SortFunc(info.queries, func(a, b querySpec) int { return a.id - b.id })
+	if len(info.queries) == 0 || info.queries[0].id != 1 {
+		return fmt.Errorf("%s: the dialect needs query_1.sql, the time bounds", dir)
+	}
+	backends["custom-sql"] = info
+	customSQLSettings = dialect
+	return nil
+}
+
+// parseCustomSQLQuery strips the "-- args:" header of a query file and the
+// trailing semicolon of its statement.
+func parseCustomSQLQuery(id int, text string) (querySpec, error) {
+	spec := querySpec{id: id}
+	text = strings.TrimSpace(text)
+	if header, ok := strings.CutPrefix(text, "-- args:"); ok {
+		kind, rest, _ := strings.Cut(header, "\n")
+		kind = strings.TrimSpace(kind)
+		if spec.args = customSQLArgs[kind]; spec.args == nil {
+			return spec, fmt.Errorf("unknown argument kind %q", kind)
+		}
+		text = strings.TrimSpace(rest)
+	}
+	spec.text = strings.TrimSuffix(text, ";")
+	if spec.text == "" {
+		return spec, fmt.Errorf("empty query")
+	}
+	return spec, nil
+}
+
+// splitSQLStatements splits a script on the semicolons that end a line.
+func splitSQLStatements(script string) []string {
+	var stmts []string
+	for _, stmt := range statementEnd.Split(script, -1) {
+		if stmt = strings.TrimSpace(stmt); stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+	}
+	return stmts
+}
+
+// customPgBackend holds the pool rather than embedding postgresBackend, so the
+// capabilities of PostgreSQL itself (COPY, pg_stat_statements, ...) are not
+// claimed for an engine that only speaks its protocol.
+type customPgBackend struct {
+	pg      *postgresBackend
+	dialect *customSQLDialect
+}
+
+func newCustomPgBackend(connStr string, dialect *customSQLDialect) (*customPgBackend, error) {
+	pg, err := newPostgresBackend(connStr, dialect.schema)
+	if err != nil {
+		return nil, err
+	}
+	return &customPgBackend{pg: pg, dialect: dialect}, nil
+}
+
+func (b *customPgBackend) createSchema(ctx context.Context) error {
+	for _, stmt := range splitSQLStatements(b.dialect.schema) {
+		if err := b.pg.exec(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *customPgBackend) setWriteBatchSize(rows int) {
+	b.dialect.BatchSize = rows
+}
+
+// ingest queues the insert once per reading and sends BatchSize of them per
+// round trip.
+func (b *customPgBackend) ingest(ctx context.Context, readings []Reading) error {
+	for start := 0; start < len(readings); start += b.dialect.BatchSize {
+		rows := readings[start:min(start+b.dialect.BatchSize, len(readings))]
+		batch := getBatch(len(rows))
+		for _, reading := range rows {
+			batch.Queue(b.dialect.insert, reading.UserId, readingTime(reading.LastUpdatedTime), reading.Connection.Rssi, reading.Connection.Ssid)
+		}
+		err := b.pg.pool.SendBatch(ctx, batch).Close()
+		putBatch(batch)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *customPgBackend) poolSettings() PoolSettings {
+	return b.pg.poolSettings()
+}
+
+func (b *customPgBackend) exec(ctx context.Context, stmt string) error {
+	return b.pg.exec(ctx, stmt)
+}
+
+func (b *customPgBackend) query(ctx context.Context, q string, args ...any) error {
+	return b.pg.query(ctx, q, args...)
+}
+
+func (b *customPgBackend) scanRows(ctx context.Context, visit func(values []any), q string, args ...any) error {
+	return b.pg.scanRows(ctx, visit, q, args...)
+}
+
+func (b *customPgBackend) timeBounds(ctx context.Context, q string) (time.Time, time.Time, error) {
+	return b.pg.timeBounds(ctx, q)
+}
+
+func (b *customPgBackend) serverVersion(ctx context.Context) (string, error) {
+	return b.pg.serverVersion(ctx)
+}
+
+func (b *customPgBackend) close() {
+	b.pg.close()
+}
+
+// mysqlConfig parses a go-sql-driver DSN and applies the credential and TLS
+// flags on top of it. Timestamps are read and written in UTC.
+func mysqlConfig(connStr string) (*mysql.Config, error) {
+	config, err := mysql.ParseDSN(connStr)
+	if err != nil {
+		return nil, err
+	}
+	registerSecret(config.Passwd)
+	if dbCredentials.User != "" {
+		config.User = dbCredentials.User
+	}
+	if dbCredentials.Password != "" {
+		config.Passwd = dbCredentials.Password
+	}
+	config.ParseTime = true
+	config.Loc = time.UTC
+	host, _, _ := strings.Cut(config.Addr, ":")
+	tlsConfig, err := tlsSettings.config(host)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		config.TLS = tlsConfig
+	}
+	return config, nil
+}
+
+func pingMySQL(ctx context.Context, connStr string) error {
+	config, err := mysqlConfig(connStr)
+	if err != nil {
+		return err
+	}
+	connector, err := mysql.NewConnector(config)
+	if err != nil {
+		return err
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	return db.PingContext(ctx)
+}
+
+// isTransientMySQL reports whether a MySQL error is safe to retry: a broken
+// connection, 1040 too many connections, 1205 lock wait timeout or 1213
+// deadlock.
+func isTransientMySQL(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case 1040, 1205, 1213:
+			return true
+		}
+	}
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn)
+}
+
+// splitInsertValues splits a one-row INSERT into the statement up to VALUES
+// and its row tuple, which is repeated for the rows of a batch.
+func splitInsertValues(insert string) (string, string, error) {
+	at := strings.LastIndex(strings.ToUpper(insert), "VALUES")
+	if at < 0 {
+		return "", "", fmt.Errorf("insert.sql has no VALUES clause")
+	}
+	prefix := insert[:at+len("VALUES")]
+	tuple := strings.TrimSuffix(strings.TrimSpace(insert[at+len("VALUES"):]), ";")
+	if strings.Count(tuple, "?") != 4 {
+		return "", "", fmt.Errorf("the VALUES of insert.sql need 4 placeholders, user_id, timestamp, rssi and ssid")
+	}
+	return prefix, tuple, nil
+}
+
+type customMySQLBackend struct {
+	db      *sql.DB
+	dialect *customSQLDialect
+	// prefix and tuple build the multi-row INSERT of a batch.
+	prefix, tuple string
+	maxIdle       int
+	args          []any
+}
+
+func newCustomMySQLBackend(connStr string, dialect *customSQLDialect) (*customMySQLBackend, error) {
+	config, err := mysqlConfig(connStr)
+	if err != nil {
+		return nil, err
+	}
+	connector, err := mysql.NewConnector(config)
+	if err != nil {
+		return nil, err
+	}
+	db := sql.OpenDB(connector)
+
+	// database/sql keeps 2 idle connections and opens any number by default.
+	maxIdle := 2
+	if poolTuning.MaxConns > 0 {
+		db.SetMaxOpenConns(poolTuning.MaxConns)
+	}
+	if poolTuning.MinConns > 0 {
+		maxIdle = poolTuning.MinConns
+		db.SetMaxIdleConns(maxIdle)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	prefix, tuple, _ := splitInsertValues(dialect.insert)
+	return &customMySQLBackend{db: db, dialect: dialect, prefix: prefix, tuple: tuple, maxIdle: maxIdle}, nil
+}
+
+func (b *customMySQLBackend) createSchema(ctx context.Context) error {
+	for _, stmt := range splitSQLStatements(b.dialect.schema) {
+		if err := b.exec(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *customMySQLBackend) setWriteBatchSize(rows int) {
+	b.dialect.BatchSize = rows
+}
+
+// ingest sends one multi-row INSERT per BatchSize readings.
+func (b *customMySQLBackend) ingest(ctx context.Context, readings []Reading) error {
+	var stmt strings.Builder
+	for start := 0; start < len(readings); start += b.dialect.BatchSize {
+		rows := readings[start:min(start+b.dialect.BatchSize, len(readings))]
+		stmt.Reset()
+		stmt.WriteString(b.prefix)
+		b.args = b.args[:0]
+		for i, reading := range rows {
+			if i > 0 {
+				stmt.WriteByte(',')
+			}
+			stmt.WriteByte(' ')
+			stmt.WriteString(b.tuple)
+			b.args = append(b.args, reading.UserId, readingTime(reading.LastUpdatedTime).UTC(), reading.Connection.Rssi, reading.Connection.Ssid)
+		}
+		if _, err := b.db.ExecContext(ctx, stmt.String(), b.args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *customMySQLBackend) exec(ctx context.Context, stmt string) error {
+	_, err := b.db.ExecContext(ctx, stmt)
+	return err
+}
+
+func (b *customMySQLBackend) query(ctx context.Context, q string, args ...any) error {
+	rows, err := b.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+	}
+	return rows.Err()
+}
+
+func (b *customMySQLBackend) scanRows(ctx context.Context, visit func(values []any), q string, args ...any) error {
+	rows, err := b.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return err
+	}
+	return scanSQLRows(rows, visit)
+}
+
+func (b *customMySQLBackend) timeBounds(ctx context.Context, q string) (time.Time, time.Time, error) {
+	var minTime, maxTime time.Time
+	err := b.db.QueryRowContext(ctx, q).Scan(&minTime, &maxTime)
+	return minTime, maxTime, err
+}
+
+func (b *customMySQLBackend) poolSettings() PoolSettings {
+	return PoolSettings{MaxConns: b.db.Stats().MaxOpenConnections, MinConns: b.maxIdle}
+}
+
+func (b *customMySQLBackend) serverVersion(ctx context.Context) (string, error) {
+	var version string
+	err := b.db.QueryRowContext(ctx, "SELECT VERSION()").Scan(&version)
+	return version, err
+}
+
+func (b *customMySQLBackend) close() {
+	b.db.Close()
+}
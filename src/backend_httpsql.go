@@ -0,0 +1,432 @@
+//go:build !nohttpsql
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// The http-sql backend reaches an engine through an HTTP SQL gateway, e.g. the
+// HTTP interface of ClickHouse Cloud, with the statements and the request
+// layout taken from a dialect file (-http-sql-dialect) instead of Go code. The
+// connection string is the endpoint URL.
+
+func init() {
+	registerBackend(backendInfo{
+		name:        "http-sql",
+		description: "Generic SQL over HTTP, configured with -http-sql-dialect",
+		open: func(connStr string) (backend, error) {
+			return newHTTPSQLBackend(connStr)
+		},
+		ping: func(ctx context.Context, connStr string) error {
+			b, err := newHTTPSQLBackend(connStr)
+			if err != nil {
+				return err
+			}
+			return b.exec(ctx, b.dialect.Ping)
+		},
+		isTransient: func(err error) bool {
+			var httpErr *httpSQLError
+			if errors.As(err, &httpErr) {
+				switch httpErr.StatusCode {
+				case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+					return true
+				}
+			}
+			return false
+		},
+		// Gateways of experimental engines rarely support the whole catalog.
+		lenientQueries: true,
+	})
+	loadHTTPSQLDialect = readHTTPSQLDialect
+}
+
+// httpSQLDialect is the JSON dialect file of -http-sql-dialect.
+type httpSQLDialect struct {
+	// Name is recorded as the database type, http-sql:<name>.
+	Name    string         `json:"name"`
+	Request httpSQLRequest `json:"request"`
+	// TimeFormat renders the timestamps of the inserts and the query
+	// parameters, in UTC, as a Go layout; RFC 3339 when empty.
+	TimeFormat string `json:"timeFormat"`
+	// Ping is sent to check that the gateway is up; SELECT 1 when empty.
+	Ping   string   `json:"ping"`
+	Schema []string `json:"schema"`
+	// Insert is a text/template rendering one write request from .Table and
+	// .Rows, whose entries have UserId, Ssid, Rssi and Time.
+	Insert    string `json:"insert"`
+	BatchSize int    `json:"batchSize"`
+	// Queries are text/templates of the catalog queries by id. Query 1 must
+	// answer with one CSV or TSV row holding the oldest and newest timestamp.
+	// The parameters are .Min, .Max, .Middle, .HourBefore, .HourAfter and
+	// .DayAfter.
+	Queries map[int]string `json:"queries"`
+
+	insert  *template.Template
+	queries map[string]*template.Template
+}
+
+type httpSQLRequest struct {
+	// Method is POST unless set.
+	Method string `json:"method"`
+	// Path is appended to the endpoint URL.
+	Path string `json:"path"`
+	// SQLParam sends the statement as this URL parameter instead of the body.
+	SQLParam    string            `json:"sqlParam"`
+	ContentType string            `json:"contentType"`
+	Headers     map[string]string `json:"headers"`
+	// TokenHeader carries -db-token, with TokenPrefix in front of it;
+	// Authorization and "Bearer " when empty. -db-user and -db-password are
+	// sent as basic auth.
+	TokenHeader string `json:"tokenHeader"`
+	TokenPrefix string `json:"tokenPrefix"`
+}
+
+// httpSQLSettings is the dialect of the run, loaded by main.
+var httpSQLSettings *httpSQLDialect
+
+// httpSQLFuncs quote values for the templates: sql as a single-quoted SQL
+// literal, json as a JSON value.
+var httpSQLFuncs = template.FuncMap{
+	"sql": func(s string) string {
+		return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+	},
+	"json": func(v any) (string, error) {
+		encoded, err := json.Marshal(v)
+		return string(encoded), err
+	},
+}
+
+// readHTTPSQLDialect loads the dialect and registers its queries with the
+// http-sql backend.
+func readHTTPSQLDialect(path string) error {
+	encoded, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if encoded, err = expandJSONEnv(encoded); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	dialect := &httpSQLDialect{}
+	if err := json.Unmarshal(encoded, dialect); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if dialect.Name == "" || dialect.Insert == "" {
+		return fmt.Errorf("%s: the dialect needs a name and an insert template", path)
+	}
+	if _, ok := dialect.Queries[1]; !ok {
+		return fmt.Errorf("%s: the dialect needs query 1, the time bounds", path)
+	}
+	if dialect.Request.Method == "" {
+		dialect.Request.Method = http.MethodPost
+	}
+	if dialect.TimeFormat == "" {
+		dialect.TimeFormat = time.RFC3339
+	}
+	if dialect.Ping == "" {
+		dialect.Ping = "SELECT 1"
+	}
+	if dialect.BatchSize <= 0 {
+		dialect.BatchSize = 5000
+	}
+	for name, value := range dialect.Request.Headers {
+		if credentialHeader.MatchString(name) {
+			registerSecret(value)
+			if _, token, ok := strings.Cut(value, " "); ok {
+				registerSecret(token)
+			}
+		}
+	}
+
+	if dialect.insert, err = template.New("insert").Funcs(httpSQLFuncs).Parse(dialect.Insert); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	info := backends["http-sql"]
+	info.name = "http-sql:" + dialect.Name
+	info.queries = nil
+	dialect.queries = map[string]*template.Template{}
+	for id, text := range dialect.Queries {
+		if id < 1 || id >= len(queryDescriptions) {
+			return fmt.Errorf("%s: unknown query id %d", path, id)
+		}
+		tmpl, err := template.New(strconv.Itoa(id)).Funcs(httpSQLFuncs).Parse(text)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		dialect.queries[text] = tmpl
+		info.queries = append(info.queries, querySpec{id: id, text: text, args: boundsAsArg})
+	}
+	backends["http-sql"] = info
+	httpSQLSettings = dialect
+	return nil
+}
+
+// credentialHeader matches the headers of a dialect that carry a credential,
+// whose values are then redacted like the credential flags.
+var credentialHeader = regexp.MustCompile(`(?i)auth|key|token|secret|password`)
+
+// boundsAsArg hands the bounds to the query templates, which pick the
+// parameters they need.
+func boundsAsArg(b queryBounds) []any {
+	return []any{b}
+}
+
+type httpSQLError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *httpSQLError) Error() string {
+	return fmt.Sprintf("http-sql: %d %s", e.StatusCode, e.Message)
+}
+
+type httpSQLBackend struct {
+	endpoint *url.URL
+	http     *http.Client
+	dialect  *httpSQLDialect
+}
+
+func newHTTPSQLBackend(connStr string) (*httpSQLBackend, error) {
+	if httpSQLSettings == nil {
+		return nil, fmt.Errorf("the http-sql backend needs -http-sql-dialect")
+	}
+	endpoint, err := url.Parse(connStr)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{}
+	tlsConfig, err := tlsSettings.config(endpoint.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConfig
+		client.Transport = transport
+	}
+	return &httpSQLBackend{endpoint: endpoint, http: client, dialect: httpSQLSettings}, nil
+}
+
+// send posts one statement and returns the body of the answer.
+func (b *httpSQLBackend) send(ctx context.Context, stmt string) ([]byte, error) {
+	r := b.dialect.Request
+	target := *b.endpoint
+	target.Path = strings.TrimSuffix(target.Path, "/") + r.Path
+	var body io.Reader
+	if r.SQLParam != "" {
+		params := target.Query()
+		params.Set(r.SQLParam, stmt)
+		target.RawQuery = params.Encode()
+	} else {
+		body = strings.NewReader(stmt)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, r.Method, target.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if r.ContentType != "" {
+		req.Header.Set("Content-Type", r.ContentType)
+	}
+	for name, value := range r.Headers {
+		req.Header.Set(name, value)
+	}
+	switch {
+	case dbCredentials.User != "":
+		req.SetBasicAuth(dbCredentials.User, dbCredentials.Password)
+	case dbCredentials.Token != "":
+		header, prefix := r.TokenHeader, r.TokenPrefix
+		if header == "" {
+			header, prefix = "Authorization", "Bearer "
+		}
+		req.Header.Set(header, prefix+dbCredentials.Token)
+	}
+
+	resp, err := b.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	answer, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, &httpSQLError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(answer))}
+	}
+	return answer, nil
+}
+
+func (b *httpSQLBackend) createSchema(ctx context.Context) error {
+	for _, stmt := range b.dialect.Schema {
+		if err := b.exec(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type httpSQLRow struct {
+	UserId string
+	Ssid   string
+	Rssi   float64
+	Time   string
+}
+
+func (b *httpSQLBackend) setWriteBatchSize(rows int) {
+	b.dialect.BatchSize = rows
+}
+
+func (b *httpSQLBackend) ingest(ctx context.Context, readings []Reading) error {
+	return b.ingestInto(ctx, "user_events", readings)
+}
+
+// ingestInto renders one write request per BatchSize readings.
+func (b *httpSQLBackend) ingestInto(ctx context.Context, table string, readings []Reading) error {
+	var stmt bytes.Buffer
+	for start := 0; start < len(readings); start += b.dialect.BatchSize {
+		batch := readings[start:min(start+b.dialect.BatchSize, len(readings))]
+		rows := make([]httpSQLRow, len(batch))
+		for i, reading := range batch {
+			rows[i] = httpSQLRow{
+				UserId: reading.UserId,
+				Ssid:   reading.Connection.Ssid,
+				Rssi:   reading.Connection.Rssi,
+				Time:   readingTime(reading.LastUpdatedTime).UTC().Format(b.dialect.TimeFormat),
+			}
+		}
+		stmt.Reset()
+		if err := b.dialect.insert.Execute(&stmt, map[string]any{"Table": table, "Rows": rows}); err != nil {
+			return err
+		}
+		if _, err := b.send(ctx, stmt.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *httpSQLBackend) exec(ctx context.Context, stmt string) error {
+	_, err := b.send(ctx, stmt)
+	return err
+}
+
+// render fills a query template with the parameters of the bounds; statements
+// that are not templates of the dialect are sent as they are.
+func (b *httpSQLBackend) render(q string, args []any) (string, error) {
+	tmpl, ok := b.dialect.queries[q]
+	if !ok {
+		return q, nil
+	}
+	var bounds queryBounds
+	if len(args) == 1 {
+		bounds, _ = args[0].(queryBounds)
+	}
+	format := func(t time.Time) string { return t.UTC().Format(b.dialect.TimeFormat) }
+	var rendered strings.Builder
+	err := tmpl.Execute(&rendered, map[string]string{
+		"Min":        format(bounds.min),
+		"Max":        format(bounds.max),
+		"Middle":     format(bounds.middle),
+		"HourBefore": format(bounds.middle.Add(-time.Hour)),
+		"HourAfter":  format(bounds.middle.Add(time.Hour)),
+		"DayAfter":   format(bounds.middle.Add(24 * time.Hour)),
+	})
+	return rendered.String(), err
+}
+
+func (b *httpSQLBackend) query(ctx context.Context, q string, args ...any) error {
+	stmt, err := b.render(q, args)
+	if err != nil {
+		return err
+	}
+	_, err = b.send(ctx, stmt)
+	return err
+}
+
+// scanRows hands over every non-empty line of the answer as one value; the
+// CSV or TSV fields are not split, as the checksum only needs a stable text.
+func (b *httpSQLBackend) scanRows(ctx context.Context, visit func(values []any), q string, args ...any) error {
+	stmt, err := b.render(q, args)
+	if err != nil {
+		return err
+	}
+	answer, err := b.send(ctx, stmt)
+	if err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(answer))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			visit([]any{line})
+		}
+	}
+	return scanner.Err()
+}
+
+// timeBounds reads the first row of the answer as two CSV or TSV fields in
+// the dialect's time format, RFC 3339 or Unix seconds.
+func (b *httpSQLBackend) timeBounds(ctx context.Context, q string) (time.Time, time.Time, error) {
+	stmt, err := b.render(q, nil)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	answer, err := b.send(ctx, stmt)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(answer))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		sep := "\t"
+		if !strings.Contains(line, sep) {
+			sep = ","
+		}
+		fields := strings.Split(line, sep)
+		if len(fields) != 2 {
+			break
+		}
+		minTime, err := b.parseTime(fields[0])
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		maxTime, err := b.parseTime(fields[1])
+		return minTime, maxTime, err
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("http-sql: the time bounds query did not answer with a row of two timestamps: %.200q", answer)
+}
+
+func (b *httpSQLBackend) parseTime(field string) (time.Time, error) {
+	field = strings.Trim(strings.TrimSpace(field), `"'`)
+	for _, layout := range []string{b.dialect.TimeFormat, time.RFC3339Nano} {
+		if t, err := time.ParseInLocation(layout, field, time.UTC); err == nil {
+			return t, nil
+		}
+	}
+	if seconds, err := strconv.ParseFloat(field, 64); err == nil {
+		return time.Unix(0, int64(seconds*float64(time.Second))).UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("http-sql: unrecognised timestamp %q", field)
+}
+
+func (b *httpSQLBackend) close() {
+	b.http.CloseIdleConnections()
+}
@@ -0,0 +1,1066 @@
+//go:build !noinfluxdb
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api"
+	ihttp "github.com/influxdata/influxdb-client-go/v2/api/http"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"github.com/influxdata/influxdb-client-go/v2/domain"
+)
+
+func init() {
+	registerBackend(backendInfo{
+		name:        "influxdb",
+		description: "InfluxDB 2.x (line protocol ingestion, Flux queries)",
+		open: func(connStr string) (backend, error) {
+			return newInfluxBackend(connStr)
+		},
+		ping: func(ctx context.Context, connStr string) error {
+			client, err := newInfluxClient(connStr)
+			if err != nil {
+				return err
+			}
+			defer client.Close()
+			ok, err := client.Ping(ctx)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return fmt.Errorf("influxdb is not ready")
+			}
+			return nil
+		},
+		isTransient: func(err error) bool {
+			var httpErr *ihttp.Error
+			if errors.As(err, &httpErr) {
+				switch httpErr.StatusCode {
+				case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+					return true
+				}
+			}
+			return false
+		},
+		// The bucket is provisioned by the container; a missing one fails the
+		// query, an empty one does not.
+		schemaProbe: `from(bucket: "benchmark") |> range(start: -1m) |> limit(n: 1)`,
+		queries: []querySpec{
+			{id: 1, text: influxMinTimeQuery},
+			{id: 2, text: `from(bucket: "benchmark")
+		|> range(start: -30y)
+		|> filter(fn: (r) => r._measurement == "user_events")
+		|> keep(columns: ["_time"])
+		|> count()`},
+			{id: 3, text: `from(bucket: "benchmark")
+		|> range(start: -30y)
+		|> filter(fn: (r) => r._measurement == "user_events")
+		|> distinct(column: "user_id")
+		|> count()`},
+			{id: 4, text: `from(bucket: "benchmark")
+		|> range(start: -30y)
+		|> filter(fn: (r) => r._measurement == "user_events" and r._field == "rssi")
+		|> mean()`},
+			{id: 5, text: `from(bucket: "benchmark")
+		|> range(start: -30y, stop: %s)
+		|> filter(fn: (r) => r._measurement == "user_events")
+		|> count()`, args: atMiddle},
+			{id: 6, text: `from(bucket: "benchmark")
+		|> range(start: %s)
+		|> filter(fn: (r) => r._measurement == "user_events")
+		|> count()`, args: atMiddle},
+			{id: 7, text: `from(bucket: "benchmark")
+		|> range(start: %s, stop: %s)
+		|> filter(fn: (r) => r._measurement == "user_events")
+		|> count()`, args: aroundMiddle},
+			{id: 8, text: `from(bucket: "benchmark")
+		|> range(start: %s, stop: %s)
+		|> filter(fn: (r) => r._measurement == "user_events")
+		|> aggregateWindow(every: 1h, fn: count)`, args: dayFromMiddle},
+			{id: 9, text: `from(bucket: "benchmark")
+		|> range(start: -30y)
+		|> filter(fn: (r) => r._measurement == "user_events")
+		|> group(columns: ["user_id"])
+		|> count()
+		|> top(n: 10)`},
+			{id: 10, text: `from(bucket: "benchmark")
+		|> range(start: -30y)
+		|> filter(fn: (r) => r._measurement == "user_events" and r._field == "rssi" and r._value > -50.0)
+		|> count()`},
+			{id: 11, text: `from(bucket: "benchmark")
+		|> range(start: -30y)
+		|> filter(fn: (r) => r._measurement == "user_events" and r._field == "rssi" and r._value < -80.0)
+		|> count()`},
+			{id: 12, text: `from(bucket: "benchmark")
+		|> range(start: -30y)
+		|> filter(fn: (r) => r._measurement == "user_events")
+		|> group(columns: ["ssid"])
+		|> count()
+		|> top(n: 10)`},
+			{id: 13, text: `from(bucket: "benchmark")
+		|> range(start: -30y)
+		|> filter(fn: (r) => r._measurement == "user_events" and r._field == "rssi")
+		|> group(columns: ["user_id"])
+		|> aggregateWindow(every: inf, fn: mean)
+		|> top(n: 100)`},
+			{id: 14, text: `from(bucket: "benchmark")
+		|> range(start: -30y)
+		|> filter(fn: (r) => r._measurement == "user_events" and r._field == "rssi")
+		|> quantile(q: 0.25, method: "estimate_tdigest")
+		|> yield(name: "q1")`},
+			{id: 15, text: `from(bucket: "benchmark")
+		|> range(start: %s, stop: %s)
+		|> filter(fn: (r) => r._measurement == "user_events")
+		|> count()`, args: firstHalf},
+			{id: 16, text: `from(bucket: "benchmark")
+		|> range(start: %s, stop: %s)
+		|> filter(fn: (r) => r._measurement == "user_events")
+		|> count()`, args: secondHalf},
+			{id: 17, text: `from(bucket: "benchmark")
+		|> range(start: -30y)
+		|> filter(fn: (r) => r._measurement == "user_events")
+		|> group(columns: ["_time"])
+		|> aggregateWindow(every: 1h, fn: count)
+		|> group(columns: ["hour"])
+		|> sum()`},
+			{id: 18, text: `from(bucket: "benchmark")
+		|> range(start: -30y)
+		|> filter(fn: (r) => r._measurement == "user_events" and r._field == "rssi")
+		|> aggregateWindow(every: 1d, fn: stddev)
+		|> limit(n: 30)`},
+			{id: 19, text: `from(bucket: "benchmark")
+		|> range(start: -30y)
+		|> filter(fn: (r) => r._measurement == "user_events")
+		|> aggregateWindow(every: 1h, fn: count)
+		|> top(n: 5)`},
+			{id: 20, text: `from(bucket: "benchmark")
+		|> range(start: -30y)
+		|> filter(fn: (r) => r._measurement == "user_events" and r._field == "rssi")
+		|> group(columns: ["user_id"])
+		|> sort(columns: ["_time"])
+		|> map(fn: (r) => ({r with _value: int(v: r._time), gap: int(v: r._time)}))
+		|> difference(columns: ["gap"], keepFirst: true)
+		|> map(fn: (r) => ({r with new_session: if exists r.gap and r.gap > int(v: 30m) then 1 else 0}))
+		|> cumulativeSum(columns: ["new_session"])
+		|> group(columns: ["user_id", "new_session"])
+		|> spread()
+		|> group(columns: ["user_id"])
+		|> reduce(identity: {sessions: 0, _value: 0}, fn: (r, accumulator) => ({sessions: accumulator.sessions + 1, _value: accumulator._value + r._value}))
+		|> group()
+		|> top(n: 10)`},
+			{id: 21, text: `from(bucket: "benchmark")
+		|> range(start: %s, stop: %s)
+		|> filter(fn: (r) => r._measurement == "user_events" and r._field == "rssi")
+		|> group(columns: ["user_id"])
+		|> timedMovingAverage(every: 1m, period: 15m)
+		|> mean()`, args: dayFromMiddle},
+			{id: 22, text: `from(bucket: "benchmark")
+		|> range(start: %s, stop: %s)
+		|> filter(fn: (r) => r._measurement == "user_events" and r._field == "rssi")
+		|> group(columns: ["ssid"])
+		|> aggregateWindow(every: 1h, fn: count, createEmpty: true)`, args: dayFromMiddle},
+			{id: 23, text: `from(bucket: "benchmark")
+		|> range(start: %s, stop: %s)
+		|> filter(fn: (r) => r._measurement == "user_events" and r._field == "rssi")
+		|> group(columns: ["ssid"])
+		|> aggregateWindow(every: 1h, fn: mean, createEmpty: true)
+		|> fill(usePrevious: true)`, args: dayFromMiddle},
+			{id: 24, text: `from(bucket: "benchmark")
+		|> range(start: %s, stop: %s)
+		|> filter(fn: (r) => r._measurement == "user_events" and r._field == "rssi")
+		|> group(columns: ["ssid"])
+		|> window(every: 15m)
+		|> distinct(column: "user_id")
+		|> count()`, args: dayFromMiddle},
+		},
+		capabilities: &Capabilities{
+			Percentiles:     true,
+			WindowFunctions: true,
+			GapFill:         true,
+			ApproxDistinct:  false,
+			Updates:         false,
+			Deletes:         true,
+			Note:            "a point is overwritten by writing it again",
+		},
+		bucketQuery: func(w bucketWidth) string {
+			return fmt.Sprintf(`from(bucket: "benchmark")
+		|> range(start: -30y)
+		|> filter(fn: (r) => r._measurement == "user_events" and r._field == "rssi")
+		|> group(columns: ["ssid"])
+		|> window(every: %s)
+		|> distinct(column: "user_id")
+		|> count()`, w.name)
+		},
+		// Most Flux queries time out or are rejected on the full data set; the
+		// benchmark records them as -1 rather than aborting. Likewise a chunk
+		// whose writes are still rejected after the retries is marked failed.
+		lenientQueries:   true,
+		lenientIngestion: true,
+		// The rollup scripts are the bodies a downsampling task would run,
+		// executed once over the whole range.
+		downsampling: rollupDialect{
+			create: []string{
+				`from(bucket: "benchmark")
+			|> range(start: -30y)
+			|> filter(fn: (r) => r._measurement == "user_events" and r._field == "rssi")
+			|> group(columns: ["ssid"])
+			|> aggregateWindow(every: 1h, fn: count, createEmpty: false)
+			|> set(key: "_measurement", value: "user_events_hourly")
+			|> set(key: "_field", value: "readings")
+			|> to(bucket: "benchmark")`,
+				`from(bucket: "benchmark")
+			|> range(start: -30y)
+			|> filter(fn: (r) => r._measurement == "user_events" and r._field == "rssi")
+			|> group(columns: ["ssid"])
+			|> aggregateWindow(every: 1h, fn: sum, createEmpty: false)
+			|> set(key: "_measurement", value: "user_events_hourly")
+			|> set(key: "_field", value: "rssi_sum")
+			|> to(bucket: "benchmark")`,
+			},
+			queries: []querySpec{
+				{id: 2, text: `from(bucket: "benchmark")
+		|> range(start: -30y)
+		|> filter(fn: (r) => r._measurement == "user_events_hourly" and r._field == "readings")
+		|> group()
+		|> sum()`},
+				{id: 8, text: `from(bucket: "benchmark")
+		|> range(start: %s, stop: %s)
+		|> filter(fn: (r) => r._measurement == "user_events_hourly" and r._field == "readings")
+		|> group()
+		|> aggregateWindow(every: 1h, fn: sum)`, args: dayFromMiddle},
+				{id: 12, text: `from(bucket: "benchmark")
+		|> range(start: -30y)
+		|> filter(fn: (r) => r._measurement == "user_events_hourly" and r._field == "readings")
+		|> group(columns: ["ssid"])
+		|> sum()
+		|> group()
+		|> top(n: 10)`},
+				{id: 19, text: `from(bucket: "benchmark")
+		|> range(start: -30y)
+		|> filter(fn: (r) => r._measurement == "user_events_hourly" and r._field == "readings")
+		|> group()
+		|> aggregateWindow(every: 1h, fn: sum)
+		|> top(n: 5)`},
+			},
+		},
+		// Every building is a measurement of its own, created by its first
+		// write; the spanning queries select them by name prefix.
+		buildings: buildingDialect{
+			table: func(name string) []string { return nil },
+			queries: func(tables []string) []querySpec {
+				return []querySpec{
+					{id: 1, text: `from(bucket: "benchmark")
+		|> range(start: -30y)
+		|> filter(fn: (r) => r._measurement =~ /^user_events_/ and r._field == "rssi")
+		|> group()
+		|> count()`},
+					{id: 2, text: `from(bucket: "benchmark")
+		|> range(start: -30y)
+		|> filter(fn: (r) => r._measurement =~ /^user_events_/ and r._field == "rssi")
+		|> group()
+		|> mean()`},
+					{id: 3, text: `from(bucket: "benchmark")
+		|> range(start: %s, stop: %s)
+		|> filter(fn: (r) => r._measurement =~ /^user_events_/ and r._field == "rssi")
+		|> group()
+		|> aggregateWindow(every: 1h, fn: count)`, args: dayFromMiddle},
+					{id: 4, text: fmt.Sprintf(`from(bucket: "benchmark")
+		|> range(start: -30y)
+		|> filter(fn: (r) => r._measurement == %q and r._field == "rssi")
+		|> group()
+		|> count()`, tables[0])},
+					{id: 5, text: fmt.Sprintf(`from(bucket: "benchmark")
+		|> range(start: %%s, stop: %%s)
+		|> filter(fn: (r) => r._measurement == %q and r._field == "rssi")
+		|> group()
+		|> aggregateWindow(every: 1h, fn: count)`, tables[0]), args: dayFromMiddle},
+				}
+			},
+		},
+		// Extra text values are tags, numbers fields.
+		extraColumns: extraColumnDialect{
+			groupBy: func(column string) string {
+				return fmt.Sprintf(`data = from(bucket: "benchmark")
+		|> range(start: -30y)
+		|> filter(fn: (r) => r._measurement == "user_events" and r._field == "rssi")
+		|> group(columns: [%q])
+
+		data |> count() |> yield(name: "records")
+		data |> unique(column: "user_id") |> count() |> yield(name: "users")
+		data |> mean() |> yield(name: "rssi")`, column)
+			},
+		},
+		// The building and floor are tags and the coordinates fields, which the
+		// spatial queries pivot into columns.
+		locations: locationDialect{
+			queries: func(area geoArea) []querySpec {
+				located := `from(bucket: "benchmark")
+		|> range(start: -30y)
+		|> filter(fn: (r) => r._measurement == "` + locatedTable + `" and (r._field == "latitude" or r._field == "longitude"))
+		|> pivot(rowKey: ["_time"], columnKey: ["_field"], valueColumn: "_value")`
+				return []querySpec{
+					{id: 1, text: `from(bucket: "benchmark")
+		|> range(start: -30y)
+		|> filter(fn: (r) => r._measurement == "` + locatedTable + `" and r._field == "rssi")
+		|> group(columns: ["building"])
+		|> count()
+		|> group()
+		|> sort(columns: ["_value"], desc: true)`},
+					{id: 2, text: `from(bucket: "benchmark")
+		|> range(start: %s, stop: %s)
+		|> filter(fn: (r) => r._measurement == "` + locatedTable + `" and r._field == "rssi")
+		|> group(columns: ["building"])
+		|> window(every: 1h)
+		|> unique(column: "user_id")
+		|> count()`, args: dayFromMiddle},
+					{id: 3, text: `from(bucket: "benchmark")
+		|> range(start: -30y)
+		|> filter(fn: (r) => r._measurement == "` + locatedTable + `" and r._field == "rssi")
+		|> group(columns: ["building", "floor_number"])
+		|> reduce(fn: (r, accumulator) => ({count: accumulator.count + 1, sum: accumulator.sum + r._value}), identity: {count: 0, sum: 0.0})
+		|> map(fn: (r) => ({r with mean: r.sum / float(v: r.count)}))`},
+					{id: 4, text: fmt.Sprintf(`inside = %s
+		|> filter(fn: (r) => r.latitude >= %f and r.latitude <= %f and r.longitude >= %f and r.longitude <= %f)
+		|> group()
+
+		inside |> count(column: "latitude") |> yield(name: "records")
+		inside |> unique(column: "user_id") |> count(column: "user_id") |> yield(name: "users")`,
+						located, area.minLat, area.maxLat, area.minLon, area.maxLon)},
+					{id: 5, text: `import "math"
+
+		` + located + `
+		|> map(fn: (r) => ({r with cell_lat: math.floor(x: r.latitude * 1000.0), cell_lon: math.floor(x: r.longitude * 1000.0)}))
+		|> group(columns: ["cell_lat", "cell_lon"])
+		|> count(column: "latitude")
+		|> group()
+		|> top(n: 20, columns: ["latitude"])`},
+				}
+			},
+		},
+		hourOfDayQuery: `import "date"
+
+		from(bucket: "benchmark")
+		|> range(start: -30y)
+		|> filter(fn: (r) => r._measurement == "user_events" and r._field == "rssi")
+		|> map(fn: (r) => ({r with hour: date.hour(t: r._time)}))
+		|> group(columns: ["hour"])
+		|> count()`,
+		reconciliation: reconciliationDialect{
+			countQuery: `from(bucket: "benchmark")
+		|> range(start: -30y)
+		|> filter(fn: (r) => r._measurement == "user_events" and r._field == "rssi")
+		|> count()
+		|> group()
+		|> sum()`,
+			duplicates: "points with the same measurement, tags and timestamp overwrite each other",
+		},
+		// async writes through the non-blocking write API, which batches
+		// in the background and reports failed requests on a channel.
+		ingestMethods: []string{"blocking", "async"},
+		// Compactions cannot be triggered; the storage engine compacts the
+		// shards in the background after writes.
+		maintenance: []maintenanceOp{
+			{name: "compaction-wait", wait: waitForInfluxCompaction},
+		},
+		retention: true,
+		export:    true,
+		fidelity:  true,
+		container: containerSpec{
+			image: "influxdb:2.7.11",
+			ports: []string{"8086:8086"},
+			env: []string{
+				"DOCKER_INFLUXDB_INIT_MODE=setup",
+				"DOCKER_INFLUXDB_INIT_USERNAME=admin",
+				"DOCKER_INFLUXDB_INIT_PASSWORD=adminpass",
+				"DOCKER_INFLUXDB_INIT_ORG=" + influxOrg,
+				"DOCKER_INFLUXDB_INIT_BUCKET=benchmark",
+				"DOCKER_INFLUXDB_INIT_ADMIN_TOKEN=" + influxToken,
+			},
+			// The WAL is fsynced on every write by default; a delay batches
+			// the fsyncs of the writes within it.
+			durabilityEnv: map[string][]string{
+				durabilityRelaxed: {"INFLUXD_STORAGE_WAL_FSYNC_DELAY=100ms"},
+				durabilityStrict:  {"INFLUXD_STORAGE_WAL_FSYNC_DELAY=0s"},
+			},
+		},
+		timeToInsight: timeToInsightDialect{
+			rollup: []string{
+				`from(bucket: "benchmark")
+			|> range(start: -30y)
+			|> filter(fn: (r) => r._measurement == "user_events" and r._field == "rssi")
+			|> group(columns: ["ssid"])
+			|> aggregateWindow(every: 1h, fn: count, createEmpty: false)
+			|> set(key: "_measurement", value: "user_events_hourly")
+			|> set(key: "_field", value: "readings")
+			|> to(bucket: "benchmark")`,
+				`from(bucket: "benchmark")
+			|> range(start: -30y)
+			|> filter(fn: (r) => r._measurement == "user_events" and r._field == "rssi")
+			|> group(columns: ["ssid"])
+			|> aggregateWindow(every: 1h, fn: mean, createEmpty: false)
+			|> set(key: "_measurement", value: "user_events_hourly")
+			|> set(key: "_field", value: "avg_rssi")
+			|> to(bucket: "benchmark")`,
+			},
+			dashboard: []string{
+				`from(bucket: "benchmark")
+			|> range(start: -30y)
+			|> filter(fn: (r) => r._measurement == "user_events_hourly" and r._field == "readings")
+			|> group(columns: ["ssid"])
+			|> sum()
+			|> group()
+			|> top(n: 10)`,
+				`from(bucket: "benchmark")
+			|> range(start: -30y)
+			|> filter(fn: (r) => r._measurement == "user_events_hourly" and r._field == "readings")
+			|> group()
+			|> aggregateWindow(every: 1h, fn: sum, createEmpty: false)`,
+				`from(bucket: "benchmark")
+			|> range(start: -30y)
+			|> filter(fn: (r) => r._measurement == "user_events_hourly" and r._field == "avg_rssi")
+			|> group(columns: ["ssid"])
+			|> mean()
+			|> group()
+			|> bottom(n: 10)`,
+				`from(bucket: "benchmark")
+			|> range(start: -30y)
+			|> filter(fn: (r) => r._measurement == "user_events_hourly" and r._field == "readings")
+			|> group()
+			|> aggregateWindow(every: 1h, fn: sum, createEmpty: false)
+			|> top(n: 1)`,
+			},
+		},
+	})
+}
+
+// Flux has no single query for both bounds, so query 1 is timed as the sum of
+// a min and a max query.
+const influxMinTimeQuery = `from(bucket: "benchmark")
+		|> range(start: -30y)
+		|> filter(fn: (r) => r._measurement == "user_events")
+		|> keep(columns: ["_time"])
+		|> limit(n: 1)
+		|> min(column: "_time")`
+
+const influxMaxTimeQuery = `from(bucket: "benchmark")
+		|> range(start: -30y)
+		|> filter(fn: (r) => r._measurement == "user_events")
+		|> keep(columns: ["_time"])
+		|> limit(n: 1)
+		|> max(column: "_time")`
+
+// defaultInfluxBatchSize is the number of points per write request unless
+// -write-batch-size is given.
+const defaultInfluxBatchSize = 5000
+
+type influxBackend struct {
+	client    influxdb2.Client
+	writeAPI  api.WriteAPIBlocking
+	queryAPI  api.QueryAPI
+	batchSize int
+
+	// method is the ingest method; the async write API is opened on the
+	// first async write.
+	method      string
+	asyncClient influxdb2.Client
+	asyncAPI    api.WriteAPI
+	asyncSync   chan chan struct{}
+	asyncTrack  writeTracker
+}
+
+// influxToken and influxOrg are set up by the container; -db-token and
+// -influx-org replace them for other servers.
+const (
+	influxToken = "mytoken123"
+	influxOrg   = "myorg"
+)
+
+func influxOrgName() string {
+	if dbCredentials.Org != "" {
+		return dbCredentials.Org
+	}
+	return influxOrg
+}
+
+func newInfluxClient(connStr string) (influxdb2.Client, error) {
+	options, err := influxOptions()
+	if err != nil {
+		return nil, err
+	}
+	return influxdb2.NewClientWithOptions(connStr, influxTokenValue(), options), nil
+}
+
+func influxTokenValue() string {
+	if dbCredentials.Token != "" {
+		return dbCredentials.Token
+	}
+	return influxToken
+}
+
+func influxOptions() (*influxdb2.Options, error) {
+	options := influxdb2.DefaultOptions()
+	tlsConfig, err := tlsSettings.config("")
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		options.SetTLSConfig(tlsConfig)
+	}
+	return options, nil
+}
+
+func newInfluxBackend(connStr string) (*influxBackend, error) {
+	client, err := newInfluxClient(connStr)
+	if err != nil {
+		return nil, err
+	}
+	return &influxBackend{
+		client:    client,
+		writeAPI:  client.WriteAPIBlocking(influxOrgName(), "benchmark"),
+		queryAPI:  client.QueryAPI(influxOrgName()),
+		batchSize: defaultInfluxBatchSize,
+	}, nil
+}
+
+func (b *influxBackend) setWriteBatchSize(rows int) {
+	b.batchSize = rows
+}
+
+func (b *influxBackend) setIngestMethod(method string) {
+	b.method = method
+}
+
+// createSchema is a no-op: the bucket is provisioned when the server is set up.
+func (b *influxBackend) createSchema(ctx context.Context) error {
+	return nil
+}
+
+// serverVersion reads the version from the health check.
+func (b *influxBackend) serverVersion(ctx context.Context) (string, error) {
+	health, err := b.client.Health(ctx)
+	if err != nil {
+		return "", err
+	}
+	if health.Version == nil {
+		return "", fmt.Errorf("the health check reports no version")
+	}
+	return "InfluxDB " + *health.Version, nil
+}
+
+// influxSettings are the storage engine and query settings of the
+// configuration that decide the ingestion and query throughput.
+var influxSettings = []string{
+	"storage-cache-max-memory-size",
+	"storage-cache-snapshot-memory-size",
+	"storage-cache-snapshot-write-cold-duration",
+	"storage-compact-throughput-burst",
+	"storage-max-concurrent-compactions",
+	"storage-wal-fsync-delay",
+	"query-concurrency",
+	"query-queue-size",
+	"query-memory-bytes",
+}
+
+// serverSettings reads the settings from /api/v2/config, which needs an
+// operator token.
+func (b *influxBackend) serverSettings(ctx context.Context) (map[string]string, error) {
+	config, err := b.client.APIClient().GetConfig(ctx, &domain.GetConfigParams{})
+	if err != nil {
+		return nil, err
+	}
+	settings := map[string]string{}
+	if config.Config == nil {
+		return settings, nil
+	}
+	for _, name := range influxSettings {
+		if value, ok := (*config.Config)[name]; ok {
+			settings[name] = fmt.Sprint(value)
+		}
+	}
+	return settings, nil
+}
+
+// ingest writes the readings with the blocking write API, one request per
+// batchSize points, so that the measured time covers the acknowledged writes
+// and a rejected request fails the chunk. The async ingest method hands them
+// to the non-blocking write API instead, see ingestAsync.
+func (b *influxBackend) ingest(ctx context.Context, readings []Reading) error {
+	return b.ingestInto(ctx, "user_events", readings)
+}
+
+// ingestInto writes the readings to the given measurement.
+func (b *influxBackend) ingestInto(ctx context.Context, measurement string, readings []Reading) error {
+	if b.method == "async" {
+		return b.ingestAsync(measurement, readings)
+	}
+	points := make([]*write.Point, 0, b.batchSize)
+	for _, reading := range readings {
+		points = append(points, influxPoint(measurement, reading))
+		if len(points) == b.batchSize {
+			if err := b.writeAPI.WritePoint(ctx, points...); err != nil {
+				return err
+			}
+			points = points[:0]
+		}
+	}
+
+	if len(points) == 0 {
+		return nil
+	}
+	return b.writeAPI.WritePoint(ctx, points...)
+}
+
+func influxPoint(measurement string, reading Reading) *write.Point {
+	p := influxdb2.NewPointWithMeasurement(measurement).
+		AddTag("user_id", reading.UserId).
+		AddTag("ssid", reading.Connection.Ssid).
+		AddField("rssi", reading.Connection.Rssi).
+		SetTime(readingTime(reading.LastUpdatedTime))
+	// Unknown extra values and empty tags are left out.
+	for j, value := range extraValues(&reading) {
+		switch {
+		case value == nil:
+		case extraColumns[j].isTag():
+			if tag := extraTag(value); tag != "" {
+				p.AddTag(extraColumns[j].Name, tag)
+			}
+		default:
+			p.AddField(extraColumns[j].Name, value)
+		}
+	}
+	return p
+}
+
+// ingestAsync hands the readings to the non-blocking write API, which sends
+// them in batches of batchSize points from the background; a full buffer
+// blocks the writer. The write API is flushed before returning, so the
+// measured time still covers the requests, but their failures only reach
+// the error channel: the points are counted as rejected rather than failing
+// the chunk.
+func (b *influxBackend) ingestAsync(measurement string, readings []Reading) error {
+	if b.asyncAPI == nil {
+		if err := b.openAsyncAPI(); err != nil {
+			return err
+		}
+	}
+	b.asyncTrack.sending(len(readings))
+	for _, reading := range readings {
+		b.asyncAPI.WritePoint(influxPoint(measurement, reading))
+	}
+	b.asyncAPI.Flush()
+	return nil
+}
+
+// openAsyncAPI opens the non-blocking write API on a client of its own and
+// subscribes to its errors. A request that failed with a retryable status is
+// discarded by the callback, which counts its points, instead of being queued
+// for retries that may be evicted without a trace. The error channel of the
+// other failures carries no batch, so the client counts the points of the
+// requests the server refused as it sends them.
+func (b *influxBackend) openAsyncAPI() error {
+	options, err := influxOptions()
+	if err != nil {
+		return err
+	}
+	options.SetBatchSize(uint(b.batchSize))
+	options.HTTPOptions().SetHTTPDoer(refusedPoints{client: options.HTTPClient(), track: &b.asyncTrack})
+	b.asyncClient = influxdb2.NewClientWithOptions(b.client.ServerURL(), influxTokenValue(), options)
+	b.asyncAPI = b.asyncClient.WriteAPI(influxOrgName(), "benchmark")
+	b.asyncAPI.SetWriteFailedCallback(func(batch string, _ ihttp.Error, _ uint) bool {
+		b.asyncTrack.rejected.Add(int64(strings.Count(batch, "\n")))
+		return false
+	})
+	errs := b.asyncAPI.Errors()
+	b.asyncSync = make(chan chan struct{})
+	counted := func(err error) {
+		var httpErr *ihttp.Error
+		switch {
+		case !errors.As(err, &httpErr):
+			// A point that could not be encoded.
+			b.asyncTrack.reject(1, err)
+		default:
+			// The callback or refusedPoints counted the points.
+			b.asyncTrack.reject(0, err)
+		}
+	}
+	go func() {
+		for {
+			select {
+			case err, ok := <-errs:
+				if !ok {
+					return
+				}
+				counted(err)
+			case done := <-b.asyncSync:
+				// The write API was flushed, so its last errors are
+				// buffered in the channel.
+				for drained := false; !drained; {
+					select {
+					case err, ok := <-errs:
+						if ok {
+							counted(err)
+						}
+						drained = !ok
+					default:
+						drained = true
+					}
+				}
+				close(done)
+			}
+		}
+	}()
+	return nil
+}
+
+// refusedPoints sends the requests of the async write API and counts the
+// points of a write the server refused with a client error, which is not
+// retried. A retryable status is left to the write failed callback, and the
+// partial writes the client only logs are left to the reconciliation.
+type refusedPoints struct {
+	client *http.Client
+	track  *writeTracker
+}
+
+// influxIgnoredWriteErrors are the messages of the write errors the client
+// logs instead of reporting them on the error channel.
+var influxIgnoredWriteErrors = []string{
+	"hinted handoff queue not empty", "partial write", "points beyond retention policy", "unable to parse",
+}
+
+func (d refusedPoints) Do(req *http.Request) (*http.Response, error) {
+	resp, err := d.client.Do(req)
+	if err != nil || resp.StatusCode < http.StatusBadRequest || resp.StatusCode >= http.StatusTooManyRequests ||
+		!strings.HasSuffix(req.URL.Path, "/api/v2/write") || req.GetBody == nil {
+		return resp, err
+	}
+	// The client decodes the error from the body, so it is read and put back.
+	message, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(message))
+	for _, ignored := range influxIgnoredWriteErrors {
+		if strings.Contains(string(message), ignored) {
+			return resp, nil
+		}
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return resp, nil
+	}
+	defer body.Close()
+	batch, _ := io.ReadAll(body)
+	d.track.rejected.Add(int64(strings.Count(string(batch), "\n")))
+	return resp, nil
+}
+
+func waitForInfluxCompaction(ctx context.Context, b backend) error {
+	influx, ok := b.(*influxBackend)
+	if !ok {
+		return fmt.Errorf("not an influxdb backend")
+	}
+	return waitUntilIdle(ctx, "compactions", influx.pendingCompactions)
+}
+
+// pendingCompactions returns the number of active and queued compactions of
+// the storage engine from the Prometheus metrics of the server.
+func (b *influxBackend) pendingCompactions(ctx context.Context) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(b.client.ServerURL(), "/")+"/metrics", nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := b.client.Options().HTTPClient().Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("reading the metrics: %s", resp.Status)
+	}
+	metrics, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	var pending float64
+	for _, line := range strings.Split(string(metrics), "\n") {
+		if !strings.HasPrefix(line, "storage_compactions_active") && !strings.HasPrefix(line, "storage_compactions_queued") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if value, err := strconv.ParseFloat(fields[len(fields)-1], 64); err == nil {
+			pending += value
+		}
+	}
+	return int64(pending), nil
+}
+
+func (b *influxBackend) asyncWrites() *AsyncWriteResult {
+	if b.asyncAPI == nil {
+		return nil
+	}
+	b.asyncAPI.Flush()
+	done := make(chan struct{})
+	b.asyncSync <- done
+	<-done
+	return b.asyncTrack.result()
+}
+
+// ingestLocated writes the readings to user_events_located with the building
+// and floor as tags and the coordinates, when known, as fields.
+func (b *influxBackend) ingestLocated(ctx context.Context, readings []Reading) error {
+	points := make([]*write.Point, 0, b.batchSize)
+	for i := range readings {
+		reading := &readings[i]
+		building, floor, lat, lon := locationValues(reading)
+		p := influxdb2.NewPointWithMeasurement(locatedTable).
+			AddTag("user_id", reading.UserId).
+			AddTag("ssid", reading.Connection.Ssid).
+			AddTag("building", building).
+			AddTag("floor_number", strconv.Itoa(floor)).
+			AddField("rssi", reading.Connection.Rssi).
+			SetTime(readingTime(reading.LastUpdatedTime))
+		if lat != nil {
+			p.AddField("latitude", lat).AddField("longitude", lon)
+		}
+
+		points = append(points, p)
+		if len(points) == b.batchSize {
+			if err := b.writeAPI.WritePoint(ctx, points...); err != nil {
+				return err
+			}
+			points = points[:0]
+		}
+	}
+
+	if len(points) == 0 {
+		return nil
+	}
+	return b.writeAPI.WritePoint(ctx, points...)
+}
+
+func (b *influxBackend) expireBefore(ctx context.Context, cutoff time.Time) error {
+	return b.client.DeleteAPI().DeleteWithName(ctx, influxOrgName(), "benchmark", time.Unix(0, 0), cutoff, `_measurement="user_events"`)
+}
+
+// dropTable deletes every point of the measurement; the bucket itself stays.
+func (b *influxBackend) dropTable(ctx context.Context, measurement string) error {
+	return b.client.DeleteAPI().DeleteWithName(ctx, influxOrgName(), "benchmark", time.Unix(0, 0), time.Now(), fmt.Sprintf(`_measurement="%s"`, measurement))
+}
+
+// exec runs a Flux script for its side effects, e.g. a to() rollup.
+func (b *influxBackend) exec(ctx context.Context, stmt string) error {
+	return b.query(ctx, stmt)
+}
+
+// formatFluxQuery substitutes the arguments into the query text with
+// fmt.Sprintf; time values are rendered as RFC3339.
+func formatFluxQuery(q string, args []any) string {
+	if len(args) == 0 {
+		return q
+	}
+	formatted := make([]any, len(args))
+	for i, arg := range args {
+		if t, ok := arg.(time.Time); ok {
+			formatted[i] = t.Format(time.RFC3339)
+		} else {
+			formatted[i] = arg
+		}
+	}
+	return fmt.Sprintf(q, formatted...)
+}
+
+// query runs a Flux query.
+func (b *influxBackend) query(ctx context.Context, q string, args ...any) error {
+	result, err := b.queryAPI.Query(ctx, formatFluxQuery(q, args))
+	if err != nil {
+		return err
+	}
+	for result.Next() {
+		// Just consume the result
+	}
+	result.Close()
+	return result.Err()
+}
+
+// scanRows hands over the columns of every record, in the order of their
+// names, without the result and table annotations.
+func (b *influxBackend) scanRows(ctx context.Context, visit func(values []any), q string, args ...any) error {
+	result, err := b.queryAPI.Query(ctx, formatFluxQuery(q, args))
+	if err != nil {
+		return err
+	}
+	defer result.Close()
+	for result.Next() {
+		record := result.Record().Values()
+		names := make([]string, 0, len(record))
+		for name := range record {
+			if name != "result" && name != "table" {
+				names = append(names, name)
+			}
+		}
+		slices.Sort(names)
+		values := make([]any, 0, 2*len(names))
+		for _, name := range names {
+			values = append(values, name, record[name])
+		}
+		visit(values)
+	}
+	return result.Err()
+}
+
+// timeBounds ignores q and runs the min and the max query one after another.
+func (b *influxBackend) timeBounds(ctx context.Context, q string) (time.Time, time.Time, error) {
+	minTime, err := b.firstRecordTime(ctx, influxMinTimeQuery)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	maxTime, err := b.firstRecordTime(ctx, influxMaxTimeQuery)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return minTime, maxTime, nil
+}
+
+func (b *influxBackend) firstRecordTime(ctx context.Context, q string) (time.Time, error) {
+	result, err := b.queryAPI.Query(ctx, q)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var t time.Time
+	for result.Next() {
+		t = result.Record().Time()
+	}
+	result.Close()
+	return t, result.Err()
+}
+
+// count sums the values of the records of q, e.g. the per-series counts of
+// a count() without a group() and sum().
+func (b *influxBackend) count(ctx context.Context, q string) (int64, error) {
+	result, err := b.queryAPI.Query(ctx, q)
+	if err != nil {
+		return 0, err
+	}
+	defer result.Close()
+
+	var n int64
+	for result.Next() {
+		value, _ := result.Record().Value().(int64)
+		n += value
+	}
+	return n, result.Err()
+}
+
+func (b *influxBackend) countByHour(ctx context.Context, q string) (map[int]int64, error) {
+	result, err := b.queryAPI.Query(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+
+	counts := map[int]int64{}
+	for result.Next() {
+		record := result.Record()
+		hour, _ := record.ValueByKey("hour").(int64)
+		count, _ := record.Value().(int64)
+		counts[int(hour)] += count
+	}
+	return counts, result.Err()
+}
+
+// export streams every point of user_events through the query API and writes
+// it as a CSV line. The backup API copies shard files and needs an operator
+// token, so a query export is what a bucket owner can run.
+func (b *influxBackend) export(ctx context.Context, w io.Writer) error {
+	result, err := b.queryAPI.Query(ctx, `from(bucket: "benchmark")
+		|> range(start: -30y)
+		|> filter(fn: (r) => r._measurement == "user_events")`)
+	if err != nil {
+		return err
+	}
+	defer result.Close()
+
+	out := csv.NewWriter(w)
+	for result.Next() {
+		record := result.Record()
+		err := out.Write([]string{
+			record.Time().Format(time.RFC3339),
+			fmt.Sprint(record.ValueByKey("user_id")),
+			fmt.Sprint(record.ValueByKey("ssid")),
+			fmt.Sprint(record.Value()),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if err := result.Err(); err != nil {
+		return err
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func (b *influxBackend) close() {
+	if b.asyncClient != nil {
+		b.asyncClient.Close()
+	}
+	b.client.Close()
+}
+
+func (b *influxBackend) lookupReading(ctx context.Context, userId string, at time.Time) ([]storedReading, error) {
+	result, err := b.queryAPI.Query(ctx, fmt.Sprintf(`from(bucket: "benchmark")
+		|> range(start: %s, stop: %s)
+		|> filter(fn: (r) => r._measurement == "user_events" and r._field == "rssi" and r.user_id == %q)`,
+		at.UTC().Format(time.RFC3339), at.Add(time.Second).UTC().Format(time.RFC3339), userId))
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+
+	var stored []storedReading
+	for result.Next() {
+		record := result.Record()
+		rssi, _ := record.Value().(float64)
+		ssid, _ := record.ValueByKey("ssid").(string)
+		stored = append(stored, storedReading{Rssi: rssi, Ssid: ssid})
+	}
+	return stored, result.Err()
+}
+
+// influxProbeReader reads over the HTTP query API, which is independent of the
+// write path.
+type influxProbeReader struct {
+	b *influxBackend
+}
+
+func (b *influxBackend) newProbeReader(ctx context.Context) (probeReader, error) {
+	return &influxProbeReader{b: b}, nil
+}
+
+func (r *influxProbeReader) visible(ctx context.Context, userId string) (bool, error) {
+	result, err := r.b.queryAPI.Query(ctx, fmt.Sprintf(`from(bucket: "benchmark")
+		|> range(start: -30y)
+		|> filter(fn: (r) => r._measurement == "user_events" and r.user_id == %q)
+		|> count()`, userId))
+	if err != nil {
+		return false, err
+	}
+	found := false
+	for result.Next() {
+		found = true
+	}
+	result.Close()
+	return found, result.Err()
+}
+
+func (r *influxProbeReader) release() {}
@@ -0,0 +1,544 @@
+//go:build !noinfluxdb1
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	registerBackend(backendInfo{
+		name:        "influxdb1",
+		description: "InfluxDB 1.x (line protocol ingestion, InfluxQL queries over HTTP)",
+		open: func(connStr string) (backend, error) {
+			return newInflux1Backend(connStr)
+		},
+		ping: func(ctx context.Context, connStr string) error {
+			client, err := newInflux1Client(connStr)
+			if err != nil {
+				return err
+			}
+			return client.ping(ctx)
+		},
+		isTransient: func(err error) bool {
+			var httpErr *influx1Error
+			if errors.As(err, &httpErr) {
+				switch httpErr.StatusCode {
+				case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+					return true
+				}
+			}
+			return false
+		},
+		schemaProbe: "SHOW RETENTION POLICIES ON " + influx1Database,
+		// InfluxQL cannot group by hour of day, has no variance and cannot
+		// subtract timestamps, so queries 17, 18 and 20 are not implemented.
+		queries: []querySpec{
+			{id: 1, text: "SELECT FIRST(rssi) FROM user_events; SELECT LAST(rssi) FROM user_events"},
+			{id: 2, text: "SELECT COUNT(rssi) FROM user_events"},
+			{id: 3, text: `SHOW TAG VALUES EXACT CARDINALITY FROM user_events WITH KEY = "user_id"`},
+			{id: 4, text: "SELECT MEAN(rssi) FROM user_events"},
+			{id: 5, text: "SELECT COUNT(rssi) FROM user_events WHERE time < '%s'", args: atMiddle},
+			{id: 6, text: "SELECT COUNT(rssi) FROM user_events WHERE time > '%s'", args: atMiddle},
+			{id: 7, text: "SELECT COUNT(rssi) FROM user_events WHERE time >= '%s' AND time <= '%s'", args: aroundMiddle},
+			{id: 8, text: "SELECT COUNT(rssi) FROM user_events WHERE time >= '%s' AND time < '%s' GROUP BY time(1h)", args: dayFromMiddle},
+			{id: 9, text: "SELECT TOP(count, user_id, 10) FROM (SELECT COUNT(rssi) AS count FROM user_events GROUP BY user_id)"},
+			{id: 10, text: "SELECT COUNT(rssi) FROM user_events WHERE rssi > -50"},
+			{id: 11, text: "SELECT COUNT(rssi) FROM user_events WHERE rssi < -80"},
+			{id: 12, text: "SELECT TOP(count, ssid, 10) FROM (SELECT COUNT(rssi) AS count FROM user_events GROUP BY ssid)"},
+			{id: 13, text: "SELECT TOP(mean, user_id, 100), min, max FROM (SELECT MEAN(rssi) AS mean, MIN(rssi) AS min, MAX(rssi) AS max FROM user_events GROUP BY user_id)"},
+			{id: 14, text: "SELECT PERCENTILE(rssi, 25) AS q1, PERCENTILE(rssi, 50) AS median, PERCENTILE(rssi, 75) AS q3 FROM user_events"},
+			{id: 15, text: "SELECT COUNT(rssi) FROM user_events WHERE time >= '%s' AND time <= '%s'", args: firstHalf},
+			{id: 16, text: "SELECT COUNT(rssi) FROM user_events WHERE time >= '%s' AND time <= '%s'", args: secondHalf},
+			{id: 19, text: "SELECT TOP(count, 5) FROM (SELECT COUNT(rssi) AS count FROM user_events WHERE time >= '%s' AND time <= '%s' GROUP BY time(1h))", args: wholeRange},
+			{id: 21, text: "SELECT MOVING_AVERAGE(MEAN(rssi), 15) FROM user_events WHERE time >= '%s' AND time < '%s' GROUP BY time(1m), user_id", args: dayFromMiddle},
+			{id: 22, text: "SELECT COUNT(rssi) FROM user_events WHERE time >= '%s' AND time < '%s' GROUP BY time(1h), ssid fill(0)", args: dayFromMiddle},
+			{id: 23, text: "SELECT MEAN(rssi) FROM user_events WHERE time >= '%s' AND time < '%s' GROUP BY time(1h), ssid fill(previous)", args: dayFromMiddle},
+			{id: 24, text: "SELECT COUNT(count) FROM (SELECT COUNT(rssi) AS count FROM user_events WHERE time >= '%s' AND time < '%s' GROUP BY time(15m), ssid, user_id fill(none)) GROUP BY time(15m), ssid", args: dayFromMiddle},
+		},
+		capabilities: &Capabilities{
+			Percentiles:     true,
+			WindowFunctions: false,
+			GapFill:         true,
+			ApproxDistinct:  false,
+			Updates:         false,
+			Deletes:         true,
+			Note:            "InfluxQL has moving aggregates but no LAG to split sessions, no hour-of-day function and no variance aggregate; a point is overwritten by writing it again",
+		},
+		lenientQueries:   true,
+		lenientIngestion: true,
+		explainPrefix:    "EXPLAIN ANALYZE ",
+		// Extra text values are tags, numbers fields; the distinct users are
+		// counted by a second statement of the same request.
+		extraColumns: extraColumnDialect{
+			groupBy: func(column string) string {
+				return fmt.Sprintf(`SELECT COUNT(rssi), MEAN(rssi) FROM user_events GROUP BY "%[1]s"; SELECT COUNT(count) FROM (SELECT COUNT(rssi) AS count FROM user_events GROUP BY "%[1]s", user_id) GROUP BY "%[1]s"`, column)
+			},
+		},
+		reconciliation: reconciliationDialect{
+			countQuery: "SELECT COUNT(rssi) FROM user_events",
+			duplicates: "points with the same measurement, tags and timestamp overwrite each other",
+		},
+		retention: true,
+		export:    true,
+		fidelity:  true,
+		container: containerSpec{
+			image: "influxdb:1.8.10",
+			ports: []string{"8087:8086"},
+			env:   []string{"INFLUXDB_DB=" + influx1Database},
+			durabilityEnv: map[string][]string{
+				durabilityRelaxed: {"INFLUXDB_DATA_WAL_FSYNC_DELAY=100ms"},
+				durabilityStrict:  {"INFLUXDB_DATA_WAL_FSYNC_DELAY=0s"},
+			},
+		},
+	})
+}
+
+// influx1Database is created by createSchema; rows use the default retention
+// policy.
+const influx1Database = "benchmark"
+
+// influx1Error is a non-2xx answer of the HTTP API.
+type influx1Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *influx1Error) Error() string {
+	return fmt.Sprintf("influxdb: %d %s", e.StatusCode, e.Message)
+}
+
+// influx1Client talks to the 1.x HTTP API directly, which avoids a driver
+// dependency for the small part of it the benchmark needs.
+type influx1Client struct {
+	baseURL string
+	http    *http.Client
+}
+
+func newInflux1Client(baseURL string) (*influx1Client, error) {
+	client := &http.Client{}
+	tlsConfig, err := tlsSettings.config("")
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConfig
+		client.Transport = transport
+	}
+	return &influx1Client{baseURL: strings.TrimSuffix(baseURL, "/"), http: client}, nil
+}
+
+// send authenticates the request with the credential flags, as basic auth
+// with a user or as a token otherwise, and sends it.
+func (c *influx1Client) send(req *http.Request) (*http.Response, error) {
+	switch {
+	case dbCredentials.User != "":
+		req.SetBasicAuth(dbCredentials.User, dbCredentials.Password)
+	case dbCredentials.Token != "":
+		req.Header.Set("Authorization", "Token "+dbCredentials.Token)
+	}
+	return c.http.Do(req)
+}
+
+func (c *influx1Client) do(req *http.Request) ([]byte, error) {
+	resp, err := c.send(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, &influx1Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+	}
+	return body, nil
+}
+
+func (c *influx1Client) ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/ping", nil)
+	if err != nil {
+		return err
+	}
+	_, err = c.do(req)
+	return err
+}
+
+// version reads the server version from the header of the answer to a ping.
+func (c *influx1Client) version(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/ping", nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.send(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return "", &influx1Error{StatusCode: resp.StatusCode, Message: resp.Status}
+	}
+	return resp.Header.Get("X-Influxdb-Version"), nil
+}
+
+// export streams the answer of a chunked query to w as it arrives, without
+// decoding it.
+func (c *influx1Client) export(ctx context.Context, q string, w io.Writer) error {
+	form := url.Values{"db": {influx1Database}, "q": {q}, "chunked": {"true"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/query", strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/csv")
+
+	resp, err := c.send(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(resp.Body)
+		return &influx1Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+func (c *influx1Client) write(ctx context.Context, lines []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/write?precision=s&db="+influx1Database, bytes.NewReader(lines))
+	if err != nil {
+		return err
+	}
+	_, err = c.do(req)
+	return err
+}
+
+type influx1Series struct {
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"`
+	Values  [][]any  `json:"values"`
+}
+
+type influx1Result struct {
+	Series []influx1Series `json:"series"`
+	Error  string          `json:"error"`
+}
+
+// query runs one or more ';'-separated InfluxQL statements and returns one
+// result per statement. Statement errors are reported with status 200, so they
+// are turned into errors here.
+func (c *influx1Client) query(ctx context.Context, q string) ([]influx1Result, error) {
+	form := url.Values{"db": {influx1Database}, "q": {q}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/query", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	body, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	var decoded struct {
+		Results []influx1Result `json:"results"`
+		Error   string          `json:"error"`
+	}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		return nil, err
+	}
+	if decoded.Error != "" {
+		return nil, fmt.Errorf("influxdb: %s", decoded.Error)
+	}
+	for _, result := range decoded.Results {
+		if result.Error != "" {
+			return nil, fmt.Errorf("influxdb: %s", result.Error)
+		}
+	}
+	return decoded.Results, nil
+}
+
+type influx1Backend struct {
+	client    *influx1Client
+	batchSize int
+}
+
+func newInflux1Backend(connStr string) (*influx1Backend, error) {
+	client, err := newInflux1Client(connStr)
+	if err != nil {
+		return nil, err
+	}
+	return &influx1Backend{client: client, batchSize: defaultInflux1BatchSize}, nil
+}
+
+// defaultInflux1BatchSize is the number of points per write request unless
+// -write-batch-size is given; 5000 is the batch size InfluxDB 1.x recommends.
+const defaultInflux1BatchSize = 5000
+
+func (b *influx1Backend) setWriteBatchSize(rows int) {
+	b.batchSize = rows
+}
+
+func (b *influx1Backend) serverVersion(ctx context.Context) (string, error) {
+	version, err := b.client.version(ctx)
+	return "InfluxDB " + version, err
+}
+
+// serverSettings reads the data and coordinator sections of SHOW DIAGNOSTICS;
+// InfluxDB 1.x does not report the cache sizes of its configuration.
+func (b *influx1Backend) serverSettings(ctx context.Context) (map[string]string, error) {
+	results, err := b.client.query(ctx, "SHOW DIAGNOSTICS")
+	if err != nil {
+		return nil, err
+	}
+	settings := map[string]string{}
+	for _, result := range results {
+		for _, series := range result.Series {
+			if (series.Name != "config-data" && series.Name != "config-coordinator") || len(series.Values) == 0 {
+				continue
+			}
+			for i, column := range series.Columns {
+				if i < len(series.Values[0]) {
+					settings[series.Name+"."+column] = fmt.Sprint(series.Values[0][i])
+				}
+			}
+		}
+	}
+	return settings, nil
+}
+
+func (b *influx1Backend) createSchema(ctx context.Context) error {
+	return b.exec(ctx, "CREATE DATABASE "+influx1Database)
+}
+
+// influx1Escaper escapes tag values for the line protocol.
+var influx1Escaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+
+// influx1StringEscaper escapes string field values for the line protocol.
+var influx1StringEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+func (b *influx1Backend) ingest(ctx context.Context, readings []Reading) error {
+	return b.ingestInto(ctx, "user_events", readings)
+}
+
+// lineBuffers reuse the line protocol buffers, which grow to a whole write
+// request, across batches.
+var lineBuffers = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+func (b *influx1Backend) ingestInto(ctx context.Context, measurement string, readings []Reading) error {
+	lines := lineBuffers.Get().(*bytes.Buffer)
+	defer lineBuffers.Put(lines)
+	lines.Reset()
+	points := 0
+	for i := range readings {
+		reading := &readings[i]
+		if len(extraColumns) == 0 {
+			fmt.Fprintf(lines, "%s,ssid=%s,user_id=%s rssi=%s %d\n",
+				measurement,
+				influx1Escaper.Replace(reading.Connection.Ssid),
+				influx1Escaper.Replace(reading.UserId),
+				strconv.FormatFloat(reading.Connection.Rssi, 'f', -1, 64),
+				readingTime(reading.LastUpdatedTime).Unix())
+		} else {
+			writeInflux1Line(lines, measurement, reading)
+		}
+
+		points++
+		if points == b.batchSize {
+			if err := b.client.write(ctx, lines.Bytes()); err != nil {
+				return err
+			}
+			lines.Reset()
+			points = 0
+		}
+	}
+
+	if points == 0 {
+		return nil
+	}
+	return b.client.write(ctx, lines.Bytes())
+}
+
+// writeInflux1Line writes a reading with its extra columns as tags and
+// fields. Unknown values and empty tags are left out.
+func writeInflux1Line(lines *bytes.Buffer, measurement string, reading *Reading) {
+	fmt.Fprintf(lines, "%s,ssid=%s,user_id=%s", measurement, influx1Escaper.Replace(reading.Connection.Ssid), influx1Escaper.Replace(reading.UserId))
+	values := extraValues(reading)
+	for j, value := range values {
+		if !extraColumns[j].isTag() {
+			continue
+		}
+		if tag := extraTag(value); tag != "" {
+			fmt.Fprintf(lines, ",%s=%s", extraColumns[j].Name, influx1Escaper.Replace(tag))
+		}
+	}
+	fmt.Fprintf(lines, " rssi=%s", strconv.FormatFloat(reading.Connection.Rssi, 'f', -1, 64))
+	for j, value := range values {
+		if extraColumns[j].isTag() {
+			continue
+		}
+		switch v := value.(type) {
+		case string:
+			fmt.Fprintf(lines, ",%s=\"%s\"", extraColumns[j].Name, influx1StringEscaper.Replace(v))
+		case int64:
+			fmt.Fprintf(lines, ",%s=%di", extraColumns[j].Name, v)
+		case float64:
+			fmt.Fprintf(lines, ",%s=%s", extraColumns[j].Name, strconv.FormatFloat(v, 'f', -1, 64))
+		}
+	}
+	fmt.Fprintf(lines, " %d\n", readingTime(reading.LastUpdatedTime).Unix())
+}
+
+func (b *influx1Backend) dropTable(ctx context.Context, measurement string) error {
+	return b.exec(ctx, "DROP MEASUREMENT "+measurement)
+}
+
+func (b *influx1Backend) exec(ctx context.Context, stmt string) error {
+	_, err := b.client.query(ctx, stmt)
+	return err
+}
+
+// export runs a chunked SELECT * in CSV; influxd backup only runs inside the
+// server's container.
+func (b *influx1Backend) export(ctx context.Context, w io.Writer) error {
+	return b.client.export(ctx, "SELECT * FROM user_events", w)
+}
+
+func (b *influx1Backend) expireBefore(ctx context.Context, cutoff time.Time) error {
+	return b.exec(ctx, fmt.Sprintf("DELETE FROM user_events WHERE time < '%s'", cutoff.UTC().Format(time.RFC3339)))
+}
+
+func (b *influx1Backend) lookupReading(ctx context.Context, userId string, at time.Time) ([]storedReading, error) {
+	results, err := b.client.query(ctx, fmt.Sprintf("SELECT rssi, ssid FROM user_events WHERE user_id = '%s' AND time = '%s'",
+		strings.ReplaceAll(userId, "'", `\'`), at.UTC().Format(time.RFC3339)))
+	if err != nil {
+		return nil, err
+	}
+
+	var stored []storedReading
+	for _, result := range results {
+		for _, series := range result.Series {
+			for _, values := range series.Values {
+				// values[0] is the time column.
+				rssi, _ := values[1].(float64)
+				ssid, _ := values[2].(string)
+				stored = append(stored, storedReading{Rssi: rssi, Ssid: ssid})
+			}
+		}
+	}
+	return stored, nil
+}
+
+// count reads the single value of a COUNT statement, which InfluxDB returns
+// next to the time column; an empty measurement returns no series.
+func (b *influx1Backend) count(ctx context.Context, q string) (int64, error) {
+	results, err := b.client.query(ctx, q)
+	if err != nil {
+		return 0, err
+	}
+	if len(results) == 0 || len(results[0].Series) == 0 || len(results[0].Series[0].Values) == 0 {
+		return 0, nil
+	}
+	n, _ := results[0].Series[0].Values[0][1].(float64)
+	return int64(n), nil
+}
+
+// formatInflux1Query substitutes the arguments into an InfluxQL query with
+// fmt.Sprintf; time values are rendered as RFC3339 literals.
+func formatInflux1Query(q string, args []any) string {
+	if len(args) == 0 {
+		return q
+	}
+	formatted := make([]any, len(args))
+	for i, arg := range args {
+		if t, ok := arg.(time.Time); ok {
+			formatted[i] = t.UTC().Format(time.RFC3339)
+		} else {
+			formatted[i] = arg
+		}
+	}
+	return fmt.Sprintf(q, formatted...)
+}
+
+func (b *influx1Backend) query(ctx context.Context, q string, args ...any) error {
+	_, err := b.client.query(ctx, formatInflux1Query(q, args))
+	return err
+}
+
+// scanRows hands over the rows of every series of every statement, prefixed
+// with the series name.
+func (b *influx1Backend) scanRows(ctx context.Context, visit func(values []any), q string, args ...any) error {
+	results, err := b.client.query(ctx, formatInflux1Query(q, args))
+	if err != nil {
+		return err
+	}
+	for _, result := range results {
+		for _, series := range result.Series {
+			for _, row := range series.Values {
+				visit(append([]any{series.Name}, row...))
+			}
+		}
+	}
+	return nil
+}
+
+// timeBounds runs the FIRST and the LAST statement of q in one request and
+// reads the time of their single row.
+func (b *influx1Backend) timeBounds(ctx context.Context, q string) (time.Time, time.Time, error) {
+	results, err := b.client.query(ctx, q)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if len(results) != 2 {
+		return time.Time{}, time.Time{}, fmt.Errorf("influxdb: expected 2 results for the time bounds, got %d", len(results))
+	}
+
+	bounds := make([]time.Time, 2)
+	for i, result := range results {
+		if len(result.Series) == 0 || len(result.Series[0].Values) == 0 {
+			return time.Time{}, time.Time{}, fmt.Errorf("influxdb: user_events is empty")
+		}
+		text, _ := result.Series[0].Values[0][0].(string)
+		if bounds[i], err = time.Parse(time.RFC3339Nano, text); err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	}
+	return bounds[0], bounds[1], nil
+}
+
+// plan returns the EXPLAIN ANALYZE tree, which InfluxDB returns as one row per
+// line.
+func (b *influx1Backend) plan(ctx context.Context, stmt string, args ...any) (string, error) {
+	results, err := b.client.query(ctx, formatInflux1Query(stmt, args))
+	if err != nil {
+		return "", err
+	}
+
+	var lines []string
+	for _, result := range results {
+		for _, series := range result.Series {
+			for _, values := range series.Values {
+				lines = append(lines, formatPlanRow(values))
+			}
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func (b *influx1Backend) close() {
+	b.client.http.CloseIdleConnections()
+}
@@ -0,0 +1,562 @@
+//go:build !nomock
+
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"net/url"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// The mock backend keeps the readings in memory and answers the catalog in Go,
+// so the ingestion, the queries, the result files and the reports can be run
+// end to end without a database. Its durations measure nothing but the
+// client. The connection string names the in-memory database, so that the
+// phases that reconnect find the data again, e.g. mock://run1; a latency
+// parameter, mock://run1?latency=2ms, delays every request to give the reports
+// non-zero times.
+
+func init() {
+	registerBackend(backendInfo{
+		name:        "mock",
+		description: "In-memory mock for testing the pipeline without a database",
+		open: func(connStr string) (backend, error) {
+			return openMockBackend(connStr)
+		},
+		ping: func(ctx context.Context, connStr string) error {
+			_, err := openMockBackend(connStr)
+			return err
+		},
+		isTransient: func(err error) bool { return false },
+		queries: []querySpec{
+			{id: 1, text: "time_bounds"},
+			{id: 2, text: "count_all"},
+			{id: 3, text: "count_distinct_users"},
+			{id: 4, text: "avg_rssi"},
+			{id: 5, text: "count_before_middle", args: atMiddle},
+			{id: 6, text: "count_after_middle", args: atMiddle},
+			{id: 7, text: "count_around_middle", args: aroundMiddle},
+			{id: 8, text: "hourly_counts_24h", args: dayFromMiddle},
+			{id: 9, text: "top_users"},
+			{id: 10, text: "count_strong_signal"},
+			{id: 11, text: "count_weak_signal"},
+			{id: 12, text: "top_ssids"},
+			{id: 13, text: "rssi_stats_by_user"},
+			{id: 14, text: "rssi_percentiles"},
+			{id: 15, text: "count_first_half", args: firstHalf},
+			{id: 16, text: "count_second_half", args: secondHalf},
+			{id: 17, text: "counts_by_hour_of_day"},
+			{id: 18, text: "daily_rssi_variance"},
+			{id: 19, text: "peak_hours"},
+			{id: 20, text: "user_sessions"},
+			{id: 21, text: "moving_avg_rssi", args: dayFromMiddle},
+			{id: 22, text: "gap_filled_hourly_counts", args: dayFromMiddle},
+			{id: 24, text: "occupancy", args: dayFromMiddle},
+		},
+		capabilities: &Capabilities{
+			Percentiles:     true,
+			WindowFunctions: true,
+			GapFill:         false,
+			ApproxDistinct:  false,
+			Updates:         false,
+			Deletes:         false,
+			Note:            "in-memory mock without the last-value fill and the approximate occupancy",
+		},
+		schemaProbe:    "count_all",
+		hourOfDayQuery: "counts_by_hour_of_day",
+		fidelity:       true,
+		reconciliation: reconciliationDialect{
+			countQuery: "count_all",
+			duplicates: "every point is kept as its own row, identical ones included",
+		},
+	})
+}
+
+// mockRow is a reading as the mock database stores it.
+type mockRow struct {
+	userId string
+	ssid   string
+	rssi   float64
+	at     time.Time
+}
+
+// mockDatabase is the user_events table of one connection string.
+type mockDatabase struct {
+	mu      sync.RWMutex
+	created bool
+	rows    []mockRow
+}
+
+var (
+	mockDatabasesMu sync.Mutex
+	mockDatabases   = map[string]*mockDatabase{}
+)
+
+type mockBackend struct {
+	db      *mockDatabase
+	latency time.Duration
+}
+
+func openMockBackend(connStr string) (*mockBackend, error) {
+	u, err := url.Parse(connStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mock connection string: %w", err)
+	}
+	b := &mockBackend{}
+	if latency := u.Query().Get("latency"); latency != "" {
+		if b.latency, err = time.ParseDuration(latency); err != nil {
+			return nil, fmt.Errorf("invalid mock latency: %w", err)
+		}
+	}
+	name := u.Host + u.Path
+	mockDatabasesMu.Lock()
+	defer mockDatabasesMu.Unlock()
+	if b.db = mockDatabases[name]; b.db == nil {
+		b.db = &mockDatabase{}
+		mockDatabases[name] = b.db
+	}
+	return b, nil
+}
+
+// wait is the simulated round trip of a request.
+func (b *mockBackend) wait(ctx context.Context) error {
+	if b.latency <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(b.latency)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *mockBackend) createSchema(ctx context.Context) error {
+	if err := b.wait(ctx); err != nil {
+		return err
+	}
+	b.db.mu.Lock()
+	defer b.db.mu.Unlock()
+	b.db.created = true
+	return nil
+}
+
+func (b *mockBackend) ingest(ctx context.Context, readings []Reading) error {
+	if err := b.wait(ctx); err != nil {
+		return err
+	}
+	b.db.mu.Lock()
+	defer b.db.mu.Unlock()
+	if !b.db.created {
+		return fmt.Errorf("mock: table user_events does not exist")
+	}
+	for _, reading := range readings {
+		b.db.rows = append(b.db.rows, mockRow{
+			userId: reading.UserId,
+			ssid:   reading.Connection.Ssid,
+			rssi:   reading.Connection.Rssi,
+			at:     readingTime(reading.LastUpdatedTime),
+		})
+	}
+	return nil
+}
+
+// exec accepts every statement; the mock has no settings or maintenance.
+func (b *mockBackend) exec(ctx context.Context, stmt string) error {
+	return b.wait(ctx)
+}
+
+func (b *mockBackend) query(ctx context.Context, q string, args ...any) error {
+	_, err := b.answer(ctx, q, args)
+	return err
+}
+
+func (b *mockBackend) scanRows(ctx context.Context, visit func(values []any), q string, args ...any) error {
+	rows, err := b.answer(ctx, q, args)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		visit(row)
+	}
+	return nil
+}
+
+func (b *mockBackend) timeBounds(ctx context.Context, q string) (time.Time, time.Time, error) {
+	rows, err := b.answer(ctx, q, nil)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if len(rows) == 0 {
+		return time.Time{}, time.Time{}, fmt.Errorf("mock: user_events is empty")
+	}
+	return rows[0][0].(time.Time), rows[0][1].(time.Time), nil
+}
+
+func (b *mockBackend) count(ctx context.Context, q string) (int64, error) {
+	rows, err := b.answer(ctx, q, nil)
+	if err != nil {
+		return 0, err
+	}
+	return rows[0][0].(int64), nil
+}
+
+func (b *mockBackend) countByHour(ctx context.Context, q string) (map[int]int64, error) {
+	rows, err := b.answer(ctx, q, nil)
+	if err != nil {
+		return nil, err
+	}
+	counts := map[int]int64{}
+	for _, row := range rows {
+		counts[row[0].(int)] = row[1].(int64)
+	}
+	return counts, nil
+}
+
+func (b *mockBackend) lookupReading(ctx context.Context, userId string, at time.Time) ([]storedReading, error) {
+	if err := b.wait(ctx); err != nil {
+		return nil, err
+	}
+	b.db.mu.RLock()
+	defer b.db.mu.RUnlock()
+	var stored []storedReading
+	for _, row := range b.db.rows {
+		if row.userId == userId && row.at.Equal(at) {
+			stored = append(stored, storedReading{Rssi: row.rssi, Ssid: row.ssid})
+		}
+	}
+	return stored, nil
+}
+
+// serverSettings reports the simulated latency, the one setting of the mock.
+func (b *mockBackend) serverSettings(ctx context.Context) (map[string]string, error) {
+	return map[string]string{"latency": b.latency.String()}, nil
+}
+
+func (b *mockBackend) close() {}
+
+// answer runs the catalog query q, named as in the results, on the stored
+// rows.
+func (b *mockBackend) answer(ctx context.Context, q string, args []any) ([][]any, error) {
+	if err := b.wait(ctx); err != nil {
+		return nil, err
+	}
+	run, ok := mockQueries[q]
+	if !ok {
+		return nil, fmt.Errorf("mock: unknown query %q", q)
+	}
+	times := make([]time.Time, len(args))
+	for i, arg := range args {
+		t, ok := arg.(time.Time)
+		if !ok {
+			return nil, fmt.Errorf("mock: parameter %d of %s is not a time", i+1, q)
+		}
+		times[i] = t
+	}
+	b.db.mu.RLock()
+	defer b.db.mu.RUnlock()
+	if !b.db.created {
+		return nil, fmt.Errorf("mock: table user_events does not exist")
+	}
+	return run(b.db.rows, times), nil
+}
+
+// mockQueries answer the catalog queries like the SQL of the PostgreSQL
+// backend: BETWEEN includes both bounds, the 24-hour windows exclude the end
+// where the SQL does, and ties are broken by the group key so the answers are
+// stable.
+var mockQueries = map[string]func(rows []mockRow, args []time.Time) [][]any{
+	"time_bounds": func(rows []mockRow, _ []time.Time) [][]any {
+		if len(rows) == 0 {
+			return nil
+		}
+		first, last := rows[0].at, rows[0].at
+		for _, row := range rows {
+			if row.at.Before(first) {
+				first = row.at
+			}
+			if row.at.After(last) {
+				last = row.at
+			}
+		}
+		return [][]any{{first, last}}
+	},
+	"count_all": func(rows []mockRow, _ []time.Time) [][]any {
+		return [][]any{{int64(len(rows))}}
+	},
+	"count_distinct_users": func(rows []mockRow, _ []time.Time) [][]any {
+		users := map[string]bool{}
+		for _, row := range rows {
+			users[row.userId] = true
+		}
+		return [][]any{{int64(len(users))}}
+	},
+	"avg_rssi": func(rows []mockRow, _ []time.Time) [][]any {
+		return [][]any{{mockMean(rows)}}
+	},
+	"count_before_middle": func(rows []mockRow, args []time.Time) [][]any {
+		return mockCount(rows, func(row mockRow) bool { return row.at.Before(args[0]) })
+	},
+	"count_after_middle": func(rows []mockRow, args []time.Time) [][]any {
+		return mockCount(rows, func(row mockRow) bool { return row.at.After(args[0]) })
+	},
+	"count_around_middle": mockCountBetween,
+	"count_first_half":    mockCountBetween,
+	"count_second_half":   mockCountBetween,
+	"hourly_counts_24h": func(rows []mockRow, args []time.Time) [][]any {
+		groups := mockGroup(mockBetween(rows, args[0], args[1]), func(row mockRow) time.Time { return row.at.Truncate(time.Hour) })
+		return mockSortedCounts(groups, func(a, b time.Time) int { return a.Compare(b) })
+	},
+	"top_users": func(rows []mockRow, _ []time.Time) [][]any {
+		return mockTopCounts(mockGroup(rows, func(row mockRow) string { return row.userId }), 10)
+	},
+	"count_strong_signal": func(rows []mockRow, _ []time.Time) [][]any {
+		return mockCount(rows, func(row mockRow) bool { return row.rssi > -50 })
+	},
+	"count_weak_signal": func(rows []mockRow, _ []time.Time) [][]any {
+		return mockCount(rows, func(row mockRow) bool { return row.rssi < -80 })
+	},
+	"top_ssids": func(rows []mockRow, _ []time.Time) [][]any {
+		return mockTopCounts(mockGroup(rows, func(row mockRow) string { return row.ssid }), 10)
+	},
+	"rssi_stats_by_user": func(rows []mockRow, _ []time.Time) [][]any {
+		var answer [][]any
+		for user, group := range mockGroup(rows, func(row mockRow) string { return row.userId }) {
+			low, high := math.Inf(1), math.Inf(-1)
+			for _, row := range group {
+				low, high = min(low, row.rssi), max(high, row.rssi)
+			}
+			answer = append(answer, []any{user, mockMean(group), low, high})
+		}
+		slices.SortFunc(answer, func(a, b []any) int {
+			return cmp.Or(cmp.Compare(b[1].(float64), a[1].(float64)), cmp.Compare(a[0].(string), b[0].(string)))
+		})
+		return answer[:min(len(answer), 100)]
+	},
+	"rssi_percentiles": func(rows []mockRow, _ []time.Time) [][]any {
+		if len(rows) == 0 {
+			return [][]any{{nil, nil, nil}}
+		}
+		values := make([]float64, len(rows))
+		for i, row := range rows {
+			values[i] = row.rssi
+		}
+		sort.Float64s(values)
+		return [][]any{{mockPercentileCont(values, 0.25), mockPercentileCont(values, 0.5), mockPercentileCont(values, 0.75)}}
+	},
+	"counts_by_hour_of_day": func(rows []mockRow, _ []time.Time) [][]any {
+		groups := mockGroup(rows, func(row mockRow) int { return row.at.UTC().Hour() })
+		return mockSortedCounts(groups, cmp.Compare[int])
+	},
+	"daily_rssi_variance": func(rows []mockRow, _ []time.Time) [][]any {
+		var answer [][]any
+		for day, group := range mockGroup(rows, func(row mockRow) time.Time { return row.at.Truncate(24 * time.Hour) }) {
+			// VARIANCE is the sample variance, NULL for a single row.
+			var variance any
+			if len(group) > 1 {
+				mean, sum := mockMean(group).(float64), 0.0
+				for _, row := range group {
+					sum += (row.rssi - mean) * (row.rssi - mean)
+				}
+				variance = sum / float64(len(group)-1)
+			}
+			answer = append(answer, []any{day, variance})
+		}
+		slices.SortFunc(answer, func(a, b []any) int { return a[0].(time.Time).Compare(b[0].(time.Time)) })
+		return answer[:min(len(answer), 30)]
+	},
+	"peak_hours": func(rows []mockRow, _ []time.Time) [][]any {
+		answer := mockSortedCounts(mockGroup(rows, func(row mockRow) time.Time { return row.at.Truncate(time.Hour) }),
+			func(a, b time.Time) int { return a.Compare(b) })
+		slices.SortStableFunc(answer, func(a, b []any) int { return cmp.Compare(b[1].(int64), a[1].(int64)) })
+		return answer[:min(len(answer), 5)]
+	},
+	"user_sessions": func(rows []mockRow, _ []time.Time) [][]any {
+		var answer [][]any
+		for user, group := range mockGroup(rows, func(row mockRow) string { return row.userId }) {
+			slices.SortFunc(group, func(a, b mockRow) int { return a.at.Compare(b.at) })
+			sessions, duration, start := int64(1), time.Duration(0), group[0].at
+			for i := 1; i < len(group); i++ {
+				if group[i].at.Sub(group[i-1].at) > 30*time.Minute {
+					duration += group[i-1].at.Sub(start)
+					sessions, start = sessions+1, group[i].at
+				}
+			}
+			duration += group[len(group)-1].at.Sub(start)
+			answer = append(answer, []any{user, sessions, duration})
+		}
+		slices.SortFunc(answer, func(a, b []any) int {
+			return cmp.Or(cmp.Compare(b[2].(time.Duration), a[2].(time.Duration)), cmp.Compare(a[0].(string), b[0].(string)))
+		})
+		return answer[:min(len(answer), 10)]
+	},
+	"moving_avg_rssi": func(rows []mockRow, args []time.Time) [][]any {
+		var sum float64
+		var n int
+		for _, group := range mockGroup(mockBetween(rows, args[0], args[1]), func(row mockRow) string { return row.userId }) {
+			slices.SortFunc(group, func(a, b mockRow) int { return a.at.Compare(b.at) })
+			// The window of a row reaches 15 minutes back and includes the
+			// rows that share its timestamp.
+			first, windowSum := 0, 0.0
+			for last := 0; last < len(group); last++ {
+				windowSum += group[last].rssi
+				for group[first].at.Before(group[last].at.Add(-15 * time.Minute)) {
+					windowSum -= group[first].rssi
+					first++
+				}
+				end := last
+				for end+1 < len(group) && group[end+1].at.Equal(group[last].at) {
+					end++
+				}
+				peers := 0.0
+				for _, row := range group[last+1 : end+1] {
+					peers += row.rssi
+				}
+				sum += (windowSum + peers) / float64(end-first+1)
+				n++
+			}
+		}
+		if n == 0 {
+			return [][]any{{nil}}
+		}
+		return [][]any{{sum / float64(n)}}
+	},
+	"gap_filled_hourly_counts": func(rows []mockRow, args []time.Time) [][]any {
+		window := mockBefore(rows, args[0], args[1])
+		counts := map[string]map[time.Time]int64{}
+		for _, row := range window {
+			if counts[row.ssid] == nil {
+				counts[row.ssid] = map[time.Time]int64{}
+			}
+			counts[row.ssid][row.at.Truncate(time.Hour)]++
+		}
+		ssids := make([]string, 0, len(counts))
+		for ssid := range counts {
+			ssids = append(ssids, ssid)
+		}
+		sort.Strings(ssids)
+		var answer [][]any
+		for hour := args[0].Truncate(time.Hour); !hour.After(args[1].Add(-time.Hour)); hour = hour.Add(time.Hour) {
+			for _, ssid := range ssids {
+				answer = append(answer, []any{hour, ssid, counts[ssid][hour]})
+			}
+		}
+		return answer
+	},
+	"occupancy": func(rows []mockRow, args []time.Time) [][]any {
+		type key struct {
+			bucket time.Time
+			ssid   string
+		}
+		users := map[key]map[string]bool{}
+		for _, row := range mockBefore(rows, args[0], args[1]) {
+			k := key{row.at.Truncate(15 * time.Minute), row.ssid}
+			if users[k] == nil {
+				users[k] = map[string]bool{}
+			}
+			users[k][row.userId] = true
+		}
+		var answer [][]any
+		for k, distinct := range users {
+			answer = append(answer, []any{k.bucket, k.ssid, int64(len(distinct))})
+		}
+		slices.SortFunc(answer, func(a, b []any) int {
+			return cmp.Or(a[0].(time.Time).Compare(b[0].(time.Time)), cmp.Compare(a[1].(string), b[1].(string)))
+		})
+		return answer
+	},
+}
+
+func mockCount(rows []mockRow, keep func(row mockRow) bool) [][]any {
+	var n int64
+	for _, row := range rows {
+		if keep(row) {
+			n++
+		}
+	}
+	return [][]any{{n}}
+}
+
+func mockCountBetween(rows []mockRow, args []time.Time) [][]any {
+	return [][]any{{int64(len(mockBetween(rows, args[0], args[1])))}}
+}
+
+// mockBetween keeps the rows from start to end, both included.
+func mockBetween(rows []mockRow, start time.Time, end time.Time) []mockRow {
+	var kept []mockRow
+	for _, row := range rows {
+		if !row.at.Before(start) && !row.at.After(end) {
+			kept = append(kept, row)
+		}
+	}
+	return kept
+}
+
+// mockBefore keeps the rows from start up to, not including, end.
+func mockBefore(rows []mockRow, start time.Time, end time.Time) []mockRow {
+	var kept []mockRow
+	for _, row := range rows {
+		if !row.at.Before(start) && row.at.Before(end) {
+			kept = append(kept, row)
+		}
+	}
+	return kept
+}
+
+func mockGroup[K comparable](rows []mockRow, key func(row mockRow) K) map[K][]mockRow {
+	groups := map[K][]mockRow{}
+	for _, row := range rows {
+		k := key(row)
+		groups[k] = append(groups[k], row)
+	}
+	return groups
+}
+
+// mockSortedCounts counts the rows of every group, in the order of the keys.
+func mockSortedCounts[K comparable](groups map[K][]mockRow, compare func(a, b K) int) [][]any {
+	keys := make([]K, 0, len(groups))
+	for k := range groups {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, compare)
+	answer := make([][]any, len(keys))
+	for i, k := range keys {
+		answer[i] = []any{k, int64(len(groups[k]))}
+	}
+	return answer
+}
+
+// mockTopCounts returns the n largest groups by count.
+func mockTopCounts(groups map[string][]mockRow, n int) [][]any {
+	answer := mockSortedCounts(groups, cmp.Compare[string])
+	slices.SortStableFunc(answer, func(a, b []any) int { return cmp.Compare(b[1].(int64), a[1].(int64)) })
+	return answer[:min(len(answer), n)]
+}
+
+func mockMean(rows []mockRow) any {
+	if len(rows) == 0 {
+		return nil
+	}
+	var sum float64
+	for _, row := range rows {
+		sum += row.rssi
+	}
+	return sum / float64(len(rows))
+}
+
+// mockPercentileCont interpolates between the two nearest sorted values like
+// percentile_cont.
+func mockPercentileCont(sorted []float64, p float64) float64 {
+	position := p * float64(len(sorted)-1)
+	lower := int(math.Floor(position))
+	upper := min(lower+1, len(sorted)-1)
+	return sorted[lower] + (position-float64(lower))*(sorted[upper]-sorted[lower])
+}
@@ -0,0 +1,148 @@
+//go:build !nopostgres
+
+package main
+
+import "fmt"
+
+func init() {
+	registerBackend(backendInfo{
+		name:        "postgres",
+		description: "PostgreSQL (pgx, COPY ingestion)",
+		open: func(connStr string) (backend, error) {
+			return newPostgresBackend(connStr, postgresSchema)
+		},
+		ping:        pingPostgres,
+		isTransient: isTransientPostgres,
+		queries: []querySpec{
+			{id: 1, text: "SELECT MIN(timestamp), MAX(timestamp) FROM user_events"},
+			{id: 2, text: "SELECT COUNT(*) FROM user_events"},
+			{id: 3, text: "SELECT COUNT(DISTINCT user_id) FROM user_events"},
+			{id: 4, text: "SELECT AVG(rssi) FROM user_events"},
+			{id: 5, text: "SELECT COUNT(*) FROM user_events WHERE timestamp < $1", args: atMiddle},
+			{id: 6, text: "SELECT COUNT(*) FROM user_events WHERE timestamp > $1", args: atMiddle},
+			{id: 7, text: "SELECT COUNT(*) FROM user_events WHERE timestamp BETWEEN $1 AND $2", args: aroundMiddle},
+			{id: 8, text: "SELECT date_trunc('hour', timestamp) as hour, COUNT(*) FROM user_events WHERE timestamp BETWEEN $1 AND $2 GROUP BY hour ORDER BY hour", args: dayFromMiddle},
+			{id: 9, text: "SELECT user_id, COUNT(*) as count FROM user_events GROUP BY user_id ORDER BY count DESC LIMIT 10"},
+			{id: 10, text: "SELECT COUNT(*) FROM user_events WHERE rssi > -50"},
+			{id: 11, text: "SELECT COUNT(*) FROM user_events WHERE rssi < -80"},
+			{id: 12, text: "SELECT ssid, COUNT(*) as count FROM user_events GROUP BY ssid ORDER BY count DESC LIMIT 10"},
+			{id: 13, text: "SELECT user_id, AVG(rssi), MIN(rssi), MAX(rssi) FROM user_events GROUP BY user_id ORDER BY AVG(rssi) DESC LIMIT 100"},
+			{id: 14, text: "SELECT percentile_cont(0.25) WITHIN GROUP (ORDER BY rssi) as q1, percentile_cont(0.5) WITHIN GROUP (ORDER BY rssi) as median, percentile_cont(0.75) WITHIN GROUP (ORDER BY rssi) as q3 FROM user_events"},
+			{id: 15, text: "SELECT COUNT(*) FROM user_events WHERE timestamp BETWEEN $1 AND $2", args: firstHalf},
+			{id: 16, text: "SELECT COUNT(*) FROM user_events WHERE timestamp BETWEEN $1 AND $2", args: secondHalf},
+			{id: 17, text: "SELECT EXTRACT(hour FROM timestamp) as hour, COUNT(*) as count FROM user_events GROUP BY hour ORDER BY hour"},
+			{id: 18, text: "SELECT DATE(timestamp) as day, VARIANCE(rssi) as rssi_variance FROM user_events GROUP BY day ORDER BY day LIMIT 30"},
+			{id: 19, text: "SELECT date_trunc('hour', timestamp) as hour, COUNT(*) as count FROM user_events GROUP BY hour ORDER BY count DESC LIMIT 5"},
+			{id: 20, text: "SELECT user_id, COUNT(*) AS sessions, SUM(session_end - session_start) AS session_duration FROM (SELECT user_id, MIN(timestamp) AS session_start, MAX(timestamp) AS session_end FROM (SELECT user_id, timestamp, SUM(new_session) OVER (PARTITION BY user_id ORDER BY timestamp) AS session_id FROM (SELECT user_id, timestamp, CASE WHEN timestamp - LAG(timestamp) OVER (PARTITION BY user_id ORDER BY timestamp) > INTERVAL '30 minutes' THEN 1 ELSE 0 END AS new_session FROM user_events) gaps) numbered GROUP BY user_id, session_id) sessions GROUP BY user_id ORDER BY session_duration DESC LIMIT 10"},
+			{id: 21, text: "SELECT AVG(moving_rssi) FROM (SELECT AVG(rssi) OVER (PARTITION BY user_id ORDER BY timestamp RANGE BETWEEN INTERVAL '15 minutes' PRECEDING AND CURRENT ROW) AS moving_rssi FROM user_events WHERE timestamp BETWEEN $1 AND $2) w", args: dayFromMiddle},
+			{id: 22, text: "SELECT h.hour, s.ssid, COUNT(e.rssi) FROM generate_series(date_trunc('hour', $1::timestamptz), $2::timestamptz - INTERVAL '1 hour', INTERVAL '1 hour') AS h(hour) CROSS JOIN (SELECT DISTINCT ssid FROM user_events WHERE timestamp >= $1 AND timestamp < $2) s LEFT JOIN user_events e ON e.ssid = s.ssid AND e.timestamp >= h.hour AND e.timestamp < h.hour + INTERVAL '1 hour' GROUP BY h.hour, s.ssid ORDER BY h.hour, s.ssid", args: dayFromMiddle},
+			{id: 24, text: "SELECT date_bin('15 minutes', timestamp, TIMESTAMPTZ '2000-01-01') AS bucket, ssid, COUNT(DISTINCT user_id) FROM user_events WHERE timestamp >= $1 AND timestamp < $2 GROUP BY bucket, ssid ORDER BY bucket, ssid", args: dayFromMiddle},
+		},
+		capabilities: &Capabilities{
+			Percentiles:     true,
+			WindowFunctions: true,
+			GapFill:         false,
+			ApproxDistinct:  false,
+			Updates:         true,
+			Deletes:         true,
+		},
+		bucketQuery: func(w bucketWidth) string {
+			return fmt.Sprintf("SELECT date_bin('%s', timestamp, TIMESTAMPTZ '2000-01-01') AS bucket, ssid, COUNT(DISTINCT user_id) FROM user_events GROUP BY bucket, ssid ORDER BY bucket", w.interval)
+		},
+		explainPrefix: "EXPLAIN (ANALYZE, BUFFERS) ",
+		serverTiming:  true,
+		schemaProbe:   "SELECT user_id, timestamp, rssi, ssid FROM user_events LIMIT 1",
+		schemaVariants: []schemaVariant{
+			{name: "btree", ddl: postgresSchema},
+			{name: "brin", ddl: postgresTable + " CREATE INDEX IF NOT EXISTS idx_user_events_timestamp ON user_events USING BRIN (timestamp);"},
+			{name: "noindex", ddl: postgresTable},
+			{name: "partitioned", ddl: postgresPartitionedTable + " CREATE INDEX IF NOT EXISTS idx_user_events_timestamp ON user_events (timestamp);", daily: true},
+		},
+		hourOfDayQuery: "SELECT EXTRACT(hour FROM timestamp AT TIME ZONE 'UTC')::int AS hour, COUNT(*) FROM user_events GROUP BY hour",
+		reconciliation: reconciliationDialect{
+			countQuery: "SELECT COUNT(*) FROM user_events",
+			duplicates: "every point is stored as its own row, identical ones included",
+		},
+		extraColumns: sqlExtraColumns,
+		durability:   pgDurability,
+		maintenance: []maintenanceOp{
+			{name: "vacuum-analyze", statements: []string{"VACUUM ANALYZE user_events"}},
+		},
+		serverSettings: pgSettings(),
+		retention:      true,
+		export:         true,
+		fidelity:       true,
+		container: containerSpec{
+			image:      "postgres:17.2",
+			ports:      []string{"5433:5432"},
+			env:        []string{"POSTGRES_PASSWORD=example", "POSTGRES_USER=postgres", "POSTGRES_DB=pdb"},
+			timingArgs: []string{"-c", "shared_preload_libraries=pg_stat_statements"},
+		},
+		timeToInsight: timeToInsightDialect{
+			rollup: []string{
+				"CREATE TABLE user_events_hourly AS SELECT date_trunc('hour', timestamp) AS hour, ssid, COUNT(*) AS readings, COUNT(DISTINCT user_id) AS users, AVG(rssi) AS avg_rssi FROM user_events GROUP BY 1, 2",
+			},
+			dashboard: sqlDashboardBundle,
+		},
+		joins: joinDialect{
+			schema: sqlDimensionTables,
+			queries: []querySpec{
+				{id: 1, text: "SELECT a.building, COUNT(*) AS count FROM user_events e JOIN access_points a ON a.ssid = e.ssid GROUP BY a.building ORDER BY count DESC"},
+				{id: 2, text: "SELECT date_trunc('hour', e.timestamp) AS hour, a.building, COUNT(DISTINCT e.user_id) FROM user_events e JOIN access_points a ON a.ssid = e.ssid WHERE e.timestamp >= $1 AND e.timestamp < $2 GROUP BY hour, a.building ORDER BY hour, a.building", args: dayFromMiddle},
+				{id: 3, text: "SELECT u.department, a.building, AVG(e.rssi) FROM user_events e JOIN users u ON u.user_id = e.user_id JOIN access_points a ON a.ssid = e.ssid GROUP BY u.department, a.building ORDER BY u.department, a.building"},
+			},
+		},
+		downsampling: rollupDialect{
+			create: []string{
+				"CREATE MATERIALIZED VIEW user_events_hourly AS SELECT date_trunc('hour', timestamp) AS hour, ssid, COUNT(*) AS readings, SUM(rssi) AS rssi_sum FROM user_events GROUP BY 1, 2",
+				"CREATE INDEX ON user_events_hourly (hour)",
+			},
+			queries: sqlHourlyRollupQueries,
+		},
+		buildings: buildingDialect{
+			table: func(name string) []string {
+				return []string{
+					"CREATE TABLE " + name + " (id BIGSERIAL, user_id VARCHAR(255) NOT NULL, timestamp TIMESTAMP WITH TIME ZONE NOT NULL, rssi REAL NOT NULL, ssid VARCHAR(255) NOT NULL)",
+					"CREATE INDEX ON " + name + " (timestamp)",
+				}
+			},
+			queries: func(tables []string) []querySpec {
+				return sqlBuildingQueries(tables, "date_trunc('hour', timestamp)", "timestamp >= $1 AND timestamp < $2", dayFromMiddle)
+			},
+		},
+		locations: locationDialect{
+			schema: []string{
+				"CREATE TABLE " + locatedTable + " (id BIGSERIAL, user_id VARCHAR(255) NOT NULL, timestamp TIMESTAMP WITH TIME ZONE NOT NULL, rssi REAL NOT NULL, ssid VARCHAR(255) NOT NULL, building VARCHAR(255) NOT NULL, floor_number INTEGER NOT NULL, latitude DOUBLE PRECISION, longitude DOUBLE PRECISION)",
+				"CREATE INDEX ON " + locatedTable + " (timestamp)",
+			},
+			queries: sqlLocationQueries("date_trunc('hour', timestamp)", "timestamp >= $1 AND timestamp < $2", "COUNT(DISTINCT user_id)", dayFromMiddle),
+		},
+		dedup: dedupDialect{
+			schema:     postgresTable + " CREATE UNIQUE INDEX IF NOT EXISTS idx_user_events_key ON user_events (user_id, timestamp); CREATE INDEX IF NOT EXISTS idx_user_events_timestamp ON user_events (timestamp);",
+			countQuery: "SELECT COUNT(*) FROM user_events",
+			upsert:     true,
+		},
+	})
+}
+
+const postgresTable = `
+		CREATE TABLE IF NOT EXISTS user_events (
+			id BIGSERIAL,
+			user_id VARCHAR(255) NOT NULL,
+			timestamp TIMESTAMP WITH TIME ZONE NOT NULL,
+			rssi REAL NOT NULL,
+			ssid VARCHAR(255) NOT NULL
+		);`
+
+// postgresPartitionedTable is partitioned by range of timestamp; the daily
+// partitions are created during the ingestion, when the days are known.
+const postgresPartitionedTable = `
+		CREATE TABLE IF NOT EXISTS user_events (
+			id BIGSERIAL,
+			user_id VARCHAR(255) NOT NULL,
+			timestamp TIMESTAMP WITH TIME ZONE NOT NULL,
+			rssi REAL NOT NULL,
+			ssid VARCHAR(255) NOT NULL
+		) PARTITION BY RANGE (timestamp);`
+
+const postgresSchema = postgresTable + " CREATE INDEX IF NOT EXISTS idx_user_events_timestamp ON user_events (timestamp);"
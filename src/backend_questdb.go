@@ -0,0 +1,531 @@
+//go:build !noquestdb
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	qdb "github.com/questdb/go-questdb-client/v3"
+)
+
+func init() {
+	info := backendInfo{
+		name:        "questdb",
+		description: "QuestDB (ILP ingestion, PostgreSQL wire queries)",
+		open: func(connStr string) (backend, error) {
+			return newQuestBackend(connStr)
+		},
+		ping: func(ctx context.Context, connStr string) error {
+			connParts, err := splitQuestConnStr(connStr)
+			if err != nil {
+				return err
+			}
+			return pingPostgres(ctx, connParts[1])
+		},
+		isTransient: isTransientPostgres,
+		queries: []querySpec{
+			{id: 1, text: "SELECT MIN(timestamp), MAX(timestamp) FROM user_events"},
+			{id: 2, text: "SELECT COUNT(*) FROM user_events"},
+			{id: 3, text: "SELECT COUNT(DISTINCT user_id) FROM user_events"},
+			{id: 4, text: "SELECT AVG(rssi) FROM user_events"},
+			{id: 5, text: "SELECT COUNT(*) FROM user_events WHERE timestamp < $1", args: atMiddle},
+			{id: 6, text: "SELECT COUNT(*) FROM user_events WHERE timestamp > $1", args: atMiddle},
+			{id: 7, text: "SELECT COUNT(*) FROM user_events WHERE timestamp BETWEEN dateadd('h', -1, $1) AND dateadd('h', 1, $1)", args: atMiddle},
+			{id: 8, text: "SELECT timestamp, COUNT(*) FROM user_events WHERE timestamp BETWEEN $1 AND dateadd('h', 24, $1) SAMPLE BY 1h LIMIT 24", args: atMiddle},
+			{id: 9, text: "SELECT user_id, COUNT(*) as count FROM user_events ORDER BY count DESC LIMIT 10"},
+			{id: 10, text: "SELECT COUNT(*) FROM user_events WHERE rssi > -50"},
+			{id: 11, text: "SELECT COUNT(*) FROM user_events WHERE rssi < -80"},
+			{id: 12, text: "SELECT ssid, COUNT(*) as count FROM user_events ORDER BY count DESC LIMIT 10"},
+			{id: 13, text: "SELECT user_id, avg(rssi), min(rssi), max(rssi) FROM user_events ORDER BY avg DESC LIMIT 100"},
+			{id: 14, text: "SELECT -approx_percentile(-rssi, 1.0-0.25) as q1, -approx_percentile(-rssi, 1.0-0.5) as median, -approx_percentile(-rssi, 1.0-0.75) as q3 FROM user_events"},
+			{id: 15, text: "SELECT COUNT(*) FROM user_events WHERE timestamp BETWEEN $1 AND $2", args: firstHalf},
+			{id: 16, text: "SELECT COUNT(*) FROM user_events WHERE timestamp BETWEEN $1 AND $2", args: secondHalf},
+			{id: 17, text: "SELECT hour(timestamp) as hour, COUNT(*) as count FROM user_events ORDER BY hour"},
+			{id: 18, text: "SELECT timestamp, variance(rssi) as rssi_variance FROM user_events SAMPLE BY 1d LIMIT 30"},
+			{id: 19, text: "SELECT timestamp, count FROM (SELECT timestamp, COUNT(*) as count FROM user_events SAMPLE BY 1h) ORDER BY count DESC LIMIT 5"},
+			{id: 20, text: "SELECT user_id, count() AS sessions, sum(session_end - session_start) AS session_duration FROM (SELECT user_id, session_id, min(timestamp) AS session_start, max(timestamp) AS session_end FROM (SELECT user_id, timestamp, sum(new_session) OVER (PARTITION BY user_id ORDER BY timestamp ROWS UNBOUNDED PRECEDING) AS session_id FROM (SELECT user_id, timestamp, CASE WHEN datediff('s', previous_ts, timestamp) > 1800 THEN 1 ELSE 0 END AS new_session FROM (SELECT user_id, timestamp, lag(timestamp) OVER (PARTITION BY user_id ORDER BY timestamp) AS previous_ts FROM user_events)))) ORDER BY session_duration DESC LIMIT 10"},
+			{id: 21, text: "SELECT avg(moving_rssi) FROM (SELECT avg(rssi) OVER (PARTITION BY user_id ORDER BY timestamp RANGE BETWEEN 15 MINUTE PRECEDING AND CURRENT ROW) AS moving_rssi FROM user_events WHERE timestamp BETWEEN $1 AND dateadd('h', 24, $1))", args: atMiddle},
+			{id: 22, text: "SELECT timestamp, ssid, count() FROM user_events WHERE timestamp BETWEEN $1 AND dateadd('h', 24, $1) SAMPLE BY 1h FILL(0)", args: atMiddle},
+			{id: 23, text: "SELECT timestamp, ssid, avg(rssi) FROM user_events WHERE timestamp BETWEEN $1 AND dateadd('h', 24, $1) SAMPLE BY 1h FILL(PREV)", args: atMiddle},
+			{id: 24, text: "SELECT timestamp, ssid, count_distinct(user_id) FROM user_events WHERE timestamp BETWEEN $1 AND dateadd('h', 24, $1) SAMPLE BY 15m", args: atMiddle},
+		},
+		capabilities: &Capabilities{
+			Percentiles:     true,
+			WindowFunctions: true,
+			GapFill:         true,
+			ApproxDistinct:  false,
+			Updates:         true,
+			Deletes:         false,
+			Note:            "readings are removed by dropping partitions only",
+		},
+		bucketQuery: func(w bucketWidth) string {
+			return fmt.Sprintf("SELECT timestamp, ssid, count_distinct(user_id) FROM user_events SAMPLE BY %s", w.name)
+		},
+		// QuestDB only explains the plan, it cannot execute it with ANALYZE.
+		explainPrefix: "EXPLAIN ",
+		serverTiming:  true,
+		schemaProbe:   "SELECT user_id, timestamp, rssi, ssid FROM user_events LIMIT 1",
+		// Deduplication is applied when the WAL is applied to the table, so
+		// the count can briefly include rows that are about to be dropped.
+		dedup: dedupDialect{
+			schema: `
+		CREATE TABLE IF NOT EXISTS user_events (
+			ssid SYMBOL,
+			user_id SYMBOL,
+			rssi DOUBLE,
+			timestamp TIMESTAMP
+		) TIMESTAMP(timestamp) PARTITION BY DAY WAL DEDUP UPSERT KEYS(timestamp, user_id)`,
+			countQuery: "SELECT count() FROM user_events",
+		},
+		// The dimension tables have no designated timestamp, so they are
+		// neither partitioned nor written through the WAL and every INSERT is
+		// visible right away.
+		joins: joinDialect{
+			schema: []string{
+				"CREATE TABLE IF NOT EXISTS users (user_id SYMBOL, department SYMBOL, role SYMBOL)",
+				"CREATE TABLE IF NOT EXISTS access_points (ssid SYMBOL, building SYMBOL, floor_number INT)",
+			},
+			queries: []querySpec{
+				{id: 1, text: "SELECT a.building, count() AS count FROM user_events e JOIN access_points a ON a.ssid = e.ssid ORDER BY count DESC"},
+				{id: 2, text: "SELECT timestamp_floor('h', e.timestamp) AS hour, a.building, count_distinct(e.user_id) FROM user_events e JOIN access_points a ON a.ssid = e.ssid WHERE e.timestamp BETWEEN $1 AND dateadd('h', 24, $1) ORDER BY hour, a.building", args: atMiddle},
+				{id: 3, text: "SELECT u.department, a.building, avg(e.rssi) FROM user_events e JOIN users u ON u.user_id = e.user_id JOIN access_points a ON a.ssid = e.ssid ORDER BY u.department, a.building"},
+			},
+		},
+		// The rollup is filled with a SAMPLE BY insert-select. It bypasses the
+		// WAL so that it is complete when the INSERT returns.
+		downsampling: rollupDialect{
+			create: []string{
+				"CREATE TABLE user_events_hourly (timestamp TIMESTAMP, ssid SYMBOL, readings LONG, rssi_sum DOUBLE) TIMESTAMP(timestamp) PARTITION BY MONTH BYPASS WAL",
+				"INSERT INTO user_events_hourly SELECT timestamp, ssid, count() AS readings, sum(rssi) AS rssi_sum FROM user_events SAMPLE BY 1h",
+			},
+			queries: []querySpec{
+				{id: 2, text: "SELECT sum(readings) FROM user_events_hourly"},
+				{id: 4, text: "SELECT sum(rssi_sum) / sum(readings) FROM user_events_hourly"},
+				{id: 8, text: "SELECT timestamp, sum(readings) FROM user_events_hourly WHERE timestamp BETWEEN $1 AND dateadd('h', 24, $1) SAMPLE BY 1h LIMIT 24", args: atMiddle},
+				{id: 12, text: "SELECT ssid, sum(readings) AS count FROM user_events_hourly ORDER BY count DESC LIMIT 10"},
+				{id: 17, text: "SELECT hour(timestamp) AS hour, sum(readings) AS count FROM user_events_hourly ORDER BY hour"},
+				{id: 19, text: "SELECT timestamp, sum(readings) AS count FROM user_events_hourly ORDER BY count DESC LIMIT 5"},
+			},
+		},
+		buildings: buildingDialect{
+			table: func(name string) []string {
+				return []string{"CREATE TABLE " + name + " (ssid SYMBOL, user_id SYMBOL, rssi DOUBLE, timestamp TIMESTAMP) TIMESTAMP(timestamp) PARTITION BY DAY WAL"}
+			},
+			queries: func(tables []string) []querySpec {
+				return sqlBuildingQueries(tables, "timestamp_floor('h', timestamp)", "timestamp BETWEEN $1 AND dateadd('h', 24, $1)", atMiddle)
+			},
+		},
+		locations: locationDialect{
+			schema: []string{
+				"CREATE TABLE " + locatedTable + " (ssid SYMBOL, user_id SYMBOL, rssi DOUBLE, building SYMBOL, floor_number INT, latitude DOUBLE, longitude DOUBLE, timestamp TIMESTAMP) TIMESTAMP(timestamp) PARTITION BY DAY WAL",
+			},
+			queries: sqlLocationQueries("timestamp_floor('h', timestamp)", "timestamp BETWEEN $1 AND dateadd('h', 24, $1)", "count_distinct(user_id)", atMiddle),
+		},
+		// ILP adds the extra columns on their first write.
+		extraColumns: extraColumnDialect{
+			types:   map[string]string{extraText: "VARCHAR", extraInteger: "LONG", extraReal: "DOUBLE"},
+			groupBy: sqlGroupByExtra("count_distinct(user_id)"),
+		},
+		hourOfDayQuery: "SELECT hour(timestamp) AS hour, count() FROM user_events",
+		reconciliation: reconciliationDialect{
+			countQuery: "SELECT count() FROM user_events",
+			duplicates: "the WAL table keeps identical points without DEDUP keys; rows still pending in the WAL are not counted",
+		},
+		// The commit mode is a server setting, applied to managed containers.
+		durability: durabilityDialect{
+			show: "SELECT property_path, value FROM (SHOW PARAMETERS) WHERE property_path IN ('cairo.commit.mode', 'cairo.o3.max.lag', 'cairo.wal.enabled.default')",
+		},
+		// Rows are queryable once the WAL is applied to the table.
+		maintenance: []maintenanceOp{
+			{name: "wal-apply", wait: func(ctx context.Context, b backend) error {
+				return waitUntilIdle(ctx, "WAL transactions", pendingRows(b, "SELECT coalesce(sum(sequencerTxn - writerTxn), 0) FROM wal_tables() WHERE name = 'user_events'"))
+			}},
+			{name: "vacuum", statements: []string{"VACUUM TABLE user_events"}},
+		},
+		// The commit lag and the writer threads decide how soon ingested rows
+		// are queryable and how many tables are written at once.
+		serverSettings: "SELECT property_path, value FROM (SHOW PARAMETERS) WHERE property_path IN ('shared.worker.count', 'cairo.max.uncommitted.rows', 'cairo.o3.min.lag', 'cairo.o3.max.lag', 'cairo.commit.mode', 'cairo.wal.enabled.default', 'cairo.wal.apply.worker.count', 'line.tcp.commit.interval.default', 'line.tcp.commit.interval.fraction', 'line.tcp.writer.worker.count', 'pg.select.cache.enabled')",
+		retention:      true,
+		export:         true,
+		fidelity:       true,
+		container: containerSpec{
+			image:     "questdb/questdb:8.3.3",
+			ports:     []string{"9000:9000", "8812:8812"},
+			timingEnv: []string{"QDB_QUERY_TRACING_ENABLED=true"},
+			durabilityEnv: map[string][]string{
+				durabilityRelaxed: {"QDB_CAIRO_COMMIT_MODE=nosync"},
+				durabilityStrict:  {"QDB_CAIRO_COMMIT_MODE=sync"},
+			},
+		},
+		timeToInsight: timeToInsightDialect{
+			rollup: []string{
+				"CREATE TABLE user_events_hourly AS (SELECT timestamp, ssid, count() AS readings, count_distinct(user_id) AS users, avg(rssi) AS avg_rssi FROM user_events SAMPLE BY 1h) TIMESTAMP(timestamp) PARTITION BY DAY",
+			},
+			dashboard: []string{
+				"SELECT ssid, sum(users) AS users FROM user_events_hourly ORDER BY users DESC LIMIT 10",
+				"SELECT timestamp, sum(readings) AS readings FROM user_events_hourly SAMPLE BY 1h",
+				"SELECT ssid, avg(avg_rssi) AS rssi FROM user_events_hourly ORDER BY rssi LIMIT 10",
+				"SELECT timestamp, users FROM (SELECT timestamp, sum(users) AS users FROM user_events_hourly SAMPLE BY 1h) ORDER BY users DESC LIMIT 1",
+			},
+		},
+	}
+	registerBackend(info)
+
+	// questdb-pgwire runs the same workload but ingests over the PostgreSQL
+	// wire protocol, to quantify the cost of the protocol against ILP.
+	pgwire := info
+	pgwire.name = "questdb-pgwire"
+	pgwire.description = "QuestDB (PostgreSQL wire ingestion and queries)"
+	pgwire.open = func(connStr string) (backend, error) {
+		return newQuestPGWireBackend(connStr)
+	}
+	pgwire.ping = pingPostgres
+	// The table createSchema created gets the extra columns before the first
+	// write.
+	pgwire.extraColumns.addColumn = "ALTER TABLE user_events ADD COLUMN %s %s"
+	registerBackend(pgwire)
+}
+
+// splitQuestConnStr splits 'ingestUrl:::queryUrl': rows are written over ILP
+// and queries go through the PostgreSQL wire protocol.
+func splitQuestConnStr(connStr string) ([]string, error) {
+	connParts := strings.Split(connStr, ":::")
+	if len(connParts) != 2 {
+		return nil, fmt.Errorf("invalid connection string format, expected 'ingestUrl:::queryUrl'")
+	}
+	return connParts, nil
+}
+
+// questSenderConf applies the credential and TLS flags to the ILP part of the
+// connection string. The Go client only takes the system's CA certificates
+// (SSL_CERT_FILE) and no client certificates, so -tls-ca, -tls-cert and
+// -tls-server-name only apply to the PostgreSQL wire part.
+func questSenderConf(conf string) string {
+	schema, params, ok := strings.Cut(conf, "::")
+	if !ok {
+		// Left to the client to reject.
+		return conf
+	}
+
+	overrides := map[string]string{}
+	if tlsSettings.Enabled {
+		switch schema {
+		case "http":
+			schema = "https"
+		case "tcp":
+			schema = "tcps"
+		}
+		if tlsSettings.SkipVerify {
+			overrides["tls_verify"] = "unsafe_off"
+		}
+		if tlsSettings.CAFile != "" || tlsSettings.CertFile != "" || tlsSettings.ServerName != "" {
+			fmt.Println("[WARN] The QuestDB ILP client uses the system CA certificates and ignores -tls-ca, -tls-cert and -tls-server-name")
+		}
+	}
+	// TCP authenticates with a key id and an ECDSA key, which stay in the
+	// connection string.
+	if schema == "http" || schema == "https" {
+		if dbCredentials.User != "" {
+			overrides["username"] = dbCredentials.User
+			overrides["password"] = dbCredentials.Password
+		}
+		if dbCredentials.Token != "" {
+			overrides["token"] = dbCredentials.Token
+		}
+	}
+
+	// Values escape a semicolon by doubling it.
+	var fields []string
+	var field strings.Builder
+	for i := 0; i < len(params); i++ {
+		if params[i] == ';' {
+			if i+1 < len(params) && params[i+1] == ';' {
+				field.WriteString(";;")
+				i++
+				continue
+			}
+			fields = append(fields, field.String())
+			field.Reset()
+			continue
+		}
+		field.WriteByte(params[i])
+	}
+	if field.Len() > 0 {
+		fields = append(fields, field.String())
+	}
+
+	var kept []string
+	for _, f := range fields {
+		key, _, _ := strings.Cut(f, "=")
+		if _, replaced := overrides[key]; !replaced && f != "" {
+			kept = append(kept, f)
+		}
+	}
+	for _, key := range []string{"username", "password", "token", "tls_verify"} {
+		if value, ok := overrides[key]; ok {
+			kept = append(kept, key+"="+strings.ReplaceAll(value, ";", ";;"))
+		}
+	}
+	return schema + "::" + strings.Join(kept, ";") + ";"
+}
+
+type questBackend struct {
+	postgresBackend
+	sender qdb.LineSender
+	timing questTiming
+	// writes counts the points sent over ILP. Over HTTP a flush is a
+	// transaction the server confirms, so a failed one rejects the points
+	// of the call; over TCP the server drops what it cannot parse without
+	// telling the client, which only the reconciliation notices.
+	writes writeTracker
+}
+
+func newQuestBackend(connStr string) (*questBackend, error) {
+	connParts, err := splitQuestConnStr(connStr)
+	if err != nil {
+		return nil, err
+	}
+
+	sender, err := qdb.LineSenderFromConf(context.Background(), questSenderConf(connParts[0]))
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := newPgPool(connParts[1])
+	if err != nil {
+		return nil, err
+	}
+	return &questBackend{postgresBackend: postgresBackend{pool: pool}, sender: sender}, nil
+}
+
+// createSchema is a no-op unless a schema was set: QuestDB creates the table on
+// the first ILP write.
+func (b *questBackend) createSchema(ctx context.Context) error {
+	if b.schema == "" {
+		return nil
+	}
+	return b.postgresBackend.createSchema(ctx)
+}
+
+func (b *questBackend) ingest(ctx context.Context, readings []Reading) error {
+	return b.ingestInto(ctx, "user_events", readings)
+}
+
+func (b *questBackend) ingestInto(ctx context.Context, table string, readings []Reading) error {
+	return b.tracked(len(readings), b.sendReadings(ctx, table, readings))
+}
+
+func (b *questBackend) sendReadings(ctx context.Context, table string, readings []Reading) error {
+	for i := range readings {
+		reading := &readings[i]
+		sender := b.sender.Table(table).
+			Symbol("ssid", reading.Connection.Ssid).
+			Symbol("user_id", reading.UserId).
+			Float64Column("rssi", reading.Connection.Rssi)
+		// Unknown extra values are left out and stored as NULL; ILP adds
+		// the columns on their first write.
+		for j, value := range extraValues(reading) {
+			switch v := value.(type) {
+			case string:
+				sender = sender.StringColumn(extraColumns[j].Name, v)
+			case int64:
+				sender = sender.Int64Column(extraColumns[j].Name, v)
+			case float64:
+				sender = sender.Float64Column(extraColumns[j].Name, v)
+			}
+		}
+		if err := sender.At(ctx, readingTime(reading.LastUpdatedTime)); err != nil {
+			return err
+		}
+	}
+
+	return b.sender.Flush(ctx)
+}
+
+// ingestLocated writes the readings with their location over ILP; unknown
+// coordinates are left out and stored as NULL.
+func (b *questBackend) ingestLocated(ctx context.Context, readings []Reading) error {
+	return b.tracked(len(readings), b.sendLocated(ctx, readings))
+}
+
+func (b *questBackend) sendLocated(ctx context.Context, readings []Reading) error {
+	for i := range readings {
+		reading := &readings[i]
+		building, floor, lat, lon := locationValues(reading)
+		sender := b.sender.Table(locatedTable).
+			Symbol("ssid", reading.Connection.Ssid).
+			Symbol("user_id", reading.UserId).
+			Symbol("building", building).
+			Float64Column("rssi", reading.Connection.Rssi).
+			Int64Column("floor_number", int64(floor))
+		if lat != nil {
+			sender = sender.Float64Column("latitude", lat.(float64)).Float64Column("longitude", lon.(float64))
+		}
+		if err := sender.At(ctx, readingTime(reading.LastUpdatedTime)); err != nil {
+			return err
+		}
+	}
+
+	return b.sender.Flush(ctx)
+}
+
+// tracked counts the points of an ILP write, all of them rejected when the
+// write failed: the sender drops its buffer and the chunk is retried as a
+// whole.
+func (b *questBackend) tracked(points int, err error) error {
+	b.writes.sending(points)
+	if err != nil {
+		b.writes.reject(points, err)
+	}
+	return err
+}
+
+func (b *questBackend) asyncWrites() *AsyncWriteResult {
+	return b.writes.result()
+}
+
+// questExpireBefore drops the daily partitions older than the cutoff. QuestDB
+// cannot delete single rows, so the partition holding the cutoff is kept.
+func questExpireBefore(ctx context.Context, pool *pgxpool.Pool, cutoff time.Time) error {
+	_, err := pool.Exec(ctx, fmt.Sprintf("ALTER TABLE user_events DROP PARTITION WHERE timestamp < '%s'", cutoff.UTC().Format("2006-01-02T15:04:05.000000Z")))
+	return err
+}
+
+func (b *questBackend) expireBefore(ctx context.Context, cutoff time.Time) error {
+	return questExpireBefore(ctx, b.pool, cutoff)
+}
+
+// export streams the table over the PostgreSQL wire protocol; QuestDB's COPY
+// only imports.
+func (b *questBackend) export(ctx context.Context, w io.Writer) error {
+	return exportRows(ctx, b.pool, "SELECT * FROM user_events", w)
+}
+
+func (b *questBackend) close() {
+	b.sender.Close(context.Background())
+	b.pool.Close()
+}
+
+// questPGWireBackend writes rows with INSERT statements over the PostgreSQL wire
+// protocol. QuestDB's COPY only imports server-side files, so the INSERTs are
+// pipelined in one batch per chunk.
+type questPGWireBackend struct {
+	postgresBackend
+	timing questTiming
+}
+
+func newQuestPGWireBackend(connStr string) (*questPGWireBackend, error) {
+	pool, err := newPgPool(connStr)
+	if err != nil {
+		return nil, err
+	}
+	// Same layout as the table QuestDB creates on the first ILP write.
+	return &questPGWireBackend{postgresBackend: postgresBackend{pool: pool, schema: `
+		CREATE TABLE IF NOT EXISTS user_events (
+			ssid SYMBOL,
+			user_id SYMBOL,
+			rssi DOUBLE,
+			timestamp TIMESTAMP
+		) TIMESTAMP(timestamp) PARTITION BY DAY WAL`}}, nil
+}
+
+func (b *questPGWireBackend) ingest(ctx context.Context, readings []Reading) error {
+	return b.ingestInto(ctx, "user_events", readings)
+}
+
+func (b *questPGWireBackend) ingestInto(ctx context.Context, table string, readings []Reading) error {
+	columns, placeholders := extraInsertColumns(4, true)
+	insert := "INSERT INTO " + table + " (ssid, user_id, rssi, timestamp" + columns + ") VALUES ($1, $2, $3, $4" + placeholders + ")"
+	batch := getBatch(len(readings))
+	defer putBatch(batch)
+	for i := range readings {
+		reading := &readings[i]
+		batch.Queue(
+			insert,
+			append([]any{
+				reading.Connection.Ssid,
+				reading.UserId,
+				reading.Connection.Rssi,
+				readingTime(reading.LastUpdatedTime),
+			}, extraValues(reading)...)...,
+		)
+	}
+
+	return b.pool.SendBatch(ctx, batch).Close()
+}
+
+func (b *questPGWireBackend) ingestLocated(ctx context.Context, readings []Reading) error {
+	insert := "INSERT INTO " + locatedTable + " (ssid, user_id, rssi, building, floor_number, latitude, longitude, timestamp) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)"
+	batch := getBatch(len(readings))
+	defer putBatch(batch)
+	for i := range readings {
+		reading := &readings[i]
+		building, floor, lat, lon := locationValues(reading)
+		batch.Queue(
+			insert,
+			reading.Connection.Ssid,
+			reading.UserId,
+			reading.Connection.Rssi,
+			building,
+			floor,
+			lat,
+			lon,
+			readingTime(reading.LastUpdatedTime),
+		)
+	}
+
+	return b.pool.SendBatch(ctx, batch).Close()
+}
+
+func (b *questPGWireBackend) export(ctx context.Context, w io.Writer) error {
+	return exportRows(ctx, b.pool, "SELECT * FROM user_events", w)
+}
+
+func (b *questPGWireBackend) expireBefore(ctx context.Context, cutoff time.Time) error {
+	return questExpireBefore(ctx, b.pool, cutoff)
+}
+
+// questTiming reads the _query_trace table QuestDB fills when query tracing is
+// enabled (QDB_QUERY_TRACING_ENABLED=true); both QuestDB backends query over
+// PGWire.
+type questTiming struct {
+	// since is the server time of the last mark.
+	since time.Time
+}
+
+func (t *questTiming) mark(ctx context.Context, pool *pgxpool.Pool) error {
+	return pool.QueryRow(ctx, "SELECT now()").Scan(&t.since)
+}
+
+func (t *questTiming) meanMs(ctx context.Context, pool *pgxpool.Pool) (float64, int, error) {
+	var mean *float64
+	var statements int
+	err := pool.QueryRow(ctx, `SELECT avg(execution_micros) / 1000.0, count()
+		FROM _query_trace
+		WHERE ts >= $1 AND query_text NOT LIKE '%_query_trace%' AND query_text NOT LIKE 'SELECT now()%'`, t.since).Scan(&mean, &statements)
+	if err != nil || mean == nil {
+		return 0, 0, err
+	}
+	return *mean, statements, nil
+}
+
+func (b *questBackend) markServerTime(ctx context.Context) error {
+	return b.timing.mark(ctx, b.pool)
+}
+
+func (b *questBackend) serverTimeMs(ctx context.Context) (float64, int, error) {
+	return b.timing.meanMs(ctx, b.pool)
+}
+
+func (b *questPGWireBackend) markServerTime(ctx context.Context) error {
+	return b.timing.mark(ctx, b.pool)
+}
+
+func (b *questPGWireBackend) serverTimeMs(ctx context.Context) (float64, int, error) {
+	return b.timing.meanMs(ctx, b.pool)
+}
@@ -0,0 +1,280 @@
+//go:build !notimescaledb
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func init() {
+	registerBackend(backendInfo{
+		name:        "timescaledb",
+		description: "TimescaleDB hypertable (pgx, COPY ingestion, tiered storage)",
+		open: func(connStr string) (backend, error) {
+			return newTimescaleBackend(connStr)
+		},
+		ping:        pingPostgres,
+		isTransient: isTransientPostgres,
+		queries: []querySpec{
+			{id: 1, text: "SELECT MIN(timestamp), MAX(timestamp) FROM user_events"},
+			{id: 2, text: "SELECT COUNT(*) FROM user_events"},
+			{id: 3, text: "SELECT COUNT(DISTINCT user_id) FROM user_events"},
+			{id: 4, text: "SELECT AVG(rssi) FROM user_events"},
+			{id: 5, text: "SELECT COUNT(*) FROM user_events WHERE timestamp < $1", args: atMiddle},
+			{id: 6, text: "SELECT COUNT(*) FROM user_events WHERE timestamp > $1", args: atMiddle},
+			{id: 7, text: "SELECT COUNT(*) FROM user_events WHERE timestamp BETWEEN $1 AND $2", args: aroundMiddle},
+			{id: 8, text: "SELECT date_trunc('hour', timestamp) as hour, COUNT(*) FROM user_events WHERE timestamp BETWEEN $1 AND $2 GROUP BY hour ORDER BY hour", args: dayFromMiddle},
+			{id: 9, text: "SELECT user_id, COUNT(*) as count FROM user_events GROUP BY user_id ORDER BY count DESC LIMIT 10"},
+			{id: 10, text: "SELECT COUNT(*) FROM user_events WHERE rssi > -50"},
+			{id: 11, text: "SELECT COUNT(*) FROM user_events WHERE rssi < -80"},
+			{id: 12, text: "SELECT ssid, COUNT(*) as count FROM user_events GROUP BY ssid ORDER BY count DESC LIMIT 10"},
+			{id: 13, text: "SELECT user_id, AVG(rssi), MIN(rssi), MAX(rssi) FROM user_events GROUP BY user_id ORDER BY AVG(rssi) DESC LIMIT 100"},
+			{id: 14, text: "SELECT percentile_cont(0.25) WITHIN GROUP (ORDER BY rssi) as q1, percentile_cont(0.5) WITHIN GROUP (ORDER BY rssi) as median, percentile_cont(0.75) WITHIN GROUP (ORDER BY rssi) as q3 FROM user_events"},
+			{id: 15, text: "SELECT COUNT(*) FROM user_events WHERE timestamp BETWEEN $1 AND $2", args: firstHalf},
+			{id: 16, text: "SELECT COUNT(*) FROM user_events WHERE timestamp BETWEEN $1 AND $2", args: secondHalf},
+			{id: 17, text: "SELECT EXTRACT(hour FROM timestamp) as hour, COUNT(*) as count FROM user_events GROUP BY hour ORDER BY hour"},
+			{id: 18, text: "SELECT DATE(timestamp) as day, VARIANCE(rssi) as rssi_variance FROM user_events GROUP BY day ORDER BY day LIMIT 30"},
+			{id: 19, text: "SELECT date_trunc('hour', timestamp) as hour, COUNT(*) as count FROM user_events GROUP BY hour ORDER BY count DESC LIMIT 5"},
+			{id: 20, text: "SELECT user_id, COUNT(*) AS sessions, SUM(session_end - session_start) AS session_duration FROM (SELECT user_id, MIN(timestamp) AS session_start, MAX(timestamp) AS session_end FROM (SELECT user_id, timestamp, SUM(new_session) OVER (PARTITION BY user_id ORDER BY timestamp) AS session_id FROM (SELECT user_id, timestamp, CASE WHEN timestamp - LAG(timestamp) OVER (PARTITION BY user_id ORDER BY timestamp) > INTERVAL '30 minutes' THEN 1 ELSE 0 END AS new_session FROM user_events) gaps) numbered GROUP BY user_id, session_id) sessions GROUP BY user_id ORDER BY session_duration DESC LIMIT 10"},
+			{id: 21, text: "SELECT AVG(moving_rssi) FROM (SELECT AVG(rssi) OVER (PARTITION BY user_id ORDER BY timestamp RANGE BETWEEN INTERVAL '15 minutes' PRECEDING AND CURRENT ROW) AS moving_rssi FROM user_events WHERE timestamp BETWEEN $1 AND $2) w", args: dayFromMiddle},
+			{id: 22, text: "SELECT time_bucket_gapfill('1 hour', timestamp) AS hour, ssid, COALESCE(COUNT(*), 0) FROM user_events WHERE timestamp >= $1 AND timestamp < $2 GROUP BY hour, ssid ORDER BY hour, ssid", args: dayFromMiddle},
+			{id: 23, text: "SELECT time_bucket_gapfill('1 hour', timestamp) AS hour, ssid, locf(AVG(rssi)) FROM user_events WHERE timestamp >= $1 AND timestamp < $2 GROUP BY hour, ssid ORDER BY hour, ssid", args: dayFromMiddle},
+			{id: 24, text: "SELECT time_bucket('15 minutes', timestamp) AS bucket, ssid, COUNT(DISTINCT user_id) FROM user_events WHERE timestamp >= $1 AND timestamp < $2 GROUP BY bucket, ssid ORDER BY bucket, ssid", args: dayFromMiddle},
+		},
+		capabilities: &Capabilities{
+			Percentiles:     true,
+			WindowFunctions: true,
+			GapFill:         true,
+			ApproxDistinct:  false,
+			Updates:         true,
+			Deletes:         true,
+			Note:            "approximate distinct counts need the TimescaleDB Toolkit extension",
+		},
+		bucketQuery: func(w bucketWidth) string {
+			return fmt.Sprintf("SELECT time_bucket('%s', timestamp) AS bucket, ssid, COUNT(DISTINCT user_id) FROM user_events GROUP BY bucket, ssid ORDER BY bucket", w.interval)
+		},
+		explainPrefix:  "EXPLAIN (ANALYZE, BUFFERS) ",
+		tiered:         true,
+		serverTiming:   true,
+		schemaProbe:    "SELECT user_id, timestamp, rssi, ssid FROM user_events LIMIT 1",
+		hourOfDayQuery: "SELECT EXTRACT(hour FROM timestamp AT TIME ZONE 'UTC')::int AS hour, COUNT(*) FROM user_events GROUP BY hour",
+		reconciliation: reconciliationDialect{
+			countQuery: "SELECT COUNT(*) FROM user_events",
+			duplicates: "every point is stored as its own row, identical ones included",
+		},
+		extraColumns: sqlExtraColumns,
+		durability:   pgDurability,
+		// Chunks are compressed first, as a compression policy would, and
+		// then vacuumed.
+		maintenance: []maintenanceOp{
+			{name: "compress-chunks", statements: []string{
+				"ALTER TABLE user_events SET (timescaledb.compress, timescaledb.compress_segmentby = 'ssid', timescaledb.compress_orderby = 'timestamp DESC')",
+				"SELECT compress_chunk(c, if_not_compressed => true) FROM show_chunks('user_events') c",
+			}},
+			{name: "vacuum-analyze", statements: []string{"VACUUM ANALYZE user_events"}},
+		},
+		serverSettings: pgSettings("timescaledb.max_background_workers"),
+		retention:      true,
+		export:         true,
+		fidelity:       true,
+		container: containerSpec{
+			image: "timescale/timescaledb:2.17.2-pg17",
+			ports: []string{"5432:5432"},
+			env:   []string{"POSTGRES_PASSWORD=example", "POSTGRES_USER=postgres", "POSTGRES_DB=tsdb"},
+			// Replaces the image's setting, which only preloads timescaledb.
+			timingArgs: []string{"-c", "shared_preload_libraries=timescaledb,pg_stat_statements"},
+		},
+		timeToInsight: timeToInsightDialect{
+			rollup: []string{
+				"CREATE TABLE user_events_hourly AS SELECT time_bucket('1 hour', timestamp) AS hour, ssid, COUNT(*) AS readings, COUNT(DISTINCT user_id) AS users, AVG(rssi) AS avg_rssi FROM user_events GROUP BY 1, 2",
+			},
+			dashboard: sqlDashboardBundle,
+		},
+		joins: joinDialect{
+			schema: sqlDimensionTables,
+			queries: []querySpec{
+				{id: 1, text: "SELECT a.building, COUNT(*) AS count FROM user_events e JOIN access_points a ON a.ssid = e.ssid GROUP BY a.building ORDER BY count DESC"},
+				{id: 2, text: "SELECT time_bucket('1 hour', e.timestamp) AS hour, a.building, COUNT(DISTINCT e.user_id) FROM user_events e JOIN access_points a ON a.ssid = e.ssid WHERE e.timestamp >= $1 AND e.timestamp < $2 GROUP BY hour, a.building ORDER BY hour, a.building", args: dayFromMiddle},
+				{id: 3, text: "SELECT u.department, a.building, AVG(e.rssi) FROM user_events e JOIN users u ON u.user_id = e.user_id JOIN access_points a ON a.ssid = e.ssid GROUP BY u.department, a.building ORDER BY u.department, a.building"},
+			},
+		},
+		// The building hypertables use the positional create_hypertable, which
+		// every 2.x version still accepts, and the default chunk interval.
+		buildings: buildingDialect{
+			table: func(name string) []string {
+				return []string{
+					"CREATE TABLE " + name + " (id BIGSERIAL, user_id VARCHAR(255) NOT NULL, timestamp TIMESTAMP WITH TIME ZONE NOT NULL, rssi REAL NOT NULL, ssid VARCHAR(255) NOT NULL)",
+					"SELECT create_hypertable('" + name + "', 'timestamp', chunk_time_interval => INTERVAL '" + defaultChunkInterval + "')",
+				}
+			},
+			queries: func(tables []string) []querySpec {
+				return sqlBuildingQueries(tables, "time_bucket('1 hour', timestamp)", "timestamp >= $1 AND timestamp < $2", dayFromMiddle)
+			},
+		},
+		locations: locationDialect{
+			schema: []string{
+				"CREATE TABLE " + locatedTable + " (id BIGSERIAL, user_id VARCHAR(255) NOT NULL, timestamp TIMESTAMP WITH TIME ZONE NOT NULL, rssi REAL NOT NULL, ssid VARCHAR(255) NOT NULL, building VARCHAR(255) NOT NULL, floor_number INTEGER NOT NULL, latitude DOUBLE PRECISION, longitude DOUBLE PRECISION)",
+				"SELECT create_hypertable('" + locatedTable + "', 'timestamp', chunk_time_interval => INTERVAL '" + defaultChunkInterval + "')",
+			},
+			queries: sqlLocationQueries("time_bucket('1 hour', timestamp)", "timestamp >= $1 AND timestamp < $2", "COUNT(DISTINCT user_id)", dayFromMiddle),
+		},
+		dedup: dedupDialect{
+			schema:     timescaleDedupTable,
+			countQuery: "SELECT COUNT(*) FROM user_events",
+			upsert:     true,
+		},
+		compression: []string{
+			"ALTER TABLE user_events SET (timescaledb.compress, timescaledb.compress_segmentby = 'ssid', timescaledb.compress_orderby = 'timestamp DESC')",
+			"SELECT compress_chunk(c) FROM show_chunks('user_events') c",
+		},
+		downsampling: rollupDialect{
+			create: []string{
+				"CREATE MATERIALIZED VIEW user_events_hourly WITH (timescaledb.continuous) AS SELECT time_bucket('1 hour', timestamp) AS hour, ssid, COUNT(*) AS readings, SUM(rssi) AS rssi_sum FROM user_events GROUP BY hour, ssid WITH DATA",
+			},
+			queries: sqlHourlyRollupQueries,
+		},
+		continuousAggregates: rollupDialect{
+			create: []string{
+				"CREATE MATERIALIZED VIEW user_events_hourly_cagg WITH (timescaledb.continuous) AS SELECT time_bucket('1 hour', timestamp) AS hour, COUNT(*) AS readings FROM user_events GROUP BY hour WITH DATA",
+				"CREATE MATERIALIZED VIEW user_events_daily_cagg WITH (timescaledb.continuous) AS SELECT time_bucket('1 day', timestamp) AS day, VARIANCE(rssi) AS rssi_variance FROM user_events GROUP BY day WITH DATA",
+			},
+			queries: []querySpec{
+				{id: 8, text: "SELECT hour, readings FROM user_events_hourly_cagg WHERE hour BETWEEN $1 AND $2 ORDER BY hour", args: dayFromMiddle},
+				{id: 17, text: "SELECT EXTRACT(hour FROM hour) as hour_of_day, SUM(readings) as count FROM user_events_hourly_cagg GROUP BY hour_of_day ORDER BY hour_of_day"},
+				{id: 18, text: "SELECT day, rssi_variance FROM user_events_daily_cagg ORDER BY day LIMIT 30"},
+				{id: 19, text: "SELECT hour, readings as count FROM user_events_hourly_cagg ORDER BY readings DESC LIMIT 5"},
+			},
+		},
+	})
+}
+
+const timescaleTable = `
+		CREATE TABLE user_events (
+			id BIGSERIAL,
+			user_id VARCHAR(255) NOT NULL,
+			timestamp TIMESTAMP WITH TIME ZONE NOT NULL,
+			rssi REAL NOT NULL,
+			ssid VARCHAR(255) NOT NULL
+		)`
+
+// timescaleDedupTable adds the uniqueness key of the duplicates scenario. Unique
+// constraints of a hypertable have to include its partitioning column.
+const timescaleDedupTable = `
+		CREATE TABLE user_events (
+			id BIGSERIAL,
+			user_id VARCHAR(255) NOT NULL,
+			timestamp TIMESTAMP WITH TIME ZONE NOT NULL,
+			rssi REAL NOT NULL,
+			ssid VARCHAR(255) NOT NULL,
+			UNIQUE (user_id, timestamp)
+		)`
+
+// defaultChunkInterval is the chunk interval used when -chunk-interval is not
+// given.
+const defaultChunkInterval = "4 hours"
+
+type timescaleBackend struct {
+	postgresBackend
+	chunkInterval string
+}
+
+func newTimescaleBackend(connStr string) (*timescaleBackend, error) {
+	b, err := newPostgresBackend(connStr, timescaleTable)
+	if err != nil {
+		return nil, err
+	}
+	return &timescaleBackend{postgresBackend: *b, chunkInterval: defaultChunkInterval}, nil
+}
+
+func (b *timescaleBackend) setChunkInterval(interval string) {
+	b.chunkInterval = interval
+}
+
+// createSchema turns user_events into a hypertable with the API of the
+// installed extension: the tsdb.hypertable table option from 2.20 on,
+// by_range() from 2.13 on and the positional create_hypertable() before that.
+func (b *timescaleBackend) createSchema(ctx context.Context) error {
+	var version string
+	err := b.pool.QueryRow(ctx, "SELECT extversion FROM pg_extension WHERE extname = 'timescaledb'").Scan(&version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("the timescaledb extension is not installed")
+	}
+	if err != nil {
+		return err
+	}
+
+	var major, minor int
+	if _, err := fmt.Sscanf(version, "%d.%d", &major, &minor); err != nil {
+		return fmt.Errorf("unrecognised timescaledb version %q: %w", version, err)
+	}
+
+	// Let the server parse the interval, so that only its canonical form is
+	// spliced into the DDL.
+	var interval string
+	if err := b.pool.QueryRow(ctx, "SELECT $1::interval::text", b.chunkInterval).Scan(&interval); err != nil {
+		return fmt.Errorf("invalid chunk interval %q: %w", b.chunkInterval, err)
+	}
+	infof("TimescaleDB %s, chunk interval %s\n", version, interval)
+
+	var ddl string
+	switch {
+	case major > 2 || major == 2 && minor >= 20:
+		ddl = fmt.Sprintf("%s WITH (tsdb.hypertable, tsdb.partition_column = 'timestamp', tsdb.chunk_interval = '%s')", b.schema, interval)
+	case major == 2 && minor >= 13:
+		ddl = fmt.Sprintf("%s; SELECT create_hypertable('user_events', by_range('timestamp', INTERVAL '%s'))", b.schema, interval)
+	default:
+		ddl = fmt.Sprintf("%s; SELECT create_hypertable('user_events', 'timestamp', chunk_time_interval => INTERVAL '%s')", b.schema, interval)
+	}
+	return b.exec(ctx, ddl)
+}
+
+// serverVersion adds the version of the extension, released apart from PostgreSQL.
+func (b *timescaleBackend) serverVersion(ctx context.Context) (string, error) {
+	version, err := b.postgresBackend.serverVersion(ctx)
+	if err != nil {
+		return "", err
+	}
+	var extension string
+	if err := b.pool.QueryRow(ctx, "SELECT extversion FROM pg_extension WHERE extname = 'timescaledb'").Scan(&extension); err != nil {
+		return version, err
+	}
+	return version + ", TimescaleDB " + extension, nil
+}
+
+// expireBefore drops whole chunks, the way a retention policy does; rows of a
+// chunk that straddles the cutoff are kept.
+func (b *timescaleBackend) expireBefore(ctx context.Context, cutoff time.Time) error {
+	_, err := b.pool.Exec(ctx, "SELECT drop_chunks('user_events', older_than => $1::timestamptz)", cutoff)
+	return err
+}
+
+// moveToColdTier moves whole chunks to another tablespace. The tablespace has
+// to exist on the server, typically backed by slower storage.
+func (b *timescaleBackend) moveToColdTier(ctx context.Context, cutoff time.Time, target string) (int, error) {
+	rows, err := b.pool.Query(ctx, `
+		SELECT move_chunk(chunk => c, destination_tablespace => $1, index_destination_tablespace => $1)
+		FROM show_chunks('user_events', older_than => $2::timestamptz) c`, target, cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	moved := 0
+	for rows.Next() {
+		moved++
+	}
+	rows.Close()
+	return moved, rows.Err()
+}
+
+// tableSize includes the chunks, their indexes and, once compressed, the
+// compressed chunks of the hypertable.
+func (b *timescaleBackend) tableSize(ctx context.Context) (int64, error) {
+	var size int64
+	err := b.pool.QueryRow(ctx, "SELECT hypertable_size('user_events')").Scan(&size)
+	return size, err
+}
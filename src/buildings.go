@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"src/results"
+)
+
+// buildingDialect is the per-building layout of a backend: one table or
+// measurement per building instead of the single user_events table, so the
+// schema split can be compared with a regular run on the same data.
+type buildingDialect struct {
+	// table returns the statements that create the table of one building.
+	table func(name string) []string
+	// queries renders the queries of buildingQueryDescriptions for the
+	// building tables. The single-building queries use tables[0].
+	queries func(tables []string) []querySpec
+}
+
+// tableIngester is implemented by backends that can write readings to a table
+// other than user_events with the same ingestion path.
+type tableIngester interface {
+	ingestInto(ctx context.Context, table string, readings []Reading) error
+}
+
+var buildingQueryDescriptions = []string{
+	1: "Count all records across buildings",
+	2: "Average RSSI across buildings",
+	3: "24 hours aggregation from middle time across buildings",
+	4: "Count records of one building",
+	5: "24 hours aggregation from middle time of one building",
+}
+
+// unassignedBuilding holds the readings of access points missing from the
+// dimension dataset.
+const unassignedBuilding = "unassigned"
+
+// buildingTable is the table of a building, e.g. user_events_building_03.
+func buildingTable(building string) string {
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, strings.ToLower(building))
+	return "user_events_" + name
+}
+
+// buildingRouter routes every reading to the table of its access point's
+// building, so the regular ingestion loop can fill the per-building tables.
+// It also tracks the time range, which the queries derive their arguments
+// from.
+type buildingRouter struct {
+	backend
+	ingester tableIngester
+	dialect  buildingDialect
+	// tables lists the building tables, the one of unassignedBuilding last.
+	tables  []string
+	tableOf map[string]string
+	minTime int
+	maxTime int
+}
+
+func newBuildingRouter(b backend, ingester tableIngester, dialect buildingDialect, dims dimensions) *buildingRouter {
+	router := &buildingRouter{backend: b, ingester: ingester, dialect: dialect, tableOf: map[string]string{}}
+	for _, ap := range dims.AccessPoints {
+		table := buildingTable(ap.Building)
+		router.tableOf[ap.Ssid] = table
+		if !slices.Contains(router.tables, table) {
+			router.tables = append(router.tables, table)
+		}
+	}
+	slices.Sort(router.tables)
+	router.tables = append(router.tables, buildingTable(unassignedBuilding))
+	return router
+}
+
+func (b *buildingRouter) createSchema(ctx context.Context) error {
+	for _, table := range b.tables {
+		for _, stmt := range b.dialect.table(table) {
+			if err := b.exec(ctx, stmt); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (b *buildingRouter) ingest(ctx context.Context, readings []Reading) error {
+	byTable := map[string][]Reading{}
+	for _, reading := range readings {
+		table, ok := b.tableOf[reading.Connection.Ssid]
+		if !ok {
+			table = buildingTable(unassignedBuilding)
+		}
+		byTable[table] = append(byTable[table], reading)
+
+		if b.minTime == 0 || reading.LastUpdatedTime < b.minTime {
+			b.minTime = reading.LastUpdatedTime
+		}
+		b.maxTime = max(b.maxTime, reading.LastUpdatedTime)
+	}
+
+	for _, table := range b.tables {
+		if len(byTable[table]) == 0 {
+			continue
+		}
+		if err := b.ingester.ingestInto(ctx, table, byTable[table]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *buildingRouter) bounds() queryBounds {
+	return newQueryBounds(readingTime(b.minTime), readingTime(b.maxTime))
+}
+
+// unionAll repeats the statement format once per table and joins the copies
+// with UNION ALL.
+func unionAll(tables []string, format string) string {
+	parts := make([]string, len(tables))
+	for i, table := range tables {
+		parts[i] = fmt.Sprintf(format, table)
+	}
+	return strings.Join(parts, " UNION ALL ")
+}
+
+// sqlBuildingQueries renders the per-building queries of the SQL dialects.
+// hour truncates the timestamp to the hour and day selects the 24 hours from
+// the middle time with the arguments returned by args.
+func sqlBuildingQueries(tables []string, hour string, day string, args func(queryBounds) []any) []querySpec {
+	return []querySpec{
+		{id: 1, text: "SELECT SUM(count) FROM (" + unionAll(tables, "SELECT COUNT(*) AS count FROM %s") + ") t"},
+		{id: 2, text: "SELECT SUM(total) / SUM(count) FROM (" + unionAll(tables, "SELECT SUM(rssi) AS total, COUNT(*) AS count FROM %s") + ") t"},
+		{id: 3, text: "SELECT hour, SUM(count) FROM (" + unionAll(tables, "SELECT "+hour+" AS hour, COUNT(*) AS count FROM %s WHERE "+day+" GROUP BY hour") + ") t GROUP BY hour ORDER BY hour", args: args},
+		{id: 4, text: "SELECT COUNT(*) FROM " + tables[0]},
+		{id: 5, text: "SELECT " + hour + " AS hour, COUNT(*) FROM " + tables[0] + " WHERE " + day + " GROUP BY hour ORDER BY hour", args: args},
+	}
+}
+
+// benchmarkBuildings ingests the dataset into one table per building, using
+// the buildings of the dimension dataset, and times queries that span every
+// building table against queries on a single one. They correspond to catalog
+// queries 2, 4 and 8 of a regular run.
+func benchmarkBuildings(info backendInfo, connStr string, outFile string, opts benchmarkOptions) error {
+	if info.buildings.table == nil {
+		return fmt.Errorf("per-building scenario is not available for %s", info.name)
+	}
+	if opts.SchemaVariant != "" || opts.ChunkInterval != "" || opts.Shards > 0 {
+		return fmt.Errorf("the per-building scenario uses its own schema")
+	}
+
+	dims, err := loadDimensions(opts)
+	if err != nil {
+		return err
+	}
+
+	raw, err := info.open(connStr)
+	if err != nil {
+		return err
+	}
+	defer raw.close()
+
+	ingester, ok := raw.(tableIngester)
+	if !ok {
+		return fmt.Errorf("backend %s cannot write to other tables", info.name)
+	}
+	if err := configureBackend(info, raw, opts); err != nil {
+		return err
+	}
+	b := newBuildingRouter(raw, ingester, info.buildings, dims)
+	infof("Per-building: splitting the readings over %d tables\n", len(b.tables))
+
+	ctx := context.Background()
+	scenario := ScenarioResult{Name: "buildings"}
+	if err := loadScenarioData(ctx, info, b, opts, &scenario); err != nil {
+		return err
+	}
+
+	bounds := b.bounds()
+	queries := info.buildings.queries(b.tables)
+	for id := 1; id < len(buildingQueryDescriptions); id++ {
+		idx := slices.IndexFunc(queries, func(q querySpec) bool { return q.id == id })
+		if idx < 0 {
+			scenario.Queries = append(scenario.Queries, QueryResult{
+				QueryId:     id,
+				DurationMs:  -1,
+				Description: buildingQueryDescriptions[id],
+			})
+			continue
+		}
+		q := queries[idx]
+
+		infof("Running per-building query %d: %s\n", id, buildingQueryDescriptions[id])
+		samples, err := repeatQuery(opts.QueryRepeats, func() error {
+			return b.query(ctx, q.text, q.arguments(bounds)...)
+		})
+		duration := results.MedianMs(samples)
+		if err != nil {
+			if !info.lenientQueries {
+				return err
+			}
+			duration = -1
+			samples = nil
+		}
+
+		result := QueryResult{
+			QueryId:     id,
+			DurationMs:  duration,
+			Description: buildingQueryDescriptions[id],
+		}
+		if len(samples) > 1 {
+			result.SamplesMs = samples
+		}
+		scenario.Queries = append(scenario.Queries, result)
+	}
+
+	return writeResults(outFile, BenchmarkResults{
+		DbType:    info.name,
+		Seed:      opts.Seed,
+		Scenarios: []ScenarioResult{scenario},
+	})
+}
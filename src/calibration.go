@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"src/results"
+)
+
+const (
+	// calibrationTimerCalls is the number of clock reads the timer overhead
+	// is averaged over.
+	calibrationTimerCalls = 1_000_000
+	// calibrationReadings is the size of the synthetic chunk the decoding is
+	// timed on, about a chunk of the campus dataset.
+	calibrationReadings = 10_000
+	// calibrationDecodeTime is how long the chunk is decoded again and again.
+	calibrationDecodeTime = 500 * time.Millisecond
+	// calibrationRoundTrips is the number of timed round trips to the
+	// loopback server, after as many as a tenth of them untimed.
+	calibrationRoundTrips = 10_000
+)
+
+// CalibrationResult is the overhead of the benchmark client itself, measured
+// before the run against no database at all: the clock the durations are
+// taken with, decoding the JSON chunks and a round trip over loopback TCP to
+// a server that answers at once. It is the noise floor below which the
+// differences between databases are the client's.
+type CalibrationResult struct {
+	// TimerOverheadNs is the mean cost of reading the clock and
+	// TimerResolutionNs the smallest step it was seen to advance by.
+	TimerOverheadNs   float64 `json:"timerOverheadNs"`
+	TimerResolutionNs int64   `json:"timerResolutionNs"`
+	// DecodeReadingsPerSec and DecodeMBPerSec are the rates at which the
+	// client decodes a chunk of readings.
+	DecodeReadingsPerSec float64           `json:"decodeReadingsPerSec"`
+	DecodeMBPerSec       float64           `json:"decodeMBPerSec"`
+	Loopback             LoopbackRoundTrip `json:"loopback"`
+}
+
+// LoopbackRoundTrip summarizes the round trips of one byte to a no-op server
+// on the loopback interface, the least a query of a networked database can
+// take from the client.
+type LoopbackRoundTrip struct {
+	RoundTrips int `json:"roundTrips"`
+	results.Latencies
+}
+
+// calibrate measures the overhead of the client. It needs no database, so it
+// runs before the schema is created.
+func calibrate() (*CalibrationResult, error) {
+	calibration := &CalibrationResult{}
+	calibration.TimerOverheadNs, calibration.TimerResolutionNs = measureTimer()
+	if err := measureDecoding(calibration); err != nil {
+		return nil, err
+	}
+	loopback, err := measureLoopback()
+	if err != nil {
+		return nil, fmt.Errorf("loopback calibration: %w", err)
+	}
+	calibration.Loopback = loopback
+	infof("Calibration: clock read %.0f ns (resolution %d ns), decoding %.0f readings/s (%.1f MB/s), loopback round trip p50 %.3f ms, p99 %.3f ms\n",
+		calibration.TimerOverheadNs, calibration.TimerResolutionNs, calibration.DecodeReadingsPerSec, calibration.DecodeMBPerSec,
+		loopback.P50Ms, loopback.P99Ms)
+	return calibration, nil
+}
+
+// measureTimer returns the mean duration of a clock read in nanoseconds and
+// the smallest non-zero difference between two consecutive reads.
+func measureTimer() (float64, int64) {
+	start := time.Now()
+	for i := 0; i < calibrationTimerCalls; i++ {
+		_ = time.Now()
+	}
+	overhead := float64(time.Since(start).Nanoseconds()) / calibrationTimerCalls
+
+	resolution := time.Duration(0)
+	previous := time.Now()
+	for i := 0; i < calibrationTimerCalls/10; i++ {
+		now := time.Now()
+		if step := now.Sub(previous); step > 0 && (resolution == 0 || step < resolution) {
+			resolution = step
+		}
+		previous = now
+	}
+	return overhead, resolution.Nanoseconds()
+}
+
+// measureDecoding decodes a synthetic chunk of readings in the format of the
+// dataset until calibrationDecodeTime has passed.
+func measureDecoding(calibration *CalibrationResult) error {
+	chunk := ReadingFile{Response: make([]Reading, calibrationReadings)}
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	for i := range chunk.Response {
+		reading := &chunk.Response[i]
+		reading.UserId = "user-" + strconv.Itoa(i%1000)
+		reading.LastUpdatedTime = int(at) + i
+		reading.Connection.Ssid = "ssid-" + strconv.Itoa(i%50)
+		reading.Connection.Rssi = float64(-40 - i%50)
+	}
+	encoded, err := json.Marshal(chunk)
+	if err != nil {
+		return err
+	}
+	decoded := 0
+	start := time.Now()
+	for time.Since(start) < calibrationDecodeTime {
+		var data ReadingFile
+		if err := json.Unmarshal(encoded, &data); err != nil {
+			return err
+		}
+		decoded++
+	}
+	elapsed := time.Since(start).Seconds()
+	calibration.DecodeReadingsPerSec = float64(decoded*calibrationReadings) / elapsed
+	calibration.DecodeMBPerSec = float64(decoded*len(encoded)) / elapsed / 1e6
+	return nil
+}
+
+// measureLoopback times round trips of one byte to a server on 127.0.0.1
+// that writes every byte it reads straight back.
+func measureLoopback() (LoopbackRoundTrip, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return LoopbackRoundTrip{}, err
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.Copy(conn, conn)
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		return LoopbackRoundTrip{}, err
+	}
+	defer conn.Close()
+	warmup := calibrationRoundTrips / 10
+	samples := make([]time.Duration, 0, calibrationRoundTrips)
+	request, reply := []byte{0}, make([]byte, 1)
+	for i := 0; i < warmup+calibrationRoundTrips; i++ {
+		start := time.Now()
+		if _, err := conn.Write(request); err != nil {
+			return LoopbackRoundTrip{}, err
+		}
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return LoopbackRoundTrip{}, err
+		}
+		if i >= warmup {
+			samples = append(samples, time.Since(start))
+		}
+	}
+	return LoopbackRoundTrip{RoundTrips: len(samples), Latencies: results.Summarize(samples)}, nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import "strings"
+
+// Capabilities declares what the query language and storage of an engine
+// support. It is recorded in the results, so the report can tell a query
+// the engine cannot express, recorded as -1, from one that failed; the
+// categories of the query name the capability it needs.
+type Capabilities struct {
+	// Percentiles covers exact or approximate quantile aggregates.
+	Percentiles bool `json:"percentiles"`
+	// WindowFunctions covers moving aggregates over ordered rows and the
+	// LAG of the sessionization.
+	WindowFunctions bool `json:"windowFunctions"`
+	// GapFill is a native fill of empty time buckets, e.g. with the last
+	// value; a join against a generated series does not count.
+	GapFill        bool `json:"gapFill"`
+	ApproxDistinct bool `json:"approxDistinct"`
+	// Updates and Deletes are changes of single readings after they are
+	// written.
+	Updates bool `json:"updates"`
+	Deletes bool `json:"deletes"`
+	// Note explains the gaps, e.g. the extension a capability needs.
+	Note string `json:"note,omitempty"`
+}
+
+// names lists the capabilities that are set, for -list-backends.
+func (c Capabilities) names() string {
+	var names []string
+	for _, capability := range []struct {
+		name string
+		set  bool
+	}{
+		{"percentiles", c.Percentiles},
+		{"window functions", c.WindowFunctions},
+		{"gap fill", c.GapFill},
+		{"approximate distinct", c.ApproxDistinct},
+		{"updates", c.Updates},
+		{"deletes", c.Deletes},
+	} {
+		if capability.set {
+			names = append(names, capability.name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ", ")
+}
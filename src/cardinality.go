@@ -0,0 +1,18 @@
+package main
+
+import "strconv"
+
+// multiplyCardinality spreads every user_id and ssid over factor synthetic
+// variants by suffixing them with the row's position modulo factor. The number
+// of rows stays the same while the number of distinct users and access points,
+// i.e. the tag or series cardinality, grows up to factor times.
+func multiplyCardinality(readings []Reading, factor int) {
+	if factor <= 1 {
+		return
+	}
+	for i := range readings {
+		suffix := "~" + strconv.Itoa(i%factor)
+		readings[i].UserId += suffix
+		readings[i].Connection.Ssid += suffix
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rowScanner is implemented by backends that can hand the rows of a query to
+// the caller, one slice of column values per row.
+type rowScanner interface {
+	scanRows(ctx context.Context, visit func(values []any), q string, args ...any) error
+}
+
+// resultDigest counts the rows of a result set and hashes them independently
+// of their order, so that ties of an ORDER BY or an unordered GROUP BY do not
+// change the checksum between two runs.
+type resultDigest struct {
+	rows   int64
+	hashes [][sha256.Size]byte
+}
+
+func (d *resultDigest) add(values []any) {
+	columns := make([]string, len(values))
+	for i, value := range values {
+		columns[i] = canonicalValue(value)
+	}
+	d.rows++
+	d.hashes = append(d.hashes, sha256.Sum256([]byte(strings.Join(columns, "\t"))))
+}
+
+func (d *resultDigest) sum() string {
+	slices.SortFunc(d.hashes, func(a, b [sha256.Size]byte) int {
+		return strings.Compare(string(a[:]), string(b[:]))
+	})
+	all := sha256.New()
+	for _, hash := range d.hashes {
+		all.Write(hash[:])
+	}
+	return hex.EncodeToString(all.Sum(nil))
+}
+
+// canonicalValue renders a column value the same way whatever driver type it
+// arrives as. Floats are rounded to 9 significant digits, so that the summation
+// order of a parallel aggregate does not change the checksum; times are
+// rendered in UTC.
+func canonicalValue(value any) string {
+	if valuer, ok := value.(driver.Valuer); ok {
+		if v, err := valuer.Value(); err == nil {
+			value = v
+		}
+	}
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case float32:
+		return canonicalFloat(float64(v))
+	case float64:
+		return canonicalFloat(v)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func canonicalFloat(v float64) string {
+	if v == math.Trunc(v) && math.Abs(v) < 1e15 {
+		return strconv.FormatFloat(v, 'f', 0, 64)
+	}
+	return strconv.FormatFloat(v, 'g', 9, 64)
+}
+
+// captureChecksum runs a catalog query once more, outside the measured window,
+// and returns the number of rows it returned and the checksum of the result
+// set. A failure only loses the checksum, it does not fail the benchmark.
+func captureChecksum(ctx context.Context, b backend, q querySpec, bounds queryBounds) (int64, string) {
+	scanner, ok := b.(rowScanner)
+	if !ok {
+		return 0, ""
+	}
+	var digest resultDigest
+	if err := scanner.scanRows(ctx, digest.add, q.text, q.arguments(bounds)...); err != nil {
+		fmt.Printf("[WARN] Failed to read the result of query %d: %v\n", q.id, err)
+		return 0, ""
+	}
+	return digest.rows, digest.sum()
+}
+
+// scanSQLRows hands the rows of a database/sql result to visit.
+func scanSQLRows(rows *sql.Rows, visit func(values []any)) error {
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		values := make([]any, len(columns))
+		targets := make([]any, len(columns))
+		for i := range values {
+			targets[i] = &values[i]
+		}
+		if err := rows.Scan(targets...); err != nil {
+			return err
+		}
+		visit(values)
+	}
+	return rows.Err()
+}
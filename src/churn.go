@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"src/results"
+)
+
+// ChurnLatencies are the latencies of one kind of operation of the
+// connection-churn scenario, in milliseconds, over the successful ones.
+type ChurnLatencies struct {
+	Operations int `json:"operations"`
+	Errors     int `json:"errors,omitempty"`
+	results.Latencies
+}
+
+type ConnectionChurnResult struct {
+	// Rate is the number of operations started per second.
+	Rate float64 `json:"rate"`
+	// Pooled are the writes over connections reused from a pool, Churned the
+	// writes each over a connection of its own, from opening it to closing
+	// it. Open and Close are those two steps alone; drivers that connect
+	// lazily establish the connection in the write.
+	Pooled  ChurnLatencies `json:"pooled"`
+	Churned ChurnLatencies `json:"churned"`
+	Open    ChurnLatencies `json:"open"`
+	Close   ChurnLatencies `json:"close"`
+	// OverheadP50Ms and OverheadP99Ms are what a connection of its own adds
+	// to a write.
+	OverheadP50Ms float64 `json:"overheadP50Ms"`
+	OverheadP99Ms float64 `json:"overheadP99Ms"`
+	// PoolSize is the number of connections the pool opened to keep up with
+	// the rate, MaxOpen the most connections open at once without it.
+	PoolSize int `json:"poolSize"`
+	MaxOpen  int `json:"maxOpen"`
+}
+
+// benchmarkConnectionChurn writes single readings at a fixed rate the way
+// serverless functions and edge devices do, which connect for every event and
+// disconnect after it. It first writes operations readings over connections
+// reused from a pool, then as many others each over a connection opened and
+// closed for it, so that the difference is the cost of establishing a
+// connection.
+// Operations start on schedule, whether the previous ones finished or not,
+// so a server that slows down under the churn ends up with more connections
+// open at once, recorded as maxOpen.
+func benchmarkConnectionChurn(info backendInfo, connStr string, outFile string, opts benchmarkOptions, rate float64, operations int) error {
+	if rate <= 0 || operations < 1 {
+		return fmt.Errorf("the connection-churn scenario needs a positive rate and at least one operation")
+	}
+
+	pooled, err := info.open(connStr)
+	if err != nil {
+		return err
+	}
+	clients := &churnPool{info: info, connStr: connStr, opts: opts, idle: []backend{pooled}, size: 1}
+	defer clients.close()
+	if err := configureBackend(info, pooled, opts); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	scenario := ScenarioResult{Name: "connection-churn"}
+	start := time.Now()
+	if err := pooled.createSchema(ctx); err != nil {
+		return err
+	}
+	scenario.addPhase("setup", time.Since(start))
+
+	// Both phases write readings of their own, loaded before they start.
+	var pending []Reading
+	for chunk := 0; len(pending) < 2*operations; chunk++ {
+		hasNext, data, err := loadDataChunk(readingsDir, chunk)
+		if err != nil {
+			return err
+		}
+		pending = append(pending, data.Response[:min(len(data.Response), 2*operations-len(pending))]...)
+		if !hasNext {
+			break
+		}
+	}
+	if len(pending) < 2 {
+		return fmt.Errorf("the dataset has too few readings")
+	}
+	half := len(pending) / 2
+
+	result := &ConnectionChurnResult{Rate: rate}
+	infof("Connection churn: %d writes at %g/s over reused connections\n", half, rate)
+	var pooledSamples churnSamples
+	start = time.Now()
+	runAtRate(rate, pending[:half], func(reading Reading) {
+		began := time.Now()
+		b, err := clients.get()
+		if err == nil {
+			err = b.ingest(ctx, []Reading{reading})
+			clients.put(b)
+		}
+		pooledSamples.record(time.Since(began), err)
+	})
+	scenario.addPhase("pooled", time.Since(start))
+	result.PoolSize = clients.size
+	if err := interrupted(); err != nil {
+		return err
+	}
+
+	infof("Connection churn: %d writes at %g/s, each over a new connection\n", len(pending)-half, rate)
+	var churned, opened, closed churnSamples
+	var mu sync.Mutex
+	open := 0
+	start = time.Now()
+	runAtRate(rate, pending[half:], func(reading Reading) {
+		mu.Lock()
+		open++
+		result.MaxOpen = max(result.MaxOpen, open)
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			open--
+			mu.Unlock()
+		}()
+
+		began := time.Now()
+		b, err := info.open(connStr)
+		opened.record(time.Since(began), err)
+		if err != nil {
+			churned.record(0, err)
+			return
+		}
+		if err = configureBackend(info, b, opts); err == nil {
+			err = b.ingest(ctx, []Reading{reading})
+		}
+		closing := time.Now()
+		b.close()
+		closed.record(time.Since(closing), nil)
+		churned.record(time.Since(began), err)
+	})
+	scenario.addPhase("churned", time.Since(start))
+	if err := interrupted(); err != nil {
+		return err
+	}
+
+	result.Pooled, result.Churned = pooledSamples.summary(), churned.summary()
+	result.Open, result.Close = opened.summary(), closed.summary()
+	if result.Pooled.Operations == 0 {
+		return fmt.Errorf("every write over a reused connection failed, e.g. with: %w", pooledSamples.firstErr)
+	}
+	if result.Churned.Operations == 0 {
+		return fmt.Errorf("every write over a new connection failed, e.g. with: %w", churned.firstErr)
+	}
+	if churned.firstErr != nil {
+		fmt.Printf("[WARN] Connection churn: %d writes over a new connection failed, e.g. with: %v\n", result.Churned.Errors, churned.firstErr)
+	}
+	result.OverheadP50Ms = result.Churned.P50Ms - result.Pooled.P50Ms
+	result.OverheadP99Ms = result.Churned.P99Ms - result.Pooled.P99Ms
+	scenario.NRecords = result.Pooled.Operations + result.Churned.Operations
+	scenario.ConnectionChurn = result
+
+	infof("Connection churn for %s: p50 %.3f ms pooled, %.3f ms over a new connection (+%.3f ms), at most %d open\n",
+		info.name, result.Pooled.P50Ms, result.Churned.P50Ms, result.OverheadP50Ms, result.MaxOpen)
+	return writeResults(outFile, BenchmarkResults{
+		DbType:       info.name,
+		IngestMethod: opts.IngestMethod,
+		Pool:         poolSettingsOf(pooled),
+		Scenarios:    []ScenarioResult{scenario},
+	})
+}
+
+// runAtRate starts op for every reading at a fixed rate, each in its own
+// goroutine, and waits for them to finish.
+func runAtRate(rate float64, readings []Reading, op func(Reading)) {
+	interval := time.Duration(float64(time.Second) / rate)
+	next := time.Now()
+	var wg sync.WaitGroup
+	for _, reading := range readings {
+		if interrupted() != nil {
+			break
+		}
+		time.Sleep(time.Until(next))
+		next = next.Add(interval)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			op(reading)
+		}()
+	}
+	wg.Wait()
+}
+
+// churnPool hands out idle backends, opening another one when every backend
+// is busy; connection pools of client libraries grow the same way.
+type churnPool struct {
+	info    backendInfo
+	connStr string
+	opts    benchmarkOptions
+
+	mu   sync.Mutex
+	idle []backend
+	size int
+}
+
+func (p *churnPool) get() (backend, error) {
+	p.mu.Lock()
+	if n := len(p.idle); n > 0 {
+		b := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mu.Unlock()
+		return b, nil
+	}
+	p.mu.Unlock()
+
+	b, err := p.info.open(p.connStr)
+	if err != nil {
+		return nil, err
+	}
+	if err := configureBackend(p.info, b, p.opts); err != nil {
+		b.close()
+		return nil, err
+	}
+	p.mu.Lock()
+	p.size++
+	p.mu.Unlock()
+	return b, nil
+}
+
+func (p *churnPool) put(b backend) {
+	p.mu.Lock()
+	p.idle = append(p.idle, b)
+	p.mu.Unlock()
+}
+
+func (p *churnPool) close() {
+	for _, b := range p.idle {
+		b.close()
+	}
+}
+
+// churnSamples collects the latencies of concurrent operations.
+type churnSamples struct {
+	mu       sync.Mutex
+	samples  []time.Duration
+	errors   int
+	firstErr error
+}
+
+func (s *churnSamples) record(d time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.errors++
+		if s.firstErr == nil {
+			s.firstErr = err
+		}
+		return
+	}
+	s.samples = append(s.samples, d)
+}
+
+func (s *churnSamples) summary() ChurnLatencies {
+	return ChurnLatencies{Operations: len(s.samples), Errors: s.errors, Latencies: results.Summarize(s.samples)}
+}
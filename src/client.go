@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clientOptions are the -client-* flags, which constrain the benchmark process
+// itself so that its share of the host does not vary between runs. Zero values
+// keep the Go runtime's defaults.
+type clientOptions struct {
+	// Cpus is a CPU list like "0-3,6" the process is pinned to.
+	Cpus       string
+	GoMaxProcs int
+	// MemoryLimit is the soft limit of the Go heap, e.g. "2g".
+	MemoryLimit string
+}
+
+// ClientSettings are the resources the benchmark client ran with, recorded
+// in every result.
+type ClientSettings struct {
+	Cpus             string `json:"cpus,omitempty"`
+	NumCpu           int    `json:"numCpu"`
+	GoMaxProcs       int    `json:"goMaxProcs"`
+	MemoryLimitBytes int64  `json:"memoryLimitBytes,omitempty"`
+}
+
+// clientSettings is filled in by applyClientLimits.
+var clientSettings ClientSettings
+
+// applyClientLimits pins the process to the CPUs and sets GOMAXPROCS and the
+// memory limit. Without -client-gomaxprocs, GOMAXPROCS follows the pinned
+// CPUs, as it would have had the process been started with taskset.
+func applyClientLimits(opts clientOptions) error {
+	if opts.GoMaxProcs < 0 {
+		return fmt.Errorf("-client-gomaxprocs must not be negative")
+	}
+	if opts.Cpus != "" {
+		cpus, err := parseCPUList(opts.Cpus)
+		if err != nil {
+			return fmt.Errorf("-client-cpus: %w", err)
+		}
+		if err := pinCPUs(cpus); err != nil {
+			return fmt.Errorf("-client-cpus: %w", err)
+		}
+		if opts.GoMaxProcs == 0 {
+			runtime.GOMAXPROCS(len(cpus))
+		}
+		infof("Pinned the client to CPUs %s\n", opts.Cpus)
+	}
+	if opts.GoMaxProcs > 0 {
+		runtime.GOMAXPROCS(opts.GoMaxProcs)
+	}
+	if opts.MemoryLimit != "" {
+		limit, err := parseByteSize(opts.MemoryLimit)
+		if err != nil {
+			return fmt.Errorf("-client-memory-limit: %w", err)
+		}
+		debug.SetMemoryLimit(limit)
+	}
+
+	clientSettings = ClientSettings{
+		Cpus:       opts.Cpus,
+		NumCpu:     runtime.NumCPU(),
+		GoMaxProcs: runtime.GOMAXPROCS(0),
+	}
+	if limit := debug.SetMemoryLimit(-1); limit != math.MaxInt64 {
+		clientSettings.MemoryLimitBytes = limit
+	}
+	return nil
+}
+
+// parseCPUList reads the cpuset syntax of taskset -c: comma-separated CPUs and
+// inclusive ranges.
+func parseCPUList(list string) ([]int, error) {
+	var cpus []int
+	seen := map[int]bool{}
+	for _, part := range strings.Split(list, ",") {
+		first, last, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		from, err := strconv.Atoi(first)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CPU %q", part)
+		}
+		to := from
+		if isRange {
+			if to, err = strconv.Atoi(last); err != nil || to < from {
+				return nil, fmt.Errorf("invalid CPU range %q", part)
+			}
+		}
+		if from < 0 {
+			return nil, fmt.Errorf("invalid CPU %q", part)
+		}
+		for cpu := from; cpu <= to; cpu++ {
+			if !seen[cpu] {
+				seen[cpu] = true
+				cpus = append(cpus, cpu)
+			}
+		}
+	}
+	return cpus, nil
+}
+
+// parseByteSize accepts a number of bytes with an optional k, m or g suffix
+// (powers of 1024), like docker --memory.
+func parseByteSize(size string) (int64, error) {
+	multipliers := map[byte]int64{'k': 1 << 10, 'm': 1 << 20, 'g': 1 << 30}
+	lower := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(size)), "b")
+	multiplier := int64(1)
+	if lower != "" {
+		if m, ok := multipliers[lower[len(lower)-1]]; ok {
+			multiplier = m
+			lower = lower[:len(lower)-1]
+		}
+	}
+	n, err := strconv.ParseInt(lower, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+	return n * multiplier, nil
+}
+
+// GCStats are the garbage collections of the client during part of a run.
+// Their stop-the-world pauses delay the writes and queries in flight, so a
+// large total points at client overhead in the measured durations.
+type GCStats struct {
+	Cycles         uint32  `json:"cycles"`
+	PauseTotalMs   float64 `json:"pauseTotalMs"`
+	AllocatedBytes uint64  `json:"allocatedBytes"`
+}
+
+type ClientGCResult struct {
+	Ingestion GCStats `json:"ingestion"`
+	Run       GCStats `json:"run"`
+}
+
+// gcSnapshot holds the cumulative counters of the runtime at one point of a
+// run. Reading them stops the world briefly, so it is only done between
+// phases.
+type gcSnapshot struct {
+	cycles    uint32
+	pauseNs   uint64
+	allocated uint64
+}
+
+func readGCSnapshot() gcSnapshot {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return gcSnapshot{cycles: stats.NumGC, pauseNs: stats.PauseTotalNs, allocated: stats.TotalAlloc}
+}
+
+func (s gcSnapshot) since(earlier gcSnapshot) GCStats {
+	return GCStats{
+		Cycles:         s.cycles - earlier.cycles,
+		PauseTotalMs:   float64(s.pauseNs-earlier.pauseNs) / float64(time.Millisecond),
+		AllocatedBytes: s.allocated - earlier.allocated,
+	}
+}
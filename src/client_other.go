@@ -0,0 +1,17 @@
+//go:build !linux
+
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+func pinCPUs(cpus []int) error {
+	return fmt.Errorf("pinning the client to CPUs is only supported on Linux")
+}
+
+// processCPUTime is not measured outside Linux.
+func processCPUTime() time.Duration {
+	return 0
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// coldHook gets the database cold again before a catalog query, so that every
+// query is timed without the pages and caches of the previous ones: a shell
+// command, e.g. one dropping the OS page cache, or a restart of the managed
+// container. Its duration is kept out of the timings.
+type coldHook struct {
+	// Command is run with sh -c; empty restarts Container instead.
+	Command string
+	// EveryRun runs the hook before every run of a query, warm-up and
+	// repeats alike, instead of once before its first run.
+	EveryRun bool
+
+	container    managedDatabase
+	dbType       string
+	connStr      string
+	readyTimeout time.Duration
+}
+
+// newColdHook parses the value of -cold-hook: restart, or a shell command.
+func newColdHook(value string, every string, container managedDatabase, dbType string, connStr string, readyTimeout time.Duration) (*coldHook, error) {
+	if every != "query" && every != "run" {
+		return nil, fmt.Errorf("-cold-hook-every must be query or run, not %q", every)
+	}
+	hook := &coldHook{EveryRun: every == "run", dbType: dbType, connStr: connStr, readyTimeout: readyTimeout}
+	if strings.TrimSpace(value) != "restart" {
+		hook.Command = value
+		return hook, nil
+	}
+	hook.container = container
+	return hook, nil
+}
+
+// run runs the hook and returns how long it took. After a restart it waits
+// until the database answers a ping again; the connection pools of the
+// drivers replace the connections the restart broke.
+func (h *coldHook) run(ctx context.Context) (time.Duration, error) {
+	start := time.Now()
+	if h.Command != "" {
+		out, err := exec.CommandContext(ctx, "sh", "-c", h.Command).CombinedOutput()
+		if err != nil {
+			return 0, fmt.Errorf("cold hook %q failed: %w: %s", h.Command, err, strings.TrimSpace(string(out)))
+		}
+		return time.Since(start), nil
+	}
+	if err := h.container.restart(); err != nil {
+		return 0, err
+	}
+	if err := waitForDatabase(h.dbType, h.connStr, h.readyTimeout); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
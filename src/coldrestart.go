@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+type ColdRestartResult struct {
+	// RestartMs runs from the restart of the container until the database
+	// answers a ping again.
+	RestartMs    int64         `json:"restartMs"`
+	FirstQueryMs int64         `json:"firstQueryMs"`
+	Queries      []QueryResult `json:"queries"`
+}
+
+// runColdRestart restarts the managed container after the ingestion and runs
+// the query catalog once on the cold database. The backend is closed and a new
+// one is returned, as the connections do not survive the restart.
+func runColdRestart(ctx context.Context, info backendInfo, b backend, connStr string, opts benchmarkOptions) (*ColdRestartResult, backend, error) {
+	b.close()
+
+	result := &ColdRestartResult{}
+	start := time.Now()
+	if err := opts.Container.restart(); err != nil {
+		return nil, nil, err
+	}
+	if err := waitForDatabase(info.name, connStr, opts.ReadyTimeout); err != nil {
+		return nil, nil, err
+	}
+	result.RestartMs = time.Since(start).Milliseconds()
+	infof("Database ready %d ms after the restart\n", result.RestartMs)
+
+	b, err := info.open(connStr)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := configureBackend(info, b, opts); err != nil {
+		b.close()
+		return nil, nil, err
+	}
+
+	// A warm-up would hide the cold first query this measures.
+	cold := opts
+	cold.QueryWarmup = 0
+	result.Queries, _, err = runQueryCatalog(ctx, info, b, cold)
+	if err != nil {
+		b.close()
+		return nil, nil, err
+	}
+	result.FirstQueryMs = result.Queries[0].DurationMs
+	return result, b, nil
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// command is a subcommand of the binary. run defines the flags of the
+// subcommand on flags, parses args with them and runs it. A request for help
+// (-h) prints the usage and returns nil before anything runs, which the
+// completion relies on to list the flags.
+type command struct {
+	name    string
+	summary string
+	run     func(flags *flag.FlagSet, args []string) error
+}
+
+// commands lists the subcommands in the order of the help. It is a function
+// rather than a variable, as completion refers back to it.
+func commands() []command {
+	return []command{
+		{"bench", "Ingest the dataset into a database and time the query catalog (the default)", runBench},
+		{"generate", "Write a synthetic readings directory", runGenerate},
+		{"profile", "Report the statistics of a readings directory, without a database", runProfile},
+		{"report", "Summarize result files per series: ingestion throughput and query times", runReport},
+		{"compare", "Compare the query times of a result file with those of a baseline", runCompare},
+		{"verify", "Check the manifest of a result file against the dataset and the query catalog", runVerify},
+		{"completion", "Print the shell completion script for bash or zsh", runCompletion},
+	}
+}
+
+func lookupCommand(name string) (command, bool) {
+	for _, c := range commands() {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return command{}, false
+}
+
+// programName is the name the binary was invoked with, for the usage and the
+// completion script.
+func programName() string {
+	return filepath.Base(os.Args[0])
+}
+
+// run dispatches the command line to its subcommand. Arguments that start
+// with a flag run bench, so invocations from before the subcommands keep
+// working.
+func run() error {
+	stopWatching := watchSignals()
+	defer stopWatching()
+
+	name, args := "bench", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" {
+		if len(args) == 0 {
+			printCommands(os.Stdout)
+			return nil
+		}
+		name, args = args[0], []string{"-h"}
+	}
+	c, ok := lookupCommand(name)
+	if !ok {
+		printCommands(os.Stderr)
+		return configErrorf("unknown command: %s", name)
+	}
+	err := c.run(newCommandFlags(c), args)
+	printRunSummary(err)
+	return err
+}
+
+// newCommandFlags is the flag set of c, whose usage names the subcommand; the
+// usage of bench also lists the others, as it is the one a bare invocation
+// shows.
+func newCommandFlags(c command) *flag.FlagSet {
+	flags := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	flags.Usage = func() {
+		out := flags.Output()
+		fmt.Fprintf(out, "Usage: %s %s [flags]\n\n%s.\n\nFlags:\n", programName(), c.name, c.summary)
+		flags.PrintDefaults()
+		fmt.Fprintf(out, "\nEvery flag can also be set with %s_<FLAG>, e.g. %s for -conn-file, or with %s_%s_<FLAG> for this command only.\n",
+			envPrefix, envName("", "conn-file"), envPrefix, strings.ToUpper(c.name))
+		if c.name == "bench" {
+			fmt.Fprintln(out)
+			printCommands(out)
+		}
+	}
+	return flags
+}
+
+// envPrefix starts the environment variables that set flags, for containers
+// and CI schedulers where a long command line is awkward to build.
+const envPrefix = "SCBENCH"
+
+// envName is the environment variable of a flag, SCBENCH_CONN_FILE for
+// -conn-file, or SCBENCH_BENCH_CONN_FILE for the one of a command.
+func envName(command, flagName string) string {
+	name := envPrefix
+	if command != "" {
+		name += "_" + command
+	}
+	return strings.ToUpper(strings.ReplaceAll(name+"_"+flagName, "-", "_"))
+}
+
+// parseCommandFlags parses the flags of a subcommand, taking the ones not on
+// the command line from the environment: SCBENCH_<COMMAND>_<FLAG> before
+// SCBENCH_<FLAG>.
+func parseCommandFlags(flags *flag.FlagSet, args []string) error {
+	var err error
+	flags.VisitAll(func(f *flag.Flag) {
+		for _, name := range []string{envName(flags.Name(), f.Name), envName("", f.Name)} {
+			value, ok := os.LookupEnv(name)
+			if !ok {
+				continue
+			}
+			if setErr := flags.Set(f.Name, value); setErr != nil && err == nil {
+				err = configErrorf("%s: invalid value %q for -%s: %v", name, value, f.Name, setErr)
+			}
+			break
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return flags.Parse(args)
+}
+
+func printCommands(out io.Writer) {
+	fmt.Fprintf(out, "Usage: %s <command> [flags]\n\nCommands:\n", programName())
+	for _, c := range commands() {
+		fmt.Fprintf(out, "  %-11s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(out, "\nRun \"%s help <command>\" for the flags of a command.\n", programName())
+}
+
+// commandFlags returns the flags of c, found by asking it for help with the
+// usage discarded.
+func commandFlags(c command) []string {
+	flags := newCommandFlags(c)
+	flags.SetOutput(io.Discard)
+	c.run(flags, []string{"-h"})
+	var names []string
+	flags.VisitAll(func(f *flag.Flag) {
+		names = append(names, "-"+f.Name)
+	})
+	return names
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"text/tabwriter"
+)
+
+// runCompare is the compare subcommand: it sets the catalog queries of a
+// candidate result file against those of a baseline, e.g. two versions of an
+// engine or two schema variants. Under -result-checksums it also flags the
+// queries whose answer changed, and it lists the server settings that differ
+// between the two runs. With -max-slowdown it fails on a regression,
+// so that it can gate a CI job.
+func runCompare(flags *flag.FlagSet, args []string) error {
+	maxSlowdown := flags.Float64("max-slowdown", 0, "Fail when a query of the candidate takes more than this many times as long as in the baseline, e.g. 1.2; 0 only reports")
+	if err := parseCommandFlags(flags, args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return asConfigError(err)
+	}
+	if flags.NArg() != 2 {
+		return configErrorf("compare needs a baseline and a candidate result file")
+	}
+	if *maxSlowdown < 0 || (*maxSlowdown > 0 && *maxSlowdown < 1) {
+		return configErrorf("-max-slowdown must be at least 1, or 0 to only report")
+	}
+	files, err := readResultFiles(flags.Args())
+	if err != nil {
+		return err
+	}
+	baseline, candidate := files[0], files[1]
+	if baseline.CatalogVersion != candidate.CatalogVersion {
+		fmt.Printf("[WARN] The catalogs differ: version %d in the baseline, %d in the candidate\n", baseline.CatalogVersion, candidate.CatalogVersion)
+	}
+	fmt.Printf("Baseline   %s (%s)\nCandidate  %s (%s)\n\n", seriesLabel(baseline), flags.Arg(0), seriesLabel(candidate), flags.Arg(1))
+	if err := printSettingDifferences(baseline.ServerSettings, candidate.ServerSettings); err != nil {
+		return err
+	}
+
+	before := map[int]QueryResult{}
+	for _, q := range baseline.Queries {
+		before[q.QueryId] = q
+	}
+	var slower, changed int
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(out, "Query\tBaseline (ms)\tCandidate (ms)\tRatio\t")
+	for _, q := range candidate.Queries {
+		b, ok := before[q.QueryId]
+		if !ok {
+			continue
+		}
+		ratio, note := "n/a", ""
+		if b.DurationMs >= 0 && q.DurationMs >= 0 {
+			// A query under a millisecond counts as one, so that the
+			// ratio stays finite.
+			r := float64(max(q.DurationMs, 1)) / float64(max(b.DurationMs, 1))
+			ratio = fmt.Sprintf("%.2fx", r)
+			if *maxSlowdown > 0 && r > *maxSlowdown {
+				slower++
+				note = "slower"
+			}
+		}
+		if b.Checksum != "" && q.Checksum != "" && b.Checksum != q.Checksum {
+			changed++
+			note = joinNote(note, "result differs")
+		}
+		fmt.Fprintf(out, "%d %s\t%d\t%d\t%s\t%s\n", q.QueryId, q.Query, b.DurationMs, q.DurationMs, ratio, note)
+	}
+	if err := out.Flush(); err != nil {
+		return err
+	}
+
+	if changed > 0 {
+		fmt.Printf("[WARN] %d queries return a different result than in the baseline\n", changed)
+	}
+	if slower > 0 {
+		return fmt.Errorf("%d queries take more than %gx as long as in the baseline", slower, *maxSlowdown)
+	}
+	return nil
+}
+
+// printSettingDifferences lists the server settings that differ between the
+// two runs, so that a change in the timings can be traced to the tuning.
+// Result files recorded without the settings are not compared.
+func printSettingDifferences(baseline, candidate map[string]string) error {
+	if len(baseline) == 0 || len(candidate) == 0 {
+		return nil
+	}
+	var names []string
+	for name, value := range baseline {
+		if other, ok := candidate[name]; !ok || other != value {
+			names = append(names, name)
+		}
+	}
+	for name := range candidate {
+		if _, ok := baseline[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	slices.Sort(names)
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(out, "Setting\tBaseline\tCandidate\t")
+	for _, name := range names {
+		fmt.Fprintf(out, "%s\t%s\t%s\t\n", name, settingOrDash(baseline, name), settingOrDash(candidate, name))
+	}
+	if err := out.Flush(); err != nil {
+		return err
+	}
+	fmt.Println()
+	return nil
+}
+
+func settingOrDash(settings map[string]string, name string) string {
+	if value, ok := settings[name]; ok {
+		return value
+	}
+	return "-"
+}
+
+func joinNote(note, more string) string {
+	if note == "" {
+		return more
+	}
+	return note + ", " + more
+}
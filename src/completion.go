@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// runCompletion is the completion subcommand: it prints a completion script
+// of the subcommands, their flags and the database types, e.g. for
+// source <(./entrypoint completion bash). zsh runs the bash script through
+// bashcompinit.
+func runCompletion(flags *flag.FlagSet, args []string) error {
+	if err := parseCommandFlags(flags, args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return asConfigError(err)
+	}
+	shell := flags.Arg(0)
+	if flags.NArg() != 1 || (shell != "bash" && shell != "zsh") {
+		return configErrorf("completion needs the shell: bash or zsh")
+	}
+	if shell == "zsh" {
+		fmt.Println("autoload -U +X bashcompinit && bashcompinit")
+	}
+	fmt.Print(bashCompletion(programName()))
+	return nil
+}
+
+func bashCompletion(program string) string {
+	var names []string
+	var cases strings.Builder
+	for _, c := range commands() {
+		names = append(names, c.name)
+		fmt.Fprintf(&cases, "\t%s) words=%q ;;\n", c.name, strings.Join(commandFlags(c), " "))
+	}
+	function := "_" + strings.NewReplacer("-", "_", ".", "_").Replace(program)
+	return fmt.Sprintf(`%[1]s() {
+	local cur=${COMP_WORDS[COMP_CWORD]} prev=${COMP_WORDS[COMP_CWORD-1]} command=bench words
+	if [[ $COMP_CWORD -eq 1 && $cur != -* ]]; then
+		COMPREPLY=($(compgen -W %[2]q -- "$cur"))
+		return
+	fi
+	if [[ ${COMP_WORDS[1]} != -* ]]; then
+		command=${COMP_WORDS[1]}
+	fi
+	if [[ $prev == -type ]]; then
+		COMPREPLY=($(compgen -W %[3]q -- "$cur"))
+		return
+	fi
+	case $command in
+%[4]s	esac
+	COMPREPLY=($(compgen -W "$words" -- "$cur"))
+}
+complete -o default -F %[1]s %[5]s
+`, function, strings.Join(names, " "), strings.Join(backendNames(), " "), cases.String(), program)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// sizedBackend is implemented by backends that can report the on-disk size of
+// the user_events table, including its indexes and chunks.
+type sizedBackend interface {
+	tableSize(ctx context.Context) (int64, error)
+}
+
+// benchmarkCompression ingests the full dataset, compresses it with the
+// engine's native compression and re-runs the query catalog on the compressed
+// data. The table size before and after compression is recorded next to the
+// phases.
+func benchmarkCompression(info backendInfo, connStr string, outFile string, opts benchmarkOptions) error {
+	if len(info.compression) == 0 {
+		return fmt.Errorf("compression scenario is not available for %s", info.name)
+	}
+
+	b, err := info.open(connStr)
+	if err != nil {
+		return err
+	}
+	defer b.close()
+
+	sized, ok := b.(sizedBackend)
+	if !ok {
+		return fmt.Errorf("backend %s cannot report its table size", info.name)
+	}
+
+	ctx := context.Background()
+	scenario := ScenarioResult{Name: "compression"}
+	if err := configureBackend(info, b, opts); err != nil {
+		return err
+	}
+	if err := loadScenarioData(ctx, info, b, opts, &scenario); err != nil {
+		return err
+	}
+
+	if scenario.SizeBeforeBytes, err = sized.tableSize(ctx); err != nil {
+		return err
+	}
+
+	infof("Compression: compressing the table\n")
+	start := time.Now()
+	for _, stmt := range info.compression {
+		if err := b.exec(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	scenario.addPhase("compress", time.Since(start))
+
+	if scenario.SizeAfterBytes, err = sized.tableSize(ctx); err != nil {
+		return err
+	}
+	infof("Compressed %d bytes to %d bytes\n", scenario.SizeBeforeBytes, scenario.SizeAfterBytes)
+
+	scenario.Queries, _, err = runQueryCatalog(ctx, info, b, opts)
+	if err != nil {
+		return err
+	}
+
+	return writeResults(outFile, BenchmarkResults{
+		DbType:        info.name,
+		SchemaVariant: opts.SchemaVariant,
+		IngestMethod:  opts.IngestMethod,
+		ChunkInterval: opts.ChunkInterval,
+		Shards:        opts.Shards,
+		Scenarios:     []ScenarioResult{scenario},
+	})
+}
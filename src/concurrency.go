@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"src/results"
+)
+
+// defaultConcurrencyQuery is the query of the concurrency sweep unless
+// -concurrency-query is given: the 24 hours aggregation, a dashboard query
+// that reads a slice of the data rather than all of it.
+const defaultConcurrencyQuery = 8
+
+type concurrencyOptions struct {
+	// MaxClients is the last step of the sweep; 0 disables it.
+	MaxClients   int
+	QueryId      int
+	StepDuration time.Duration
+}
+
+func (c concurrencyOptions) enabled() bool {
+	return c.MaxClients > 0
+}
+
+// steps returns the client counts of the sweep: the powers of two up to
+// MaxClients, and MaxClients itself.
+func (c concurrencyOptions) steps() []int {
+	var steps []int
+	for clients := 1; clients < c.MaxClients; clients *= 2 {
+		steps = append(steps, clients)
+	}
+	return append(steps, c.MaxClients)
+}
+
+type ConcurrencyResult struct {
+	QueryId        int                     `json:"queryId"`
+	Query          string                  `json:"query"`
+	Description    string                  `json:"description"`
+	StepDurationMs int64                   `json:"stepDurationMs"`
+	Steps          []ConcurrencyStepResult `json:"steps"`
+}
+
+// ConcurrencyStepResult holds the latencies of the successful queries of a
+// step in milliseconds.
+type ConcurrencyStepResult struct {
+	Clients int `json:"clients"`
+	Queries int `json:"queries"`
+	Errors  int `json:"errors,omitempty"`
+	// Throughput is the number of successful queries per second.
+	Throughput float64 `json:"throughput"`
+	results.Latencies
+}
+
+// runConcurrencySweep runs one query of the catalog from 1, 2, 4, ... clients
+// at once, each repeating it back to back for the step duration, which gives
+// the throughput and latency curve of the engine as the load grows. The
+// clients share the backend's connections, as the clients of a workload do.
+func runConcurrencySweep(ctx context.Context, info backendInfo, b backend, c concurrencyOptions, bounds queryBounds) (*ConcurrencyResult, error) {
+	q, ok := info.lookupQuery(c.QueryId)
+	if !ok {
+		return nil, fmt.Errorf("query %d of the concurrency sweep is not supported for database type: %s", c.QueryId, info.name)
+	}
+	args := q.arguments(bounds)
+	result := &ConcurrencyResult{
+		QueryId:        c.QueryId,
+		Query:          queryMetadata[c.QueryId].name,
+		Description:    queryDescriptions[c.QueryId],
+		StepDurationMs: c.StepDuration.Milliseconds(),
+	}
+	for _, clients := range c.steps() {
+		infof("Concurrency sweep: query %d from %d clients for %s\n", c.QueryId, clients, c.StepDuration)
+		samples := make([][]time.Duration, clients)
+		failed := make([]int, clients)
+		var firstErr error
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		start := time.Now()
+		deadline := start.Add(c.StepDuration)
+		for i := range clients {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for time.Now().Before(deadline) && interrupted() == nil {
+					began := time.Now()
+					if err := b.query(ctx, q.text, args...); err != nil {
+						failed[i]++
+						mu.Lock()
+						if firstErr == nil {
+							firstErr = err
+						}
+						mu.Unlock()
+						continue
+					}
+					samples[i] = append(samples[i], time.Since(began))
+				}
+			}()
+		}
+		wg.Wait()
+		elapsed := time.Since(start)
+		if err := interrupted(); err != nil {
+			return nil, err
+		}
+
+		step := ConcurrencyStepResult{Clients: clients}
+		var all []time.Duration
+		for i := range clients {
+			all = append(all, samples[i]...)
+			step.Errors += failed[i]
+		}
+		if firstErr != nil {
+			if len(all) == 0 && !info.lenientQueries {
+				return nil, firstErr
+			}
+			fmt.Printf("[WARN] Concurrency sweep: %d queries from %d clients failed, e.g. with: %v\n", step.Errors, clients, firstErr)
+		}
+		if len(all) > 0 {
+			step.Queries = len(all)
+			step.Throughput = float64(len(all)) / elapsed.Seconds()
+			step.Latencies = results.Summarize(all)
+		}
+		result.Steps = append(result.Steps, step)
+		infof("Concurrency sweep: %d clients, %.1f queries/s, p50 %.3f ms, p99 %.3f ms\n", clients, step.Throughput, step.P50Ms, step.P99Ms)
+	}
+	return result, nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type ReadYourWritesResult struct {
+	Probes      int     `json:"probes"`
+	Failures    int     `json:"failures"`
+	FailureRate float64 `json:"failureRate"`
+	TimedOut    int     `json:"timedOut"`
+	MeanDelayMs float64 `json:"meanDelayMs"`
+	MaxDelayMs  float64 `json:"maxDelayMs"`
+}
+
+// probeReader checks for a probe row on a connection that is reserved for
+// reading, so the read never shares a session with the write that preceded it.
+type probeReader interface {
+	visible(ctx context.Context, userId string) (bool, error)
+	release()
+}
+
+type readYourWritesBackend interface {
+	newProbeReader(ctx context.Context) (probeReader, error)
+}
+
+// benchmarkReadYourWrites writes single readings and immediately reads them
+// back on a different connection. A probe fails when the first read does not
+// see the row; the reader then polls until the row shows up or the timeout
+// expires, which gives the visibility delay.
+func benchmarkReadYourWrites(dbType string, connStr string, outFile string, probes int, timeout time.Duration) error {
+	b, err := newBackend(dbType, connStr)
+	if err != nil {
+		return err
+	}
+	defer b.close()
+
+	rywBackend, ok := b.(readYourWritesBackend)
+	if !ok {
+		return fmt.Errorf("read-your-writes probe is not available for %s", dbType)
+	}
+
+	ctx := context.Background()
+	if err := b.createSchema(ctx); err != nil {
+		return err
+	}
+
+	reader, err := rywBackend.newProbeReader(ctx)
+	if err != nil {
+		return err
+	}
+	defer reader.release()
+
+	result := &ReadYourWritesResult{Probes: probes}
+	var totalDelay time.Duration
+	var delayed int
+	runId := time.Now().UnixNano()
+
+	infof("Running %d read-your-writes probes\n", probes)
+	for i := 0; i < probes; i++ {
+		reading := Reading{
+			UserId:          fmt.Sprintf("ryw-probe-%d-%d", runId, i),
+			LastUpdatedTime: int(time.Now().Unix()),
+		}
+		reading.Connection.Ssid = "ryw-probe"
+		reading.Connection.Rssi = -50
+
+		if err := b.ingest(ctx, []Reading{reading}); err != nil {
+			return err
+		}
+		written := time.Now()
+
+		found, err := reader.visible(ctx, reading.UserId)
+		if err != nil {
+			return err
+		}
+		if found {
+			continue
+		}
+
+		result.Failures++
+		for !found && time.Since(written) < timeout {
+			time.Sleep(10 * time.Millisecond)
+			if found, err = reader.visible(ctx, reading.UserId); err != nil {
+				return err
+			}
+		}
+		if !found {
+			result.TimedOut++
+			continue
+		}
+
+		delay := time.Since(written)
+		totalDelay += delay
+		delayed++
+		result.MaxDelayMs = max(result.MaxDelayMs, float64(delay.Microseconds())/1000)
+	}
+
+	if probes > 0 {
+		result.FailureRate = float64(result.Failures) / float64(probes)
+	}
+	if delayed > 0 {
+		result.MeanDelayMs = float64(totalDelay.Microseconds()) / 1000 / float64(delayed)
+	}
+	infof("Read-your-writes failures: %d/%d (%d timed out)\n", result.Failures, probes, result.TimedOut)
+
+	return writeResults(outFile, BenchmarkResults{
+		DbType:         dbType,
+		ReadYourWrites: result,
+	})
+}
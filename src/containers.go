@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+)
+
+// containerSpec describes how to run a database under test as a standalone
+// container. Each backend registers its own; images are pinned so that runs are
+// reproducible, and ports match the mappings used in docker-compose.yaml.
+type containerSpec struct {
+	image string
+	ports []string
+	env   []string
+	args  []string
+	extra []string
+	// timingEnv and timingArgs turn on the statement log serverTimer reads,
+	// under -server-timing only since it costs the engine some work.
+	timingEnv  []string
+	timingArgs []string
+	// durabilityEnv is, per -durability level, the server configuration
+	// that sets it, for engines that only read it at startup.
+	durabilityEnv map[string][]string
+}
+
+// forRun returns the spec with the settings the limits ask for.
+func (s containerSpec) forRun(limits containerLimits) containerSpec {
+	if limits.ServerTiming {
+		s.env = append(slices.Clone(s.env), s.timingEnv...)
+		s.args = append(slices.Clone(s.args), s.timingArgs...)
+	}
+	if limits.Durability != "" {
+		s.env = append(slices.Clone(s.env), s.durabilityEnv[limits.Durability]...)
+	}
+	return s
+}
+
+// containerLimits are the resource constraints applied to a managed container.
+// Empty values leave the docker defaults in place.
+type containerLimits struct {
+	Cpus   string
+	Memory string
+	Image  string
+	// ServerTiming enables the statement log of the engine.
+	ServerTiming bool
+	// Durability is the -durability level the server is configured for.
+	Durability string
+}
+
+// managedDatabase is a database started by the tool for a run, either a local
+// Docker container or a Kubernetes StatefulSet.
+type managedDatabase interface {
+	// restart stops the database and starts it again with its data.
+	restart() error
+	// remove stops the database and deletes its data.
+	remove()
+}
+
+// launchDatabase starts the managed database of a run; main replaces it with
+// the Kubernetes launcher under -kubernetes.
+var launchDatabase = func(dbType string, limits containerLimits) (managedDatabase, error) {
+	container, err := startContainer(dbType, limits)
+	if err != nil {
+		return nil, err
+	}
+	return container, nil
+}
+
+// kubeOptions are the -kube-* flags.
+type kubeOptions struct {
+	Namespace string
+	// Kubeconfig is used outside the cluster; inside, the service account of
+	// the pod is.
+	Kubeconfig string
+	// StartTimeout bounds the wait for the pod to be scheduled, pull its image
+	// and start.
+	StartTimeout time.Duration
+}
+
+var kubeSettings = kubeOptions{StartTimeout: 10 * time.Minute}
+
+// kubernetesLauncher is registered by kubernetes.go; nil when the binary is
+// built with the nokubernetes tag.
+var kubernetesLauncher func(dbType string, limits containerLimits) (managedDatabase, error)
+
+type managedContainer struct {
+	name string
+}
+
+// startContainer runs the pinned image for dbType in the background. Any
+// leftover container from a previous run is removed first so every run starts
+// from an empty database.
+func startContainer(dbType string, limits containerLimits) (*managedContainer, error) {
+	spec := backends[dbType].container.forRun(limits)
+	if spec.image == "" {
+		return nil, fmt.Errorf("no container definition for database type %q", dbType)
+	}
+
+	name := "smartcampus-bench-" + dbType
+	_ = exec.Command("docker", "rm", "-f", "-v", name).Run()
+
+	args := []string{"run", "-d", "--name", name}
+	if limits.Cpus != "" {
+		args = append(args, "--cpus", limits.Cpus)
+	}
+	if limits.Memory != "" {
+		args = append(args, "--memory", limits.Memory)
+	}
+	for _, port := range spec.ports {
+		args = append(args, "-p", port)
+	}
+	for _, env := range spec.env {
+		args = append(args, "-e", env)
+	}
+	args = append(args, spec.extra...)
+
+	image := spec.image
+	if limits.Image != "" {
+		image = limits.Image
+	}
+	args = append(args, image)
+	args = append(args, spec.args...)
+
+	infof("Starting container %s (%s)\n", name, image)
+	out, err := exec.Command("docker", args...).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("docker run failed: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	return &managedContainer{name: name}, nil
+}
+
+// restart stops the container and starts it again with its data, e.g. to
+// empty the database's caches.
+func (c *managedContainer) restart() error {
+	infof("Restarting container %s\n", c.name)
+	out, err := exec.Command("docker", "restart", c.name).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("docker restart failed: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// remove stops the container and deletes it together with its volumes.
+func (c *managedContainer) remove() {
+	infof("Removing container %s\n", c.name)
+	out, err := exec.Command("docker", "rm", "-f", "-v", c.name).CombinedOutput()
+	if err != nil {
+		fmt.Printf("[WARN] Failed to remove container %s: %v: %s\n", c.name, err, strings.TrimSpace(string(out)))
+	}
+}
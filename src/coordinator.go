@@ -0,0 +1,455 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A distributed ingestion splits the chunks among workers on several client
+// machines, so that the load is not capped by what one client can send. The
+// coordinator creates the schema, hands every worker a disjoint chunk range
+// once all have joined, merges the ingestion of the workers and runs the
+// query catalog. Every worker needs the same dataset in its readings
+// directory.
+//
+// The protocol is JSON requests to the coordinator: POST /assign, which is
+// answered once the last worker joined, POST /heartbeat every
+// workerHeartbeat while a worker ingests and POST /results.
+
+// workerHeartbeat is how often a worker tells the coordinator that it is
+// still ingesting.
+const workerHeartbeat = 10 * time.Second
+
+// workerAssignment is the chunk range of a worker, from ChunkStart up to, not
+// including, ChunkEnd.
+type workerAssignment struct {
+	Worker     int `json:"worker"`
+	ChunkStart int `json:"chunkStart"`
+	ChunkEnd   int `json:"chunkEnd"`
+}
+
+type WorkerResult struct {
+	Worker     int               `json:"worker"`
+	Host       string            `json:"host"`
+	ChunkStart int               `json:"chunkStart"`
+	ChunkEnd   int               `json:"chunkEnd"`
+	Ingestion  []IngestionResult `json:"ingestion"`
+	// Error is why the worker did not finish its range.
+	Error string `json:"error,omitempty"`
+}
+
+type DistributedResult struct {
+	Workers  []WorkerResult `json:"workers"`
+	NRecords int            `json:"nRecords"`
+	// WallMs is the time from the start of the first batch of any worker to
+	// the end of the last, and Throughput the readings per second over it.
+	WallMs     int64   `json:"wallMs"`
+	Throughput float64 `json:"throughput"`
+}
+
+type coordinator struct {
+	ranges []workerAssignment
+
+	mu sync.Mutex
+	// free are the ranges no waiting worker holds. A worker that leaves
+	// before the start puts its range back for the next one to join.
+	free    []workerAssignment
+	started chan struct{}
+	// hosts and lastSeen are the name and the last word of the worker of
+	// every range.
+	hosts    []string
+	lastSeen []time.Time
+	results  []*WorkerResult
+	missing  int
+	done     chan struct{}
+}
+
+func newCoordinator(ranges []workerAssignment) *coordinator {
+	return &coordinator{
+		ranges:   ranges,
+		free:     slices.Clone(ranges),
+		started:  make(chan struct{}),
+		hosts:    make([]string, len(ranges)),
+		lastSeen: make([]time.Time, len(ranges)),
+		results:  make([]*WorkerResult, len(ranges)),
+		missing:  len(ranges),
+		done:     make(chan struct{}),
+	}
+}
+
+// splitChunks divides the chunks from first up to end into workers ranges
+// whose sizes differ by at most one chunk.
+func splitChunks(first, end, workers int) []workerAssignment {
+	ranges := make([]workerAssignment, workers)
+	n := end - first
+	for i := range ranges {
+		ranges[i] = workerAssignment{Worker: i, ChunkStart: first + i*n/workers, ChunkEnd: first + (i+1)*n/workers}
+	}
+	return ranges
+}
+
+func (c *coordinator) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /assign", c.assign)
+	mux.HandleFunc("POST /heartbeat", c.heartbeat)
+	mux.HandleFunc("POST /results", c.collect)
+	return mux
+}
+
+// assign hands the next range to a joining worker and answers once every
+// worker has joined, so that the workers start together.
+func (c *coordinator) assign(w http.ResponseWriter, r *http.Request) {
+	var joining struct {
+		Host string `json:"host"`
+	}
+	json.NewDecoder(r.Body).Decode(&joining)
+	c.mu.Lock()
+	if len(c.free) == 0 {
+		c.mu.Unlock()
+		http.Error(w, fmt.Sprintf("all %d workers have joined", len(c.ranges)), http.StatusConflict)
+		return
+	}
+	assignment := c.free[0]
+	c.free = c.free[1:]
+	c.hosts[assignment.Worker] = joining.Host
+	infof("Coordinator: worker %d of %d joined: %s (%s)\n", len(c.ranges)-len(c.free), len(c.ranges), joining.Host, r.RemoteAddr)
+	if len(c.free) == 0 {
+		// The silence of a worker is counted from the start.
+		now := time.Now()
+		for i := range c.lastSeen {
+			c.lastSeen[i] = now
+		}
+		close(c.started)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-c.started:
+	case <-r.Context().Done():
+		if c.release(assignment) {
+			fmt.Printf("[WARN] Coordinator: %s (%s) left before the start; chunks %d to %d go to the next worker to join\n",
+				joining.Host, r.RemoteAddr, assignment.ChunkStart, assignment.ChunkEnd-1)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(assignment)
+}
+
+// release puts the range of a worker that left while waiting back among the
+// free ones. Once the last worker has joined the ranges are handed out and it
+// is too late: the range is still answered in case the connection is alive.
+func (c *coordinator) release(assignment workerAssignment) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	select {
+	case <-c.started:
+		return false
+	default:
+	}
+	c.free = append([]workerAssignment{assignment}, c.free...)
+	return true
+}
+
+func (c *coordinator) heartbeat(w http.ResponseWriter, r *http.Request) {
+	var beat struct {
+		Worker int `json:"worker"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&beat); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if beat.Worker < 0 || beat.Worker >= len(c.results) || c.results[beat.Worker] != nil {
+		http.Error(w, fmt.Sprintf("unexpected heartbeat of worker %d", beat.Worker), http.StatusConflict)
+		return
+	}
+	c.lastSeen[beat.Worker] = time.Now()
+}
+
+// expireSilent marks the workers that have not been heard of for longer than
+// timeout since the start as failed, so that the coordinator does not wait
+// for a worker that crashed or lost its network.
+func (c *coordinator) expireSilent(timeout time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	select {
+	case <-c.started:
+	default:
+		return
+	}
+	for i, seen := range c.lastSeen {
+		if c.results[i] != nil || time.Since(seen) <= timeout {
+			continue
+		}
+		c.results[i] = &WorkerResult{
+			Worker:     i,
+			Host:       c.hosts[i],
+			ChunkStart: c.ranges[i].ChunkStart,
+			ChunkEnd:   c.ranges[i].ChunkEnd,
+			Ingestion:  []IngestionResult{},
+			Error:      fmt.Sprintf("no word from the worker for %s", timeout),
+		}
+		fmt.Printf("[WARN] Coordinator: worker %d (%s) has not been heard of for %s, marking it failed\n", i, c.hosts[i], timeout)
+		c.missing--
+		if c.missing == 0 {
+			close(c.done)
+		}
+	}
+}
+
+func (c *coordinator) collect(w http.ResponseWriter, r *http.Request) {
+	var result WorkerResult
+	if err := json.NewDecoder(r.Body).Decode(&result); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if result.Worker < 0 || result.Worker >= len(c.results) || c.results[result.Worker] != nil {
+		http.Error(w, fmt.Sprintf("unexpected results of worker %d", result.Worker), http.StatusConflict)
+		return
+	}
+	c.results[result.Worker] = &result
+	if result.Error != "" {
+		fmt.Printf("[WARN] Coordinator: worker %d (%s) failed: %s\n", result.Worker, result.Host, result.Error)
+	} else {
+		infof("Coordinator: worker %d (%s) finished chunks %d to %d\n", result.Worker, result.Host, result.ChunkStart, result.ChunkEnd-1)
+	}
+	c.missing--
+	if c.missing == 0 {
+		close(c.done)
+	}
+}
+
+// runCoordinator serves the chunk ranges of workers workers on addr and
+// writes the merged results once all of them reported back or were silent
+// for longer than workerTimeout.
+func runCoordinator(info backendInfo, connStr string, outFile string, opts benchmarkOptions, addr string, workers int, workerTimeout time.Duration) error {
+	files, err := os.ReadDir(readingsDir)
+	if err != nil {
+		return err
+	}
+	end := scaledChunkCount(len(files), opts.Scale)
+	if opts.ChunkEnd > 0 {
+		end = min(end, opts.ChunkEnd)
+	}
+	if end-opts.ChunkStart < workers {
+		return fmt.Errorf("%d chunks cannot be split among %d workers", max(0, end-opts.ChunkStart), workers)
+	}
+
+	b, err := info.open(connStr)
+	if err != nil {
+		return err
+	}
+	defer b.close()
+	if err := configureBackend(info, b, opts); err != nil {
+		return err
+	}
+	ctx := context.Background()
+	if opts.NoCreate {
+		err = checkProvisionedSchema(ctx, info, b)
+	} else {
+		err = b.createSchema(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	c := newCoordinator(splitChunks(opts.ChunkStart, end, workers))
+	server := &http.Server{Handler: c.handler()}
+	go server.Serve(listener)
+	defer server.Close()
+	infof("Coordinator: waiting for %d workers on %s\n", workers, listener.Addr())
+
+	for waiting := true; waiting; {
+		select {
+		case <-c.done:
+			waiting = false
+		case <-time.After(time.Second):
+			if err := interrupted(); err != nil {
+				return err
+			}
+			c.expireSilent(workerTimeout)
+		}
+	}
+
+	distributed, ingestion := mergeWorkerResults(c.results)
+	results := BenchmarkResults{
+		DbType:         info.name,
+		IngestMethod:   opts.IngestMethod,
+		CatalogVersion: queryCatalogVersion,
+		Ingestion:      ingestion,
+		Distributed:    distributed,
+	}
+	var failed []string
+	for _, result := range distributed.Workers {
+		if result.Error != "" {
+			failed = append(failed, fmt.Sprintf("worker %d: %s", result.Worker, result.Error))
+		}
+	}
+	if len(failed) > 0 {
+		return errors.Join(writeResults(outFile, results), fmt.Errorf("the distributed ingestion failed: %s", strings.Join(failed, "; ")))
+	}
+	infof("Coordinator: %d workers wrote %d readings in %d ms (%.0f readings/s)\n",
+		workers, distributed.NRecords, distributed.WallMs, distributed.Throughput)
+
+	results.Queries, _, err = runQueryCatalog(ctx, info, b, opts)
+	if err != nil {
+		return err
+	}
+	return writeResults(outFile, results)
+}
+
+// mergeWorkerResults orders the batches of all workers by their start, with
+// NRecords counting the readings of every worker and OffsetMs from the first
+// batch of any worker. A worker without batches, e.g. one that failed at its
+// first chunk, has an empty list rather than null, and so has the merge.
+func mergeWorkerResults(results []*WorkerResult) (*DistributedResult, []IngestionResult) {
+	distributed := &DistributedResult{}
+	merged := []IngestionResult{}
+	for _, result := range results {
+		if result.Ingestion == nil {
+			result.Ingestion = []IngestionResult{}
+		}
+		distributed.Workers = append(distributed.Workers, *result)
+		merged = append(merged, result.Ingestion...)
+	}
+	if len(merged) == 0 {
+		return distributed, merged
+	}
+
+	slices.SortStableFunc(merged, func(a, b IngestionResult) int {
+		return a.StartedAt.Compare(b.StartedAt)
+	})
+	first, last := merged[0].StartedAt, merged[0].StartedAt
+	for i := range merged {
+		if !merged[i].Failed {
+			distributed.NRecords += merged[i].BatchRows
+		}
+		merged[i].NRecords = distributed.NRecords
+		merged[i].OffsetMs = merged[i].StartedAt.Sub(first).Milliseconds()
+		if end := merged[i].StartedAt.Add(time.Duration(merged[i].DurationMs) * time.Millisecond); end.After(last) {
+			last = end
+		}
+	}
+	distributed.WallMs = last.Sub(first).Milliseconds()
+	if distributed.WallMs > 0 {
+		distributed.Throughput = float64(distributed.NRecords) / last.Sub(first).Seconds()
+	}
+	return distributed, merged
+}
+
+// runWorker joins the coordinator at coordinatorURL, ingests the chunk range
+// it is handed and reports the batches back.
+func runWorker(info backendInfo, connStr string, opts benchmarkOptions, coordinatorURL string) error {
+	host, _ := os.Hostname()
+	name := fmt.Sprintf("%s-%d", host, os.Getpid())
+	coordinatorURL = strings.TrimSuffix(coordinatorURL, "/")
+
+	b, err := info.open(connStr)
+	if err != nil {
+		return err
+	}
+	defer b.close()
+	if err := configureBackend(info, b, opts); err != nil {
+		return err
+	}
+
+	infof("Worker: joining %s, waiting for the other workers\n", coordinatorURL)
+	var assignment workerAssignment
+	if err := postCoordinator(coordinatorURL+"/assign", map[string]string{"host": name}, &assignment); err != nil {
+		return err
+	}
+	infof("Worker %d: ingesting chunks %d to %d\n", assignment.Worker, assignment.ChunkStart, assignment.ChunkEnd-1)
+
+	opts.ChunkStart, opts.ChunkEnd = assignment.ChunkStart, assignment.ChunkEnd
+	stopHeartbeat := sendHeartbeats(coordinatorURL, assignment.Worker)
+	ingestion, err := ingestChunks(context.Background(), info, b, 0, opts)
+	stopHeartbeat()
+	if ingestion == nil {
+		ingestion = []IngestionResult{}
+	}
+	result := WorkerResult{
+		Worker:     assignment.Worker,
+		Host:       name,
+		ChunkStart: assignment.ChunkStart,
+		ChunkEnd:   assignment.ChunkEnd,
+		Ingestion:  ingestion,
+	}
+	if err != nil {
+		// Only the worker knows its credentials, so it redacts them before
+		// they reach the coordinator and its result file.
+		result.Error = redact(err.Error())
+	}
+	if postErr := postCoordinator(coordinatorURL+"/results", result, nil); postErr != nil {
+		return errors.Join(err, postErr)
+	}
+	return err
+}
+
+// sendHeartbeats posts a heartbeat every workerHeartbeat until the returned
+// function is called. A failed heartbeat is only logged: the results are
+// what the coordinator waits for.
+func sendHeartbeats(coordinatorURL string, worker int) func() {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(workerHeartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := postCoordinator(coordinatorURL+"/heartbeat", map[string]int{"worker": worker}, nil); err != nil {
+					debugf("Worker %d: heartbeat failed: %v\n", worker, err)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-done
+	}
+}
+
+// postCoordinator posts value as JSON and decodes the answer into reply,
+// unless reply is nil. There is no timeout: /assign waits for the other
+// workers.
+func postCoordinator(url string, value any, reply any) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	resp, err := http.Post(url, "application/json", bytes.NewReader(encoded))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s: %s: %s", url, resp.Status, strings.TrimSpace(string(message)))
+	}
+	if reply == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(reply)
+}
@@ -0,0 +1 @@
+SELECT MIN(timestamp), MAX(timestamp) FROM user_events
@@ -0,0 +1 @@
+SELECT ssid, COUNT(*) AS count FROM user_events GROUP BY ssid ORDER BY count DESC LIMIT 10
@@ -0,0 +1,2 @@
+-- args: second-half
+SELECT COUNT(*) FROM user_events WHERE timestamp BETWEEN $1 AND $2
@@ -0,0 +1 @@
+SELECT COUNT(DISTINCT user_id) FROM user_events
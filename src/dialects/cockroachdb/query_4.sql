@@ -0,0 +1 @@
+SELECT AVG(rssi) FROM user_events
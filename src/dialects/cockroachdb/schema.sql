@@ -0,0 +1,8 @@
+CREATE TABLE user_events (
+    id UUID NOT NULL DEFAULT gen_random_uuid() PRIMARY KEY,
+    user_id STRING NOT NULL,
+    timestamp TIMESTAMPTZ NOT NULL,
+    rssi REAL NOT NULL,
+    ssid STRING NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_user_events_timestamp ON user_events (timestamp);
@@ -0,0 +1 @@
+INSERT INTO user_events (user_id, timestamp, rssi, ssid) VALUES (?, ?, ?, ?)
@@ -0,0 +1 @@
+SELECT COUNT(*) FROM user_events WHERE rssi < -80
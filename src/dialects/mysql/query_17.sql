@@ -0,0 +1 @@
+SELECT HOUR(timestamp) AS hour, COUNT(*) AS count FROM user_events GROUP BY hour ORDER BY hour
@@ -0,0 +1,2 @@
+-- args: middle
+SELECT COUNT(*) FROM user_events WHERE timestamp < ?
@@ -0,0 +1,2 @@
+-- args: around-middle
+SELECT COUNT(*) FROM user_events WHERE timestamp BETWEEN ? AND ?
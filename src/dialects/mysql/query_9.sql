@@ -0,0 +1 @@
+SELECT user_id, COUNT(*) AS count FROM user_events GROUP BY user_id ORDER BY count DESC LIMIT 10
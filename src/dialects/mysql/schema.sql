@@ -0,0 +1,8 @@
+CREATE TABLE user_events (
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    timestamp DATETIME(6) NOT NULL,
+    rssi FLOAT NOT NULL,
+    ssid VARCHAR(255) NOT NULL,
+    INDEX idx_user_events_timestamp (timestamp)
+);
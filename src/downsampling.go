@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// sqlHourlyRollupQueries answer catalog queries from a user_events_hourly
+// rollup with the columns hour, ssid, readings and rssi_sum, in the PostgreSQL
+// dialect.
+var sqlHourlyRollupQueries = []querySpec{
+	{id: 2, text: "SELECT SUM(readings) FROM user_events_hourly"},
+	{id: 4, text: "SELECT SUM(rssi_sum) / SUM(readings) FROM user_events_hourly"},
+	{id: 8, text: "SELECT hour, SUM(readings) FROM user_events_hourly WHERE hour BETWEEN $1 AND $2 GROUP BY hour ORDER BY hour", args: dayFromMiddle},
+	{id: 12, text: "SELECT ssid, SUM(readings) AS count FROM user_events_hourly GROUP BY ssid ORDER BY count DESC LIMIT 10"},
+	{id: 17, text: "SELECT EXTRACT(hour FROM hour) AS hour_of_day, SUM(readings) AS count FROM user_events_hourly GROUP BY hour_of_day ORDER BY hour_of_day"},
+	{id: 19, text: "SELECT hour, SUM(readings) AS count FROM user_events_hourly GROUP BY hour ORDER BY count DESC LIMIT 5"},
+}
+
+// benchmarkDownsampling ingests the full dataset, times the catalog queries an
+// hourly downsample can answer on the raw table, builds the downsample with
+// the engine's own mechanism and times the same queries on it. The ratio of
+// the two is the query speedup the rollup buys for its build time.
+func benchmarkDownsampling(info backendInfo, connStr string, outFile string, opts benchmarkOptions) error {
+	rollup := info.downsampling
+	if len(rollup.create) == 0 {
+		return fmt.Errorf("downsampling scenario is not available for %s", info.name)
+	}
+
+	b, err := info.open(connStr)
+	if err != nil {
+		return err
+	}
+	defer b.close()
+
+	ctx := context.Background()
+	scenario := ScenarioResult{Name: "downsampling"}
+	if err := configureBackend(info, b, opts); err != nil {
+		return err
+	}
+	if err := loadScenarioData(ctx, info, b, opts, &scenario); err != nil {
+		return err
+	}
+
+	boundsQuery, _ := info.lookupQuery(1)
+	minTime, maxTime, err := b.timeBounds(ctx, boundsQuery.text)
+	if err != nil {
+		return err
+	}
+	bounds := newQueryBounds(minTime, maxTime)
+
+	var raw []querySpec
+	for _, q := range rollup.queries {
+		if rawQuery, ok := info.lookupQuery(q.id); ok {
+			raw = append(raw, rawQuery)
+		}
+	}
+	infof("Downsampling: running the queries on the raw table\n")
+	scenario.BaselineQueries, err = timeCatalogQueries(ctx, info, b, opts, raw, bounds)
+	if err != nil {
+		return err
+	}
+
+	infof("Downsampling: building the hourly rollup\n")
+	start := time.Now()
+	for _, stmt := range rollup.create {
+		if err := b.exec(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	scenario.addPhase("downsample", time.Since(start))
+
+	infof("Downsampling: running the queries on the rollup\n")
+	scenario.Queries, err = timeCatalogQueries(ctx, info, b, opts, rollup.queries, bounds)
+	if err != nil {
+		return err
+	}
+
+	return writeResults(outFile, BenchmarkResults{
+		DbType:        info.name,
+		SchemaVariant: opts.SchemaVariant,
+		IngestMethod:  opts.IngestMethod,
+		ChunkInterval: opts.ChunkInterval,
+		Shards:        opts.Shards,
+		Scenarios:     []ScenarioResult{scenario},
+	})
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// dedupDialect is a backend's way of keeping one row per (user_id, timestamp):
+// a schema with a uniqueness key or a deduplicating engine, the statements that
+// force pending deduplication to complete, and a query that counts the rows as
+// the engine presents them after deduplication. upsert routes the writes
+// through upsertBackend instead of the regular ingestion.
+type dedupDialect struct {
+	schema     string
+	finalize   []string
+	countQuery string
+	upsert     bool
+}
+
+// upsertBackend is implemented by backends whose bulk ingestion path rejects
+// duplicate keys, e.g. COPY against a unique index. upsert writes the readings
+// and skips the rows whose key already exists.
+type upsertBackend interface {
+	upsert(ctx context.Context, readings []Reading) error
+}
+
+// rowCounter is implemented by backends that can return a single count.
+type rowCounter interface {
+	count(ctx context.Context, q string) (int64, error)
+}
+
+// upsertingBackend routes ingestion through upsert, so the regular ingestion
+// loop can be reused for tables with a uniqueness key.
+type upsertingBackend struct {
+	backend
+	upserter upsertBackend
+}
+
+func (b upsertingBackend) ingest(ctx context.Context, readings []Reading) error {
+	return b.upserter.upsert(ctx, readings)
+}
+
+// benchmarkDuplicates ingests the dataset into a deduplicating table, re-sends
+// percent of the readings and measures what deduplication costs. The row count
+// after the duplicates must equal the one before them.
+func benchmarkDuplicates(info backendInfo, connStr string, outFile string, opts benchmarkOptions, percent int) error {
+	dedup := info.dedup
+	if dedup.schema == "" {
+		return fmt.Errorf("duplicates scenario is not available for %s", info.name)
+	}
+	if percent < 0 || percent > 100 {
+		return fmt.Errorf("duplicate percentage %d is not between 0 and 100", percent)
+	}
+	if opts.SchemaVariant != "" || opts.Scale != 1 {
+		return fmt.Errorf("the duplicates scenario uses its own schema and the unscaled dataset")
+	}
+
+	raw, err := info.open(connStr)
+	if err != nil {
+		return err
+	}
+	defer raw.close()
+
+	counter, ok := raw.(rowCounter)
+	if !ok {
+		return fmt.Errorf("backend %s cannot count rows", info.name)
+	}
+	if err := configureBackend(info, raw, opts); err != nil {
+		return err
+	}
+	raw.(schemaSetter).setSchema(dedup.schema)
+
+	var b backend = raw
+	if dedup.upsert {
+		upserter, ok := raw.(upsertBackend)
+		if !ok {
+			return fmt.Errorf("backend %s cannot upsert", info.name)
+		}
+		b = upsertingBackend{backend: raw, upserter: upserter}
+	}
+
+	ctx := context.Background()
+	scenario := ScenarioResult{Name: "duplicates"}
+	if err := loadScenarioData(ctx, info, b, opts, &scenario); err != nil {
+		return err
+	}
+	if scenario.RowsBefore, err = counter.count(ctx, dedup.countQuery); err != nil {
+		return err
+	}
+
+	infof("Duplicates: re-sending %d%% of the readings\n", percent)
+	var duplicatesDuration time.Duration
+	for currentChunk := 0; ; currentChunk++ {
+		hasNext, data, err := loadDataChunk(readingsDir, currentChunk)
+		if err != nil {
+			return err
+		}
+		multiplyCardinality(data.Response, opts.CardinalityFactor)
+
+		var duplicates []Reading
+		for i, reading := range data.Response {
+			if i%100 < percent {
+				duplicates = append(duplicates, reading)
+			}
+		}
+
+		start := time.Now()
+		_, waited, err := ingestWithRetry(ctx, b, duplicates, opts.Retry, info.isTransient)
+		if err != nil {
+			return err
+		}
+		duplicatesDuration += time.Since(start) - waited
+		scenario.DuplicatesSent += len(duplicates)
+
+		if !hasNext {
+			break
+		}
+	}
+	scenario.addPhase("duplicates", duplicatesDuration)
+
+	start := time.Now()
+	for _, stmt := range dedup.finalize {
+		if err := b.exec(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	scenario.addPhase("finalize", time.Since(start))
+
+	if scenario.RowsAfter, err = counter.count(ctx, dedup.countQuery); err != nil {
+		return err
+	}
+	if scenario.RowsAfter != scenario.RowsBefore {
+		fmt.Printf("[WARN] Duplicates: %d rows before and %d rows after re-sending %d readings\n", scenario.RowsBefore, scenario.RowsAfter, scenario.DuplicatesSent)
+	} else {
+		infof("Duplicates: row count unchanged at %d\n", scenario.RowsAfter)
+	}
+
+	return writeResults(outFile, BenchmarkResults{
+		DbType:            info.name,
+		ChunkInterval:     opts.ChunkInterval,
+		Shards:            opts.Shards,
+		CardinalityFactor: opts.CardinalityFactor,
+		Scenarios:         []ScenarioResult{scenario},
+	})
+}
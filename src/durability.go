@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+)
+
+// Durability levels of -durability. Engines differ in what they promise when
+// they acknowledge a write, so throughput is only comparable between runs at
+// the same level: relaxed acknowledges before the data is on disk, strict
+// only after.
+const (
+	durabilityRelaxed = "relaxed"
+	durabilityStrict  = "strict"
+)
+
+// durabilityDialect is how a backend sets the durability of its writes. The
+// settings the server only reads at startup are set through the container,
+// see containerSpec.durabilityEnv.
+type durabilityDialect struct {
+	// params are, per level, the session settings of every connection:
+	// run-time parameters on pgx, query settings on ClickHouse.
+	params map[string]map[string]string
+	// statements are, per level, run once the schema exists, e.g. table
+	// settings.
+	statements map[string][]string
+	// show returns the effective durability settings as name and value rows;
+	// they are recorded with every run, with or without -durability.
+	show string
+}
+
+// durabilityParams are the session settings of -durability for the backend
+// under test, read by the backends as they open their connections.
+var durabilityParams map[string]string
+
+type DurabilityResult struct {
+	// Level is empty when the run kept the engine's defaults.
+	Level    string            `json:"level,omitempty"`
+	Settings map[string]string `json:"settings,omitempty"`
+}
+
+// pgDurability sets synchronous_commit, which decides whether a commit waits
+// for its WAL flush; fsync and the other server settings are only recorded.
+var pgDurability = durabilityDialect{
+	params: map[string]map[string]string{
+		durabilityRelaxed: {"synchronous_commit": "off"},
+		durabilityStrict:  {"synchronous_commit": "on"},
+	},
+	show: "SELECT name, setting FROM pg_settings WHERE name IN ('synchronous_commit', 'fsync', 'full_page_writes', 'wal_sync_method', 'commit_delay')",
+}
+
+// supportsDurability reports whether the backend can run at the level.
+func (info backendInfo) supportsDurability(level string) bool {
+	return len(info.durability.params[level]) > 0 || len(info.durability.statements[level]) > 0 || info.durabilityByServer(level)
+}
+
+// durabilityByServer reports whether the level is only set in the server's
+// configuration, which needs a managed container.
+func (info backendInfo) durabilityByServer(level string) bool {
+	return len(info.durability.params[level]) == 0 && len(info.durability.statements[level]) == 0 && len(info.container.durabilityEnv[level]) > 0
+}
+
+func checkDurabilityLevel(level string) error {
+	if level != "" && level != durabilityRelaxed && level != durabilityStrict {
+		return fmt.Errorf("unknown durability level %q, expected %s or %s", level, durabilityRelaxed, durabilityStrict)
+	}
+	return nil
+}
+
+// applyDurability runs the statements of the level and records the effective
+// settings. Without a show query the settings are the ones the level set.
+func applyDurability(ctx context.Context, info backendInfo, b backend, level string) (*DurabilityResult, error) {
+	for _, stmt := range info.durability.statements[level] {
+		if err := b.exec(ctx, stmt); err != nil {
+			return nil, fmt.Errorf("setting %s durability: %w", level, err)
+		}
+	}
+
+	result := &DurabilityResult{Level: level, Settings: map[string]string{}}
+	scanner, ok := b.(rowScanner)
+	if info.durability.show != "" && ok {
+		err := scanner.scanRows(ctx, func(values []any) {
+			if len(values) >= 2 {
+				result.Settings[settingText(values[0])] = settingText(values[1])
+			}
+		}, info.durability.show)
+		if err == nil {
+			return result, nil
+		}
+		fmt.Printf("[WARN] Could not read the durability settings of %s: %v\n", info.name, err)
+		clear(result.Settings)
+	}
+	maps.Copy(result.Settings, info.durability.params[level])
+	for _, env := range info.container.durabilityEnv[level] {
+		name, value, _ := strings.Cut(env, "=")
+		result.Settings[name] = value
+	}
+	if level == "" && len(result.Settings) == 0 {
+		return nil, nil
+	}
+	return result, nil
+}
+
+func settingText(value any) string {
+	if raw, ok := value.([]byte); ok {
+		return string(raw)
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// energyInterval is how often the energy source is read during a phase; the
+// power of ipmi is integrated over it and the RAPL counters must not wrap
+// twice in between.
+const energyInterval = time.Second
+
+// energySource reads the energy consumption of the database host. Counters
+// return the energy in joules since an arbitrary origin, the others the power
+// in watts at the time of the read.
+type energySource interface {
+	name() string
+	cumulative() bool
+	read(ctx context.Context) (float64, error)
+}
+
+// newEnergySource parses -energy: rapl, scaphandre=<metrics URL> or ipmi,
+// optionally ipmi=<ipmitool options> for a remote BMC. The source is read
+// once, so a missing sensor is a configuration error and not a warning in
+// the middle of the run.
+func newEnergySource(spec string) (energySource, error) {
+	kind, arg, _ := strings.Cut(spec, "=")
+	var source energySource
+	switch kind {
+	case "rapl":
+		zones, err := filepath.Glob("/sys/class/powercap/intel-rapl:[0-9]")
+		if err != nil || len(zones) == 0 {
+			return nil, fmt.Errorf("-energy rapl: no RAPL package zones under /sys/class/powercap")
+		}
+		source = &raplSource{zones: zones, last: make([]int64, len(zones))}
+	case "scaphandre":
+		if arg == "" {
+			return nil, fmt.Errorf("-energy scaphandre needs the URL of the exporter, e.g. scaphandre=http://db-host:8080/metrics")
+		}
+		source = &scaphandreSource{url: arg}
+	case "ipmi":
+		source = &ipmiSource{args: strings.Fields(arg)}
+	default:
+		return nil, fmt.Errorf("unknown -energy source %q, expected rapl, scaphandre=<url> or ipmi", spec)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := source.read(ctx); err != nil {
+		return nil, fmt.Errorf("-energy %s: %w", kind, err)
+	}
+	return source, nil
+}
+
+type EnergyResult struct {
+	Source string        `json:"source"`
+	Phases []PhaseEnergy `json:"phases"`
+}
+
+// PhaseEnergy is the energy the database host consumed over one phase of the
+// run, idle consumption included.
+type PhaseEnergy struct {
+	Phase      string  `json:"phase"`
+	DurationMs int64   `json:"durationMs"`
+	Joules     float64 `json:"joules"`
+	MeanWatts  float64 `json:"meanWatts"`
+	PeakWatts  float64 `json:"peakWatts"`
+	// RowsPerJoule is the ingestion efficiency; it is only set for the
+	// ingestion.
+	RowsPerJoule float64 `json:"rowsPerJoule,omitempty"`
+}
+
+// meterPhase reads the source every energyInterval until the returned
+// function is called, which returns the energy of the phase. The peak is the
+// highest power over one interval.
+func meterPhase(source energySource, phase string) func() PhaseEnergy {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan PhaseEnergy)
+	go func() {
+		energy := PhaseEnergy{Phase: phase}
+		start := time.Now()
+		ticker := time.NewTicker(energyInterval)
+		defer ticker.Stop()
+		var last float64
+		var firstAt, lastAt time.Time
+		warned := false
+		for stopping := false; ; {
+			// The last read is taken after the phase has ended, so it gets a
+			// context of its own.
+			readCtx, cancelRead := context.WithTimeout(context.Background(), 5*energyInterval)
+			value, err := source.read(readCtx)
+			cancelRead()
+			now := time.Now()
+			switch {
+			case err != nil:
+				if !warned {
+					fmt.Printf("[WARN] Failed to read the energy of the %s: %v\n", phase, err)
+					warned = true
+				}
+			case lastAt.IsZero():
+				firstAt = now
+			case source.cumulative():
+				energy.Joules += value - last
+				energy.PeakWatts = max(energy.PeakWatts, (value-last)/now.Sub(lastAt).Seconds())
+			default:
+				// The power is integrated with the trapezoidal rule.
+				energy.Joules += (value + last) / 2 * now.Sub(lastAt).Seconds()
+				energy.PeakWatts = max(energy.PeakWatts, value)
+			}
+			if err == nil {
+				lastAt, last = now, value
+			}
+			if stopping {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				stopping = true
+			case <-ticker.C:
+			}
+		}
+		energy.DurationMs = time.Since(start).Milliseconds()
+		// The mean is taken over the span between the first and the last
+		// read, which the joules cover.
+		if span := lastAt.Sub(firstAt).Seconds(); span > 0 {
+			energy.MeanWatts = energy.Joules / span
+		}
+		done <- energy
+	}()
+
+	var once sync.Once
+	var energy PhaseEnergy
+	return func() PhaseEnergy {
+		once.Do(func() {
+			cancel()
+			energy = <-done
+			infof("The database host used %.0f J over the %s (%.1f W on average, %.1f W peak)\n",
+				energy.Joules, phase, energy.MeanWatts, energy.PeakWatts)
+		})
+		return energy
+	}
+}
+
+// raplSource sums the package zones of the Intel and AMD RAPL counters of the
+// host the benchmark runs on, which is the database host with managed
+// containers. The subzones of a package, e.g. its cores, are part of it and
+// are left out. The counters wrap at max_energy_range_uj.
+type raplSource struct {
+	zones  []string
+	last   []int64
+	total  float64
+	primed bool
+}
+
+func (s *raplSource) name() string     { return "rapl" }
+func (s *raplSource) cumulative() bool { return true }
+
+func (s *raplSource) read(ctx context.Context) (float64, error) {
+	for i, zone := range s.zones {
+		value, err := readCounter(filepath.Join(zone, "energy_uj"))
+		if err != nil {
+			return 0, err
+		}
+		if s.primed {
+			delta := value - s.last[i]
+			if delta < 0 {
+				wrap, err := readCounter(filepath.Join(zone, "max_energy_range_uj"))
+				if err != nil {
+					return 0, err
+				}
+				delta += wrap
+			}
+			s.total += float64(delta) / 1e6
+		}
+		s.last[i] = value
+	}
+	s.primed = true
+	return s.total, nil
+}
+
+func readCounter(path string) (int64, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(raw)), 10, 64)
+}
+
+// scaphandreSource reads the host energy counter of a Scaphandre Prometheus
+// exporter, which can run on a database host other than the benchmark's.
+type scaphandreSource struct {
+	url string
+}
+
+const scaphandreMetric = "scaph_host_energy_microjoules"
+
+func (s *scaphandreSource) name() string     { return "scaphandre" }
+func (s *scaphandreSource) cumulative() bool { return true }
+
+func (s *scaphandreSource) read(ctx context.Context) (float64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("%s answered %s", s.url, resp.Status)
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		name, rest, _ := strings.Cut(line, " ")
+		if name != scaphandreMetric && !strings.HasPrefix(name, scaphandreMetric+"{") {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			break
+		}
+		microjoules, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", scaphandreMetric, err)
+		}
+		return microjoules / 1e6, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("%s does not export %s", s.url, scaphandreMetric)
+}
+
+// ipmiSource reads the power of the host from its BMC with ipmitool dcmi
+// power reading. The options select a remote BMC, e.g. -I lanplus -H bmc
+// -U admin -E, with the password in IPMI_PASSWORD.
+type ipmiSource struct {
+	args []string
+}
+
+func (s *ipmiSource) name() string     { return "ipmi" }
+func (s *ipmiSource) cumulative() bool { return false }
+
+func (s *ipmiSource) read(ctx context.Context) (float64, error) {
+	args := append(append([]string{}, s.args...), "dcmi", "power", "reading")
+	out, err := exec.CommandContext(ctx, "ipmitool", args...).CombinedOutput()
+	if err != nil {
+		return 0, fmt.Errorf("ipmitool: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		label, value, ok := strings.Cut(line, ":")
+		if !ok || strings.TrimSpace(label) != "Instantaneous power reading" {
+			continue
+		}
+		watts, _, _ := strings.Cut(strings.TrimSpace(value), " ")
+		return strconv.ParseFloat(watts, 64)
+	}
+	return 0, fmt.Errorf("ipmitool reported no instantaneous power reading")
+}
@@ -1,20 +1,24 @@
 package main
 
 import (
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	qdb "github.com/questdb/go-questdb-client/v3"
+	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/ClickHouse/clickhouse-go/v2"
-	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
-	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/klauspost/compress/zstd"
+
+	"src/results"
 )
 
 type Reading struct {
@@ -24,6 +28,10 @@ type Reading struct {
 		Ssid string  `json:"ssid"`
 		Rssi float64 `json:"rssi"`
 	} `json:"connection"`
+	// Location is only used by the locations scenario.
+	Location *readingLocation `json:"location,omitempty"`
+	// Extra holds the values of the -extra-columns fields, in their order.
+	Extra []any `json:"-"`
 }
 
 type ReadingFile struct {
@@ -31,2601 +39,1639 @@ type ReadingFile struct {
 }
 
 type QueryResult struct {
-	QueryId     int    `json:"queryId"`
-	DurationMs  int64  `json:"durationMs"`
-	Description string `json:"description"`
+	QueryId int `json:"queryId"`
+	// Query is the stable name of a catalog query and Categories what kind
+	// of query it is; both are empty for the queries of scenarios.
+	Query       string   `json:"query,omitempty"`
+	Categories  []string `json:"categories,omitempty"`
+	DurationMs  int64    `json:"durationMs"`
+	Description string   `json:"description"`
+	Plan        string   `json:"plan,omitempty"`
+	// SamplesMs holds every repeat when a query is run more than once;
+	// DurationMs is then their median.
+	SamplesMs []int64 `json:"samplesMs,omitempty"`
+	// WarmupMs are the durations of the -warmup runs before the repeats,
+	// which no statistic includes.
+	WarmupMs []int64 `json:"warmupMs,omitempty"`
+	// ColdHookMs is the time spent in -cold-hook before the runs of the
+	// query, which no duration includes.
+	ColdHookMs int64 `json:"coldHookMs,omitempty"`
+	// ServerMs is the mean execution time of a run as logged by the engine,
+	// under -server-timing; the rest of the client time is network, driver and
+	// result transfer.
+	ServerMs float64 `json:"serverMs,omitempty"`
+	// Rows and Checksum describe the result set under -result-checksums, so a
+	// query whose answer changes between two versions of an engine is spotted
+	// without comparing the rows themselves.
+	Rows     *int64 `json:"rows,omitempty"`
+	Checksum string `json:"checksum,omitempty"`
+}
+
+type PhaseResult struct {
+	Name       string `json:"name"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+type ScenarioResult struct {
+	Name       string        `json:"name"`
+	DurationMs int64         `json:"durationMs"`
+	NRecords   int           `json:"nRecords,omitempty"`
+	Phases     []PhaseResult `json:"phases"`
+	// SizeBeforeBytes and SizeAfterBytes are the on-disk size of the table
+	// around a phase that rewrites it, e.g. compression.
+	SizeBeforeBytes int64 `json:"sizeBeforeBytes,omitempty"`
+	SizeAfterBytes  int64 `json:"sizeAfterBytes,omitempty"`
+	// DuplicatesSent readings were re-sent between RowsBefore and RowsAfter,
+	// which match when the engine deduplicated them.
+	DuplicatesSent int           `json:"duplicatesSent,omitempty"`
+	RowsBefore     int64         `json:"rowsBefore,omitempty"`
+	RowsAfter      int64         `json:"rowsAfter,omitempty"`
+	Queries        []QueryResult `json:"queries,omitempty"`
+	// BaselineQueries are the raw-table counterparts of Queries, timed on the
+	// same data before a phase that builds a rollup.
+	BaselineQueries []QueryResult `json:"baselineQueries,omitempty"`
+	// SingleRow holds the insert latencies of the single-row scenario.
+	SingleRow *SingleRowResult `json:"singleRow,omitempty"`
+	// ConnectionChurn holds the write latencies of the connection-churn
+	// scenario.
+	ConnectionChurn *ConnectionChurnResult `json:"connectionChurn,omitempty"`
+	// Partitions and SizeBytes describe the table of the native-partitions
+	// scenario once loaded.
+	Partitions int   `json:"partitions,omitempty"`
+	SizeBytes  int64 `json:"sizeBytes,omitempty"`
+}
+
+// addPhase records a finished phase and adds it to the scenario total.
+func (s *ScenarioResult) addPhase(name string, duration time.Duration) {
+	s.Phases = append(s.Phases, PhaseResult{Name: name, DurationMs: duration.Milliseconds()})
+	s.DurationMs += duration.Milliseconds()
 }
 
+// IngestionResult is kept in the results package, so that tools reading the
+// result files can use it.
+type IngestionResult = results.IngestionResult
+
 type BenchmarkResults struct {
-	DbType    string `json:"dbType"`
-	Ingestion []struct {
-		DurationMs int64 `json:"durationMs"`
-		NRecords   int   `json:"nRecords"`
-	} `json:"ingestion"`
-	Queries []QueryResult `json:"queries"`
+	DbType            string                `json:"dbType"`
+	SchemaVariant     string                `json:"schemaVariant,omitempty"`
+	ChunkInterval     string                `json:"chunkInterval,omitempty"`
+	Shards            int                   `json:"shards,omitempty"`
+	IngestMethod      string                `json:"ingestMethod,omitempty"`
+	Pass              int                   `json:"pass,omitempty"`
+	Scale             float64               `json:"scale,omitempty"`
+	CardinalityFactor int                   `json:"cardinalityFactor,omitempty"`
+	ChunkStart        int                   `json:"chunkStart,omitempty"`
+	ChunkEnd          int                   `json:"chunkEnd,omitempty"`
+	Seed              uint64                `json:"seed,omitempty"`
+	CatalogVersion    int                   `json:"catalogVersion,omitempty"`
+	Capabilities      *Capabilities         `json:"capabilities,omitempty"`
+	RandomParams      bool                  `json:"randomParams,omitempty"`
+	Pseudonymization  string                `json:"pseudonymization,omitempty"`
+	SchemaMapping     string                `json:"schemaMapping,omitempty"`
+	PrefetchChunks    int                   `json:"prefetchChunks,omitempty"`
+	Pool              *PoolSettings         `json:"pool,omitempty"`
+	Client            *ClientSettings       `json:"client,omitempty"`
+	ClientGC          *ClientGCResult       `json:"clientGc,omitempty"`
+	ClientProfiles    []ClientProfile       `json:"clientProfiles,omitempty"`
+	Energy            *EnergyResult         `json:"energy,omitempty"`
+	Calibration       *CalibrationResult    `json:"calibration,omitempty"`
+	Resources         *ResourceUsage        `json:"resources,omitempty"`
+	Topology          *TopologyResult       `json:"topology,omitempty"`
+	Schema            *PhaseResult          `json:"schema,omitempty"`
+	Ingestion         []IngestionResult     `json:"ingestion"`
+	AsyncWrites       *AsyncWriteResult     `json:"asyncWrites,omitempty"`
+	Reconciliation    *ReconciliationResult `json:"reconciliation,omitempty"`
+	Warmup            *WarmupResult         `json:"warmup,omitempty"`
+	ColdRestart       *ColdRestartResult    `json:"coldRestart,omitempty"`
+	Queries           []QueryResult         `json:"queries"`
+	Fidelity          *FidelityResult       `json:"fidelity,omitempty"`
+	HourCheck         *HourCheckResult      `json:"hourCheck,omitempty"`
+	BucketSweep       []BucketSweepResult   `json:"bucketSweep,omitempty"`
+	SizeSweep         []SizeSweepResult     `json:"sizeSweep,omitempty"`
+	Concurrency       *ConcurrencyResult    `json:"concurrencySweep,omitempty"`
+	Distributed       *DistributedResult    `json:"distributed,omitempty"`
+	Joins             *JoinResult           `json:"joins,omitempty"`
+	ExtraColumns      *ExtraColumnsResult   `json:"extraColumns,omitempty"`
+	Durability        *DurabilityResult     `json:"durability,omitempty"`
+	ServerSettings    map[string]string     `json:"serverSettings,omitempty"`
+	Maintenance       *MaintenanceResult    `json:"maintenance,omitempty"`
+	Export            *ExportResult         `json:"export,omitempty"`
+	Archive           *ArchiveResult        `json:"archive,omitempty"`
+	Retention         *RetentionResult      `json:"retention,omitempty"`
+	DryRun            *DryRunResult         `json:"dryRun,omitempty"`
+	Scenarios         []ScenarioResult      `json:"scenarios,omitempty"`
+
+	ReadYourWrites *ReadYourWritesResult `json:"readYourWrites,omitempty"`
+	Workload       *WorkloadResult       `json:"workload,omitempty"`
+	SLO            *SLOResult            `json:"slo,omitempty"`
+	Manifest       *RunManifest          `json:"manifest,omitempty"`
+	// Partial marks results recovered from the journal of a run that did not
+	// complete.
+	Partial bool `json:"partial,omitempty"`
 }
 
-func loadDataChunk(currentChunk int) (bool, ReadingFile, error) {
-	fmt.Printf("[INFO] Loading data chunk %d\n", currentChunk)
-	fd, err := os.Open("../data/readings/readings_" + strconv.Itoa(currentChunk) + ".json")
+// compressResultsOver is the size in bytes above which result files are
+// written gzip-compressed; 0 disables compression.
+var compressResultsOver int64 = 1 << 20
+
+func writeResults(outFile string, benchmark BenchmarkResults) error {
+	encoded, err := results.Encode(benchmark)
 	if err != nil {
-		return false, ReadingFile{}, err
+		return err
 	}
-
-	defer fd.Close()
-	var data ReadingFile
-	if err := json.NewDecoder(fd).Decode(&data); err != nil {
-		return false, ReadingFile{}, err
+	if err := writeResultFile(outFile, encoded); err != nil {
+		return err
 	}
+	addToRunSummary(benchmark)
+	publishResults(benchmark, encoded)
+	return nil
+}
 
-	filesInDirectory, err := os.ReadDir("../data/readings")
-	if err != nil {
-		return false, ReadingFile{}, err
+func writeResultFile(outFile string, encoded []byte) error {
+	path, err := results.WriteFile(outFile, encoded, compressResultsOver)
+	if err == nil && path != outFile {
+		infof("Results are %d bytes, wrote %s\n", len(encoded), path)
 	}
-
-	if currentChunk+1 < len(filesInDirectory) {
-		return true, data, nil
+	if err == nil && runSummary != nil && !slices.Contains(runSummary.Outputs, path) {
+		runSummary.Outputs = append(runSummary.Outputs, path)
 	}
+	return err
+}
 
-	return false, data, nil
+// readingsDir holds the measured dataset, one readings_N.json file per chunk.
+// The integration tests point it at a generated dataset.
+var readingsDir = "../data/readings"
+
+func loadDataChunk(dir string, currentChunk int) (bool, ReadingFile, error) {
+	hasNext, data, _, err := loadDataChunkTimed(dir, currentChunk)
+	return hasNext, data, err
 }
 
-func benchmarkPostgres(connStr string, outFile string) error {
-	pool, err := pgxpool.New(context.Background(), connStr)
+// chunkExtensions are the forms of a chunk file the loader accepts, tried in
+// this order: plain JSON, gzip and zstd. A directory holds one form per chunk.
+var chunkExtensions = []string{".json", ".json.gz", ".json.zst"}
+
+// loadDataChunkTimed also returns the time spent decompressing the chunk file,
+// 0 for plain JSON.
+func loadDataChunkTimed(dir string, currentChunk int) (bool, ReadingFile, time.Duration, error) {
+	infof("Loading data chunk %d\n", currentChunk)
+	var fd *os.File
+	var ext string
+	var err error
+	for _, ext = range chunkExtensions {
+		fd, err = os.Open(filepath.Join(dir, "readings_"+strconv.Itoa(currentChunk)+ext))
+		if !errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+	}
 	if err != nil {
-		return err
+		return false, ReadingFile{}, 0, err
 	}
 
-	// Create the table if it doesn't exist
-	_, err = pool.Exec(context.Background(), `
-		CREATE TABLE user_events (
-			id BIGSERIAL,
-			user_id VARCHAR(255) NOT NULL,
-			timestamp TIMESTAMP WITH TIME ZONE NOT NULL,
-			rssi REAL NOT NULL,
-			ssid VARCHAR(255) NOT NULL
-		); CREATE INDEX IF NOT EXISTS idx_user_events_timestamp ON user_events (timestamp);`)
+	defer fd.Close()
+	var data ReadingFile
+	var decompress time.Duration
+	var encoded []byte
+	switch {
+	case ext == ".json" && len(extraColumns) == 0 && readingMapping == nil:
+		err = json.NewDecoder(fd).Decode(&data)
+	case ext == ".json":
+		// The extra fields are decoded from the same bytes in a second pass.
+		encoded, err = io.ReadAll(fd)
+	default:
+		// Decompress the whole chunk before decoding it, so that the two can
+		// be told apart.
+		start := time.Now()
+		if encoded, err = decompressChunk(fd, ext); err != nil {
+			return false, ReadingFile{}, 0, fmt.Errorf("%s: %w", fd.Name(), err)
+		}
+		decompress = time.Since(start)
+	}
+	if err == nil && encoded != nil {
+		if readingMapping != nil {
+			if data.Response, err = readingMapping.decode(encoded); err != nil {
+				err = fmt.Errorf("%s: %w", fd.Name(), err)
+			}
+		} else {
+			err = json.Unmarshal(encoded, &data)
+		}
+	}
 	if err != nil {
-		return err
+		return false, ReadingFile{}, 0, err
 	}
-
-	currentChunk := 0
-	results := BenchmarkResults{}
-	nRecords := 0
-
-	// Ingestion benchmark
-	for {
-		hasNext, data, err := loadDataChunk(currentChunk)
-		if err != nil {
-			return err
+	if len(extraColumns) > 0 && readingMapping == nil {
+		if err := decodeExtraFields(encoded, data.Response); err != nil {
+			return false, ReadingFile{}, 0, fmt.Errorf("%s: %w", fd.Name(), err)
 		}
+	}
+	pseudonyms.apply(data.Response)
 
-		start := time.Now()
+	filesInDirectory, err := os.ReadDir(dir)
+	if err != nil {
+		return false, ReadingFile{}, 0, err
+	}
 
-		rows := make([][]interface{}, len(data.Response))
-		for i, reading := range data.Response {
-			rows[i] = []interface{}{
-				reading.UserId,
-				time.Unix(int64(reading.LastUpdatedTime), 0),
-				reading.Connection.Rssi,
-				reading.Connection.Ssid,
-			}
-		}
+	if currentChunk+1 < len(filesInDirectory) {
+		return true, data, decompress, nil
+	}
 
-		_, err = pool.CopyFrom(
-			context.Background(),
-			pgx.Identifier{"user_events"},
-			[]string{"user_id", "timestamp", "rssi", "ssid"},
-			pgx.CopyFromRows(rows),
-		)
+	return false, data, decompress, nil
+}
 
+func decompressChunk(r io.Reader, ext string) ([]byte, error) {
+	switch ext {
+	case ".json.gz":
+		zr, err := gzip.NewReader(r)
 		if err != nil {
-			return err
+			return nil, err
 		}
-
-		nRecords += len(data.Response)
-		duration := time.Since(start).Milliseconds()
-		results.Ingestion = append(results.Ingestion, struct {
-			DurationMs int64 `json:"durationMs"`
-			NRecords   int   `json:"nRecords"`
-		}{
-			DurationMs: duration,
-			NRecords:   nRecords,
-		})
-
-		currentChunk++
-		if !hasNext {
-			break
+		defer zr.Close()
+		return io.ReadAll(zr)
+	case ".json.zst":
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
 		}
+		defer zr.Close()
+		return io.ReadAll(zr)
 	}
+	return nil, fmt.Errorf("unknown chunk compression %s", ext)
+}
 
-	// Query benchmarks
-	var minTime, maxTime, middleTime time.Time
+// benchmarkOptions are the optional phases and behaviours of a full benchmark
+// run.
+type benchmarkOptions struct {
+	Retry   retryPolicy
+	Archive archiveOptions
+	Warmup  warmupOptions
+	// SchemaVariant selects one of the backend's schemaVariants; empty keeps
+	// the default DDL.
+	SchemaVariant string
+	// ChunkInterval overrides the chunk width of backends that partition the
+	// table into time chunks, e.g. "1 hour".
+	ChunkInterval string
+	// Shards overrides the shard count of backends that shard the table;
+	// 0 keeps the backend default.
+	Shards int
+	// IngestMethod selects one of the backend's ingestMethods; empty keeps
+	// the default.
+	IngestMethod string
+	// Durability is the durability level of the writes, relaxed or strict;
+	// empty keeps the engine's defaults.
+	Durability string
+	// PrefetchChunks is how many chunks are read and decoded ahead of the one
+	// being written; 0 loads every chunk after the previous one is written.
+	PrefetchChunks int
+	// QueryRepeats is how often every query is run; the raw durations are
+	// kept next to the median.
+	QueryRepeats int
+	// QueryWarmup is how often every catalog query runs before its repeats,
+	// so that engines that compile or cache plans are timed in steady state.
+	QueryWarmup int
+	// ColdHook is run before every catalog query, or every run of one, to
+	// time it on a cold database; nil runs the queries back to back.
+	ColdHook *coldHook
+	// BucketSweep runs the occupancy aggregation at every bucket width after
+	// the query catalog.
+	BucketSweep bool
+	// Concurrency runs one catalog query from a growing number of clients
+	// after the query catalog.
+	Concurrency concurrencyOptions
+	// Explain attaches the EXPLAIN output of every query to its result.
+	Explain bool
+	// ResultChecksums runs every catalog query once more after it has been
+	// timed and records the number of rows and a checksum of the result.
+	ResultChecksums bool
+	// ServerTiming reads the execution time of every catalog query from the
+	// engine's statement log next to the client round trip.
+	ServerTiming bool
+	// RetentionFraction is the share of the time range, from the oldest
+	// reading, that is expired after the queries; 0 skips the phase.
+	RetentionFraction float64
+	// Scale is the share of the dataset that is ingested: below 1 only the
+	// leading chunks, above 1 time-shifted replays of it.
+	Scale float64
+	// CardinalityFactor multiplies the number of distinct users and access
+	// points in the ingested data; 1 keeps the dataset as it is.
+	CardinalityFactor int
+	// ChunkStart and ChunkEnd limit the measured ingestion to the chunks from
+	// ChunkStart up to, not including, ChunkEnd; 0 ends with the dataset.
+	ChunkStart int
+	ChunkEnd   int
+	// WriteBatchSize is the number of rows per write request of backends that
+	// split a chunk; 0 keeps the backend default.
+	WriteBatchSize int
+	// Pass is the index of the run within a repeated campaign, recorded so
+	// that time-of-day effects can be told apart from engine differences.
+	Pass int
+	// Joins loads the users and access points dimension tables after the
+	// query catalog and runs the join queries.
+	Joins bool
+	// Export times a full export of the data after the queries.
+	Export bool
+	// VerifyHours compares the hour-of-day buckets of the backend with the
+	// source readings after the queries.
+	VerifyHours bool
+	// FidelitySamples is the number of source readings looked up after the
+	// queries to detect lost or altered points; 0 skips the audit.
+	FidelitySamples int
+	// ColdRestart restarts Container between the ingestion and the queries
+	// and runs the catalog once on the cold database.
+	ColdRestart bool
+	// Maintenance times the engine's maintenance operations after the query
+	// catalog and runs the catalog again.
+	Maintenance bool
+	// Container is the managed database of the run, nil when the database is
+	// not managed by the tool.
+	Container managedDatabase
+	// NoCreate skips the DDL of the readings table and checks the schema of a
+	// database provisioned outside the tool instead.
+	NoCreate bool
+	// Topology is the multi-node target of -topology; nil for a single
+	// database.
+	Topology *topologyConfig
+	// ReadyTimeout bounds the wait for the database after a restart.
+	ReadyTimeout time.Duration
+	// RandomParams anchors the time range queries of the catalog at a random
+	// reading on every repetition instead of the middle time.
+	RandomParams bool
+	// Seed drives everything random in a run: the fidelity sample, the
+	// synthetic dimensions and the random query parameters. It is recorded in
+	// the results, so a run can be repeated exactly.
+	Seed uint64
+	// DimensionsFile is the JSON dimension dataset of the join phase and the
+	// buildings and locations scenarios; a synthetic one is derived from the
+	// readings when it is empty.
+	DimensionsFile string
+	// Workload is run by concurrent clients after the query catalog; nil
+	// skips the phase.
+	Workload *workload
+	// SLO holds the objectives of -slo the results are checked against; nil
+	// checks none.
+	SLO *sloConfig
+	// Manifest hashes the dataset, the query catalog and the binary after the
+	// run and records them with the server version in the results.
+	Manifest bool
+	// Progress journals the ingestion chunks and catalog queries as they are
+	// measured; nil outside the main ingestion and catalog of a run.
+	Progress *progressLog
+	// SizeSweep are the percentages of the chunks after which the ingestion
+	// pauses to run the query catalog.
+	SizeSweep []float64
+	// Checkpoints holds the catalogs of SizeSweep; nil outside the measured
+	// ingestion of a run.
+	Checkpoints *sizeSweep
+	// ProfileDir receives CPU and heap profiles of the client over the
+	// measured ingestion and the query catalog; empty takes none.
+	ProfileDir string
+	// Calibrate measures the overhead of the client, against no database,
+	// before the schema is created.
+	Calibrate bool
+	// Energy meters the database host over the measured ingestion and the
+	// query catalog; nil measures nothing.
+	Energy energySource
+}
 
-	// Query 1: Get time bounds
-	fmt.Println("[INFO] Running query 1: Get time bounds")
-	start := time.Now()
-	err = pool.QueryRow(context.Background(), "SELECT MIN(timestamp), MAX(timestamp) FROM user_events").Scan(&minTime, &maxTime)
-	if err != nil {
-		return err
+// checkBackend rejects options the backend does not support.
+func (opts benchmarkOptions) checkBackend(info backendInfo) error {
+	if _, ok := info.lookupSchemaVariant(opts.SchemaVariant); opts.SchemaVariant != "" && !ok {
+		return fmt.Errorf("unknown schema variant for %s: %s", info.name, opts.SchemaVariant)
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     1,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Get time bounds",
-	})
-	fmt.Println("[INFO] Done with query 1")
-
-	middleTime = minTime.Add(maxTime.Sub(minTime) / 2)
-
-	// Query 2: Count all records
-	fmt.Println("[INFO] Running query 2: Count all records")
-	start = time.Now()
-	var totalCount int
-	err = pool.QueryRow(context.Background(), "SELECT COUNT(*) FROM user_events").Scan(&totalCount)
-	if err != nil {
-		return err
+	if opts.IngestMethod != "" && !slices.Contains(info.ingestMethods, opts.IngestMethod) {
+		return fmt.Errorf("unknown ingest method for %s: %s", info.name, opts.IngestMethod)
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     2,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Count all records",
-	})
-	fmt.Println("[INFO] Done with query 2")
-
-	// Query 3: Count distinct users
-	fmt.Println("[INFO] Running query 3: Count distinct users")
-	start = time.Now()
-	var distinctUsers int
-	err = pool.QueryRow(context.Background(), "SELECT COUNT(DISTINCT user_id) FROM user_events").Scan(&distinctUsers)
-	if err != nil {
-		return err
+	if opts.Archive.Target != "" && !info.tiered {
+		return fmt.Errorf("tiered storage is not supported for database type: %s", info.name)
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     3,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Count distinct users",
-	})
-	fmt.Println("[INFO] Done with query 3")
-
-	// Query 4: Average RSSI
-	fmt.Println("[INFO] Running query 4: Average RSSI")
-	start = time.Now()
-	var avgRssi float64
-	err = pool.QueryRow(context.Background(), "SELECT AVG(rssi) FROM user_events").Scan(&avgRssi)
-	if err != nil {
-		return err
+	if opts.BucketSweep && info.bucketQuery == nil {
+		return fmt.Errorf("the bucket sweep is not supported for database type: %s", info.name)
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     4,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Average RSSI",
-	})
-	fmt.Println("[INFO] Done with query 4")
-
-	// Query 5: Records before middle time
-	fmt.Println("[INFO] Running query 5: Records before middle time")
-	start = time.Now()
-	var beforeMiddle int
-	err = pool.QueryRow(context.Background(), "SELECT COUNT(*) FROM user_events WHERE timestamp < $1", middleTime).Scan(&beforeMiddle)
-	if err != nil {
-		return err
+	if _, ok := info.lookupQuery(opts.Concurrency.QueryId); opts.Concurrency.enabled() && !ok {
+		return fmt.Errorf("query %d of the concurrency sweep is not supported for database type: %s", opts.Concurrency.QueryId, info.name)
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     5,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Records before middle time",
-	})
-	fmt.Println("[INFO] Done with query 5")
-
-	// Query 6: Records after middle time
-	fmt.Println("[INFO] Running query 6: Records after middle time")
-	start = time.Now()
-	var afterMiddle int
-	err = pool.QueryRow(context.Background(), "SELECT COUNT(*) FROM user_events WHERE timestamp > $1", middleTime).Scan(&afterMiddle)
-	if err != nil {
-		return err
+	if opts.Joins && len(info.joins.queries) == 0 {
+		return fmt.Errorf("join queries are not supported for database type: %s", info.name)
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     6,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Records after middle time",
-	})
-	fmt.Println("[INFO] Done with query 6")
-
-	// Query 7: Records around middle time (±1 hour)
-	fmt.Println("[INFO] Running query 7: Records around middle time (±1 hour)")
-	start = time.Now()
-	var aroundMiddle int
-	hourBefore := middleTime.Add(-time.Hour)
-	hourAfter := middleTime.Add(time.Hour)
-	err = pool.QueryRow(context.Background(), "SELECT COUNT(*) FROM user_events WHERE timestamp BETWEEN $1 AND $2", hourBefore, hourAfter).Scan(&aroundMiddle)
-	if err != nil {
-		return err
+	if len(extraColumns) > 0 && info.extraColumns.groupBy == nil {
+		return fmt.Errorf("-extra-columns is not supported for database type: %s", info.name)
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     7,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Records around middle time (±1 hour)",
-	})
-	fmt.Println("[INFO] Done with query 7")
-
-	// Query 8: 24 hours aggregation from middle time
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     8,
-		DurationMs:  -1,
-		Description: "24 hours aggregation from middle time",
-	})
-
-	// Query 9: Top 10 users by activity
-	fmt.Println("[INFO] Running query 9: Top 10 users by activity")
-	start = time.Now()
-	_, err = pool.Query(context.Background(), "SELECT user_id, COUNT(*) as count FROM user_events GROUP BY user_id ORDER BY count DESC LIMIT 10")
-	if err != nil {
-		return err
+	if opts.RetentionFraction > 0 && !info.retention {
+		return fmt.Errorf("expiring data is not supported for database type: %s", info.name)
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     9,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Top 10 users by activity",
-	})
-	fmt.Println("[INFO] Done with query 9")
-
-	// Query 10: Records with strong signal
-	fmt.Println("[INFO] Running query 10: Records with strong signal")
-	start = time.Now()
-	var strongSignal int
-	err = pool.QueryRow(context.Background(), "SELECT COUNT(*) FROM user_events WHERE rssi > -50").Scan(&strongSignal)
-	if err != nil {
-		return err
+	if opts.Export && !info.export {
+		return fmt.Errorf("exporting data is not supported for database type: %s", info.name)
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     10,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Records with strong signal",
-	})
-	fmt.Println("[INFO] Done with query 10")
-
-	// Query 11: Records with weak signal
-	fmt.Println("[INFO] Running query 11: Records with weak signal")
-	start = time.Now()
-	var weakSignal int
-	err = pool.QueryRow(context.Background(), "SELECT COUNT(*) FROM user_events WHERE rssi < -80").Scan(&weakSignal)
-	if err != nil {
-		return err
+	if opts.VerifyHours && info.hourOfDayQuery == "" {
+		return fmt.Errorf("verifying hour buckets is not supported for database type: %s", info.name)
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     11,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Records with weak signal",
-	})
-	fmt.Println("[INFO] Done with query 11")
-
-	// Query 12: Top SSIDs
-	fmt.Println("[INFO] Running query 12: Top SSIDs")
-	start = time.Now()
-	_, err = pool.Query(context.Background(), "SELECT ssid, COUNT(*) as count FROM user_events GROUP BY ssid ORDER BY count DESC LIMIT 10")
-	if err != nil {
-		return err
+	if opts.FidelitySamples > 0 && !info.fidelity {
+		return fmt.Errorf("the fidelity audit is not supported for database type: %s", info.name)
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     12,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Top SSIDs",
-	})
-	fmt.Println("[INFO] Done with query 12")
-
-	// Query 13: RSSI statistics by user
-	fmt.Println("[INFO] Running query 13: RSSI statistics by user")
-	start = time.Now()
-	_, err = pool.Query(context.Background(), "SELECT user_id, AVG(rssi), MIN(rssi), MAX(rssi) FROM user_events GROUP BY user_id ORDER BY AVG(rssi) DESC LIMIT 100")
-	if err != nil {
-		return err
+	if opts.Explain && info.explainPrefix == "" {
+		return fmt.Errorf("query plans are not supported for database type: %s", info.name)
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     13,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "RSSI statistics by user",
-	})
-	fmt.Println("[INFO] Done with query 13")
-
-	// Query 14: RSSI percentiles
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     14,
-		DurationMs:  -1,
-		Description: "RSSI percentiles",
-	})
-
-	// Query 15: Records in first half
-	fmt.Println("[INFO] Running query 15: Records in first half")
-	start = time.Now()
-	var firstHalf int
-	err = pool.QueryRow(context.Background(), "SELECT COUNT(*) FROM user_events WHERE timestamp BETWEEN $1 AND $2", minTime, middleTime).Scan(&firstHalf)
-	if err != nil {
-		return err
+	if opts.ServerTiming && !info.serverTiming {
+		return fmt.Errorf("server-side timing is not supported for database type: %s", info.name)
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     15,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Records in first half",
-	})
-	fmt.Println("[INFO] Done with query 15")
-
-	// Query 16: Records in second half
-	fmt.Println("[INFO] Running query 16: Records in second half")
-	start = time.Now()
-	var secondHalf int
-	err = pool.QueryRow(context.Background(), "SELECT COUNT(*) FROM user_events WHERE timestamp BETWEEN $1 AND $2", middleTime, maxTime).Scan(&secondHalf)
-	if err != nil {
-		return err
+	if opts.Maintenance && len(info.maintenance) == 0 {
+		return fmt.Errorf("maintenance operations are not supported for database type: %s", info.name)
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     16,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Records in second half",
-	})
-	fmt.Println("[INFO] Done with query 16")
-
-	// Query 17: Hourly user activity patterns
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     17,
-		DurationMs:  -1,
-		Description: "Hourly user activity patterns",
-	})
-
-	// Query 18: Daily RSSI variance
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     18,
-		DurationMs:  -1,
-		Description: "Daily RSSI variance",
-	})
-
-	// Query 19: Peak usage hours
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     19,
-		DurationMs:  -1,
-		Description: "Peak usage hours",
-	})
-
-	// Query 20: User session duration analysis
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     20,
-		DurationMs:  -1,
-		Description: "User session duration analysis",
-	})
-
-	results.DbType = "postgres"
-	out, err := os.Create(outFile)
-	if err != nil {
-		return err
+	if opts.Durability != "" && !info.supportsDurability(opts.Durability) {
+		return fmt.Errorf("%s durability is not supported for database type: %s", opts.Durability, info.name)
 	}
-
-	defer out.Close()
-	if err := json.NewEncoder(out).Encode(results); err != nil {
-		return err
+	if opts.NoCreate {
+		if info.schemaProbe == "" {
+			return fmt.Errorf("-no-create is not supported for database type: %s", info.name)
+		}
+		if opts.SchemaVariant != "" || opts.ChunkInterval != "" || opts.Shards > 0 {
+			return fmt.Errorf("-schema-variant, -chunk-interval and -shards shape the DDL and cannot be combined with -no-create")
+		}
+	}
+	if opts.Topology != nil {
+		if err := opts.Topology.check(info); err != nil {
+			return err
+		}
+		if opts.SchemaVariant != "" || opts.ChunkInterval != "" || opts.Shards > 0 {
+			return fmt.Errorf("-schema-variant, -chunk-interval and -shards cannot be combined with -topology")
+		}
+		// The backend of a topology does not pass these phases on to its
+		// nodes.
+		if opts.RetentionFraction > 0 || opts.Export || opts.VerifyHours || opts.FidelitySamples > 0 {
+			return fmt.Errorf("-retention-fraction, -export, -verify-hours and -fidelity-samples cannot be combined with -topology")
+		}
 	}
 	return nil
 }
 
-func benchmarkTimescaleDb(connStr string, outFile string) error {
-	pool, err := pgxpool.New(context.Background(), connStr)
+// runBenchmark ingests every data chunk and then runs the query catalog of the
+// backend. Queries the backend does not implement are recorded as -1.
+func runBenchmark(info backendInfo, connStr string, outFile string, opts benchmarkOptions) error {
+	b, err := openTarget(info, connStr, opts)
 	if err != nil {
 		return err
 	}
+	// b is replaced when the database is restarted, and nil when reopening it
+	// failed.
+	defer func() {
+		if b != nil {
+			b.close()
+		}
+	}()
+	ctx := context.Background()
+	stopSampling := startResourceSampling(opts.Container)
+	defer stopSampling()
+	gcStart := readGCSnapshot()
 
-	// Create the table if it doesn't exist
-	_, err = pool.Exec(context.Background(), `
-		CREATE TABLE user_events (
-			id BIGSERIAL,
-			user_id VARCHAR(255) NOT NULL,
-			timestamp TIMESTAMP WITH TIME ZONE NOT NULL,
-			rssi REAL NOT NULL,
-			ssid VARCHAR(255) NOT NULL
-		) WITH (
-			tsdb.hypertable,
-			tsdb.partition_column='timestamp'
-		);SELECT create_hypertable('user_events', by_range('time', INTERVAL '4 hours'), if_not_exists => TRUE);`)
-	if err != nil {
+	var dims dimensions
+	if opts.Joins {
+		if dims, err = loadDimensions(opts); err != nil {
+			return err
+		}
+	}
+
+	if err := configureBackend(info, b, opts); err != nil {
 		return err
 	}
 
 	currentChunk := 0
-	results := BenchmarkResults{}
-	nRecords := 0
-
-	// Ingestion benchmark
-	for {
-		hasNext, data, err := loadDataChunk(currentChunk)
+	results := BenchmarkResults{
+		DbType:         info.name,
+		SchemaVariant:  opts.SchemaVariant,
+		ChunkInterval:  opts.ChunkInterval,
+		Shards:         opts.Shards,
+		IngestMethod:   opts.IngestMethod,
+		Pass:           opts.Pass,
+		Seed:           opts.Seed,
+		RandomParams:   opts.RandomParams,
+		PrefetchChunks: opts.PrefetchChunks,
+		CatalogVersion: queryCatalogVersion,
+		Capabilities:   info.capabilities,
+	}
+	if pseudonyms != nil {
+		results.Pseudonymization = pseudonyms.mode
+	}
+	if readingMapping != nil {
+		results.SchemaMapping = readingMapping.file
+	}
+	if opts.Scale != 1 {
+		results.Scale = opts.Scale
+	}
+	if opts.CardinalityFactor > 1 {
+		results.CardinalityFactor = opts.CardinalityFactor
+	}
+	results.ChunkStart, results.ChunkEnd = opts.ChunkStart, opts.ChunkEnd
+
+	// The journal is only handed to the measured ingestion and the main
+	// catalog, not to the catalogs the later phases run again.
+	progress := openProgressLog(outFile)
+	completed := false
+	defer func() { progress.finish(completed) }()
+	progress.record("run", results)
+	journaled := opts
+	journaled.Progress = progress
+	// phaseDone journals a phase and stops the run between two phases once a
+	// signal asked for it.
+	phaseDone := func(field string, value any) error {
+		progress.record(field, value)
+		return interrupted()
+	}
+	// profiled runs a phase under a CPU and heap profile of the client when
+	// -profile-dir is set, and meters the energy of the database host when
+	// -energy is.
+	if opts.Energy != nil {
+		results.Energy = &EnergyResult{Source: opts.Energy.name()}
+	}
+	profiled := func(phase string, run func() error) error {
+		if opts.Energy != nil {
+			measured := run
+			run = func() error {
+				stop := meterPhase(opts.Energy, phase)
+				defer func() { results.Energy.Phases = append(results.Energy.Phases, stop()) }()
+				return measured()
+			}
+		}
+		if opts.ProfileDir == "" {
+			return run()
+		}
+		stop, err := profilePhase(opts.ProfileDir, strings.TrimSuffix(filepath.Base(outFile), filepath.Ext(outFile)), phase)
 		if err != nil {
 			return err
 		}
-
-		start := time.Now()
-
-		rows := make([][]interface{}, len(data.Response))
-		for i, reading := range data.Response {
-			rows[i] = []interface{}{
-				reading.UserId,
-				time.Unix(int64(reading.LastUpdatedTime), 0),
-				reading.Connection.Rssi,
-				reading.Connection.Ssid,
-			}
+		runErr := run()
+		profile, err := stop()
+		if err != nil {
+			return errors.Join(runErr, err)
 		}
+		results.ClientProfiles = append(results.ClientProfiles, *profile)
+		return runErr
+	}
 
-		_, err = pool.CopyFrom(
-			context.Background(),
-			pgx.Identifier{"user_events"},
-			[]string{"user_id", "timestamp", "rssi", "ssid"},
-			pgx.CopyFromRows(rows),
-		)
-
-		if err != nil {
+	if opts.Calibrate {
+		if results.Calibration, err = calibrate(); err != nil {
 			return err
 		}
+	}
 
-		nRecords += len(data.Response)
-		duration := time.Since(start).Milliseconds()
-		results.Ingestion = append(results.Ingestion, struct {
-			DurationMs int64 `json:"durationMs"`
-			NRecords   int   `json:"nRecords"`
-		}{
-			DurationMs: duration,
-			NRecords:   nRecords,
-		})
-
-		currentChunk++
-		if !hasNext {
-			break
+	// Create the table if it doesn't exist. The DDL is timed as a phase of its
+	// own, since hypertable setup and shard allocation differ between engines.
+	if opts.NoCreate {
+		if err := checkProvisionedSchema(ctx, info, b); err != nil {
+			return err
+		}
+	} else {
+		start := time.Now()
+		if err := b.createSchema(ctx); err != nil {
+			return err
+		}
+		results.Schema = &PhaseResult{Name: "create-schema", DurationMs: time.Since(start).Milliseconds()}
+		infof("Created the schema in %d ms\n", results.Schema.DurationMs)
+		if err := phaseDone("schema", results.Schema); err != nil {
+			return err
 		}
 	}
-
-	// Query benchmarks
-	var minTime, maxTime, middleTime time.Time
-
-	// Query 1: Get time bounds
-	fmt.Println("[INFO] Running query 1: Get time bounds")
-	start := time.Now()
-	err = pool.QueryRow(context.Background(), "SELECT MIN(timestamp), MAX(timestamp) FROM user_events").Scan(&minTime, &maxTime)
-	if err != nil {
-		return err
+	if len(extraColumns) > 0 {
+		results.ExtraColumns = &ExtraColumnsResult{Columns: extraColumnNames()}
+		// A pre-provisioned schema has the columns already.
+		if !opts.NoCreate {
+			added, err := addExtraColumns(ctx, info, b)
+			if err != nil {
+				return err
+			}
+			results.ExtraColumns.AddColumnsMs = added.Milliseconds()
+		}
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     1,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Get time bounds",
-	})
-	fmt.Println("[INFO] Done with query 1")
-
-	middleTime = minTime.Add(maxTime.Sub(minTime) / 2)
-
-	// Query 2: Count all records
-	fmt.Println("[INFO] Running query 2: Count all records")
-	start = time.Now()
-	var totalCount int
-	err = pool.QueryRow(context.Background(), "SELECT COUNT(*) FROM user_events").Scan(&totalCount)
-	if err != nil {
+	if results.Durability, err = applyDurability(ctx, info, b, opts.Durability); err != nil {
 		return err
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     2,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Count all records",
-	})
-	fmt.Println("[INFO] Done with query 2")
+	results.ServerSettings = readServerSettings(ctx, info, b)
 
-	// Query 3: Count distinct users
-	fmt.Println("[INFO] Running query 3: Count distinct users")
-	start = time.Now()
-	var distinctUsers int
-	err = pool.QueryRow(context.Background(), "SELECT COUNT(DISTINCT user_id) FROM user_events").Scan(&distinctUsers)
-	if err != nil {
-		return err
+	if opts.Warmup.enabled() {
+		results.Warmup, currentChunk, err = runWarmup(ctx, info, b, opts.Warmup, opts.Retry)
+		if err != nil {
+			return err
+		}
+		if err := phaseDone("warmup", results.Warmup); err != nil {
+			return err
+		}
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     3,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Count distinct users",
-	})
-	fmt.Println("[INFO] Done with query 3")
 
-	// Query 4: Average RSSI
-	fmt.Println("[INFO] Running query 4: Average RSSI")
-	start = time.Now()
-	var avgRssi float64
-	err = pool.QueryRow(context.Background(), "SELECT AVG(rssi) FROM user_events").Scan(&avgRssi)
-	if err != nil {
-		return err
+	if len(opts.SizeSweep) > 0 {
+		journaled.Checkpoints = newSizeSweep(opts.SizeSweep)
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     4,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Average RSSI",
-	})
-	fmt.Println("[INFO] Done with query 4")
-
-	// Query 5: Records before middle time
-	fmt.Println("[INFO] Running query 5: Records before middle time")
-	start = time.Now()
-	var beforeMiddle int
-	err = pool.QueryRow(context.Background(), "SELECT COUNT(*) FROM user_events WHERE timestamp < $1", middleTime).Scan(&beforeMiddle)
-	if err != nil {
+	ingestionGC := readGCSnapshot()
+	err = profiled("ingestion", func() (err error) {
+		results.Ingestion, err = ingestChunks(ctx, info, b, currentChunk, journaled)
 		return err
-	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     5,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Records before middle time",
 	})
-	fmt.Println("[INFO] Done with query 5")
-
-	// Query 6: Records after middle time
-	fmt.Println("[INFO] Running query 6: Records after middle time")
-	start = time.Now()
-	var afterMiddle int
-	err = pool.QueryRow(context.Background(), "SELECT COUNT(*) FROM user_events WHERE timestamp > $1", middleTime).Scan(&afterMiddle)
 	if err != nil {
 		return err
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     6,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Records after middle time",
-	})
-	fmt.Println("[INFO] Done with query 6")
-
-	// Query 7: Records around middle time (±1 hour)
-	fmt.Println("[INFO] Running query 7: Records around middle time (±1 hour)")
-	start = time.Now()
-	var aroundMiddle int
-	hourBefore := middleTime.Add(-time.Hour)
-	hourAfter := middleTime.Add(time.Hour)
-	err = pool.QueryRow(context.Background(), "SELECT COUNT(*) FROM user_events WHERE timestamp BETWEEN $1 AND $2", hourBefore, hourAfter).Scan(&aroundMiddle)
-	if err != nil {
-		return err
+	results.ClientGC = &ClientGCResult{Ingestion: readGCSnapshot().since(ingestionGC)}
+	if results.Energy != nil && len(results.Ingestion) > 0 {
+		if ingestion := &results.Energy.Phases[0]; ingestion.Joules > 0 {
+			ingestion.RowsPerJoule = float64(results.Ingestion[len(results.Ingestion)-1].NRecords) / ingestion.Joules
+		}
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     7,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Records around middle time (±1 hour)",
-	})
-	fmt.Println("[INFO] Done with query 7")
-
-	// Query 8: 24 hours aggregation from middle time
-	fmt.Println("[INFO] Running query 8: 24 hours aggregation from middle time")
-	start = time.Now()
-	dayAfter := middleTime.Add(24 * time.Hour)
-	_, err = pool.Query(context.Background(), "SELECT date_trunc('hour', timestamp) as hour, COUNT(*) FROM user_events WHERE timestamp BETWEEN $1 AND $2 GROUP BY hour ORDER BY hour", middleTime, dayAfter)
-	if err != nil {
-		return err
+	if results.AsyncWrites = collectAsyncWrites(info, b); results.AsyncWrites != nil {
+		if err := phaseDone("asyncWrites", results.AsyncWrites); err != nil {
+			return err
+		}
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     8,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "24 hours aggregation from middle time",
-	})
-	fmt.Println("[INFO] Done with query 8")
 
-	// Query 9: Top 10 users by activity
-	fmt.Println("[INFO] Running query 9: Top 10 users by activity")
-	start = time.Now()
-	_, err = pool.Query(context.Background(), "SELECT user_id, COUNT(*) as count FROM user_events GROUP BY user_id ORDER BY count DESC LIMIT 10")
-	if err != nil {
-		return err
+	if opts.ColdRestart {
+		results.ColdRestart, b, err = runColdRestart(ctx, info, b, connStr, opts)
+		if err != nil {
+			return err
+		}
+		if err := phaseDone("coldRestart", results.ColdRestart); err != nil {
+			return err
+		}
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     9,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Top 10 users by activity",
-	})
-	fmt.Println("[INFO] Done with query 9")
 
-	// Query 10: Records with strong signal
-	fmt.Println("[INFO] Running query 10: Records with strong signal")
-	start = time.Now()
-	var strongSignal int
-	err = pool.QueryRow(context.Background(), "SELECT COUNT(*) FROM user_events WHERE rssi > -50").Scan(&strongSignal)
-	if err != nil {
+	var bounds queryBounds
+	err = profiled("queries", func() (err error) {
+		results.Queries, bounds, err = runQueryCatalog(ctx, info, b, journaled)
 		return err
-	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     10,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Records with strong signal",
 	})
-	fmt.Println("[INFO] Done with query 10")
-
-	// Query 11: Records with weak signal
-	fmt.Println("[INFO] Running query 11: Records with weak signal")
-	start = time.Now()
-	var weakSignal int
-	err = pool.QueryRow(context.Background(), "SELECT COUNT(*) FROM user_events WHERE rssi < -80").Scan(&weakSignal)
 	if err != nil {
 		return err
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     11,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Records with weak signal",
-	})
-	fmt.Println("[INFO] Done with query 11")
-
-	// Query 12: Top SSIDs
-	fmt.Println("[INFO] Running query 12: Top SSIDs")
-	start = time.Now()
-	_, err = pool.Query(context.Background(), "SELECT ssid, COUNT(*) as count FROM user_events GROUP BY ssid ORDER BY count DESC LIMIT 10")
-	if err != nil {
-		return err
+	if journaled.Checkpoints != nil {
+		results.SizeSweep = journaled.Checkpoints.finish(currentChunk+len(results.Ingestion), results.Ingestion[len(results.Ingestion)-1].NRecords, results.Queries)
+		if err := phaseDone("sizeSweep", results.SizeSweep); err != nil {
+			return err
+		}
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     12,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Top SSIDs",
-	})
-	fmt.Println("[INFO] Done with query 12")
 
-	// Query 13: RSSI statistics by user
-	fmt.Println("[INFO] Running query 13: RSSI statistics by user")
-	start = time.Now()
-	_, err = pool.Query(context.Background(), "SELECT user_id, AVG(rssi), MIN(rssi), MAX(rssi) FROM user_events GROUP BY user_id ORDER BY AVG(rssi) DESC LIMIT 100")
-	if err != nil {
-		return err
+	if opts.Maintenance {
+		results.Maintenance, err = runMaintenance(ctx, info, b, results.Queries, opts)
+		if err != nil {
+			return err
+		}
+		if err := phaseDone("maintenance", results.Maintenance); err != nil {
+			return err
+		}
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     13,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "RSSI statistics by user",
-	})
-	fmt.Println("[INFO] Done with query 13")
 
-	// Query 14: RSSI percentiles
-	fmt.Println("[INFO] Running query 14: RSSI percentiles")
-	start = time.Now()
-	var q1, median, q3 float64
-	err = pool.QueryRow(context.Background(), "SELECT percentile_cont(0.25) WITHIN GROUP (ORDER BY rssi) as q1, percentile_cont(0.5) WITHIN GROUP (ORDER BY rssi) as median, percentile_cont(0.75) WITHIN GROUP (ORDER BY rssi) as q3 FROM user_events").Scan(&q1, &median, &q3)
-	if err != nil {
-		return err
+	if info.reconciliation.countQuery != "" {
+		results.Reconciliation, err = runReconciliation(ctx, info, b, writtenReadings(results))
+		if err != nil {
+			return err
+		}
+		if err := phaseDone("reconciliation", results.Reconciliation); err != nil {
+			return err
+		}
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     14,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "RSSI percentiles",
-	})
-	fmt.Println("[INFO] Done with query 14")
 
-	// Query 15: Records in first half
-	fmt.Println("[INFO] Running query 15: Records in first half")
-	start = time.Now()
-	var firstHalf int
-	err = pool.QueryRow(context.Background(), "SELECT COUNT(*) FROM user_events WHERE timestamp BETWEEN $1 AND $2", minTime, middleTime).Scan(&firstHalf)
-	if err != nil {
-		return err
+	if opts.VerifyHours {
+		results.HourCheck, err = runHourCheck(ctx, info, b, opts)
+		if err != nil {
+			return err
+		}
+		if err := phaseDone("hourCheck", results.HourCheck); err != nil {
+			return err
+		}
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     15,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Records in first half",
-	})
-	fmt.Println("[INFO] Done with query 15")
 
-	// Query 16: Records in second half
-	fmt.Println("[INFO] Running query 16: Records in second half")
-	start = time.Now()
-	var secondHalf int
-	err = pool.QueryRow(context.Background(), "SELECT COUNT(*) FROM user_events WHERE timestamp BETWEEN $1 AND $2", middleTime, maxTime).Scan(&secondHalf)
-	if err != nil {
-		return err
+	if opts.FidelitySamples > 0 {
+		results.Fidelity, err = runFidelityAudit(ctx, b, currentChunk, opts)
+		if err != nil {
+			return err
+		}
+		if err := phaseDone("fidelity", results.Fidelity); err != nil {
+			return err
+		}
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     16,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Records in second half",
-	})
-	fmt.Println("[INFO] Done with query 16")
 
-	// Query 17: Hourly user activity patterns
-	fmt.Println("[INFO] Running query 17: Hourly user activity patterns")
-	start = time.Now()
-	_, err = pool.Query(context.Background(), "SELECT EXTRACT(hour FROM timestamp) as hour, COUNT(*) as count FROM user_events GROUP BY hour ORDER BY hour")
-	if err != nil {
-		return err
-	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     17,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Hourly user activity patterns",
-	})
-	fmt.Println("[INFO] Done with query 17")
-
-	// Query 18: Daily RSSI variance
-	fmt.Println("[INFO] Running query 18: Daily RSSI variance")
-	start = time.Now()
-	_, err = pool.Query(context.Background(), "SELECT DATE(timestamp) as day, VARIANCE(rssi) as rssi_variance FROM user_events GROUP BY day ORDER BY day LIMIT 30")
-	if err != nil {
-		return err
-	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     18,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Daily RSSI variance",
-	})
-	fmt.Println("[INFO] Done with query 18")
-
-	// Query 19: Peak usage hours
-	fmt.Println("[INFO] Running query 19: Peak usage hours")
-	start = time.Now()
-	_, err = pool.Query(context.Background(), "SELECT date_trunc('hour', timestamp) as hour, COUNT(*) as count FROM user_events GROUP BY hour ORDER BY count DESC LIMIT 5")
-	if err != nil {
-		return err
-	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     19,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Peak usage hours",
-	})
-	fmt.Println("[INFO] Done with query 19")
-
-	// Query 20: User session duration analysis
-	fmt.Println("[INFO] Running query 20: User session duration analysis")
-	start = time.Now()
-	_, err = pool.Query(context.Background(), "SELECT user_id, MAX(timestamp) - MIN(timestamp) as session_duration FROM user_events GROUP BY user_id ORDER BY session_duration DESC LIMIT 10")
-	if err != nil {
-		return err
-	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     20,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "User session duration analysis",
-	})
-	fmt.Println("[INFO] Done with query 20")
-
-	results.DbType = "timescaledb"
-	out, err := os.Create(outFile)
-	if err != nil {
-		return err
-	}
-
-	defer out.Close()
-	if err := json.NewEncoder(out).Encode(results); err != nil {
-		return err
-	}
-	return nil
-}
-
-func benchmarkQuestDb(connStr string, outFile string) error {
-	connParts := strings.Split(connStr, ":::")
-	if len(connParts) != 2 {
-		return fmt.Errorf("invalid connection string format, expected 'ingestUrl:::queryUrl'")
-	}
-
-	ingestUrl := connParts[0]
-	queryUrl := connParts[1]
-
-	ingestPool, err := qdb.LineSenderFromConf(context.Background(), ingestUrl)
-	if err != nil {
-		return err
-	}
-
-	queryPool, err := pgxpool.New(context.Background(), queryUrl)
-	if err != nil {
-		return err
-	}
-
-	currentChunk := 0
-	results := BenchmarkResults{}
-	nRecords := 0
-	ctx := context.Background()
-
-	for {
-		hasNext, data, err := loadDataChunk(currentChunk)
+	if opts.BucketSweep {
+		results.BucketSweep, err = runBucketSweep(ctx, info, b)
 		if err != nil {
 			return err
 		}
-
-		start := time.Now()
-
-		for _, reading := range data.Response {
-			err := ingestPool.Table("user_events").
-				Symbol("ssid", reading.Connection.Ssid).
-				Symbol("user_id", reading.UserId).
-				Float64Column("rssi", reading.Connection.Rssi).
-				At(ctx, time.Unix(int64(reading.LastUpdatedTime), 0))
-			if err != nil {
-				return err
-			}
+		if err := phaseDone("bucketSweep", results.BucketSweep); err != nil {
+			return err
 		}
+	}
 
-		if err := ingestPool.Flush(ctx); err != nil {
+	if opts.Concurrency.enabled() {
+		results.Concurrency, err = runConcurrencySweep(ctx, info, b, opts.Concurrency, bounds)
+		if err != nil {
 			return err
 		}
-
-		nRecords += len(data.Response)
-		duration := time.Since(start).Milliseconds()
-		results.Ingestion = append(results.Ingestion, struct {
-			DurationMs int64 `json:"durationMs"`
-			NRecords   int   `json:"nRecords"`
-		}{
-			DurationMs: duration,
-			NRecords:   nRecords,
-		})
-
-		currentChunk++
-		if !hasNext {
-			break
+		if err := phaseDone("concurrencySweep", results.Concurrency); err != nil {
+			return err
 		}
 	}
 
-	// Query benchmarks
-	var minTime, maxTime, middleTime time.Time
-
-	// Query 1: Get time bounds
-	fmt.Println("[INFO] Running query 1: Get time bounds")
-	start := time.Now()
-	err = queryPool.QueryRow(context.Background(), "SELECT MIN(timestamp), MAX(timestamp) FROM user_events").Scan(&minTime, &maxTime)
-	if err != nil {
-		return err
-	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     1,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Get time bounds",
-	})
-	fmt.Println("[INFO] Done with query 1")
-
-	middleTime = minTime.Add(maxTime.Sub(minTime) / 2)
-
-	// Query 2: Count all records
-	fmt.Println("[INFO] Running query 2: Count all records")
-	start = time.Now()
-	var totalCount int
-	err = queryPool.QueryRow(context.Background(), "SELECT COUNT(*) FROM user_events").Scan(&totalCount)
-	if err != nil {
-		return err
-	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     2,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Count all records",
-	})
-	fmt.Println("[INFO] Done with query 2")
-
-	// Query 3: Count distinct users
-	fmt.Println("[INFO] Running query 3: Count distinct users")
-	start = time.Now()
-	var distinctUsers int
-	err = queryPool.QueryRow(context.Background(), "SELECT COUNT(DISTINCT user_id) FROM user_events").Scan(&distinctUsers)
-	if err != nil {
-		return err
-	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     3,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Count distinct users",
-	})
-	fmt.Println("[INFO] Done with query 3")
-
-	// Query 4: Average RSSI
-	fmt.Println("[INFO] Running query 4: Average RSSI")
-	start = time.Now()
-	var avgRssi float64
-	err = queryPool.QueryRow(context.Background(), "SELECT AVG(rssi) FROM user_events").Scan(&avgRssi)
-	if err != nil {
-		return err
-	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     4,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Average RSSI",
-	})
-	fmt.Println("[INFO] Done with query 4")
-
-	// Query 5: Records before middle time
-	fmt.Println("[INFO] Running query 5: Records before middle time")
-	start = time.Now()
-	var beforeMiddle int
-	err = queryPool.QueryRow(context.Background(), "SELECT COUNT(*) FROM user_events WHERE timestamp < $1", middleTime).Scan(&beforeMiddle)
-	if err != nil {
-		return err
-	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     5,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Records before middle time",
-	})
-	fmt.Println("[INFO] Done with query 5")
-
-	// Query 6: Records after middle time
-	fmt.Println("[INFO] Running query 6: Records after middle time")
-	start = time.Now()
-	var afterMiddle int
-	err = queryPool.QueryRow(context.Background(), "SELECT COUNT(*) FROM user_events WHERE timestamp > $1", middleTime).Scan(&afterMiddle)
-	if err != nil {
-		return err
-	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     6,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Records after middle time",
-	})
-	fmt.Println("[INFO] Done with query 6")
-
-	// Query 7: Records around middle time (±1 hour) - QuestDB syntax
-	fmt.Println("[INFO] Running query 7: Records around middle time (±1 hour)")
-	start = time.Now()
-	var aroundMiddle int
-	err = queryPool.QueryRow(context.Background(), "SELECT COUNT(*) FROM user_events WHERE timestamp BETWEEN dateadd('h', -1, $1) AND dateadd('h', 1, $1)", middleTime).Scan(&aroundMiddle)
-	if err != nil {
-		return err
-	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     7,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Records around middle time (±1 hour)",
-	})
-	fmt.Println("[INFO] Done with query 7")
-
-	// Query 8: 24 hours aggregation from middle time - QuestDB syntax
-	fmt.Println("[INFO] Running query 8: 24 hours aggregation from middle time")
-	start = time.Now()
-	_, err = queryPool.Query(context.Background(), "SELECT timestamp, COUNT(*) FROM user_events WHERE timestamp BETWEEN $1 AND dateadd('h', 24, $1) SAMPLE BY 1h LIMIT 24", middleTime)
-	if err != nil {
-		return err
-	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     8,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "24 hours aggregation from middle time",
-	})
-	fmt.Println("[INFO] Done with query 8")
-
-	// Query 9: Top 10 users by activity
-	fmt.Println("[INFO] Running query 9: Top 10 users by activity")
-	start = time.Now()
-	_, err = queryPool.Query(context.Background(), "SELECT user_id, COUNT(*) as count FROM user_events ORDER BY count DESC LIMIT 10")
-	if err != nil {
-		return err
-	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     9,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Top 10 users by activity",
-	})
-	fmt.Println("[INFO] Done with query 9")
-
-	// Query 10: Records with strong signal
-	fmt.Println("[INFO] Running query 10: Records with strong signal")
-	start = time.Now()
-	var strongSignal int
-	err = queryPool.QueryRow(context.Background(), "SELECT COUNT(*) FROM user_events WHERE rssi > -50").Scan(&strongSignal)
-	if err != nil {
-		return err
-	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     10,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Records with strong signal",
-	})
-	fmt.Println("[INFO] Done with query 10")
-
-	// Query 11: Records with weak signal
-	fmt.Println("[INFO] Running query 11: Records with weak signal")
-	start = time.Now()
-	var weakSignal int
-	err = queryPool.QueryRow(context.Background(), "SELECT COUNT(*) FROM user_events WHERE rssi < -80").Scan(&weakSignal)
-	if err != nil {
-		return err
-	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     11,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Records with weak signal",
-	})
-	fmt.Println("[INFO] Done with query 11")
-
-	// Query 12: Top SSIDs
-	fmt.Println("[INFO] Running query 12: Top SSIDs")
-	start = time.Now()
-	_, err = queryPool.Query(context.Background(), "SELECT ssid, COUNT(*) as count FROM user_events ORDER BY count DESC LIMIT 10")
-	if err != nil {
-		return err
-	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     12,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Top SSIDs",
-	})
-	fmt.Println("[INFO] Done with query 12")
-
-	// Query 13: RSSI statistics by user
-	fmt.Println("[INFO] Running query 13: RSSI statistics by user")
-	start = time.Now()
-	_, err = queryPool.Query(context.Background(), "SELECT user_id, avg(rssi), min(rssi), max(rssi) FROM user_events ORDER BY avg DESC LIMIT 100")
-	if err != nil {
-		return err
-	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     13,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "RSSI statistics by user",
-	})
-	fmt.Println("[INFO] Done with query 13")
-
-	// Query 14: RSSI percentiles - QuestDB syntax
-	fmt.Println("[INFO] Running query 14: RSSI percentiles")
-	start = time.Now()
-	var q1, median, q3 float64
-	err = queryPool.QueryRow(context.Background(), "SELECT -approx_percentile(-rssi, 1.0-0.25) as q1, -approx_percentile(-rssi, 1.0-0.5) as median, -approx_percentile(-rssi, 1.0-0.75) as q3 FROM user_events").Scan(&q1, &median, &q3)
-	if err != nil {
-		return err
-	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     14,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "RSSI percentiles",
-	})
-	fmt.Println("[INFO] Done with query 14")
-
-	// Query 15: Records in first half
-	fmt.Println("[INFO] Running query 15: Records in first half")
-	start = time.Now()
-	var firstHalf int
-	err = queryPool.QueryRow(context.Background(), "SELECT COUNT(*) FROM user_events WHERE timestamp BETWEEN $1 AND $2", minTime, middleTime).Scan(&firstHalf)
-	if err != nil {
-		return err
-	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     15,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Records in first half",
-	})
-	fmt.Println("[INFO] Done with query 15")
-
-	// Query 16: Records in second half
-	fmt.Println("[INFO] Running query 16: Records in second half")
-	start = time.Now()
-	var secondHalf int
-	err = queryPool.QueryRow(context.Background(), "SELECT COUNT(*) FROM user_events WHERE timestamp BETWEEN $1 AND $2", middleTime, maxTime).Scan(&secondHalf)
-	if err != nil {
-		return err
-	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     16,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Records in second half",
-	})
-	fmt.Println("[INFO] Done with query 16")
-
-	// Query 17: Hourly user activity patterns
-	fmt.Println("[INFO] Running query 17: Hourly user activity patterns")
-	start = time.Now()
-	_, err = queryPool.Query(context.Background(), "SELECT hour(timestamp) as hour, COUNT(*) as count FROM user_events ORDER BY hour")
-	if err != nil {
-		return err
-	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     17,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Hourly user activity patterns",
-	})
-	fmt.Println("[INFO] Done with query 17")
-
-	// Query 18: Daily RSSI variance
-	fmt.Println("[INFO] Running query 18: Daily RSSI variance")
-	start = time.Now()
-	_, err = queryPool.Query(context.Background(), "SELECT timestamp, variance(rssi) as rssi_variance FROM user_events SAMPLE BY 1d LIMIT 30")
-	if err != nil {
-		return err
-	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     18,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Daily RSSI variance",
-	})
-	fmt.Println("[INFO] Done with query 18")
-
-	// Query 19: Peak usage hours
-	fmt.Println("[INFO] Running query 19: Peak usage hours")
-	start = time.Now()
-	_, err = queryPool.Query(context.Background(), "SELECT timestamp, count FROM (SELECT timestamp, COUNT(*) as count FROM user_events SAMPLE BY 1h) ORDER BY count DESC LIMIT 5")
-	if err != nil {
-		return err
-	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     19,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Peak usage hours",
-	})
-	fmt.Println("[INFO] Done with query 19")
-
-	// Query 20: User session duration analysis
-	fmt.Println("[INFO] Running query 20: User session duration analysis")
-	start = time.Now()
-	_, err = queryPool.Query(context.Background(), "SELECT user_id, max(timestamp) - min(timestamp) as session_duration FROM user_events ORDER BY session_duration DESC LIMIT 10")
-	if err != nil {
-		return err
-	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     20,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "User session duration analysis",
-	})
-	fmt.Println("[INFO] Done with query 20")
-
-	results.DbType = "questdb"
-	out, err := os.Create(outFile)
-	if err != nil {
-		return err
-	}
-
-	defer out.Close()
-	if err := json.NewEncoder(out).Encode(results); err != nil {
-		return err
-	}
-	return nil
-}
-
-func benchmarkInfluxDB(connStr string, outFile string) error {
-	client := influxdb2.NewClientWithOptions("http://localhost:8086", "mytoken123", influxdb2.DefaultOptions())
-	defer client.Close()
-
-	org := "myorg"
-	bucket := "benchmark"
-	writeAPI := client.WriteAPI(org, bucket)
-	queryAPI := client.QueryAPI(org)
-
-	currentChunk := 0
-	results := BenchmarkResults{}
-	nRecords := 0
-
-	// Ingestion benchmark
-	for {
-		hasNext, data, err := loadDataChunk(currentChunk)
+	if opts.Workload != nil {
+		results.Workload, err = runWorkload(ctx, info, b, opts.Workload, opts, bounds)
 		if err != nil {
 			return err
 		}
-
-		start := time.Now()
-
-		// Convert data to InfluxDB points and write in batch
-		for _, reading := range data.Response {
-			p := influxdb2.NewPointWithMeasurement("user_events").
-				AddTag("user_id", reading.UserId).
-				AddTag("ssid", reading.Connection.Ssid).
-				AddField("rssi", reading.Connection.Rssi).
-				SetTime(time.Unix(int64(reading.LastUpdatedTime), 0))
-
-			writeAPI.WritePoint(p)
-		}
-
-		// Flush the batch
-		writeAPI.Flush()
-
-		nRecords += len(data.Response)
-		duration := time.Since(start).Milliseconds()
-		results.Ingestion = append(results.Ingestion, struct {
-			DurationMs int64 `json:"durationMs"`
-			NRecords   int   `json:"nRecords"`
-		}{
-			DurationMs: duration,
-			NRecords:   nRecords,
-		})
-
-		currentChunk++
-		if !hasNext {
-			break
+		if err := phaseDone("workload", results.Workload); err != nil {
+			return err
 		}
 	}
 
-	// Query benchmarks
-	var minTime, maxTime, middleTime time.Time
-
-	// Query 1: Get time bounds
-	fmt.Println("[INFO] Running query 1: Get time bounds")
-	start := time.Now()
-	query1 := `from(bucket: "benchmark")
-		|> range(start: -30y)
-		|> filter(fn: (r) => r._measurement == "user_events")
-		|> keep(columns: ["_time"])
-		|> limit(n: 1)
-		|> min(column: "_time")`
-	result, err := queryAPI.Query(context.Background(), query1)
-	if err != nil {
-		results.Queries = append(results.Queries, QueryResult{
-			QueryId:     1,
-			DurationMs:  -1,
-			Description: "Get time bounds",
-		})
-	} else {
-		for result.Next() {
-			minTime = result.Record().Time()
-		}
-		result.Close()
-
-		query1Max := `from(bucket: "benchmark")
-		|> range(start: -30y)
-		|> filter(fn: (r) => r._measurement == "user_events")
-		|> keep(columns: ["_time"])
-		|> limit(n: 1)
-		|> max(column: "_time")`
-		result, err = queryAPI.Query(context.Background(), query1Max)
+	if opts.Joins {
+		results.Joins, err = runJoinPhase(ctx, info, b, opts, dims, bounds)
 		if err != nil {
-			results.Queries = append(results.Queries, QueryResult{
-				QueryId:     1,
-				DurationMs:  -1,
-				Description: "Get time bounds",
-			})
-		} else {
-			for result.Next() {
-				maxTime = result.Record().Time()
-			}
-			result.Close()
-
-			results.Queries = append(results.Queries, QueryResult{
-				QueryId:     1,
-				DurationMs:  time.Since(start).Milliseconds(),
-				Description: "Get time bounds",
-			})
+			return err
 		}
-	}
-	fmt.Println("[INFO] Done with query 1")
-
-	middleTime = minTime.Add(maxTime.Sub(minTime) / 2)
-
-	// Query 2: Count all records
-	fmt.Println("[INFO] Running query 2: Count all records")
-	start = time.Now()
-	query2 := `from(bucket: "benchmark")
-		|> range(start: -30y)
-		|> filter(fn: (r) => r._measurement == "user_events")
-		|> keep(columns: ["_time"])
-		|> count()`
-	result, err = queryAPI.Query(context.Background(), query2)
-	if err != nil {
-		results.Queries = append(results.Queries, QueryResult{
-			QueryId:     2,
-			DurationMs:  -1,
-			Description: "Count all records",
-		})
-	} else {
-		for result.Next() {
-			// Just consume the result
-		}
-		result.Close()
-		results.Queries = append(results.Queries, QueryResult{
-			QueryId:     2,
-			DurationMs:  time.Since(start).Milliseconds(),
-			Description: "Count all records",
-		})
-	}
-	fmt.Println("[INFO] Done with query 2")
-
-	// Query 3: Count distinct users
-	fmt.Println("[INFO] Running query 3: Count distinct users")
-	start = time.Now()
-	query3 := `from(bucket: "benchmark")
-		|> range(start: -30y)
-		|> filter(fn: (r) => r._measurement == "user_events")
-		|> distinct(column: "user_id")
-		|> count()`
-	result, err = queryAPI.Query(context.Background(), query3)
-	if err != nil {
-		results.Queries = append(results.Queries, QueryResult{
-			QueryId:     3,
-			DurationMs:  -1,
-			Description: "Count distinct users",
-		})
-	} else {
-		for result.Next() {
-			// Just consume the result
-		}
-		result.Close()
-		results.Queries = append(results.Queries, QueryResult{
-			QueryId:     3,
-			DurationMs:  time.Since(start).Milliseconds(),
-			Description: "Count distinct users",
-		})
-	}
-	fmt.Println("[INFO] Done with query 3")
-
-	// Query 4: Average RSSI
-	fmt.Println("[INFO] Running query 4: Average RSSI")
-	start = time.Now()
-	query4 := `from(bucket: "benchmark")
-		|> range(start: -30y)
-		|> filter(fn: (r) => r._measurement == "user_events" and r._field == "rssi")
-		|> mean()`
-	result, err = queryAPI.Query(context.Background(), query4)
-	if err != nil {
-		results.Queries = append(results.Queries, QueryResult{
-			QueryId:     4,
-			DurationMs:  -1,
-			Description: "Average RSSI",
-		})
-	} else {
-		for result.Next() {
-			// Just consume the result
-		}
-		result.Close()
-		results.Queries = append(results.Queries, QueryResult{
-			QueryId:     4,
-			DurationMs:  time.Since(start).Milliseconds(),
-			Description: "Average RSSI",
-		})
-	}
-	fmt.Println("[INFO] Done with query 4")
-
-	// Query 5: Records before middle time
-	fmt.Println("[INFO] Running query 5: Records before middle time")
-	start = time.Now()
-	query5 := fmt.Sprintf(`from(bucket: "benchmark")
-		|> range(start: -30y, stop: %s)
-		|> filter(fn: (r) => r._measurement == "user_events")
-		|> count()`, middleTime.Format(time.RFC3339))
-	result, err = queryAPI.Query(context.Background(), query5)
-	if err != nil {
-		results.Queries = append(results.Queries, QueryResult{
-			QueryId:     5,
-			DurationMs:  -1,
-			Description: "Records before middle time",
-		})
-	} else {
-		for result.Next() {
-			// Just consume the result
-		}
-		result.Close()
-		results.Queries = append(results.Queries, QueryResult{
-			QueryId:     5,
-			DurationMs:  time.Since(start).Milliseconds(),
-			Description: "Records before middle time",
-		})
-	}
-	fmt.Println("[INFO] Done with query 5")
-
-	// Query 6: Records after middle time
-	fmt.Println("[INFO] Running query 6: Records after middle time")
-	start = time.Now()
-	query6 := fmt.Sprintf(`from(bucket: "benchmark")
-		|> range(start: %s)
-		|> filter(fn: (r) => r._measurement == "user_events")
-		|> count()`, middleTime.Format(time.RFC3339))
-	result, err = queryAPI.Query(context.Background(), query6)
-	if err != nil {
-		results.Queries = append(results.Queries, QueryResult{
-			QueryId:     6,
-			DurationMs:  -1,
-			Description: "Records after middle time",
-		})
-	} else {
-		for result.Next() {
-			// Just consume the result
-		}
-		result.Close()
-		results.Queries = append(results.Queries, QueryResult{
-			QueryId:     6,
-			DurationMs:  time.Since(start).Milliseconds(),
-			Description: "Records after middle time",
-		})
-	}
-	fmt.Println("[INFO] Done with query 6")
-
-	// Query 7: Records around middle time (±1 hour)
-	fmt.Println("[INFO] Running query 7: Records around middle time (±1 hour)")
-	start = time.Now()
-	hourBefore := middleTime.Add(-time.Hour)
-	hourAfter := middleTime.Add(time.Hour)
-	query7 := fmt.Sprintf(`from(bucket: "benchmark")
-		|> range(start: %s, stop: %s)
-		|> filter(fn: (r) => r._measurement == "user_events")
-		|> count()`, hourBefore.Format(time.RFC3339), hourAfter.Format(time.RFC3339))
-	result, err = queryAPI.Query(context.Background(), query7)
-	if err != nil {
-		results.Queries = append(results.Queries, QueryResult{
-			QueryId:     7,
-			DurationMs:  -1,
-			Description: "Records around middle time (±1 hour)",
-		})
-	} else {
-		for result.Next() {
-			// Just consume the result
-		}
-		result.Close()
-		results.Queries = append(results.Queries, QueryResult{
-			QueryId:     7,
-			DurationMs:  time.Since(start).Milliseconds(),
-			Description: "Records around middle time (±1 hour)",
-		})
-	}
-	fmt.Println("[INFO] Done with query 7")
-
-	// Query 8: 24 hours aggregation from middle time
-	fmt.Println("[INFO] Running query 8: 24 hours aggregation from middle time")
-	start = time.Now()
-	dayAfter := middleTime.Add(24 * time.Hour)
-	query8 := fmt.Sprintf(`from(bucket: "benchmark")
-		|> range(start: %s, stop: %s)
-		|> filter(fn: (r) => r._measurement == "user_events")
-		|> aggregateWindow(every: 1h, fn: count)`, middleTime.Format(time.RFC3339), dayAfter.Format(time.RFC3339))
-	result, err = queryAPI.Query(context.Background(), query8)
-	if err != nil {
-		results.Queries = append(results.Queries, QueryResult{
-			QueryId:     8,
-			DurationMs:  -1,
-			Description: "24 hours aggregation from middle time",
-		})
-	} else {
-		for result.Next() {
-			// Just consume the result
-		}
-		result.Close()
-		results.Queries = append(results.Queries, QueryResult{
-			QueryId:     8,
-			DurationMs:  time.Since(start).Milliseconds(),
-			Description: "24 hours aggregation from middle time",
-		})
-	}
-	fmt.Println("[INFO] Done with query 8")
-
-	// Query 9: Top 10 users by activity
-	fmt.Println("[INFO] Running query 9: Top 10 users by activity")
-	start = time.Now()
-	query9 := `from(bucket: "benchmark")
-		|> range(start: -30y)
-		|> filter(fn: (r) => r._measurement == "user_events")
-		|> group(columns: ["user_id"])
-		|> count()
-		|> top(n: 10)`
-	result, err = queryAPI.Query(context.Background(), query9)
-	if err != nil {
-		results.Queries = append(results.Queries, QueryResult{
-			QueryId:     9,
-			DurationMs:  -1,
-			Description: "Top 10 users by activity",
-		})
-	} else {
-		for result.Next() {
-			// Just consume the result
-		}
-		result.Close()
-		results.Queries = append(results.Queries, QueryResult{
-			QueryId:     9,
-			DurationMs:  time.Since(start).Milliseconds(),
-			Description: "Top 10 users by activity",
-		})
-	}
-	fmt.Println("[INFO] Done with query 9")
-
-	// Query 10: Records with strong signal
-	fmt.Println("[INFO] Running query 10: Records with strong signal")
-	start = time.Now()
-	query10 := `from(bucket: "benchmark")
-		|> range(start: -30y)
-		|> filter(fn: (r) => r._measurement == "user_events" and r._field == "rssi" and r._value > -50.0)
-		|> count()`
-	result, err = queryAPI.Query(context.Background(), query10)
-	if err != nil {
-		results.Queries = append(results.Queries, QueryResult{
-			QueryId:     10,
-			DurationMs:  -1,
-			Description: "Records with strong signal",
-		})
-	} else {
-		for result.Next() {
-			// Just consume the result
-		}
-		result.Close()
-		results.Queries = append(results.Queries, QueryResult{
-			QueryId:     10,
-			DurationMs:  time.Since(start).Milliseconds(),
-			Description: "Records with strong signal",
-		})
-	}
-	fmt.Println("[INFO] Done with query 10")
-
-	// Query 11: Records with weak signal
-	fmt.Println("[INFO] Running query 11: Records with weak signal")
-	start = time.Now()
-	query11 := `from(bucket: "benchmark")
-		|> range(start: -30y)
-		|> filter(fn: (r) => r._measurement == "user_events" and r._field == "rssi" and r._value < -80.0)
-		|> count()`
-	result, err = queryAPI.Query(context.Background(), query11)
-	if err != nil {
-		results.Queries = append(results.Queries, QueryResult{
-			QueryId:     11,
-			DurationMs:  -1,
-			Description: "Records with weak signal",
-		})
-	} else {
-		for result.Next() {
-			// Just consume the result
-		}
-		result.Close()
-		results.Queries = append(results.Queries, QueryResult{
-			QueryId:     11,
-			DurationMs:  time.Since(start).Milliseconds(),
-			Description: "Records with weak signal",
-		})
-	}
-	fmt.Println("[INFO] Done with query 11")
-
-	// Query 12: Top SSIDs
-	fmt.Println("[INFO] Running query 12: Top SSIDs")
-	start = time.Now()
-	query12 := `from(bucket: "benchmark")
-		|> range(start: -30y)
-		|> filter(fn: (r) => r._measurement == "user_events")
-		|> group(columns: ["ssid"])
-		|> count()
-		|> top(n: 10)`
-	result, err = queryAPI.Query(context.Background(), query12)
-	if err != nil {
-		results.Queries = append(results.Queries, QueryResult{
-			QueryId:     12,
-			DurationMs:  -1,
-			Description: "Top SSIDs",
-		})
-	} else {
-		for result.Next() {
-			// Just consume the result
-		}
-		result.Close()
-		results.Queries = append(results.Queries, QueryResult{
-			QueryId:     12,
-			DurationMs:  time.Since(start).Milliseconds(),
-			Description: "Top SSIDs",
-		})
-	}
-	fmt.Println("[INFO] Done with query 12")
-
-	// Query 13: RSSI statistics by user
-	fmt.Println("[INFO] Running query 13: RSSI statistics by user")
-	start = time.Now()
-	query13 := `from(bucket: "benchmark")
-		|> range(start: -30y)
-		|> filter(fn: (r) => r._measurement == "user_events" and r._field == "rssi")
-		|> group(columns: ["user_id"])
-		|> aggregateWindow(every: inf, fn: mean)
-		|> top(n: 100)`
-	result, err = queryAPI.Query(context.Background(), query13)
-	if err != nil {
-		results.Queries = append(results.Queries, QueryResult{
-			QueryId:     13,
-			DurationMs:  -1,
-			Description: "RSSI statistics by user",
-		})
-	} else {
-		for result.Next() {
-			// Just consume the result
-		}
-		result.Close()
-		results.Queries = append(results.Queries, QueryResult{
-			QueryId:     13,
-			DurationMs:  time.Since(start).Milliseconds(),
-			Description: "RSSI statistics by user",
-		})
-	}
-	fmt.Println("[INFO] Done with query 13")
-
-	// Query 14: RSSI percentiles
-	fmt.Println("[INFO] Running query 14: RSSI percentiles")
-	start = time.Now()
-	query14 := `from(bucket: "benchmark")
-		|> range(start: -30y)
-		|> filter(fn: (r) => r._measurement == "user_events" and r._field == "rssi")
-		|> quantile(q: 0.25, method: "estimate_tdigest")
-		|> yield(name: "q1")`
-	result, err = queryAPI.Query(context.Background(), query14)
-	if err != nil {
-		results.Queries = append(results.Queries, QueryResult{
-			QueryId:     14,
-			DurationMs:  -1,
-			Description: "RSSI percentiles",
-		})
-	} else {
-		for result.Next() {
-			// Just consume the result
-		}
-		result.Close()
-		results.Queries = append(results.Queries, QueryResult{
-			QueryId:     14,
-			DurationMs:  time.Since(start).Milliseconds(),
-			Description: "RSSI percentiles",
-		})
-	}
-	fmt.Println("[INFO] Done with query 14")
-
-	// Query 15: Records in first half
-	fmt.Println("[INFO] Running query 15: Records in first half")
-	start = time.Now()
-	query15 := fmt.Sprintf(`from(bucket: "benchmark")
-		|> range(start: %s, stop: %s)
-		|> filter(fn: (r) => r._measurement == "user_events")
-		|> count()`, minTime.Format(time.RFC3339), middleTime.Format(time.RFC3339))
-	result, err = queryAPI.Query(context.Background(), query15)
-	if err != nil {
-		results.Queries = append(results.Queries, QueryResult{
-			QueryId:     15,
-			DurationMs:  -1,
-			Description: "Records in first half",
-		})
-	} else {
-		for result.Next() {
-			// Just consume the result
-		}
-		result.Close()
-		results.Queries = append(results.Queries, QueryResult{
-			QueryId:     15,
-			DurationMs:  time.Since(start).Milliseconds(),
-			Description: "Records in first half",
-		})
-	}
-	fmt.Println("[INFO] Done with query 15")
-
-	// Query 16: Records in second half
-	fmt.Println("[INFO] Running query 16: Records in second half")
-	start = time.Now()
-	query16 := fmt.Sprintf(`from(bucket: "benchmark")
-		|> range(start: %s, stop: %s)
-		|> filter(fn: (r) => r._measurement == "user_events")
-		|> count()`, middleTime.Format(time.RFC3339), maxTime.Format(time.RFC3339))
-	result, err = queryAPI.Query(context.Background(), query16)
-	if err != nil {
-		results.Queries = append(results.Queries, QueryResult{
-			QueryId:     16,
-			DurationMs:  -1,
-			Description: "Records in second half",
-		})
-	} else {
-		for result.Next() {
-			// Just consume the result
-		}
-		result.Close()
-		results.Queries = append(results.Queries, QueryResult{
-			QueryId:     16,
-			DurationMs:  time.Since(start).Milliseconds(),
-			Description: "Records in second half",
-		})
-	}
-	fmt.Println("[INFO] Done with query 16")
-
-	// Query 17: Hourly user activity patterns
-	fmt.Println("[INFO] Running query 17: Hourly user activity patterns")
-	start = time.Now()
-	query17 := `from(bucket: "benchmark")
-		|> range(start: -30y)
-		|> filter(fn: (r) => r._measurement == "user_events")
-		|> group(columns: ["_time"])
-		|> aggregateWindow(every: 1h, fn: count)
-		|> group(columns: ["hour"])
-		|> sum()`
-	result, err = queryAPI.Query(context.Background(), query17)
-	if err != nil {
-		results.Queries = append(results.Queries, QueryResult{
-			QueryId:     17,
-			DurationMs:  -1,
-			Description: "Hourly user activity patterns",
-		})
-	} else {
-		for result.Next() {
-			// Just consume the result
-		}
-		result.Close()
-		results.Queries = append(results.Queries, QueryResult{
-			QueryId:     17,
-			DurationMs:  time.Since(start).Milliseconds(),
-			Description: "Hourly user activity patterns",
-		})
-	}
-	fmt.Println("[INFO] Done with query 17")
-
-	// Query 18: Daily RSSI variance
-	fmt.Println("[INFO] Running query 18: Daily RSSI variance")
-	start = time.Now()
-	query18 := `from(bucket: "benchmark")
-		|> range(start: -30y)
-		|> filter(fn: (r) => r._measurement == "user_events" and r._field == "rssi")
-		|> aggregateWindow(every: 1d, fn: stddev)
-		|> limit(n: 30)`
-	result, err = queryAPI.Query(context.Background(), query18)
-	if err != nil {
-		results.Queries = append(results.Queries, QueryResult{
-			QueryId:     18,
-			DurationMs:  -1,
-			Description: "Daily RSSI variance",
-		})
-	} else {
-		for result.Next() {
-			// Just consume the result
-		}
-		result.Close()
-		results.Queries = append(results.Queries, QueryResult{
-			QueryId:     18,
-			DurationMs:  time.Since(start).Milliseconds(),
-			Description: "Daily RSSI variance",
-		})
-	}
-	fmt.Println("[INFO] Done with query 18")
-
-	// Query 19: Peak usage hours
-	fmt.Println("[INFO] Running query 19: Peak usage hours")
-	start = time.Now()
-	query19 := `from(bucket: "benchmark")
-		|> range(start: -30y)
-		|> filter(fn: (r) => r._measurement == "user_events")
-		|> aggregateWindow(every: 1h, fn: count)
-		|> top(n: 5)`
-	result, err = queryAPI.Query(context.Background(), query19)
-	if err != nil {
-		results.Queries = append(results.Queries, QueryResult{
-			QueryId:     19,
-			DurationMs:  -1,
-			Description: "Peak usage hours",
-		})
-	} else {
-		for result.Next() {
-			// Just consume the result
-		}
-		result.Close()
-		results.Queries = append(results.Queries, QueryResult{
-			QueryId:     19,
-			DurationMs:  time.Since(start).Milliseconds(),
-			Description: "Peak usage hours",
-		})
-	}
-	fmt.Println("[INFO] Done with query 19")
-
-	// Query 20: User session duration analysis
-	fmt.Println("[INFO] Running query 20: User session duration analysis")
-	start = time.Now()
-	query20 := `from(bucket: "benchmark")
-		|> range(start: -30y)
-		|> filter(fn: (r) => r._measurement == "user_events")
-		|> group(columns: ["user_id"])
-		|> aggregateWindow(every: inf, fn: spread)
-		|> top(n: 10)`
-	result, err = queryAPI.Query(context.Background(), query20)
-	if err != nil {
-		results.Queries = append(results.Queries, QueryResult{
-			QueryId:     20,
-			DurationMs:  -1,
-			Description: "User session duration analysis",
-		})
-	} else {
-		for result.Next() {
-			// Just consume the result
+		if err := phaseDone("joins", results.Joins); err != nil {
+			return err
 		}
-		result.Close()
-		results.Queries = append(results.Queries, QueryResult{
-			QueryId:     20,
-			DurationMs:  time.Since(start).Milliseconds(),
-			Description: "User session duration analysis",
-		})
-	}
-	fmt.Println("[INFO] Done with query 20")
-
-	results.DbType = "influxdb"
-	out, err := os.Create(outFile)
-	if err != nil {
-		return err
 	}
 
-	defer out.Close()
-	if err := json.NewEncoder(out).Encode(results); err != nil {
-		return err
-	}
-	return nil
-}
-
-func benchmarkCrateDB(connStr string, outFile string) error {
-	pool, err := pgxpool.New(context.Background(), connStr)
-	if err != nil {
-		return err
-	}
-
-	// Create the table if it doesn't exist
-	_, err = pool.Exec(context.Background(), `
-		CREATE TABLE IF NOT EXISTS user_events (
-			user_id TEXT NOT NULL,
-			ts TIMESTAMP WITHOUT TIME ZONE NOT NULL,
-			rssi FLOAT NOT NULL,
-			ssid TEXT NOT NULL
-		) CLUSTERED BY (ts) INTO 4 SHARDS`)
-	if err != nil {
-		return err
-	}
-
-	currentChunk := 0
-	results := BenchmarkResults{}
-	nRecords := 0
-
-	// Ingestion benchmark
-	for {
-		hasNext, data, err := loadDataChunk(currentChunk)
-		if err != nil {
+	if results.ExtraColumns != nil {
+		if err := runExtraColumnQueries(ctx, info, b, opts, results.ExtraColumns); err != nil {
 			return err
 		}
-
-		start := time.Now()
-
-		// Use batch INSERT for CrateDB instead of CopyFrom
-		batch := &pgx.Batch{}
-		for _, reading := range data.Response {
-			batch.Queue(
-				"INSERT INTO user_events (user_id, ts, rssi, ssid) VALUES ($1, $2, $3, $4)",
-				reading.UserId,
-				time.Unix(int64(reading.LastUpdatedTime), 0),
-				reading.Connection.Rssi,
-				reading.Connection.Ssid,
-			)
+		if err := phaseDone("extraColumns", results.ExtraColumns); err != nil {
+			return err
 		}
+	}
 
-		batchResults := pool.SendBatch(context.Background(), batch)
-		err = batchResults.Close()
+	if opts.Export {
+		results.Export, err = runExportPhase(ctx, b)
 		if err != nil {
 			return err
 		}
-
-		nRecords += len(data.Response)
-		duration := time.Since(start).Milliseconds()
-		results.Ingestion = append(results.Ingestion, struct {
-			DurationMs int64 `json:"durationMs"`
-			NRecords   int   `json:"nRecords"`
-		}{
-			DurationMs: duration,
-			NRecords:   nRecords,
-		})
-
-		currentChunk++
-		if !hasNext {
-			break
+		if err := phaseDone("export", results.Export); err != nil {
+			return err
 		}
 	}
 
-	// Query benchmarks
-	var minTime, maxTime, middleTime time.Time
-
-	// Query 1: Get time bounds
-	fmt.Println("[INFO] Running query 1: Get time bounds")
-	start := time.Now()
-	err = pool.QueryRow(context.Background(), "SELECT MIN(ts), MAX(ts) FROM user_events").Scan(&minTime, &maxTime)
-	if err != nil {
-		return err
-	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     1,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Get time bounds",
-	})
-	fmt.Println("[INFO] Done with query 1")
-
-	middleTime = minTime.Add(maxTime.Sub(minTime) / 2)
-
-	// Query 2: Count all records
-	fmt.Println("[INFO] Running query 2: Count all records")
-	start = time.Now()
-	var totalCount int
-	err = pool.QueryRow(context.Background(), "SELECT COUNT(*) FROM user_events").Scan(&totalCount)
-	if err != nil {
-		return err
-	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     2,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Count all records",
-	})
-	fmt.Println("[INFO] Done with query 2")
-
-	// Query 3: Count distinct users
-	fmt.Println("[INFO] Running query 3: Count distinct users")
-	start = time.Now()
-	var distinctUsers int
-	err = pool.QueryRow(context.Background(), "SELECT COUNT(DISTINCT user_id) FROM user_events").Scan(&distinctUsers)
-	if err != nil {
-		return err
-	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     3,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Count distinct users",
-	})
-	fmt.Println("[INFO] Done with query 3")
-
-	// Query 4: Average RSSI
-	fmt.Println("[INFO] Running query 4: Average RSSI")
-	start = time.Now()
-	var avgRssi float64
-	err = pool.QueryRow(context.Background(), "SELECT AVG(rssi) FROM user_events").Scan(&avgRssi)
-	if err != nil {
-		return err
-	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     4,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Average RSSI",
-	})
-	fmt.Println("[INFO] Done with query 4")
-
-	// Query 5: Records before middle time
-	fmt.Println("[INFO] Running query 5: Records before middle time")
-	start = time.Now()
-	var beforeMiddle int
-	err = pool.QueryRow(context.Background(), "SELECT COUNT(*) FROM user_events WHERE ts < $1", middleTime).Scan(&beforeMiddle)
-	if err != nil {
-		return err
-	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     5,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Records before middle time",
-	})
-	fmt.Println("[INFO] Done with query 5")
-
-	// Query 6: Records after middle time
-	fmt.Println("[INFO] Running query 6: Records after middle time")
-	start = time.Now()
-	var afterMiddle int
-	err = pool.QueryRow(context.Background(), "SELECT COUNT(*) FROM user_events WHERE ts > $1", middleTime).Scan(&afterMiddle)
-	if err != nil {
-		return err
-	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     6,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Records after middle time",
-	})
-	fmt.Println("[INFO] Done with query 6")
-
-	// Query 7: Records around middle time (±1 hour)
-	fmt.Println("[INFO] Running query 7: Records around middle time (±1 hour)")
-	start = time.Now()
-	var aroundMiddle int
-	hourBefore := middleTime.Add(-time.Hour)
-	hourAfter := middleTime.Add(time.Hour)
-	err = pool.QueryRow(context.Background(), "SELECT COUNT(*) FROM user_events WHERE ts BETWEEN $1 AND $2", hourBefore, hourAfter).Scan(&aroundMiddle)
-	if err != nil {
-		return err
-	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     7,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Records around middle time (±1 hour)",
-	})
-	fmt.Println("[INFO] Done with query 7")
-
-	// Query 8: 24 hours aggregation from middle time
-	fmt.Println("[INFO] Running query 8: 24 hours aggregation from middle time")
-	start = time.Now()
-	dayAfter := middleTime.Add(24 * time.Hour)
-	_, err = pool.Query(context.Background(), "SELECT date_trunc('hour', ts) as hour, COUNT(*) FROM user_events WHERE ts BETWEEN $1 AND $2 GROUP BY hour ORDER BY hour", middleTime, dayAfter)
-	if err != nil {
-		return err
-	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     8,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "24 hours aggregation from middle time",
-	})
-	fmt.Println("[INFO] Done with query 8")
-
-	// Query 9: Top 10 users by activity
-	fmt.Println("[INFO] Running query 9: Top 10 users by activity")
-	start = time.Now()
-	_, err = pool.Query(context.Background(), "SELECT user_id, COUNT(*) as count FROM user_events GROUP BY user_id ORDER BY count DESC LIMIT 10")
-	if err != nil {
-		return err
-	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     9,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Top 10 users by activity",
-	})
-	fmt.Println("[INFO] Done with query 9")
-
-	// Query 10: Records with strong signal
-	fmt.Println("[INFO] Running query 10: Records with strong signal")
-	start = time.Now()
-	var strongSignal int
-	err = pool.QueryRow(context.Background(), "SELECT COUNT(*) FROM user_events WHERE rssi > -50").Scan(&strongSignal)
-	if err != nil {
-		return err
-	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     10,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Records with strong signal",
-	})
-	fmt.Println("[INFO] Done with query 10")
-
-	// Query 11: Records with weak signal
-	fmt.Println("[INFO] Running query 11: Records with weak signal")
-	start = time.Now()
-	var weakSignal int
-	err = pool.QueryRow(context.Background(), "SELECT COUNT(*) FROM user_events WHERE rssi < -80").Scan(&weakSignal)
-	if err != nil {
-		return err
-	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     11,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Records with weak signal",
-	})
-	fmt.Println("[INFO] Done with query 11")
-
-	// Query 12: Top SSIDs
-	fmt.Println("[INFO] Running query 12: Top SSIDs")
-	start = time.Now()
-	_, err = pool.Query(context.Background(), "SELECT ssid, COUNT(*) as count FROM user_events GROUP BY ssid ORDER BY count DESC LIMIT 10")
-	if err != nil {
-		return err
-	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     12,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Top SSIDs",
-	})
-	fmt.Println("[INFO] Done with query 12")
-
-	// Query 13: RSSI statistics by user
-	fmt.Println("[INFO] Running query 13: RSSI statistics by user")
-	start = time.Now()
-	_, err = pool.Query(context.Background(), "SELECT user_id, AVG(rssi), MIN(rssi), MAX(rssi) FROM user_events GROUP BY user_id ORDER BY AVG(rssi) DESC LIMIT 100")
-	if err != nil {
-		return err
-	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     13,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "RSSI statistics by user",
-	})
-	fmt.Println("[INFO] Done with query 13")
-
-	// Query 14: RSSI percentiles
-	fmt.Println("[INFO] Running query 14: RSSI percentiles")
-	start = time.Now()
-	var q25, q50, q75 float64
-	err = pool.QueryRow(context.Background(), "SELECT percentile(rssi, 0.25), percentile(rssi, 0.5), percentile(rssi, 0.75) FROM user_events").Scan(&q25, &q50, &q75)
-	if err != nil {
-		return err
-	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     14,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "RSSI percentiles",
-	})
-	fmt.Println("[INFO] Done with query 14")
-
-	// Query 15: Records in first half
-	fmt.Println("[INFO] Running query 15: Records in first half")
-	start = time.Now()
-	var firstHalf int
-	err = pool.QueryRow(context.Background(), "SELECT COUNT(*) FROM user_events WHERE ts BETWEEN $1 AND $2", minTime, middleTime).Scan(&firstHalf)
-	if err != nil {
-		return err
-	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     15,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Records in first half",
-	})
-	fmt.Println("[INFO] Done with query 15")
-
-	// Query 16: Records in second half
-	fmt.Println("[INFO] Running query 16: Records in second half")
-	start = time.Now()
-	var secondHalf int
-	err = pool.QueryRow(context.Background(), "SELECT COUNT(*) FROM user_events WHERE ts BETWEEN $1 AND $2", middleTime, maxTime).Scan(&secondHalf)
-	if err != nil {
-		return err
-	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     16,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Records in second half",
-	})
-	fmt.Println("[INFO] Done with query 16")
-
-	// Query 17: Hourly user activity patterns
-	fmt.Println("[INFO] Running query 17: Hourly user activity patterns")
-	start = time.Now()
-	_, err = pool.Query(context.Background(), "SELECT extract(hour from ts) as hour, COUNT(*) as count FROM user_events GROUP BY hour ORDER BY hour")
-	if err != nil {
-		return err
-	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     17,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Hourly user activity patterns",
-	})
-	fmt.Println("[INFO] Done with query 17")
-
-	// Query 18: Daily RSSI variance
-	fmt.Println("[INFO] Running query 18: Daily RSSI variance")
-	start = time.Now()
-	_, err = pool.Query(context.Background(), "SELECT date_trunc('day', ts) as day, variance(rssi) as rssi_variance FROM user_events GROUP BY day ORDER BY day LIMIT 30")
-	if err != nil {
-		return err
-	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     18,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Daily RSSI variance",
-	})
-	fmt.Println("[INFO] Done with query 18")
-
-	// Query 19: Peak usage hours
-	fmt.Println("[INFO] Running query 19: Peak usage hours")
-	start = time.Now()
-	_, err = pool.Query(context.Background(), "SELECT date_trunc('hour', ts) as hour, COUNT(*) as count FROM user_events GROUP BY hour ORDER BY count DESC LIMIT 5")
-	if err != nil {
-		return err
+	if opts.Archive.Target != "" {
+		results.Archive, err = runArchivalPhase(ctx, info, b, opts.Archive, bounds)
+		if err != nil {
+			return err
+		}
+		if err := phaseDone("archive", results.Archive); err != nil {
+			return err
+		}
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     19,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Peak usage hours",
-	})
-	fmt.Println("[INFO] Done with query 19")
 
-	// Query 20: User session duration analysis
-	fmt.Println("[INFO] Running query 20: User session duration analysis")
-	start = time.Now()
-	_, err = pool.Query(context.Background(), "SELECT user_id, MAX(ts) - MIN(ts) as session_duration FROM user_events GROUP BY user_id ORDER BY session_duration DESC LIMIT 10")
-	if err != nil {
-		return err
+	if opts.RetentionFraction > 0 {
+		results.Retention, err = runRetentionPhase(ctx, info, b, opts, bounds)
+		if err != nil {
+			return err
+		}
+		if err := phaseDone("retention", results.Retention); err != nil {
+			return err
+		}
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     20,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "User session duration analysis",
-	})
-	fmt.Println("[INFO] Done with query 20")
 
-	results.DbType = "cratedb"
-	out, err := os.Create(outFile)
-	if err != nil {
-		return err
+	results.Pool = poolSettingsOf(b)
+	client := clientSettings
+	results.Client = &client
+	results.ClientGC.Run = readGCSnapshot().since(gcStart)
+	results.Resources = stopSampling()
+	if cluster, ok := b.(*clusterBackend); ok {
+		results.Topology = cluster.result(info, opts.Topology)
 	}
-
-	defer out.Close()
-	if err := json.NewEncoder(out).Encode(results); err != nil {
+	if opts.SLO != nil {
+		results.SLO = opts.SLO.evaluate(&results)
+	}
+	if opts.Manifest {
+		results.Manifest, err = buildManifest(ctx, info, b, opts)
+		if err != nil {
+			return fmt.Errorf("failed to build the run manifest: %w", err)
+		}
+	}
+	if err := writeResults(outFile, results); err != nil {
 		return err
 	}
-	return nil
+	completed = true
+	return results.SLO.violation()
 }
 
-func benchmarkClickHouse(connStr string, outFile string) error {
-	conn := clickhouse.OpenDB(&clickhouse.Options{
-		Addr: []string{connStr},
-		Auth: clickhouse.Auth{
-			Database: "default",
-			Username: "default",
-			Password: "",
-		},
-	})
-	defer conn.Close()
-
-	if err := conn.Ping(); err != nil {
-		return err
+// writtenReadings is the number of readings written successfully by the
+// warm-up and the measured ingestion.
+func writtenReadings(results BenchmarkResults) int64 {
+	var n int64
+	if results.Warmup != nil {
+		n += int64(results.Warmup.NRecords)
+	}
+	if len(results.Ingestion) > 0 {
+		n += int64(results.Ingestion[len(results.Ingestion)-1].NRecords)
 	}
+	return n
+}
 
-	// Create the table if it doesn't exist
-	_, err := conn.Exec(`
-		CREATE TABLE IF NOT EXISTS user_events (
-			id UInt64,
-			user_id String,
-			timestamp DateTime,
-			rssi Float32,
-			ssid String
-		) ENGINE = MergeTree()
-		ORDER BY timestamp`)
+// ingestChunks ingests the measured dataset from startChunk on, one batch per
+// chunk, replaying or truncating it according to opts.Scale. NRecords is
+// cumulative over the measured batches. An error comes with the batches
+// written before it.
+func ingestChunks(ctx context.Context, info backendInfo, b backend, startChunk int, opts benchmarkOptions) ([]IngestionResult, error) {
+	files, err := os.ReadDir(readingsDir)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	total := scaledChunkCount(len(files), opts.Scale)
+	if total > len(files) && startChunk > 0 {
+		return nil, fmt.Errorf("-scale above 1 cannot be combined with a warm-up fraction")
+	}
+	if opts.ChunkEnd > 0 {
+		if opts.ChunkEnd > total {
+			return nil, fmt.Errorf("-chunk-end %d is past the last of the %d chunks", opts.ChunkEnd, total)
+		}
+		total = opts.ChunkEnd
+	}
+	if opts.ChunkStart > 0 {
+		// Replays are shifted by the span of the first pass, which a range
+		// starting later does not read.
+		if scaledChunkCount(len(files), opts.Scale) > len(files) {
+			return nil, fmt.Errorf("-scale above 1 cannot be combined with -chunk-start")
+		}
+		if opts.ChunkStart >= total {
+			return nil, fmt.Errorf("-chunk-start %d is past the last of the %d chunks", opts.ChunkStart, total)
+		}
+		startChunk = opts.ChunkStart
+	}
+	if startChunk >= total {
+		return nil, fmt.Errorf("scale %v leaves no chunks to measure after the warm-up", opts.Scale)
 	}
 
-	currentChunk := 0
-	results := BenchmarkResults{}
+	var batches []IngestionResult
+	next, stop := loadChunks(startChunk, total, len(files), opts)
+	defer stop()
 	nRecords := 0
-
-	// Ingestion benchmark
-	for {
-		hasNext, data, err := loadDataChunk(currentChunk)
-		if err != nil {
-			return err
+	var first time.Time
+	for currentChunk := startChunk; currentChunk < total; currentChunk++ {
+		if err := interrupted(); err != nil {
+			return batches, err
+		}
+		loadStart := time.Now()
+		chunk := next()
+		if chunk.err != nil {
+			return batches, chunk.err
 		}
+		loadWait := time.Since(loadStart)
 
 		start := time.Now()
+		if first.IsZero() {
+			first = start
+		}
 
-		// Prepare batch insert
-		tx, err := conn.Begin()
+		retries, waited, err := ingestWithRetry(ctx, b, chunk.readings, opts.Retry, info.isTransient)
+		if err != nil && !info.lenientIngestion {
+			return batches, err
+		}
 		if err != nil {
-			return err
+			fmt.Printf("[WARN] Failed to ingest data chunk %d: %v\n", currentChunk, err)
+		} else {
+			nRecords += len(chunk.readings)
 		}
 
-		stmt, err := tx.Prepare("INSERT INTO user_events (id, user_id, timestamp, rssi, ssid) VALUES (?, ?, ?, ?, ?)")
-		if err != nil {
-			return err
+		duration := time.Since(start) - waited
+		batch := IngestionResult{
+			ChunkId:      currentChunk,
+			DurationMs:   duration.Milliseconds(),
+			BatchRows:    len(chunk.readings),
+			NRecords:     nRecords,
+			Retries:      retries,
+			Errors:       retries,
+			DecompressMs: chunk.decompress.Milliseconds(),
+			LoadWaitMs:   loadWait.Milliseconds(),
+			Failed:       err != nil,
+			StartedAt:    start.UTC(),
+			OffsetMs:     start.Sub(first).Milliseconds(),
+		}
+		if batch.Failed {
+			batch.Errors++
+		} else {
+			batch.RowsPerSec = results.RowsPerSec(batch.BatchRows, duration)
+			debugf("Chunk %d: %d rows in %d ms, %.0f rows/s, %d retries\n", batch.ChunkId, batch.BatchRows, batch.DurationMs, batch.RowsPerSec, retries)
 		}
+		batches = append(batches, batch)
+		opts.Progress.recordElement("ingestion", batch)
+		if err := opts.Checkpoints.reached(ctx, info, b, opts, currentChunk+1, total, nRecords); err != nil {
+			return batches, err
+		}
+	}
+	return batches, nil
+}
 
-		for i, reading := range data.Response {
-			_, err = stmt.Exec(
-				uint64(nRecords+i+1),
-				reading.UserId,
-				time.Unix(int64(reading.LastUpdatedTime), 0),
-				reading.Connection.Rssi,
-				reading.Connection.Ssid,
-			)
-			if err != nil {
-				return err
-			}
+// runQueryCatalog runs every catalog query of the backend and returns the
+// results together with the time bounds found by query 1. Queries the backend
+// does not implement are recorded as -1.
+func runQueryCatalog(ctx context.Context, info backendInfo, b backend, opts benchmarkOptions) ([]QueryResult, queryBounds, error) {
+	var catalog []QueryResult
+	var bounds queryBounds
+	var sampler *paramSampler
+	var err error
+	if opts.RandomParams {
+		infof("Sampling random query parameters from the data\n")
+		if sampler, err = newParamSampler(opts); err != nil {
+			return nil, bounds, err
+		}
+	}
+	// The queries have to see every ingested row on engines that refresh
+	// asynchronously.
+	for _, stmt := range info.reconciliation.refresh {
+		if err := b.exec(ctx, stmt); err != nil {
+			return nil, bounds, err
+		}
+	}
+	for id := 1; id < len(queryDescriptions); id++ {
+		if err := interrupted(); err != nil {
+			return nil, bounds, err
+		}
+		q, ok := info.lookupQuery(id)
+		if !ok {
+			result := catalogQueryResult(id)
+			result.DurationMs = -1
+			catalog = append(catalog, result)
+			opts.Progress.recordElement("queries", catalog[len(catalog)-1])
+			continue
 		}
 
-		if err = tx.Commit(); err != nil {
+		infof("Running query %d: %s\n", id, queryDescriptions[id])
+		debugf("Query %d: %s\n", id, q.text)
+		var samples, warmup []int64
+		var serverMs float64
+		var hooked time.Duration
+		hook := func() error {
+			if opts.ColdHook == nil {
+				return nil
+			}
+			d, err := opts.ColdHook.run(ctx)
+			hooked += d
 			return err
 		}
+		beforeRun := hook
+		if opts.ColdHook != nil && !opts.ColdHook.EveryRun {
+			beforeRun = nil
+		}
+		runQuery := func() error {
+			if id != 1 {
+				return b.query(ctx, q.text, q.arguments(sampler.bounds(bounds))...)
+			}
+			minTime, maxTime, err := b.timeBounds(ctx, q.text)
+			bounds = newQueryBounds(minTime, maxTime)
+			return err
+		}
+		// The warm-up runs stay out of the server statistics as well.
+		err = nil
+		if opts.ColdHook != nil && !opts.ColdHook.EveryRun {
+			err = hook()
+		}
+		if err == nil && opts.QueryWarmup > 0 {
+			warmup, err = repeatQueryAfter(opts.QueryWarmup, beforeRun, runQuery)
+		}
+		if err == nil {
+			serverMs, err = timeOnServer(ctx, b, id, opts.ServerTiming, func() error {
+				var err error
+				samples, err = repeatQueryAfter(opts.QueryRepeats, beforeRun, runQuery)
+				return err
+			})
+		}
+		duration := results.MedianMs(samples)
+		if err != nil {
+			if !info.lenientQueries {
+				return nil, bounds, err
+			}
+			debugf("Query %d failed and is recorded as -1: %v\n", id, err)
+			duration = -1
+			samples = nil
+		}
 
-		nRecords += len(data.Response)
-		duration := time.Since(start).Milliseconds()
-		results.Ingestion = append(results.Ingestion, struct {
-			DurationMs int64 `json:"durationMs"`
-			NRecords   int   `json:"nRecords"`
-		}{
-			DurationMs: duration,
-			NRecords:   nRecords,
-		})
-
-		currentChunk++
-		if !hasNext {
-			break
+		result := catalogQueryResult(id)
+		result.DurationMs = duration
+		result.ServerMs = serverMs
+		if len(samples) > 1 {
+			result.SamplesMs = samples
+		}
+		result.WarmupMs = warmup
+		result.ColdHookMs = hooked.Milliseconds()
+		if opts.Explain && err == nil {
+			result.Plan = capturePlan(ctx, info, b, q, bounds)
+		}
+		if opts.ResultChecksums && err == nil {
+			rows, checksum := captureChecksum(ctx, b, q, bounds)
+			if checksum != "" {
+				result.Rows, result.Checksum = &rows, checksum
+			}
 		}
+		catalog = append(catalog, result)
+		opts.Progress.recordElement("queries", result)
+		debugf("Query %d: median %d ms of %v, warm-up %v\n", id, duration, samples, warmup)
+		infof("Done with query %d\n", id)
 	}
+	return catalog, bounds, nil
+}
 
-	// Query benchmarks
-	var minTime, maxTime, middleTime time.Time
+// repeatQuery runs a query repeats times, at least once, and returns the
+// duration of every run. It stops at the first error.
+func repeatQuery(repeats int, run func() error) ([]int64, error) {
+	return repeatQueryAfter(repeats, nil, run)
+}
 
-	// Query 1: Get time bounds
-	fmt.Println("[INFO] Running query 1: Get time bounds")
-	start := time.Now()
-	err = conn.QueryRow("SELECT MIN(timestamp), MAX(timestamp) FROM user_events").Scan(&minTime, &maxTime)
-	if err != nil {
-		return err
+// repeatQueryAfter is repeatQuery calling before, unless it is nil, ahead of
+// every run and outside of its duration.
+func repeatQueryAfter(repeats int, before func() error, run func() error) ([]int64, error) {
+	var samples []int64
+	for repeat := 0; repeat < max(repeats, 1); repeat++ {
+		if before != nil {
+			if err := before(); err != nil {
+				return samples, err
+			}
+		}
+		start := time.Now()
+		if err := run(); err != nil {
+			return samples, err
+		}
+		samples = append(samples, time.Since(start).Milliseconds())
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     1,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Get time bounds",
-	})
-	fmt.Println("[INFO] Done with query 1")
+	return samples, nil
+}
 
-	middleTime = minTime.Add(maxTime.Sub(minTime) / 2)
+func main() {
+	defer exitOnPanic()
+	exitOnError(run())
+}
 
-	// Query 2: Count all records
-	fmt.Println("[INFO] Running query 2: Count all records")
-	start = time.Now()
-	var totalCount int
-	err = conn.QueryRow("SELECT COUNT(*) FROM user_events").Scan(&totalCount)
-	if err != nil {
-		return err
-	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     2,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Count all records",
-	})
-	fmt.Println("[INFO] Done with query 2")
+// scenarioNames are the values of -scenario, checked before a database is
+// started.
+var scenarioNames = []string{
+	"time-to-insight", "read-your-writes", "compression", "continuous-aggregates", "downsampling", "duplicates",
+	"buildings", "locations", "single-row", "connection-churn", "native-partitions",
+}
 
-	// Query 3: Count distinct users
-	fmt.Println("[INFO] Running query 3: Count distinct users")
-	start = time.Now()
-	var distinctUsers int
-	err = conn.QueryRow("SELECT COUNT(DISTINCT user_id) FROM user_events").Scan(&distinctUsers)
-	if err != nil {
-		return err
+// runBench is the bench subcommand, and the default one: it parses the flags
+// of a benchmark run and runs what they ask for. Invalid invocations are
+// returned as configErrors; the deferred clean-ups, such as removing a managed
+// container, run before main exits.
+func runBench(flags *flag.FlagSet, args []string) error {
+	connStr := flags.String("conn", "", "Database connection string")
+	connEnv := flags.String("conn-env", "", "Environment variable holding the connection string, instead of -conn")
+	connFile := flags.String("conn-file", "", "File holding the connection string, e.g. a Docker secret, instead of -conn")
+	outputFile := flags.String("o", "", "Output file name")
+	dbType := flags.String("type", "", "Database type: "+strings.Join(backendNames(), ", "))
+	sqlDialect := flags.String("sql-dialect", "", "Dialect directory of the custom-sql backend: wire protocol, DDL, insert and catalog queries")
+	httpSQLDialect := flags.String("http-sql-dialect", "", "JSON dialect file of the http-sql backend: request layout, DDL, insert template and catalog queries")
+	recoverFrom := flags.String("recover-results", "", "Assemble the result file -o from the progress journal of an interrupted run and exit")
+	listBackends := flags.Bool("list-backends", false, "List the database backends compiled into this binary and exit")
+	quiet := flags.Bool("quiet", false, "Only log warnings and errors; the summary line at the end is printed anyway")
+	verbose := flags.Bool("verbose", false, "Also log the text and run times of every query and the throughput of every batch")
+	manageContainers := flags.Bool("manage-containers", false, "Start the database in a pinned Docker container before the run and remove it afterwards")
+	kubernetesMode := flags.Bool("kubernetes", false, "Deploy the database as a StatefulSet in Kubernetes instead of a Docker container, and sample its pod metrics during the run")
+	kubeNamespace := flags.String("kube-namespace", "", "Namespace of the StatefulSet; the benchmark pod's or the kubeconfig's when not set")
+	kubeconfig := flags.String("kubeconfig", "", "Kubeconfig used outside the cluster; the default loading rules apply when not set")
+	kubeStartTimeout := flags.Duration("kube-start-timeout", kubeSettings.StartTimeout, "How long to wait for the database pod to be scheduled and started")
+	clientCpus := flags.String("client-cpus", "", "CPU list the benchmark client is pinned to, like taskset -c (e.g. 0-3,6); Linux only")
+	clientGoMaxProcs := flags.Int("client-gomaxprocs", 0, "GOMAXPROCS of the benchmark client; 0 keeps the default, or the number of -client-cpus")
+	clientMemoryLimit := flags.String("client-memory-limit", "", "Soft memory limit of the benchmark client's Go runtime (e.g. 2g); empty keeps GOMEMLIMIT or no limit")
+	containerCpus := flags.String("container-cpus", "", "CPU limit for the managed container (docker --cpus) or the requests and limits of the pod")
+	containerMemory := flags.String("container-memory", "", "Memory limit for the managed container (docker --memory) or the requests and limits of the pod")
+	containerImage := flags.String("container-image", "", "Override the pinned image of the managed container")
+	scenario := flags.String("scenario", "", "Run a scenario instead of the full benchmark: time-to-insight, read-your-writes, compression, continuous-aggregates, downsampling, duplicates, buildings, locations, single-row, connection-churn or native-partitions")
+	duplicatePercent := flags.Int("duplicate-percent", 10, "Percentage of the readings re-sent in the duplicates scenario")
+	rywProbes := flags.Int("ryw-probes", 100, "Number of probes in the read-your-writes scenario")
+	singleRowWriters := flags.Int("single-row-writers", 32, "Concurrent connections of the single-row scenario, each writing one reading per request")
+	singleRowInserts := flags.Int("single-row-inserts", 20000, "Readings written by the single-row scenario, from the start of the dataset")
+	churnRate := flags.Float64("churn-rate", 20, "Writes per second of the connection-churn scenario, each over a connection opened and closed for it")
+	churnOperations := flags.Int("churn-operations", 1000, "Writes of each phase of the connection-churn scenario, over reused and over new connections")
+	rywTimeout := flags.Duration("ryw-timeout", 5*time.Second, "How long a read-your-writes probe waits for its row to become visible")
+	ingestRetries := flags.Int("ingest-retries", 3, "How many times a batch that failed with a transient error is retried")
+	ingestRetryBackoff := flags.Duration("ingest-retry-backoff", time.Second, "Initial wait before retrying a failed batch; doubles on every attempt")
+	archiveTarget := flags.String("archive", "", "Move old data to this cold tier after the queries and re-time historical queries (TimescaleDB tablespace or ClickHouse disk)")
+	archiveFraction := flags.Float64("archive-fraction", 0.5, "Fraction of the time range, from the oldest reading, moved to the cold tier")
+	retentionFraction := flags.Float64("retention-fraction", 0, "Expire this fraction of the time range, from the oldest reading, after the queries and re-run them; 0 disables the phase")
+	warmupDir := flags.String("warmup-dir", "", "Directory with a separate warm-up dataset (readings_N.json) ingested before measurement starts")
+	warmupFraction := flags.Float64("warmup-fraction", 0, "Fraction of the dataset's chunks ingested as unmeasured warm-up before measurement starts")
+	schemaVariant := flags.String("schema-variant", "", "Create the table with one of the backend's schema variants (see -list-backends)")
+	ingestMethod := flags.String("ingest-method", "", "Write the readings with one of the backend's ingest methods, e.g. copy or insert (see -list-backends)")
+	chunkInterval := flags.String("chunk-interval", "", "Chunk interval of the TimescaleDB hypertable, e.g. \"1 hour\"; 4 hours when not set")
+	shards := flags.Int("shards", 0, "Shards of the CrateDB table, of every partition with a partitioned schema variant; 4 when not set")
+	queryRepeats := flags.Int("query-repeats", 1, "How many times every query is run; all durations are stored and the median is reported")
+	queryWarmup := flags.Int("warmup", 0, "How many times every catalog query runs before its -query-repeats; their durations are stored apart and left out of the statistics")
+	compressOver := flags.Int64("compress-results-over", compressResultsOver, "Write the result file gzip-compressed (adding .gz) when it is larger than this many bytes; 0 disables compression")
+	workloadName := flags.String("workload", "", "Run a query workload with concurrent clients after the queries: "+strings.Join(workloadNames(), ", ")+" or a YAML file defining one")
+	workloadDuration := flags.Duration("workload-duration", 0, "How long the -workload runs; the duration of its definition when not set")
+	loadModel := flags.String("load-model", "", "Load model of the -workload: closed (clients with think time) or open (queries arriving at -arrival-rate, queueing measured); the definition's when not set")
+	arrivalRate := flags.Float64("arrival-rate", 0, "Queries per second offered by an open-loop -workload, as a Poisson process; the definition's when not set")
+	sizeSweep := flags.String("size-sweep", "", "Pause the ingestion after these percentages of the chunks, e.g. 10,25,50,100, and run the queries on the data written so far")
+	bucketSweep := flags.Bool("bucket-sweep", false, "Run the occupancy aggregation at 1m, 5m, 1h and 1d buckets after the queries")
+	concurrencySweep := flags.Int("concurrency-sweep", 0, "Run one query from 1, 2, 4, ... up to this many clients at once after the queries; 0 disables the sweep")
+	concurrencyQuery := flags.Int("concurrency-query", defaultConcurrencyQuery, "Catalog query of the concurrency sweep")
+	concurrencyStep := flags.Duration("concurrency-step-duration", 10*time.Second, "How long every step of the concurrency sweep runs")
+	sloFile := flags.String("slo", "", "YAML file of service level objectives, e.g. the p95 of a query or the ingestion rate; a run that misses one exits with code 4 after writing its results")
+	manifest := flags.Bool("manifest", false, "Record a provenance manifest in the result file: SHA-256 of the input chunks and the query catalog, the build of the binary and the server version")
+	calibrate := flags.Bool("calibrate", false, "Measure the overhead of the client before the run: the cost of reading the clock, the JSON decoding rate and round trips to a no-op server on loopback, recorded as the noise floor of the results")
+	energy := flags.String("energy", "", "Measure the energy of the database host over the ingestion and the queries: rapl (this host), scaphandre=<exporter metrics URL> or ipmi[=<ipmitool options>]")
+	resultChecksums := flags.Bool("result-checksums", false, "Run every query once more after it is timed and record the number of rows it returns and an order-independent SHA-256 checksum of the result")
+	explain := flags.Bool("explain", false, "Capture the EXPLAIN output (ANALYZE where supported) of every query in the result file")
+	writeBatchSize := flags.Int("write-batch-size", 0, "Points per write request for backends that split a chunk into several writes (InfluxDB, default 5000; the multi-row INSERTs of CrateDB and CockroachDB, default 1000)")
+	scale := flags.Float64("scale", 1, "Share of the dataset to ingest: below 1 only the leading chunks (smoke runs), above 1 replays with shifted timestamps (stress runs)")
+	chunkStart := flags.Int("chunk-start", 0, "Ingest the chunks from this one on, e.g. to rerun the part of a load that failed or to split a load across hosts")
+	chunkEnd := flags.Int("chunk-end", 0, "Ingest the chunks up to, not including, this one; 0 ingests up to the end of the dataset")
+	cardinalityFactor := flags.Int("cardinality-factor", 1, "Multiply the number of distinct users and SSIDs by suffixing them, to stress high tag cardinality")
+	joins := flags.Bool("joins", false, "Load users and access points dimension tables after the queries and run the join queries")
+	dimensionsFile := flags.String("dimensions", "", "JSON dimension dataset of the join queries and the buildings and locations scenarios; derived synthetically from the readings when not set")
+	pass := flags.Int("pass", 0, "Index of this run within a repeated campaign, recorded in the result file")
+	export := flags.Bool("export", false, "Time a full export of the ingested data with the engine's export mechanism after the queries")
+	pprofAddr := flags.String("pprof", "", "Serve the profiles of the client on this address, e.g. :6060, for go tool pprof while the run is in progress")
+	profileDir := flags.String("profile-dir", "", "Write CPU and heap profiles of the client over the measured ingestion and the query catalog to this directory")
+	topologyFile := flags.String("topology", "", "JSON description of a multi-node target whose nodes the ingestion writes to in turn; replaces -conn")
+	coordinatorAddr := flags.String("coordinator", "", "Coordinate a distributed ingestion: listen on this address, e.g. :7070, for -workers workers, split the chunks among them and write the merged results")
+	workers := flags.Int("workers", 2, "Number of workers of the distributed ingestion -coordinator waits for")
+	workerTimeout := flags.Duration("worker-timeout", time.Minute, "How long -coordinator waits for word from a worker that took its range before marking it failed; workers send a heartbeat every 10s")
+	workerOf := flags.String("worker", "", "Join the distributed ingestion of the coordinator at this URL, e.g. http://host:7070, and ingest the chunks it assigns")
+	matrixFile := flags.String("matrix", "", "Run every combination of a JSON matrix of databases, scales, concurrency levels and schema variants instead of a single run (requires -manage-containers)")
+	extraColumnsFile := flags.String("extra-columns", "", "YAML file of optional reading fields, e.g. device type and OS, stored in columns of their own and aggregated after the queries")
+	schemaMappingFile := flags.String("schema-mapping", "", "YAML file mapping the JSON of a dataset with another layout to the readings: the paths of the records and fixed fields, and extra columns, tags and fields as with -extra-columns")
+	pseudonymize := flags.String("pseudonymize", "", "Replace every user id as the readings are loaded: hmac with its HMAC-SHA256, token with a token of the same shape (MAC addresses stay MAC addresses), keyed by -pseudonymize-key")
+	pseudonymizeKey := flags.String("pseudonymize-key", "", "Key of -pseudonymize, at least 16 bytes: a value, env:NAME or file:PATH")
+	seed := flags.Uint64("seed", 1, "Seed of everything random in a run (fidelity sample, synthetic dimensions, random query parameters); recorded in the results")
+	durability := flags.String("durability", "", "Durability of the writes: relaxed (acknowledged before they are on disk) or strict (after); the engine's defaults when not set. The effective settings are recorded either way")
+	serverTiming := flags.Bool("server-timing", false, "Also record the execution time of every query logged by the engine (ClickHouse query_log, pg_stat_statements, CrateDB jobs_log, QuestDB query tracing), to separate it from the network")
+	randomParams := flags.Bool("random-params", false, "Anchor the time range queries at the timestamp of a random reading on every repetition instead of the middle time")
+	resultSink := flags.String("result-sink", "", "Also publish the results to a results store: a PostgreSQL connection string, jsonl:PATH to append them to a file, pushgateway:URL or influx:URL (an InfluxDB write endpoint) for Grafana")
+	prefetchChunks := flags.Int("prefetch-chunks", 1, "Number of chunks read and decoded in the background while the current one is written; 0 loads them one after the other")
+	noCreate := flags.Bool("no-create", false, "Skip the DDL of the readings table and only ingest and query, for a database whose schema is pre-provisioned; the run fails if the table is missing")
+	dryRun := flags.Bool("dry-run", false, "Run every query once against a handful of readings in a scratch table and report the ones that fail, instead of the benchmark")
+	verifyHours := flags.Bool("verify-hours", false, "Compare the readings per UTC hour of the day stored by the database with the source after the queries")
+	sourceTimezone := flags.String("source-timezone", "UTC", "Time zone whose wall clock the dataset's timestamps are in; UTC takes them as Unix times")
+	fidelitySamples := flags.Int("fidelity-samples", 0, "Look up this many random source readings after the queries and report the ones lost or altered by the database; 0 disables the audit")
+	maintenance := flags.Bool("maintenance", false, "Time the engine's maintenance operations, e.g. VACUUM ANALYZE or OPTIMIZE TABLE FINAL, after the queries and run the queries again")
+	coldHookFlag := flags.String("cold-hook", "", "Before every catalog query, run this shell command, e.g. one dropping the OS page cache, or restart the managed container with restart; its duration is not timed")
+	coldHookEvery := flags.String("cold-hook-every", "query", "When -cold-hook runs: query, once before the runs of every query, or run, before each of its warm-up runs and repeats")
+	coldRestart := flags.Bool("cold-restart", false, "Restart the managed container after the ingestion and run the queries once on the cold database before the measured queries")
+	poolMaxConns := flags.Int("pool-max-conns", 0, "Maximum number of connections of the pgx and ClickHouse pools; 0 keeps the driver default")
+	poolMinConns := flags.Int("pool-min-conns", 0, "Connections the pgx pool keeps open, or the idle connections ClickHouse keeps; 0 keeps the driver default")
+	statementCache := flags.Int("statement-cache", -1, "Capacity of the pgx prepared statement cache; 0 disables it and describes every query, -1 keeps the driver default")
+	tlsEnabled := flags.Bool("tls", false, "Connect to the database over TLS, verified against the system CA certificates unless -tls-ca is given")
+	tlsCA := flags.String("tls-ca", "", "PEM file with the CA certificates the database certificate is verified against (implies -tls)")
+	tlsCert := flags.String("tls-cert", "", "PEM client certificate for mutual TLS, with -tls-key (implies -tls)")
+	tlsKey := flags.String("tls-key", "", "PEM private key of -tls-cert")
+	tlsServerName := flags.String("tls-server-name", "", "Server name sent with SNI and verified in the certificate, when it differs from the connection host (implies -tls)")
+	tlsSkipVerify := flags.Bool("tls-insecure-skip-verify", false, "Do not verify the database certificate (implies -tls)")
+	dbUser := flags.String("db-user", "", "User replacing the one of the connection string: a value, env:NAME or file:PATH")
+	dbPassword := flags.String("db-password", "", "Password replacing the one of the connection string: a value, env:NAME or file:PATH")
+	dbToken := flags.String("db-token", "", "API token of InfluxDB and the QuestDB ILP endpoint: a value, env:NAME or file:PATH")
+	influxOrgFlag := flags.String("influx-org", "", "InfluxDB 2.x organization; myorg of the container when not set")
+	readyTimeout := flags.Duration("ready-timeout", 2*time.Minute, "How long to wait for the database to answer a ping before starting")
+	if err := parseCommandFlags(flags, args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return asConfigError(err)
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     3,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Count distinct users",
-	})
-	fmt.Println("[INFO] Done with query 3")
 
-	// Query 4: Average RSSI
-	fmt.Println("[INFO] Running query 4: Average RSSI")
-	start = time.Now()
-	var avgRssi float64
-	err = conn.QueryRow("SELECT AVG(rssi) FROM user_events").Scan(&avgRssi)
-	if err != nil {
-		return err
+	switch {
+	case *quiet && *verbose:
+		return configErrorf("-quiet and -verbose are mutually exclusive")
+	case *quiet:
+		logLevel = verbosityQuiet
+	case *verbose:
+		logLevel = verbosityVerbose
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     4,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Average RSSI",
-	})
-	fmt.Println("[INFO] Done with query 4")
 
-	// Query 5: Records before middle time
-	fmt.Println("[INFO] Running query 5: Records before middle time")
-	start = time.Now()
-	var beforeMiddle int
-	err = conn.QueryRow("SELECT COUNT(*) FROM user_events WHERE timestamp < ?", middleTime).Scan(&beforeMiddle)
-	if err != nil {
-		return err
+	if *listBackends {
+		for _, name := range backendNames() {
+			info := backends[name]
+			fmt.Printf("%-12s %s\n", name, info.description)
+			if len(info.schemaVariants) > 0 {
+				fmt.Printf("%-12s schema variants: %s\n", "", info.schemaVariantNames())
+			}
+			if len(info.ingestMethods) > 0 {
+				fmt.Printf("%-12s ingest methods: %s\n", "", strings.Join(info.ingestMethods, ", "))
+			}
+			if info.capabilities != nil {
+				fmt.Printf("%-12s capabilities: %s\n", "", info.capabilities.names())
+			}
+		}
+		return nil
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     5,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Records before middle time",
-	})
-	fmt.Println("[INFO] Done with query 5")
+	startRunSummary()
 
-	// Query 6: Records after middle time
-	fmt.Println("[INFO] Running query 6: Records after middle time")
-	start = time.Now()
-	var afterMiddle int
-	err = conn.QueryRow("SELECT COUNT(*) FROM user_events WHERE timestamp > ?", middleTime).Scan(&afterMiddle)
+	conn, err := connFromEnv(*connStr, *connEnv, *connFile)
 	if err != nil {
-		return err
-	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     6,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Records after middle time",
-	})
-	fmt.Println("[INFO] Done with query 6")
-
-	// Query 7: Records around middle time (±1 hour)
-	fmt.Println("[INFO] Running query 7: Records around middle time (±1 hour)")
-	start = time.Now()
-	var aroundMiddle int
-	hourBefore := middleTime.Add(-time.Hour)
-	hourAfter := middleTime.Add(time.Hour)
-	err = conn.QueryRow("SELECT COUNT(*) FROM user_events WHERE timestamp BETWEEN ? AND ?", hourBefore, hourAfter).Scan(&aroundMiddle)
-	if err != nil {
-		return err
+		return asConfigError(err)
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     7,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Records around middle time (±1 hour)",
-	})
-	fmt.Println("[INFO] Done with query 7")
+	*connStr = conn
+	registerConnSecrets(*connStr)
 
-	// Query 8: 24 hours aggregation from middle time
-	fmt.Println("[INFO] Running query 8: 24 hours aggregation from middle time")
-	start = time.Now()
-	dayAfter := middleTime.Add(24 * time.Hour)
-	_, err = conn.Query("SELECT toStartOfHour(timestamp) as hour, COUNT(*) FROM user_events WHERE timestamp BETWEEN ? AND ? GROUP BY hour ORDER BY hour", middleTime, dayAfter)
-	if err != nil {
-		return err
+	var topology *topologyConfig
+	if *topologyFile != "" {
+		if *connStr != "" {
+			return configErrorf("-topology replaces -conn")
+		}
+		if topology, err = readTopology(*topologyFile); err != nil {
+			return asConfigError(err)
+		}
+		*connStr = topology.Nodes[0]
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     8,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "24 hours aggregation from middle time",
-	})
-	fmt.Println("[INFO] Done with query 8")
 
-	// Query 9: Top 10 users by activity
-	fmt.Println("[INFO] Running query 9: Top 10 users by activity")
-	start = time.Now()
-	_, err = conn.Query("SELECT user_id, COUNT(*) as count FROM user_events GROUP BY user_id ORDER BY count DESC LIMIT 10")
-	if err != nil {
-		return err
+	if *recoverFrom != "" {
+		if *outputFile == "" {
+			return configErrorf("-recover-results needs -o")
+		}
+		compressResultsOver = *compressOver
+		return recoverResults(*recoverFrom, *outputFile)
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     9,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Top 10 users by activity",
-	})
-	fmt.Println("[INFO] Done with query 9")
 
-	// Query 10: Records with strong signal
-	fmt.Println("[INFO] Running query 10: Records with strong signal")
-	start = time.Now()
-	var strongSignal int
-	err = conn.QueryRow("SELECT COUNT(*) FROM user_events WHERE rssi > -50").Scan(&strongSignal)
-	if err != nil {
-		return err
+	if *matrixFile == "" && (*connStr == "" || *dbType == "" || (*outputFile == "" && *workerOf == "")) {
+		flags.Usage()
+		return configErrorf("-type, -conn and -o are required, or -matrix; run \"help\" for the other commands")
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     10,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Records with strong signal",
-	})
-	fmt.Println("[INFO] Done with query 10")
 
-	// Query 11: Records with weak signal
-	fmt.Println("[INFO] Running query 11: Records with weak signal")
-	start = time.Now()
-	var weakSignal int
-	err = conn.QueryRow("SELECT COUNT(*) FROM user_events WHERE rssi < -80").Scan(&weakSignal)
-	if err != nil {
-		return err
+	compressResultsOver = *compressOver
+	tlsSettings = tlsOptions{
+		Enabled:    *tlsEnabled || *tlsCA != "" || *tlsCert != "" || *tlsServerName != "" || *tlsSkipVerify,
+		CAFile:     *tlsCA,
+		CertFile:   *tlsCert,
+		KeyFile:    *tlsKey,
+		ServerName: *tlsServerName,
+		SkipVerify: *tlsSkipVerify,
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     11,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Records with weak signal",
-	})
-	fmt.Println("[INFO] Done with query 11")
-
-	// Query 12: Top SSIDs
-	fmt.Println("[INFO] Running query 12: Top SSIDs")
-	start = time.Now()
-	_, err = conn.Query("SELECT ssid, COUNT(*) as count FROM user_events GROUP BY ssid ORDER BY count DESC LIMIT 10")
-	if err != nil {
-		return err
+	if (tlsSettings.CertFile == "") != (tlsSettings.KeyFile == "") {
+		return configErrorf("-tls-cert and -tls-key must be given together")
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     12,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Top SSIDs",
-	})
-	fmt.Println("[INFO] Done with query 12")
-
-	// Query 13: RSSI statistics by user
-	fmt.Println("[INFO] Running query 13: RSSI statistics by user")
-	start = time.Now()
-	_, err = conn.Query("SELECT user_id, AVG(rssi), MIN(rssi), MAX(rssi) FROM user_events GROUP BY user_id ORDER BY AVG(rssi) DESC LIMIT 100")
-	if err != nil {
-		return err
+	for _, secret := range []struct {
+		ref    string
+		target *string
+	}{{*dbUser, &dbCredentials.User}, {*dbPassword, &dbCredentials.Password}, {*dbToken, &dbCredentials.Token}} {
+		value, err := resolveSecret(secret.ref)
+		if err != nil {
+			return asConfigError(err)
+		}
+		*secret.target = value
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     13,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "RSSI statistics by user",
-	})
-	fmt.Println("[INFO] Done with query 13")
-
-	// Query 14: RSSI percentiles
-	fmt.Println("[INFO] Running query 14: RSSI percentiles")
-	start = time.Now()
-	var q1, median, q3 float64
-	err = conn.QueryRow("SELECT quantile(0.25)(rssi) as q1, quantile(0.5)(rssi) as median, quantile(0.75)(rssi) as q3 FROM user_events").Scan(&q1, &median, &q3)
-	if err != nil {
-		return err
+	registerSecret(dbCredentials.Password)
+	registerSecret(dbCredentials.Token)
+	if *pseudonymize != "" {
+		key, err := resolveSecret(*pseudonymizeKey)
+		if err != nil {
+			return asConfigError(err)
+		}
+		registerSecret(key)
+		if pseudonyms, err = newPseudonymizer(*pseudonymize, key); err != nil {
+			return asConfigError(err)
+		}
+	} else if *pseudonymizeKey != "" {
+		return configErrorf("-pseudonymize-key requires -pseudonymize")
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     14,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "RSSI percentiles",
-	})
-	fmt.Println("[INFO] Done with query 14")
-
-	// Query 15: Records in first half
-	fmt.Println("[INFO] Running query 15: Records in first half")
-	start = time.Now()
-	var firstHalf int
-	err = conn.QueryRow("SELECT COUNT(*) FROM user_events WHERE timestamp BETWEEN ? AND ?", minTime, middleTime).Scan(&firstHalf)
+	dbCredentials.Org = *influxOrgFlag
+	poolTuning = poolOptions{MaxConns: *poolMaxConns, MinConns: *poolMinConns, StatementCache: *statementCache}
+	if err := poolTuning.validate(); err != nil {
+		return asConfigError(err)
+	}
+	if *httpSQLDialect != "" {
+		if loadHTTPSQLDialect == nil {
+			return configErrorf("-http-sql-dialect is not available in a binary built with the nohttpsql tag")
+		}
+		if err := loadHTTPSQLDialect(*httpSQLDialect); err != nil {
+			return asConfigError(err)
+		}
+	}
+	if *sqlDialect != "" {
+		if loadSQLDialect == nil {
+			return configErrorf("-sql-dialect is not available in a binary built with the nocustomsql tag")
+		}
+		if err := loadSQLDialect(*sqlDialect); err != nil {
+			return asConfigError(err)
+		}
+	}
+	if err := applyClientLimits(clientOptions{Cpus: *clientCpus, GoMaxProcs: *clientGoMaxProcs, MemoryLimit: *clientMemoryLimit}); err != nil {
+		return asConfigError(err)
+	}
+	if *pprofAddr != "" {
+		if err := servePprof(*pprofAddr); err != nil {
+			return configErrorf("-pprof: %v", err)
+		}
+	}
+	location, err := time.LoadLocation(*sourceTimezone)
 	if err != nil {
-		return err
+		return configErrorf("unknown time zone for -source-timezone: %s", *sourceTimezone)
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     15,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Records in first half",
-	})
-	fmt.Println("[INFO] Done with query 15")
+	sourceLocation = location
 
-	// Query 16: Records in second half
-	fmt.Println("[INFO] Running query 16: Records in second half")
-	start = time.Now()
-	var secondHalf int
-	err = conn.QueryRow("SELECT COUNT(*) FROM user_events WHERE timestamp BETWEEN ? AND ?", middleTime, maxTime).Scan(&secondHalf)
-	if err != nil {
-		return err
+	if *resultSink != "" {
+		registerConnSecrets(*resultSink)
+		sink, err := openResultSink(*resultSink)
+		if err != nil {
+			return err
+		}
+		defer sink.close()
+		resultSinks = append(resultSinks, sink)
+	}
+
+	opts := benchmarkOptions{
+		Retry:             retryPolicy{MaxRetries: *ingestRetries, Backoff: *ingestRetryBackoff},
+		Archive:           archiveOptions{Target: *archiveTarget, Fraction: *archiveFraction},
+		Warmup:            warmupOptions{Dir: *warmupDir, Fraction: *warmupFraction},
+		SchemaVariant:     *schemaVariant,
+		ChunkInterval:     *chunkInterval,
+		Shards:            *shards,
+		IngestMethod:      *ingestMethod,
+		Durability:        *durability,
+		QueryRepeats:      *queryRepeats,
+		QueryWarmup:       *queryWarmup,
+		BucketSweep:       *bucketSweep,
+		Concurrency:       concurrencyOptions{MaxClients: *concurrencySweep, QueryId: *concurrencyQuery, StepDuration: *concurrencyStep},
+		Explain:           *explain,
+		ResultChecksums:   *resultChecksums,
+		ServerTiming:      *serverTiming,
+		Pass:              *pass,
+		Scale:             *scale,
+		RetentionFraction: *retentionFraction,
+		CardinalityFactor: *cardinalityFactor,
+		ChunkStart:        *chunkStart,
+		ChunkEnd:          *chunkEnd,
+		ProfileDir:        *profileDir,
+		WriteBatchSize:    *writeBatchSize,
+		Joins:             *joins,
+		DimensionsFile:    *dimensionsFile,
+		Export:            *export,
+		FidelitySamples:   *fidelitySamples,
+		VerifyHours:       *verifyHours,
+		ColdRestart:       *coldRestart,
+		Maintenance:       *maintenance,
+		ReadyTimeout:      *readyTimeout,
+		Seed:              *seed,
+		RandomParams:      *randomParams,
+		Topology:          topology,
+		NoCreate:          *noCreate,
+		PrefetchChunks:    *prefetchChunks,
+		Manifest:          *manifest,
+		Calibrate:         *calibrate,
+	}
+	if opts.CardinalityFactor < 1 {
+		return configErrorf("-cardinality-factor must be at least 1")
+	}
+	if *workloadName != "" {
+		if opts.Workload, err = loadWorkload(*workloadName); err != nil {
+			return asConfigError(err)
+		}
+		if *workloadDuration > 0 {
+			opts.Workload.duration = *workloadDuration
+		}
+		if *loadModel != "" {
+			opts.Workload.model = *loadModel
+		}
+		if *arrivalRate > 0 {
+			opts.Workload.arrivalRate = *arrivalRate
+		}
+		if err := opts.Workload.checkModel(); err != nil {
+			return configErrorf("-workload %s: %v", *workloadName, err)
+		}
+	} else if *loadModel != "" || *arrivalRate > 0 {
+		return configErrorf("-load-model and -arrival-rate need a -workload")
+	}
+	if *extraColumnsFile != "" {
+		if *scenario != "" || *dryRun || *topologyFile != "" {
+			return configErrorf("-extra-columns extends user_events and cannot be combined with -scenario, -dry-run or -topology")
+		}
+		if *schemaMappingFile != "" {
+			return configErrorf("-extra-columns and -schema-mapping both define extra columns; list them under columns in the mapping")
+		}
+		columns, err := readExtraColumns(*extraColumnsFile)
+		if err != nil {
+			return asConfigError(err)
+		}
+		setExtraColumns(columns)
+	}
+	if *schemaMappingFile != "" {
+		mapping, err := readSchemaMapping(*schemaMappingFile)
+		if err != nil {
+			return asConfigError(err)
+		}
+		if len(mapping.Columns) > 0 && (*scenario != "" || *dryRun || *topologyFile != "") {
+			return configErrorf("the columns of -schema-mapping extend user_events and cannot be combined with -scenario, -dry-run or -topology")
+		}
+		readingMapping = mapping
+		setExtraColumns(mapping.Columns)
+	}
+	if *sloFile != "" {
+		if *scenario != "" || *dryRun {
+			return configErrorf("-slo checks the results of the benchmark and cannot be combined with -scenario or -dry-run")
+		}
+		if opts.SLO, err = readSLOConfig(*sloFile); err != nil {
+			return asConfigError(err)
+		}
+	}
+	if opts.PrefetchChunks < 0 {
+		return configErrorf("-prefetch-chunks must not be negative")
+	}
+	if opts.Shards < 0 {
+		return configErrorf("-shards must not be negative")
+	}
+	if opts.QueryWarmup < 0 {
+		return configErrorf("-warmup must not be negative")
+	}
+	if *coldHookFlag != "" {
+		if opts.QueryWarmup > 0 {
+			return configErrorf("-warmup warms up the queries that -cold-hook gets cold; use one of them")
+		}
+		if *workerOf != "" || *dryRun {
+			return configErrorf("-cold-hook times the query catalog and cannot be combined with -worker or -dry-run")
+		}
+	}
+	if opts.RetentionFraction < 0 || opts.RetentionFraction >= 1 {
+		return configErrorf("-retention-fraction must be in [0, 1)")
+	}
+	if opts.Concurrency.MaxClients < 0 {
+		return configErrorf("-concurrency-sweep must not be negative")
+	}
+	if opts.Concurrency.enabled() && (opts.Concurrency.QueryId < 2 || opts.Concurrency.QueryId >= len(queryDescriptions) || opts.Concurrency.StepDuration <= 0) {
+		return configErrorf("the concurrency sweep needs a catalog query from 2 to %d and a positive step duration", len(queryDescriptions)-1)
+	}
+	if opts.FidelitySamples < 0 {
+		return configErrorf("-fidelity-samples must not be negative")
+	}
+	if opts.Scale <= 0 {
+		return configErrorf("-scale must be positive")
+	}
+	if opts.Warmup.Dir != "" && opts.Warmup.Fraction > 0 {
+		return configErrorf("-warmup-dir and -warmup-fraction are mutually exclusive")
+	}
+	if opts.ChunkStart < 0 || opts.ChunkEnd < 0 || (opts.ChunkEnd > 0 && opts.ChunkEnd <= opts.ChunkStart) {
+		return configErrorf("-chunk-start and -chunk-end must not be negative and -chunk-end must be past -chunk-start")
+	}
+	if opts.ChunkStart > 0 && opts.Warmup.Fraction > 0 {
+		return configErrorf("-warmup-fraction warms up on the first chunks and cannot be combined with -chunk-start; use -warmup-dir")
+	}
+	if *scenario != "" && !slices.Contains(scenarioNames, *scenario) {
+		return configErrorf("unsupported scenario: %s", *scenario)
+	}
+	if opts.DimensionsFile != "" && !opts.Joins && *scenario != "buildings" && *scenario != "locations" {
+		return configErrorf("-dimensions requires -joins or the buildings or locations scenario")
+	}
+	if err := checkDurabilityLevel(opts.Durability); err != nil {
+		return asConfigError(err)
+	}
+	if opts.Durability != "" && (*scenario != "" || *dryRun) {
+		return configErrorf("-durability applies to the full benchmark and cannot be combined with -scenario or -dry-run")
+	}
+	if opts.SizeSweep, err = parseSizeSweep(*sizeSweep); err != nil {
+		return asConfigError(err)
+	}
+	if len(opts.SizeSweep) > 0 && (*scenario != "" || *dryRun) {
+		return configErrorf("-size-sweep pauses the ingestion of the full benchmark and cannot be combined with -scenario or -dry-run")
+	}
+	if opts.ChunkStart > 0 && len(opts.SizeSweep) > 0 {
+		return configErrorf("-size-sweep checkpoints are shares of the whole dataset and cannot be combined with -chunk-start")
+	}
+	if opts.ProfileDir != "" {
+		if *scenario != "" || *dryRun || *coordinatorAddr != "" || *workerOf != "" {
+			return configErrorf("-profile-dir profiles the phases of the full benchmark and cannot be combined with -scenario, -dry-run, -coordinator or -worker; use -pprof")
+		}
+		if err := os.MkdirAll(opts.ProfileDir, 0o755); err != nil {
+			return asConfigError(err)
+		}
+	}
+	if *energy != "" {
+		if *scenario != "" || *dryRun || *coordinatorAddr != "" || *workerOf != "" {
+			return configErrorf("-energy meters the phases of the full benchmark and cannot be combined with -scenario, -dry-run, -coordinator or -worker")
+		}
+		if opts.Energy, err = newEnergySource(*energy); err != nil {
+			return asConfigError(err)
+		}
+	}
+	if opts.ColdRestart && !*manageContainers {
+		return configErrorf("-cold-restart requires -manage-containers")
+	}
+	restartHook := strings.TrimSpace(*coldHookFlag) == "restart"
+	if restartHook && (!*manageContainers || *kubernetesMode) {
+		return configErrorf("-cold-hook restart requires -manage-containers and is not supported with -kubernetes")
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     16,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Records in second half",
-	})
-	fmt.Println("[INFO] Done with query 16")
 
-	// Query 17: Hourly user activity patterns
-	fmt.Println("[INFO] Running query 17: Hourly user activity patterns")
-	start = time.Now()
-	_, err = conn.Query("SELECT toHour(timestamp) as hour, COUNT(*) as count FROM user_events GROUP BY hour ORDER BY hour")
-	if err != nil {
-		return err
+	if *kubernetesMode {
+		if *manageContainers {
+			return configErrorf("-kubernetes and -manage-containers are mutually exclusive")
+		}
+		if kubernetesLauncher == nil {
+			return configErrorf("-kubernetes is not available in a binary built with the nokubernetes tag")
+		}
+		if opts.ColdRestart {
+			return configErrorf("-cold-restart is not supported with -kubernetes")
+		}
+		kubeSettings = kubeOptions{Namespace: *kubeNamespace, Kubeconfig: *kubeconfig, StartTimeout: *kubeStartTimeout}
+		launchDatabase = kubernetesLauncher
+		*manageContainers = true
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     17,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Hourly user activity patterns",
-	})
-	fmt.Println("[INFO] Done with query 17")
 
-	// Query 18: Daily RSSI variance
-	fmt.Println("[INFO] Running query 18: Daily RSSI variance")
-	start = time.Now()
-	_, err = conn.Query("SELECT toStartOfDay(timestamp) as day, varSamp(rssi) as rssi_variance FROM user_events GROUP BY day ORDER BY day LIMIT 30")
-	if err != nil {
-		return err
+	limits := containerLimits{
+		Cpus:         *containerCpus,
+		Memory:       *containerMemory,
+		Image:        *containerImage,
+		ServerTiming: *serverTiming,
+		Durability:   *durability,
+	}
+	if *noCreate && (*manageContainers || *matrixFile != "" || *scenario != "" || *dryRun) {
+		return configErrorf("-no-create runs the benchmark against a pre-provisioned database and cannot be combined with managed databases, -matrix, -scenario or -dry-run")
+	}
+	if topology != nil && (*manageContainers || *matrixFile != "" || *scenario != "" || *dryRun) {
+		return configErrorf("-topology runs the benchmark against an existing cluster and cannot be combined with managed databases, -matrix, -scenario or -dry-run")
+	}
+	if *coordinatorAddr != "" || *workerOf != "" {
+		if *coordinatorAddr != "" && *workerOf != "" {
+			return configErrorf("-coordinator and -worker are mutually exclusive")
+		}
+		if *matrixFile != "" || *scenario != "" || *dryRun || topology != nil {
+			return configErrorf("a distributed ingestion cannot be combined with -matrix, -scenario, -dry-run or -topology")
+		}
+		if opts.Scale > 1 || opts.Warmup.enabled() || len(opts.SizeSweep) > 0 {
+			return configErrorf("a distributed ingestion cannot be combined with -scale above 1, a warm-up or -size-sweep")
+		}
+		if *workers < 1 {
+			return configErrorf("-workers must be at least 1")
+		}
+		if *workerTimeout < 2*workerHeartbeat {
+			return configErrorf("-worker-timeout must be at least %s, two heartbeats of a worker", 2*workerHeartbeat)
+		}
+		if *workerOf != "" && *manageContainers {
+			return configErrorf("-worker ingests into the database of the coordinator and cannot be combined with -manage-containers")
+		}
+	}
+	if *matrixFile != "" {
+		if !*manageContainers {
+			return configErrorf("-matrix requires -manage-containers or -kubernetes, so that every run starts from an empty database")
+		}
+		return runMatrix(*matrixFile, opts, limits)
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     18,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Daily RSSI variance",
-	})
-	fmt.Println("[INFO] Done with query 18")
 
-	// Query 19: Peak usage hours
-	fmt.Println("[INFO] Running query 19: Peak usage hours")
-	start = time.Now()
-	_, err = conn.Query("SELECT toStartOfHour(timestamp) as hour, COUNT(*) as count FROM user_events GROUP BY hour ORDER BY count DESC LIMIT 5")
-	if err != nil {
-		return err
+	info, ok := backends[*dbType]
+	if !ok {
+		return configErrorf("unsupported database type: %s", *dbType)
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     19,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "Peak usage hours",
-	})
-	fmt.Println("[INFO] Done with query 19")
+	if err := opts.checkBackend(info); err != nil {
+		return asConfigError(err)
+	}
+	if info.durabilityByServer(opts.Durability) && !*manageContainers {
+		return configErrorf("-durability %s is a server setting of %s and requires -manage-containers or -kubernetes", opts.Durability, info.name)
+	}
+	durabilityParams = info.durability.params[opts.Durability]
 
-	// Query 20: User session duration analysis
-	fmt.Println("[INFO] Running query 20: User session duration analysis")
-	start = time.Now()
-	_, err = conn.Query("SELECT user_id, MAX(timestamp) - MIN(timestamp) as session_duration FROM user_events GROUP BY user_id ORDER BY session_duration DESC LIMIT 10")
-	if err != nil {
-		return err
+	if *manageContainers {
+		container, err := launchDatabase(*dbType, limits)
+		if err != nil {
+			return err
+		}
+		defer container.remove()
+		opts.Container = container
+	}
+	if *coldHookFlag != "" {
+		if opts.ColdHook, err = newColdHook(*coldHookFlag, *coldHookEvery, opts.Container, *dbType, *connStr, *readyTimeout); err != nil {
+			return asConfigError(err)
+		}
 	}
-	results.Queries = append(results.Queries, QueryResult{
-		QueryId:     20,
-		DurationMs:  time.Since(start).Milliseconds(),
-		Description: "User session duration analysis",
-	})
-	fmt.Println("[INFO] Done with query 20")
 
-	results.DbType = "clickhouse"
-	out, err := os.Create(outFile)
+	infof("Waiting for the database to become ready\n")
+	if topology != nil {
+		err = topology.waitForNodes(*dbType, *readyTimeout)
+	} else {
+		err = waitForDatabase(*dbType, *connStr, *readyTimeout)
+	}
 	if err != nil {
 		return err
 	}
-
-	defer out.Close()
-	if err := json.NewEncoder(out).Encode(results); err != nil {
+	if err := interrupted(); err != nil {
 		return err
 	}
-	return nil
-}
 
-func main() {
-	connStr := flag.String("conn", "", "Database connection string")
-	outputFile := flag.String("o", "", "Output file name")
-	dbType := flag.String("type", "", "Database type: postgres, timescaledb, questdb, cratedb, clickhouse, or influxdb")
-	flag.Parse()
+	if *coordinatorAddr != "" {
+		return runCoordinator(info, *connStr, *outputFile, opts, *coordinatorAddr, *workers, *workerTimeout)
+	}
+	if *workerOf != "" {
+		return runWorker(info, *connStr, opts, *workerOf)
+	}
 
-	if *connStr == "" || *dbType == "" || *outputFile == "" {
-		flag.Usage()
-		return
+	if *dryRun {
+		return runDryRun(info, *connStr, *outputFile)
 	}
 
-	if *dbType == "postgres" {
-		if err := benchmarkPostgres(*connStr, *outputFile); err != nil {
-			panic(err)
+	if *scenario != "" {
+		var err error
+		switch *scenario {
+		case "time-to-insight":
+			err = benchmarkTimeToInsight(*dbType, *connStr, *outputFile)
+		case "read-your-writes":
+			err = benchmarkReadYourWrites(*dbType, *connStr, *outputFile, *rywProbes, *rywTimeout)
+		case "compression":
+			err = benchmarkCompression(info, *connStr, *outputFile, opts)
+		case "continuous-aggregates":
+			err = benchmarkContinuousAggregates(info, *connStr, *outputFile, opts)
+		case "duplicates":
+			err = benchmarkDuplicates(info, *connStr, *outputFile, opts, *duplicatePercent)
+		case "downsampling":
+			err = benchmarkDownsampling(info, *connStr, *outputFile, opts)
+		case "buildings":
+			err = benchmarkBuildings(info, *connStr, *outputFile, opts)
+		case "locations":
+			err = benchmarkLocations(info, *connStr, *outputFile, opts)
+		case "single-row":
+			err = benchmarkSingleRow(info, *connStr, *outputFile, opts, *singleRowWriters, *singleRowInserts)
+		case "connection-churn":
+			err = benchmarkConnectionChurn(info, *connStr, *outputFile, opts, *churnRate, *churnOperations)
+		case "native-partitions":
+			err = benchmarkNativePartitions(info, *connStr, *outputFile, opts)
 		}
-	} else if *dbType == "timescaledb" {
-		if err := benchmarkTimescaleDb(*connStr, *outputFile); err != nil {
-			panic(err)
-		}
-	} else if *dbType == "questdb" {
-		if err := benchmarkQuestDb(*connStr, *outputFile); err != nil {
-			panic(err)
-		}
-	} else if *dbType == "cratedb" {
-		if err := benchmarkCrateDB(*connStr, *outputFile); err != nil {
-			panic(err)
-		}
-	} else if *dbType == "clickhouse" {
-		if err := benchmarkClickHouse(*connStr, *outputFile); err != nil {
-			panic(err)
-		}
-	} else if *dbType == "influxdb" {
-		if err := benchmarkInfluxDB(*connStr, *outputFile); err != nil {
-			panic(err)
-		}
-	} else {
-		panic("Unsupported database type: " + *dbType)
+		return err
 	}
+
+	return runBenchmark(info, *connStr, *outputFile, opts)
 }
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Exit codes of the binary, so scripts can tell a mistake in the invocation,
+// which fails the same way on every retry, from a run that broke down.
+const (
+	// exitFailure is a benchmark that failed while running, e.g. a database
+	// that never became ready or a write that kept failing.
+	exitFailure = 1
+	// exitConfig is an invalid flag, option combination or configuration
+	// file; nothing was started.
+	exitConfig = 2
+	// exitPanic is a bug of the tool.
+	exitPanic = 3
+	// exitSLO is a run that completed and wrote its results but missed
+	// service level objectives of -slo.
+	exitSLO = 4
+	// exitInterrupted is a run stopped by SIGINT or SIGTERM, 128 + SIGINT
+	// like a shell reports it.
+	exitInterrupted = 130
+)
+
+// configError marks an error of the invocation rather than of the run.
+type configError struct {
+	err error
+}
+
+func (e *configError) Error() string {
+	return e.err.Error()
+}
+
+func (e *configError) Unwrap() error {
+	return e.err
+}
+
+// configErrorf reports an invalid invocation.
+func configErrorf(format string, args ...any) error {
+	return &configError{err: fmt.Errorf(format, args...)}
+}
+
+// asConfigError marks err, when there is one, as an error of the invocation.
+func asConfigError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &configError{err: err}
+}
+
+// exitOnError reports the error that ended main, with the secrets redacted,
+// and exits with the code of its kind.
+func exitOnError(err error) {
+	if err == nil {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "[ERROR] %s\n", redact(err.Error()))
+	os.Exit(exitCode(err))
+}
+
+// exitCode is the exit code of the kind of err, 0 for none.
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var cfgErr *configError
+	if errors.As(err, &cfgErr) {
+		return exitConfig
+	}
+	if errors.Is(err, errInterrupted) {
+		return exitInterrupted
+	}
+	var violation *sloViolation
+	if errors.As(err, &violation) {
+		return exitSLO
+	}
+	return exitFailure
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// queryPlanner is implemented by backends that can return the output of an
+// EXPLAIN statement. The statement, including the EXPLAIN prefix of the
+// dialect, is built by the caller from backendInfo.explainPrefix.
+type queryPlanner interface {
+	plan(ctx context.Context, stmt string, args ...any) (string, error)
+}
+
+// capturePlan explains a catalog query after it has been timed. ANALYZE variants
+// execute the query once more, so this never runs inside the measured window.
+// A failing EXPLAIN only loses the plan, it does not fail the benchmark.
+func capturePlan(ctx context.Context, info backendInfo, b backend, q querySpec, bounds queryBounds) string {
+	planner, ok := b.(queryPlanner)
+	if !ok || info.explainPrefix == "" {
+		return ""
+	}
+
+	plan, err := planner.plan(ctx, info.explainPrefix+q.text, q.arguments(bounds)...)
+	if err != nil {
+		fmt.Printf("[WARN] Failed to capture the plan of query %d: %v\n", q.id, err)
+		return ""
+	}
+	return plan
+}
+
+// formatPlanRow renders one row of EXPLAIN output. Most engines return one text
+// column per line of the plan; CrateDB returns the analyzed plan as an object,
+// which is kept as JSON.
+func formatPlanRow(values []any) string {
+	columns := make([]string, len(values))
+	for i, value := range values {
+		if text, ok := value.(string); ok {
+			columns[i] = text
+			continue
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			columns[i] = fmt.Sprint(value)
+		} else {
+			columns[i] = string(encoded)
+		}
+	}
+	return strings.Join(columns, "\t")
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+type ExportResult struct {
+	DurationMs int64 `json:"durationMs"`
+	// Bytes is the size of an export streamed to the client; it is omitted
+	// for exports the server writes to its own disk.
+	Bytes int64 `json:"bytes,omitempty"`
+}
+
+// exportingBackend is implemented by backends that can export user_events in
+// full, with the engine's own export mechanism where it has one. Client-side
+// exports are written to w; server-side exports leave it untouched.
+type exportingBackend interface {
+	export(ctx context.Context, w io.Writer) error
+}
+
+// byteCounter discards what is written to it and counts the bytes, so the
+// client's disk does not weigh in on the export time.
+type byteCounter struct {
+	n int64
+}
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
+
+// runExportPhase times a full export of the ingested data.
+func runExportPhase(ctx context.Context, b backend) (*ExportResult, error) {
+	exporter, ok := b.(exportingBackend)
+	if !ok {
+		return nil, fmt.Errorf("backend does not support exporting data")
+	}
+
+	infof("Exporting the full dataset\n")
+	var counter byteCounter
+	start := time.Now()
+	if err := exporter.export(ctx, &counter); err != nil {
+		return nil, err
+	}
+	result := &ExportResult{DurationMs: time.Since(start).Milliseconds(), Bytes: counter.n}
+	infof("Exported %d bytes in %d ms\n", result.Bytes, result.DurationMs)
+	return result, nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The metric sinks push the per-query durations of every run as labelled
+// metrics, so existing Grafana dashboards can chart the benchmark history from
+// Prometheus or InfluxDB. Queries a backend did not run (-1) are left out.
+
+// runId labels the metrics of one run; runs are told apart by the second they
+// were recorded in and their pass.
+func (r runRecord) runId() string {
+	return strconv.FormatInt(r.RecordedAt.Unix(), 10) + "-" + strconv.Itoa(r.Results.Pass)
+}
+
+var metricsClient = &http.Client{Timeout: 30 * time.Second}
+
+// sendMetrics sends body to url and fails on any non-2xx answer.
+func sendMetrics(ctx context.Context, method string, url string, header http.Header, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	resp, err := metricsClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s: %s: %s", url, resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// pushgatewaySink pushes the run to a Prometheus Pushgateway, grouped by
+// database and run so that runs do not overwrite each other.
+type pushgatewaySink struct {
+	baseURL string
+}
+
+func (s *pushgatewaySink) publish(ctx context.Context, run runRecord) error {
+	var body bytes.Buffer
+	pass := strconv.Itoa(run.Results.Pass)
+
+	fmt.Fprintln(&body, "# TYPE smartcampus_query_duration_ms gauge")
+	for _, query := range run.Results.Queries {
+		if query.DurationMs < 0 {
+			continue
+		}
+		fmt.Fprintf(&body, "smartcampus_query_duration_ms{query_id=\"%d\",pass=\"%s\"} %d\n", query.QueryId, pass, query.DurationMs)
+	}
+	if records, durationMs := run.ingestionTotals(); len(run.Results.Ingestion) > 0 {
+		fmt.Fprintln(&body, "# TYPE smartcampus_ingestion_records gauge")
+		fmt.Fprintf(&body, "smartcampus_ingestion_records{pass=\"%s\"} %d\n", pass, records)
+		fmt.Fprintln(&body, "# TYPE smartcampus_ingestion_duration_ms gauge")
+		fmt.Fprintf(&body, "smartcampus_ingestion_duration_ms{pass=\"%s\"} %d\n", pass, durationMs)
+	}
+
+	target := s.baseURL + "/metrics/job/smartcampus_benchmark/db/" + url.PathEscape(run.Results.DbType) + "/run_id/" + run.runId()
+	header := http.Header{"Content-Type": {"text/plain; version=0.0.4"}}
+	return sendMetrics(ctx, http.MethodPut, target, header, body.Bytes())
+}
+
+func (s *pushgatewaySink) close() {}
+
+// metricTagEscaper escapes tag values for the line protocol.
+var metricTagEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+
+// influxMetricsSink writes the run as line protocol to an InfluxDB write
+// endpoint, either /write?db=... of 1.x or /api/v2/write?org=...&bucket=...
+// of 2.x. A token query parameter is sent as the Authorization header.
+type influxMetricsSink struct {
+	writeURL string
+	token    string
+}
+
+func newInfluxMetricsSink(target string) (*influxMetricsSink, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	query := u.Query()
+	token := query.Get("token")
+	query.Del("token")
+	query.Set("precision", "s")
+	u.RawQuery = query.Encode()
+	return &influxMetricsSink{writeURL: u.String(), token: token}, nil
+}
+
+func (s *influxMetricsSink) publish(ctx context.Context, run runRecord) error {
+	var body bytes.Buffer
+	tags := fmt.Sprintf("db=%s,run_id=%s,pass=%d", metricTagEscaper.Replace(run.Results.DbType), run.runId(), run.Results.Pass)
+	at := run.RecordedAt.Unix()
+
+	for _, query := range run.Results.Queries {
+		if query.DurationMs < 0 {
+			continue
+		}
+		fmt.Fprintf(&body, "benchmark_query,%s,query_id=%d duration_ms=%di %d\n", tags, query.QueryId, query.DurationMs, at)
+	}
+	if records, durationMs := run.ingestionTotals(); len(run.Results.Ingestion) > 0 {
+		fmt.Fprintf(&body, "benchmark_ingestion,%s records=%di,duration_ms=%di %d\n", tags, records, durationMs, at)
+	}
+	if body.Len() == 0 {
+		return nil
+	}
+
+	header := http.Header{"Content-Type": {"text/plain; charset=utf-8"}}
+	if s.token != "" {
+		header.Set("Authorization", "Token "+s.token)
+	}
+	return sendMetrics(ctx, http.MethodPost, s.writeURL, header, body.Bytes())
+}
+
+func (s *influxMetricsSink) close() {}
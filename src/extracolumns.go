@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	"src/results"
+)
+
+// extraColumnsConfig is the -extra-columns file: optional fields of the
+// readings, e.g. the device type and OS of newer exports, stored in columns of
+// their own next to the fixed ones, so a new field needs no code change.
+//
+//	columns:
+//	  - name: device_type
+//	    field: deviceType
+//	    type: text
+type extraColumnsConfig struct {
+	Columns []extraColumn `json:"columns"`
+}
+
+// extraColumn maps the JSON key field of a reading to a column. A reading
+// without the key stores NULL.
+type extraColumn struct {
+	Name string `json:"name"`
+	// Field is the key in the reading objects, or the dot separated path of a
+	// nested one; it defaults to Name.
+	Field string `json:"field"`
+	// Type is text, integer or real.
+	Type string `json:"type"`
+	// Influx is tag or field: whether InfluxDB indexes the column as a tag or
+	// stores it as a field. It defaults to tag for text and field for numbers.
+	// The readings are aggregated by the tag columns on every backend.
+	Influx string `json:"influx"`
+}
+
+const (
+	extraText    = "text"
+	extraInteger = "integer"
+	extraReal    = "real"
+)
+
+const (
+	influxTag   = "tag"
+	influxField = "field"
+)
+
+// isTag reports whether the column is a tag: an indexed dimension the readings
+// are aggregated by.
+func (c extraColumn) isTag() bool {
+	return c.Influx == influxTag || c.Influx == "" && c.Type == extraText
+}
+
+// extraColumns is set from -extra-columns with setExtraColumns; empty when the
+// readings only have the fixed columns.
+var extraColumns []extraColumn
+
+// noExtraValues is written for readings that did not come from the dataset,
+// e.g. the read-your-writes probes.
+var noExtraValues []any
+
+func setExtraColumns(columns []extraColumn) {
+	extraColumns = columns
+	noExtraValues = make([]any, len(columns))
+}
+
+// extraColumnDialect adds the columns of -extra-columns to user_events and
+// aggregates the readings by them.
+type extraColumnDialect struct {
+	// addColumn is the statement adding a column, formatted with its name and
+	// type; empty when the engine creates columns on their first write.
+	addColumn string
+	// types maps text, integer and real to the engine's column types.
+	types map[string]string
+	// groupBy renders the aggregation of the readings by a tag column.
+	groupBy func(column string) string
+}
+
+type ExtraColumnsResult struct {
+	Columns []string `json:"columns"`
+	// AddColumnsMs is the time the columns took to add after the schema.
+	AddColumnsMs int64         `json:"addColumnsMs,omitempty"`
+	Queries      []QueryResult `json:"queries,omitempty"`
+}
+
+// sqlExtraColumnTypes are the column types of the PostgreSQL-like dialects.
+var sqlExtraColumnTypes = map[string]string{extraText: "TEXT", extraInteger: "BIGINT", extraReal: "DOUBLE PRECISION"}
+
+// sqlGroupByExtra renders the aggregation by an extra column of the SQL
+// dialects; distinct counts the distinct user ids.
+func sqlGroupByExtra(distinct string) func(column string) string {
+	return func(column string) string {
+		return fmt.Sprintf("SELECT %s, COUNT(*) AS count, %s, AVG(rssi) FROM user_events GROUP BY %s ORDER BY count DESC", column, distinct, column)
+	}
+}
+
+// sqlExtraColumns adds the extra columns of the PostgreSQL-like dialects.
+var sqlExtraColumns = extraColumnDialect{
+	addColumn: "ALTER TABLE user_events ADD COLUMN %s %s",
+	types:     sqlExtraColumnTypes,
+	groupBy:   sqlGroupByExtra("COUNT(DISTINCT user_id)"),
+}
+
+var extraColumnName = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// fixedColumns are taken by the readings themselves.
+var fixedColumns = []string{"id", "user_id", "timestamp", "ts", "time", "rssi", "ssid"}
+
+func readExtraColumns(path string) ([]extraColumn, error) {
+	encoded, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg extraColumnsConfig
+	if err := yaml.UnmarshalStrict(encoded, &cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(cfg.Columns) == 0 {
+		return nil, fmt.Errorf("%s: no columns", path)
+	}
+	return checkExtraColumns(path, cfg.Columns)
+}
+
+// checkExtraColumns validates the columns of the file at path and fills in
+// their defaults.
+func checkExtraColumns(path string, columns []extraColumn) ([]extraColumn, error) {
+	var names []string
+	for i, column := range columns {
+		if !extraColumnName.MatchString(column.Name) {
+			return nil, fmt.Errorf("%s: column name %q must be lower case letters, digits and underscores", path, column.Name)
+		}
+		if slices.Contains(fixedColumns, column.Name) || slices.Contains(names, column.Name) {
+			return nil, fmt.Errorf("%s: column %s is already taken", path, column.Name)
+		}
+		if column.Type != extraText && column.Type != extraInteger && column.Type != extraReal {
+			return nil, fmt.Errorf("%s: column %s has type %q, expected text, integer or real", path, column.Name, column.Type)
+		}
+		if column.Influx != "" && column.Influx != influxTag && column.Influx != influxField {
+			return nil, fmt.Errorf("%s: column %s is an InfluxDB %q, expected tag or field", path, column.Name, column.Influx)
+		}
+		if column.Field == "" {
+			columns[i].Field = column.Name
+		}
+		names = append(names, column.Name)
+	}
+	return columns, nil
+}
+
+// extraColumnNames returns the names of the extra columns in order.
+func extraColumnNames() []string {
+	names := make([]string, len(extraColumns))
+	for i, column := range extraColumns {
+		names[i] = column.Name
+	}
+	return names
+}
+
+// extraInsertColumns returns what the extra columns add to the column list
+// and the VALUES of an INSERT whose fixed columns take the first n
+// parameters; numbered renders $n placeholders instead of ?.
+func extraInsertColumns(n int, numbered bool) (columns string, placeholders string) {
+	for i, column := range extraColumns {
+		columns += ", " + column.Name
+		if numbered {
+			placeholders += fmt.Sprintf(", $%d", n+i+1)
+		} else {
+			placeholders += ", ?"
+		}
+	}
+	return columns, placeholders
+}
+
+// extraValues returns the values of the extra columns of a reading, in the
+// order of extraColumns.
+func extraValues(reading *Reading) []any {
+	if len(reading.Extra) == len(extraColumns) {
+		return reading.Extra
+	}
+	return noExtraValues
+}
+
+// extraTag returns an extra value as the text of a tag; numbers are written
+// the way JSON writes them.
+func extraTag(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
+
+// decodeExtraFields decodes the extra fields of the readings of a chunk, a
+// second pass over the JSON that is only made under -extra-columns.
+func decodeExtraFields(encoded []byte, readings []Reading) error {
+	var file struct {
+		Response []map[string]json.RawMessage `json:"response"`
+	}
+	if err := json.Unmarshal(encoded, &file); err != nil {
+		return err
+	}
+	if len(file.Response) != len(readings) {
+		return fmt.Errorf("decoded %d readings but %d with extra fields", len(readings), len(file.Response))
+	}
+	for i, fields := range file.Response {
+		values, err := extraRecordValues(&jsonRecord{fields: fields})
+		if err != nil {
+			return fmt.Errorf("reading %d: %w", i, err)
+		}
+		readings[i].Extra = values
+	}
+	return nil
+}
+
+// extraRecordValues returns the values of the extra columns of a reading
+// object, nil where it has no value.
+func extraRecordValues(record *jsonRecord) ([]any, error) {
+	values := make([]any, len(extraColumns))
+	for j, column := range extraColumns {
+		raw, ok := record.lookup(column.Field)
+		if !ok {
+			continue
+		}
+		value, err := extraValue(column, raw)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", column.Field, err)
+		}
+		values[j] = value
+	}
+	return values, nil
+}
+
+// textValue returns a JSON string as it is, and numbers and booleans as they
+// are written.
+func textValue(raw json.RawMessage) string {
+	var text string
+	if err := json.Unmarshal(raw, &text); err != nil {
+		return string(raw)
+	}
+	return text
+}
+
+// extraValue converts a JSON value to the type of its column.
+func extraValue(column extraColumn, raw json.RawMessage) (any, error) {
+	switch column.Type {
+	case extraText:
+		return textValue(raw), nil
+	case extraInteger:
+		var number json.Number
+		if err := json.Unmarshal(raw, &number); err != nil {
+			return nil, err
+		}
+		return strconv.ParseInt(number.String(), 10, 64)
+	default:
+		var number float64
+		if err := json.Unmarshal(raw, &number); err != nil {
+			return nil, err
+		}
+		return number, nil
+	}
+}
+
+// addExtraColumns adds the extra columns to the freshly created user_events.
+func addExtraColumns(ctx context.Context, info backendInfo, b backend) (time.Duration, error) {
+	start := time.Now()
+	if info.extraColumns.addColumn == "" {
+		return 0, nil
+	}
+	for _, column := range extraColumns {
+		stmt := fmt.Sprintf(info.extraColumns.addColumn, column.Name, info.extraColumns.types[column.Type])
+		if err := b.exec(ctx, stmt); err != nil {
+			return 0, fmt.Errorf("adding column %s: %w", column.Name, err)
+		}
+	}
+	return time.Since(start), nil
+}
+
+// runExtraColumnQueries aggregates the readings by every tag column of
+// -extra-columns: records, distinct users and average RSSI per value.
+func runExtraColumnQueries(ctx context.Context, info backendInfo, b backend, opts benchmarkOptions, result *ExtraColumnsResult) error {
+	id := 0
+	for _, column := range extraColumns {
+		if !column.isTag() {
+			continue
+		}
+		id++
+		q := querySpec{id: id, text: info.extraColumns.groupBy(column.Name)}
+		description := "Records, distinct users and average RSSI per " + column.Name
+
+		infof("Running extra column query %d: %s\n", id, description)
+		samples, err := repeatQuery(opts.QueryRepeats, func() error {
+			return b.query(ctx, q.text)
+		})
+		duration := results.MedianMs(samples)
+		if err != nil {
+			if !info.lenientQueries {
+				return err
+			}
+			duration = -1
+			samples = nil
+		}
+
+		query := QueryResult{QueryId: id, DurationMs: duration, Description: description}
+		if len(samples) > 1 {
+			query.SamplesMs = samples
+		}
+		result.Queries = append(result.Queries, query)
+	}
+	return nil
+}
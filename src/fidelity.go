@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"time"
+)
+
+type FidelityResult struct {
+	Samples int `json:"samples"`
+	// Missing readings have no row with their user and timestamp.
+	Missing int `json:"missing"`
+	// Mismatched readings have rows with their user and timestamp, but none
+	// with their RSSI and SSID.
+	Mismatched int     `json:"mismatched"`
+	LossRate   float64 `json:"lossRate"`
+}
+
+// storedReading is a row returned by a fidelity lookup.
+type storedReading struct {
+	Rssi float64
+	Ssid string
+}
+
+// readingLookup is implemented by backends that can fetch the rows of one user
+// at one timestamp, so that sampled source readings can be checked against
+// what the database kept.
+type readingLookup interface {
+	lookupReading(ctx context.Context, userId string, at time.Time) ([]storedReading, error)
+}
+
+// rssiTolerance absorbs the rounding of engines that store RSSI as a 32-bit
+// float.
+const rssiTolerance = 1e-3
+
+// sampleReadings picks n readings at random from the first pass over the
+// measured chunks, with the cardinality multiplication of the ingestion. The
+// sample only depends on opts.Seed, so every database is checked against the
+// same readings.
+// Replays are left out, so the timestamps are the ones of the source files.
+func sampleReadings(startChunk int, n int, opts benchmarkOptions) ([]Reading, error) {
+	files, err := os.ReadDir(readingsDir)
+	if err != nil {
+		return nil, err
+	}
+	end := min(len(files), scaledChunkCount(len(files), opts.Scale))
+	if startChunk >= end {
+		return nil, fmt.Errorf("no measured chunks to sample readings from")
+	}
+
+	rng := rand.New(rand.NewPCG(opts.Seed, uint64(n)))
+	perChunk := map[int]int{}
+	for i := 0; i < n; i++ {
+		perChunk[startChunk+rng.IntN(end-startChunk)]++
+	}
+
+	var samples []Reading
+	chunks := make([]int, 0, len(perChunk))
+	for chunk := range perChunk {
+		chunks = append(chunks, chunk)
+	}
+	slices.Sort(chunks)
+	for _, chunk := range chunks {
+		_, data, err := loadDataChunk(readingsDir, chunk)
+		if err != nil {
+			return nil, err
+		}
+		if len(data.Response) == 0 {
+			continue
+		}
+		multiplyCardinality(data.Response, opts.CardinalityFactor)
+		for i := 0; i < perChunk[chunk]; i++ {
+			samples = append(samples, data.Response[rng.IntN(len(data.Response))])
+		}
+	}
+	return samples, nil
+}
+
+// runFidelityAudit looks up a random sample of the ingested readings and
+// counts the ones the database lost or altered. Asynchronous writers report a
+// successful write before the points are stored, so a lost point would not
+// show up in the ingestion results.
+func runFidelityAudit(ctx context.Context, b backend, startChunk int, opts benchmarkOptions) (*FidelityResult, error) {
+	lookup, ok := b.(readingLookup)
+	if !ok {
+		return nil, fmt.Errorf("backend does not support looking up readings")
+	}
+
+	samples, err := sampleReadings(startChunk, opts.FidelitySamples, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	infof("Auditing %d sampled readings\n", len(samples))
+	result := &FidelityResult{Samples: len(samples)}
+	for _, reading := range samples {
+		rows, err := lookup.lookupReading(ctx, reading.UserId, readingTime(reading.LastUpdatedTime))
+		if err != nil {
+			return nil, err
+		}
+		matches := slices.ContainsFunc(rows, func(row storedReading) bool {
+			return row.Ssid == reading.Connection.Ssid && math.Abs(row.Rssi-reading.Connection.Rssi) <= rssiTolerance
+		})
+		switch {
+		case len(rows) == 0:
+			result.Missing++
+			fmt.Printf("[WARN] Reading of %s at %d is missing\n", reading.UserId, reading.LastUpdatedTime)
+		case !matches:
+			result.Mismatched++
+			fmt.Printf("[WARN] Reading of %s at %d is stored with a different RSSI or SSID\n", reading.UserId, reading.LastUpdatedTime)
+		}
+	}
+	if result.Samples > 0 {
+		result.LossRate = float64(result.Missing+result.Mismatched) / float64(result.Samples)
+	}
+
+	infof("Fidelity audit: %d missing, %d mismatched of %d samples\n", result.Missing, result.Mismatched, result.Samples)
+	return result, nil
+}
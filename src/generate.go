@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// generateStream is the stream of the -seed PCG the synthetic readings are
+// drawn from.
+const generateStream = 0x67656e657261
+
+// runGenerate is the generate subcommand: it writes a synthetic readings
+// directory in the layout of the dataset, readings_N.json with the readings
+// in time order, to try the benchmark without the campus data or to size a
+// run beyond it. Users and access points are drawn from a Zipf distribution,
+// so that a few of them hold most of the readings and the top-k queries have
+// a clear answer.
+func runGenerate(flags *flag.FlagSet, args []string) error {
+	outDir := flags.String("o", "", "Directory to write the readings_N.json chunks to; created when missing")
+	chunks := flags.Int("chunks", 27, "Number of chunk files")
+	records := flags.Int("records", 10000, "Readings per chunk")
+	users := flags.Int("users", 1000, "Number of distinct users")
+	ssids := flags.Int("ssids", 50, "Number of distinct access points")
+	start := flags.String("start", "2024-01-01T00:00:00Z", "Timestamp of the first reading, RFC 3339")
+	interval := flags.Duration("interval", time.Second, "Mean time between two readings; the gaps are exponentially distributed")
+	seed := flags.Uint64("seed", 1, "Seed of the generator; the same seed and flags write the same files")
+	if err := parseCommandFlags(flags, args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return asConfigError(err)
+	}
+	if *outDir == "" {
+		return configErrorf("-o is required")
+	}
+	if *chunks < 1 || *records < 1 || *users < 1 || *ssids < 1 {
+		return configErrorf("-chunks, -records, -users and -ssids must be at least 1")
+	}
+	if *interval <= 0 {
+		return configErrorf("-interval must be positive")
+	}
+	at, err := time.Parse(time.RFC3339, *start)
+	if err != nil {
+		return configErrorf("-start: %v", err)
+	}
+	if err := os.MkdirAll(*outDir, 0755); err != nil {
+		return err
+	}
+
+	rng := rand.New(rand.NewPCG(*seed, generateStream))
+	userIds := rand.NewZipf(rng, 1.1, 1, uint64(*users-1))
+	ssidIds := rand.NewZipf(rng, 1.1, 1, uint64(*ssids-1))
+	for chunk := 0; chunk < *chunks; chunk++ {
+		if err := interrupted(); err != nil {
+			return err
+		}
+		file := ReadingFile{Response: make([]Reading, *records)}
+		for i := range file.Response {
+			at = at.Add(time.Duration(rng.ExpFloat64() * float64(*interval)))
+			reading := &file.Response[i]
+			reading.UserId = fmt.Sprintf("user-%d", userIds.Uint64())
+			reading.LastUpdatedTime = int(at.Unix())
+			reading.Connection.Ssid = fmt.Sprintf("ap-%d", ssidIds.Uint64())
+			// RSSI is normal around -65 dBm, clamped to [-100, -20].
+			reading.Connection.Rssi = float64(max(-100, min(-20, int(-65+12*rng.NormFloat64()))))
+		}
+		encoded, err := json.Marshal(file)
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(*outDir, fmt.Sprintf("readings_%d.json", chunk))
+		if err := os.WriteFile(path, encoded, 0644); err != nil {
+			return err
+		}
+	}
+	infof("Wrote %d chunks of %d readings to %s, up to %s\n", *chunks, *records, *outDir, at.UTC().Format(time.RFC3339))
+	return nil
+}
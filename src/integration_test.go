@@ -0,0 +1,324 @@
+//go:build integration
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/go-connections/nat"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// The integration tests start every backend's pinned image with
+// testcontainers, run the benchmark end-to-end on a tiny generated dataset and
+// check both the result file and what the database stored. They need a Docker
+// daemon and run with:
+//
+//	go test -tags integration -run TestIntegration ./...
+
+// integrationTarget is a backend under test. connStr builds the connection
+// string from the address endpoint returns for a container port, and env
+// replaces the container spec's environment when set, e.g. to shrink a heap
+// sized for the full dataset.
+type integrationTarget struct {
+	dbType  string
+	env     []string
+	connStr func(endpoint func(port string) string) string
+}
+
+var integrationTargets = []integrationTarget{
+	{dbType: "postgres", connStr: func(endpoint func(string) string) string {
+		return "postgres://postgres:example@" + endpoint("5432") + "/pdb"
+	}},
+	{dbType: "timescaledb", connStr: func(endpoint func(string) string) string {
+		return "postgres://postgres:example@" + endpoint("5432") + "/tsdb"
+	}},
+	{dbType: "questdb", connStr: func(endpoint func(string) string) string {
+		return "http::addr=" + endpoint("9000") + ";username=admin;password=quest;:::postgres://admin:quest@" + endpoint("8812") + "/qdb"
+	}},
+	{dbType: "questdb-pgwire", connStr: func(endpoint func(string) string) string {
+		return "postgres://admin:quest@" + endpoint("8812") + "/qdb"
+	}},
+	{dbType: "cratedb", env: []string{"CRATE_HEAP_SIZE=1g"}, connStr: func(endpoint func(string) string) string {
+		return "postgres://crate@" + endpoint("5432") + "/crate"
+	}},
+	{dbType: "clickhouse", connStr: func(endpoint func(string) string) string {
+		return endpoint("9000")
+	}},
+	{dbType: "influxdb", connStr: func(endpoint func(string) string) string {
+		return "http://" + endpoint("8086")
+	}},
+	{dbType: "influxdb1", connStr: func(endpoint func(string) string) string {
+		return "http://" + endpoint("8086")
+	}},
+}
+
+const (
+	integrationChunks       = 2
+	integrationChunkRecords = 60
+	// integrationStart is 2024-03-01T00:00:00Z. Readings are 30 minutes apart,
+	// so the dataset covers every hour of the day and no two readings share a
+	// timestamp.
+	integrationStart = 1709251200
+)
+
+// writeIntegrationDataset writes the tiny dataset to dir and returns its
+// readings in order.
+func writeIntegrationDataset(t *testing.T, dir string) []Reading {
+	t.Helper()
+	var all []Reading
+	for chunk := 0; chunk < integrationChunks; chunk++ {
+		var file ReadingFile
+		for i := 0; i < integrationChunkRecords; i++ {
+			n := chunk*integrationChunkRecords + i
+			reading := Reading{
+				UserId:          fmt.Sprintf("user-%d", n%5),
+				LastUpdatedTime: integrationStart + n*1800,
+			}
+			reading.Connection.Ssid = fmt.Sprintf("ap-%d", n%3)
+			reading.Connection.Rssi = -40 - float64(n%50)
+			file.Response = append(file.Response, reading)
+		}
+		encoded, err := json.Marshal(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("readings_%d.json", chunk)), encoded, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		all = append(all, file.Response...)
+	}
+	return all
+}
+
+// startIntegrationContainer runs the backend's container spec and returns the
+// connection string of the started database.
+func startIntegrationContainer(t *testing.T, target integrationTarget) string {
+	t.Helper()
+	ctx := context.Background()
+	spec := backends[target.dbType].container
+
+	env := map[string]string{}
+	specEnv := spec.env
+	if target.env != nil {
+		specEnv = target.env
+	}
+	for _, kv := range specEnv {
+		key, value, _ := strings.Cut(kv, "=")
+		env[key] = value
+	}
+	var exposed []string
+	for _, mapping := range spec.ports {
+		_, port, _ := strings.Cut(mapping, ":")
+		exposed = append(exposed, port+"/tcp")
+	}
+
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        spec.image,
+			ExposedPorts: exposed,
+			Env:          env,
+			Cmd:          spec.args,
+			WaitingFor:   wait.ForListeningPort(nat.Port(exposed[0])).WithStartupTimeout(3 * time.Minute),
+		},
+		Started: true,
+	})
+	testcontainers.CleanupContainer(t, container)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	host, err := container.Host(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	connStr := target.connStr(func(port string) string {
+		mapped, err := container.MappedPort(ctx, nat.Port(port+"/tcp"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return host + ":" + mapped.Port()
+	})
+
+	// The listening port opens before some servers accept queries.
+	if err := waitForDatabase(target.dbType, connStr, 2*time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	return connStr
+}
+
+// eventually retries check until it passes or the timeout expires, for
+// engines that make writes visible asynchronously.
+func eventually(t *testing.T, timeout time.Duration, check func() error) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for {
+		err := check()
+		if err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+func TestIntegration(t *testing.T) {
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+
+	dir := t.TempDir()
+	readingsDir = dir
+	readings := writeIntegrationDataset(t, dir)
+
+	for _, target := range integrationTargets {
+		t.Run(target.dbType, func(t *testing.T) {
+			info, ok := backends[target.dbType]
+			if !ok {
+				t.Skipf("%s is not linked into this build", target.dbType)
+			}
+			connStr := startIntegrationContainer(t, target)
+
+			opts := benchmarkOptions{
+				Retry:             retryPolicy{MaxRetries: 3, Backoff: time.Second},
+				QueryRepeats:      1,
+				Scale:             1,
+				CardinalityFactor: 1,
+				VerifyHours:       info.hourOfDayQuery != "",
+			}
+			outFile := filepath.Join(t.TempDir(), "results.json")
+			if err := runBenchmark(info, connStr, outFile, opts); err != nil {
+				t.Fatal(err)
+			}
+
+			results := readIntegrationResults(t, outFile)
+			checkIntegrationResults(t, info, results, len(readings))
+			checkIntegrationData(t, info, connStr, readings)
+		})
+	}
+}
+
+func readIntegrationResults(t *testing.T, outFile string) BenchmarkResults {
+	t.Helper()
+	encoded, err := os.ReadFile(outFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var results BenchmarkResults
+	if err := json.Unmarshal(encoded, &results); err != nil {
+		t.Fatal(err)
+	}
+	return results
+}
+
+// checkIntegrationResults asserts the structure of the result file: one
+// ingestion result per chunk and one query result per catalog query.
+func checkIntegrationResults(t *testing.T, info backendInfo, results BenchmarkResults, nRecords int) {
+	t.Helper()
+	if results.DbType != info.name {
+		t.Errorf("dbType = %q, want %q", results.DbType, info.name)
+	}
+
+	if len(results.Ingestion) != integrationChunks {
+		t.Fatalf("%d ingestion results, want %d", len(results.Ingestion), integrationChunks)
+	}
+	for i, ingestion := range results.Ingestion {
+		if ingestion.Failed {
+			t.Errorf("chunk %d failed", i)
+		}
+		if want := (i + 1) * integrationChunkRecords; ingestion.NRecords != want {
+			t.Errorf("chunk %d: nRecords = %d, want %d", i, ingestion.NRecords, want)
+		}
+	}
+
+	if len(results.Queries) != len(queryDescriptions)-1 {
+		t.Fatalf("%d query results, want %d", len(results.Queries), len(queryDescriptions)-1)
+	}
+	for i, query := range results.Queries {
+		id := i + 1
+		if query.QueryId != id || query.Description != queryDescriptions[id] {
+			t.Errorf("query result %d is %d %q, want %d %q", i, query.QueryId, query.Description, id, queryDescriptions[id])
+		}
+		_, implemented := info.lookupQuery(id)
+		if implemented && !info.lenientQueries && query.DurationMs < 0 {
+			t.Errorf("query %d: durationMs = %d", id, query.DurationMs)
+		}
+		if !implemented && query.DurationMs != -1 {
+			t.Errorf("query %d is not implemented but has durationMs = %d", id, query.DurationMs)
+		}
+	}
+
+	if results.Reconciliation != nil && results.Reconciliation.Expected != int64(nRecords) {
+		t.Errorf("reconciliation expected = %d, want %d", results.Reconciliation.Expected, nRecords)
+	}
+	if info.hourOfDayQuery != "" && results.HourCheck == nil {
+		t.Error("hour check missing from the results")
+	}
+}
+
+// checkIntegrationData queries the database on a new connection and compares
+// the row count, the time bounds and the hour-of-day buckets with the
+// dataset.
+func checkIntegrationData(t *testing.T, info backendInfo, connStr string, readings []Reading) {
+	t.Helper()
+	ctx := context.Background()
+	b, err := info.open(connStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.close()
+
+	if counter, ok := b.(rowCounter); ok && info.reconciliation.countQuery != "" {
+		eventually(t, 30*time.Second, func() error {
+			for _, stmt := range info.reconciliation.refresh {
+				if err := b.exec(ctx, stmt); err != nil {
+					return err
+				}
+			}
+			n, err := counter.count(ctx, info.reconciliation.countQuery)
+			if err != nil {
+				return err
+			}
+			if n != int64(len(readings)) {
+				return fmt.Errorf("%d rows stored, want %d", n, len(readings))
+			}
+			return nil
+		})
+	}
+
+	if q, ok := info.lookupQuery(1); ok {
+		oldest, newest, err := b.timeBounds(ctx, q.text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantOldest := readingTime(readings[0].LastUpdatedTime)
+		wantNewest := readingTime(readings[len(readings)-1].LastUpdatedTime)
+		if !oldest.Equal(wantOldest) || !newest.Equal(wantNewest) {
+			t.Errorf("time bounds = %s, %s; want %s, %s", oldest, newest, wantOldest, wantNewest)
+		}
+	}
+
+	if counter, ok := b.(hourCounter); ok && info.hourOfDayQuery != "" {
+		var want [24]int64
+		for _, reading := range readings {
+			want[readingTime(reading.LastUpdatedTime).Hour()]++
+		}
+		counts, err := counter.countByHour(ctx, info.hourOfDayQuery)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for hour := range want {
+			if counts[hour] != want[hour] {
+				t.Errorf("hour %02d: %d readings stored, want %d", hour, counts[hour], want[hour])
+			}
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+)
+
+// errInterrupted ends a run that was asked to stop by SIGINT or SIGTERM, e.g.
+// Ctrl-C or the preemption of a batch job. The run stops between two write
+// batches or queries, so the batch in flight is not cut off and the partial
+// results hold only complete measurements.
+var errInterrupted = errors.New("interrupted by a signal")
+
+var interruptRequested atomic.Bool
+
+// watchSignals turns the first SIGINT or SIGTERM into a request to stop and
+// restores the default handling, so a second one kills the process at once.
+func watchSignals() (stop func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-signals:
+			interruptRequested.Store(true)
+			signal.Reset(os.Interrupt, syscall.SIGTERM)
+			fmt.Printf("[WARN] Received %s, stopping after the current batch or query; send it again to abort at once\n", sig)
+		case <-done:
+		}
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+// interrupted returns errInterrupted once a signal asked the run to stop.
+func interrupted() error {
+	if interruptRequested.Load() {
+		return errInterrupted
+	}
+	return nil
+}
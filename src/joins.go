@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"src/results"
+)
+
+// dimensions is the metadata the readings can be joined with: who the users
+// are and in which building every access point stands.
+type dimensions struct {
+	Users        []userDimension        `json:"users"`
+	AccessPoints []accessPointDimension `json:"accessPoints"`
+}
+
+type userDimension struct {
+	UserId     string `json:"userId"`
+	Department string `json:"department"`
+	Role       string `json:"role"`
+}
+
+type accessPointDimension struct {
+	Ssid        string `json:"ssid"`
+	Building    string `json:"building"`
+	FloorNumber int    `json:"floorNumber"`
+	// Latitude and Longitude place the access point for the spatial queries
+	// of the locations scenario; they are optional.
+	Latitude  *float64 `json:"latitude,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty"`
+}
+
+type JoinResult struct {
+	LoadMs       int64         `json:"loadMs"`
+	Users        int           `json:"users"`
+	AccessPoints int           `json:"accessPoints"`
+	Queries      []QueryResult `json:"queries"`
+}
+
+// joinDialect creates the users and access_points dimension tables and joins
+// them with user_events. finalize makes the loaded rows visible to queries on
+// engines that refresh asynchronously. backslashEscapes is set for dialects in
+// which a backslash escapes the next character of a string literal.
+type joinDialect struct {
+	schema           []string
+	finalize         []string
+	backslashEscapes bool
+	queries          []querySpec
+}
+
+// sqlDimensionTables are the dimension tables of the PostgreSQL-like dialects.
+var sqlDimensionTables = []string{
+	"CREATE TABLE IF NOT EXISTS users (user_id TEXT PRIMARY KEY, department TEXT NOT NULL, role TEXT NOT NULL)",
+	"CREATE TABLE IF NOT EXISTS access_points (ssid TEXT PRIMARY KEY, building TEXT NOT NULL, floor_number INTEGER NOT NULL)",
+}
+
+var joinQueryDescriptions = []string{
+	1: "Records per building",
+	2: "Hourly distinct users per building over 24 hours from middle time",
+	3: "Average RSSI per department and building",
+}
+
+// loadDimensions returns the dimension dataset of opts.DimensionsFile, or a
+// synthetic one when no file is given.
+func loadDimensions(opts benchmarkOptions) (dimensions, error) {
+	if opts.DimensionsFile == "" {
+		return syntheticDimensions(opts)
+	}
+	return readDimensions(opts.DimensionsFile)
+}
+
+// readDimensions reads a dimension dataset written as JSON.
+func readDimensions(path string) (dimensions, error) {
+	encoded, err := os.ReadFile(path)
+	if err != nil {
+		return dimensions{}, err
+	}
+	var dims dimensions
+	if err := json.Unmarshal(encoded, &dims); err != nil {
+		return dimensions{}, fmt.Errorf("%s: %w", path, err)
+	}
+	// The dimension file holds the original ids; they must match the
+	// pseudonyms of the ingested readings.
+	for i := range dims.Users {
+		dims.Users[i].UserId = pseudonyms.userId(dims.Users[i].UserId)
+	}
+	return dims, nil
+}
+
+var (
+	syntheticDepartments = []string{"biology", "chemistry", "computer-science", "economics", "languages", "mathematics", "medicine", "physics"}
+	syntheticRoles       = []string{"student", "student", "student", "staff", "faculty", "visitor"}
+)
+
+// syntheticBuildings is the number of buildings the access points are spread
+// over when no dimension dataset is given.
+const syntheticBuildings = 12
+
+// syntheticDimensions derives a dimension dataset from the users and access
+// points of the measured dataset, with the same cardinality multiplication as
+// the ingestion. Every value is picked from a hash of opts.Seed and the id, so
+// the mapping is the same across runs and databases with the same seed.
+func syntheticDimensions(opts benchmarkOptions) (dimensions, error) {
+	files, err := os.ReadDir(readingsDir)
+	if err != nil {
+		return dimensions{}, err
+	}
+
+	users := map[string]bool{}
+	ssids := map[string]bool{}
+	for chunk := 0; chunk < min(len(files), scaledChunkCount(len(files), opts.Scale)); chunk++ {
+		_, data, err := loadDataChunk(readingsDir, chunk)
+		if err != nil {
+			return dimensions{}, err
+		}
+		multiplyCardinality(data.Response, opts.CardinalityFactor)
+		for _, reading := range data.Response {
+			users[reading.UserId] = true
+			ssids[reading.Connection.Ssid] = true
+		}
+	}
+
+	var dims dimensions
+	for _, user := range sortedKeys(users) {
+		h := hashId(opts.Seed, user)
+		dims.Users = append(dims.Users, userDimension{
+			UserId:     user,
+			Department: syntheticDepartments[h%uint32(len(syntheticDepartments))],
+			Role:       syntheticRoles[(h/7)%uint32(len(syntheticRoles))],
+		})
+	}
+	for _, ssid := range sortedKeys(ssids) {
+		h := hashId(opts.Seed, ssid)
+		building := fmt.Sprintf("building-%02d", h%syntheticBuildings+1)
+		lat, lon := syntheticCoordinates(opts.Seed, building, ssid)
+		dims.AccessPoints = append(dims.AccessPoints, accessPointDimension{
+			Ssid:        ssid,
+			Building:    building,
+			FloorNumber: int((h / syntheticBuildings) % 5),
+			Latitude:    &lat,
+			Longitude:   &lon,
+		})
+	}
+	return dims, nil
+}
+
+// syntheticCampus is the centre of the synthetic campus, the University of
+// Aveiro.
+var syntheticCampus = [2]float64{40.6303, -8.6577}
+
+// syntheticCoordinates places a building within about 500 m of the campus
+// centre and its access point within about 25 m of the building.
+func syntheticCoordinates(seed uint64, building string, ssid string) (float64, float64) {
+	offset := func(h uint32, meters float64) float64 {
+		return (float64(h%2001)/1000 - 1) * meters / metersPerDegree
+	}
+	b := hashId(seed, building)
+	a := hashId(seed, ssid)
+	lonScale := math.Cos(syntheticCampus[0] * math.Pi / 180)
+	lat := syntheticCampus[0] + offset(b, 500) + offset(a/7, 25)
+	lon := syntheticCampus[1] + (offset(b/2003, 500)+offset(a/14011, 25))/lonScale
+	return lat, lon
+}
+
+func hashId(seed uint64, id string) uint32 {
+	h := fnv.New32a()
+	h.Write(binary.LittleEndian.AppendUint64(nil, seed))
+	h.Write([]byte(id))
+	return h.Sum32()
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// dimensionInsertRows is the number of rows per INSERT statement when loading
+// the dimension tables.
+const dimensionInsertRows = 1000
+
+// loadDimensionTables creates the dimension tables and fills them with
+// multi-row INSERTs. The tables are small, so literal statements through exec
+// work for every SQL backend without a bulk path of their own.
+func loadDimensionTables(ctx context.Context, dialect joinDialect, b backend, dims dimensions) error {
+	for _, stmt := range dialect.schema {
+		if err := b.exec(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	quote := func(s string) string {
+		if dialect.backslashEscapes {
+			s = strings.ReplaceAll(s, `\`, `\\`)
+		}
+		return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+	}
+
+	var rows []string
+	for _, user := range dims.Users {
+		rows = append(rows, fmt.Sprintf("(%s, %s, %s)", quote(user.UserId), quote(user.Department), quote(user.Role)))
+	}
+	if err := insertRows(ctx, b, "INSERT INTO users (user_id, department, role) VALUES ", rows); err != nil {
+		return err
+	}
+
+	rows = rows[:0]
+	for _, ap := range dims.AccessPoints {
+		rows = append(rows, fmt.Sprintf("(%s, %s, %d)", quote(ap.Ssid), quote(ap.Building), ap.FloorNumber))
+	}
+	if err := insertRows(ctx, b, "INSERT INTO access_points (ssid, building, floor_number) VALUES ", rows); err != nil {
+		return err
+	}
+
+	for _, stmt := range dialect.finalize {
+		if err := b.exec(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func insertRows(ctx context.Context, b backend, insert string, rows []string) error {
+	for start := 0; start < len(rows); start += dimensionInsertRows {
+		end := min(start+dimensionInsertRows, len(rows))
+		if err := b.exec(ctx, insert+strings.Join(rows[start:end], ", ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runJoinPhase loads the dimension tables and runs the join queries of the
+// backend. Loading is timed separately from the ingestion of the readings.
+func runJoinPhase(ctx context.Context, info backendInfo, b backend, opts benchmarkOptions, dims dimensions, bounds queryBounds) (*JoinResult, error) {
+	result := &JoinResult{Users: len(dims.Users), AccessPoints: len(dims.AccessPoints)}
+
+	infof("Loading %d users and %d access points\n", result.Users, result.AccessPoints)
+	start := time.Now()
+	if err := loadDimensionTables(ctx, info.joins, b, dims); err != nil {
+		return nil, err
+	}
+	result.LoadMs = time.Since(start).Milliseconds()
+
+	for id := 1; id < len(joinQueryDescriptions); id++ {
+		q, ok := info.joins.lookupQuery(id)
+		if !ok {
+			result.Queries = append(result.Queries, QueryResult{
+				QueryId:     id,
+				DurationMs:  -1,
+				Description: joinQueryDescriptions[id],
+			})
+			continue
+		}
+
+		infof("Running join query %d: %s\n", id, joinQueryDescriptions[id])
+		samples, err := repeatQuery(opts.QueryRepeats, func() error {
+			return b.query(ctx, q.text, q.arguments(bounds)...)
+		})
+		duration := results.MedianMs(samples)
+		if err != nil {
+			if !info.lenientQueries {
+				return nil, err
+			}
+			duration = -1
+			samples = nil
+		}
+
+		query := QueryResult{
+			QueryId:     id,
+			DurationMs:  duration,
+			Description: joinQueryDescriptions[id],
+		}
+		if len(samples) > 1 {
+			query.SamplesMs = samples
+		}
+		if opts.Explain && err == nil {
+			query.Plan = capturePlan(ctx, info, b, q, bounds)
+		}
+		result.Queries = append(result.Queries, query)
+	}
+	return result, nil
+}
+
+func (d joinDialect) lookupQuery(id int) (querySpec, bool) {
+	for _, q := range d.queries {
+		if q.id == id {
+			return q, true
+		}
+	}
+	return querySpec{}, false
+}
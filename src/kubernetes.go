@@ -0,0 +1,290 @@
+//go:build !nokubernetes
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// With -kubernetes the database under test runs as a single-replica
+// StatefulSet behind a headless Service named like the Docker container, e.g.
+// smartcampus-bench-postgres, in the namespace of the benchmark pod. The
+// benchmark itself is meant to run inside the cluster as a Job (see
+// k8s/runner.yaml), so it reaches the database through the Service on the
+// container ports and measures the cluster network rather than a port forward.
+
+func init() {
+	kubernetesLauncher = startKubeDatabase
+}
+
+const inClusterNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// kubeClient connects with the pod's service account inside the cluster and
+// with the kubeconfig outside.
+func kubeClient() (*kubernetes.Clientset, string, error) {
+	config, err := rest.InClusterConfig()
+	namespace := kubeSettings.Namespace
+	if errors.Is(err, rest.ErrNotInCluster) {
+		rules := clientcmd.NewDefaultClientConfigLoadingRules()
+		rules.ExplicitPath = kubeSettings.Kubeconfig
+		loader := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{})
+		if config, err = loader.ClientConfig(); err != nil {
+			return nil, "", err
+		}
+		if namespace == "" {
+			if namespace, _, err = loader.Namespace(); err != nil {
+				return nil, "", err
+			}
+		}
+	} else if err != nil {
+		return nil, "", err
+	} else if namespace == "" {
+		current, err := os.ReadFile(inClusterNamespaceFile)
+		if err != nil {
+			return nil, "", err
+		}
+		namespace = strings.TrimSpace(string(current))
+	}
+
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, "", err
+	}
+	return client, namespace, nil
+}
+
+type kubeDatabase struct {
+	client    *kubernetes.Clientset
+	namespace string
+	name      string
+}
+
+// startKubeDatabase deploys the pinned image for dbType as a StatefulSet and
+// waits until its pod runs. Leftovers of a previous run are removed first so
+// every run starts from an empty database. The CPU and memory limits are set
+// as both requests and limits, so the pod gets the Guaranteed QoS class.
+func startKubeDatabase(dbType string, limits containerLimits) (managedDatabase, error) {
+	spec := backends[dbType].container.forRun(limits)
+	if spec.image == "" {
+		return nil, fmt.Errorf("no container definition for database type %q", dbType)
+	}
+	client, namespace, err := kubeClient()
+	if err != nil {
+		return nil, err
+	}
+	db := &kubeDatabase{client: client, namespace: namespace, name: "smartcampus-bench-" + dbType}
+	ctx := context.Background()
+	if err := db.delete(ctx); err != nil {
+		return nil, err
+	}
+
+	container, err := kubeContainer(spec, limits)
+	if err != nil {
+		return nil, err
+	}
+	labels := map[string]string{"app.kubernetes.io/name": db.name, "app.kubernetes.io/part-of": "smartcampus-benchmark"}
+
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: db.name, Labels: labels},
+		Spec: corev1.ServiceSpec{
+			ClusterIP: corev1.ClusterIPNone,
+			Selector:  labels,
+		},
+	}
+	for _, port := range container.Ports {
+		service.Spec.Ports = append(service.Spec.Ports, corev1.ServicePort{
+			Name:       port.Name,
+			Port:       port.ContainerPort,
+			TargetPort: intstr.FromInt32(port.ContainerPort),
+		})
+	}
+	replicas := int32(1)
+	statefulSet := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: db.name, Labels: labels},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas:    &replicas,
+			ServiceName: db.name,
+			Selector:    &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{container}},
+			},
+		},
+	}
+
+	infof("Deploying StatefulSet %s/%s (%s)\n", namespace, db.name, container.Image)
+	if _, err := client.CoreV1().Services(namespace).Create(ctx, service, metav1.CreateOptions{}); err != nil {
+		return nil, err
+	}
+	if _, err := client.AppsV1().StatefulSets(namespace).Create(ctx, statefulSet, metav1.CreateOptions{}); err != nil {
+		db.remove()
+		return nil, err
+	}
+	if err := db.waitRunning(ctx); err != nil {
+		db.remove()
+		return nil, err
+	}
+	return db, nil
+}
+
+// kubeContainer translates the Docker container spec. Only the container side
+// of the port mappings is used, as the Service exposes the container ports.
+func kubeContainer(spec containerSpec, limits containerLimits) (corev1.Container, error) {
+	container := corev1.Container{
+		Name:  "database",
+		Image: spec.image,
+		Args:  spec.args,
+	}
+	if limits.Image != "" {
+		container.Image = limits.Image
+	}
+	for _, mapping := range spec.ports {
+		_, port, _ := strings.Cut(mapping, ":")
+		number, err := strconv.ParseInt(port, 10, 32)
+		if err != nil {
+			return container, fmt.Errorf("port mapping %q: %w", mapping, err)
+		}
+		container.Ports = append(container.Ports, corev1.ContainerPort{Name: "port-" + port, ContainerPort: int32(number)})
+	}
+	for _, kv := range spec.env {
+		name, value, _ := strings.Cut(kv, "=")
+		container.Env = append(container.Env, corev1.EnvVar{Name: name, Value: value})
+	}
+
+	resources := corev1.ResourceList{}
+	if limits.Cpus != "" {
+		cpus, err := resource.ParseQuantity(limits.Cpus)
+		if err != nil {
+			return container, fmt.Errorf("-container-cpus: %w", err)
+		}
+		resources[corev1.ResourceCPU] = cpus
+	}
+	if limits.Memory != "" {
+		memory, err := parseMemoryLimit(limits.Memory)
+		if err != nil {
+			return container, fmt.Errorf("-container-memory: %w", err)
+		}
+		resources[corev1.ResourceMemory] = memory
+	}
+	if len(resources) > 0 {
+		container.Resources = corev1.ResourceRequirements{Requests: resources, Limits: resources}
+	}
+	return container, nil
+}
+
+// parseMemoryLimit accepts the docker --memory syntax (512m, 4g) next to
+// Kubernetes quantities (512Mi, 4Gi).
+func parseMemoryLimit(limit string) (resource.Quantity, error) {
+	units := map[string]string{"b": "", "k": "Ki", "m": "Mi", "g": "Gi"}
+	lower := strings.ToLower(limit)
+	if suffix, ok := units[lower[len(lower)-1:]]; ok {
+		if _, err := strconv.ParseFloat(lower[:len(lower)-1], 64); err == nil {
+			limit = lower[:len(lower)-1] + suffix
+		}
+	}
+	return resource.ParseQuantity(limit)
+}
+
+// waitRunning waits until the pod of the StatefulSet runs. Readiness of the
+// database itself is left to waitForDatabase.
+func (db *kubeDatabase) waitRunning(ctx context.Context) error {
+	pod := db.name + "-0"
+	return wait.PollUntilContextTimeout(ctx, 2*time.Second, kubeSettings.StartTimeout, true, func(ctx context.Context) (bool, error) {
+		p, err := db.client.CoreV1().Pods(db.namespace).Get(ctx, pod, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		switch p.Status.Phase {
+		case corev1.PodRunning:
+			return true, nil
+		case corev1.PodFailed, corev1.PodSucceeded:
+			return false, fmt.Errorf("pod %s exited: %s", pod, p.Status.Phase)
+		}
+		return false, nil
+	})
+}
+
+// restart is not supported: the database keeps its data in the pod, which a
+// restart replaces.
+func (db *kubeDatabase) restart() error {
+	return fmt.Errorf("restarting a database on Kubernetes is not supported")
+}
+
+// delete removes the StatefulSet and the Service and waits until the pod is
+// gone, so a new one does not find the old pod's name taken.
+func (db *kubeDatabase) delete(ctx context.Context) error {
+	foreground := metav1.DeletePropagationForeground
+	options := metav1.DeleteOptions{PropagationPolicy: &foreground}
+	if err := db.client.AppsV1().StatefulSets(db.namespace).Delete(ctx, db.name, options); err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	if err := db.client.CoreV1().Services(db.namespace).Delete(ctx, db.name, options); err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return wait.PollUntilContextTimeout(ctx, 2*time.Second, 5*time.Minute, true, func(ctx context.Context) (bool, error) {
+		_, err := db.client.CoreV1().Pods(db.namespace).Get(ctx, db.name+"-0", metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	})
+}
+
+func (db *kubeDatabase) remove() {
+	infof("Removing StatefulSet %s/%s\n", db.namespace, db.name)
+	if err := db.delete(context.Background()); err != nil {
+		fmt.Printf("[WARN] Failed to remove StatefulSet %s: %v\n", db.name, err)
+	}
+}
+
+// podMetrics is the part of a metrics.k8s.io PodMetrics the benchmark reads.
+type podMetrics struct {
+	Containers []struct {
+		Usage map[corev1.ResourceName]resource.Quantity `json:"usage"`
+	} `json:"containers"`
+}
+
+// sampleResources reads the pod's usage from the metrics API, which needs
+// metrics-server in the cluster.
+func (db *kubeDatabase) sampleResources(ctx context.Context) (ResourceSample, error) {
+	raw, err := db.client.RESTClient().Get().
+		AbsPath("/apis/metrics.k8s.io/v1beta1/namespaces", db.namespace, "pods", db.name+"-0").
+		DoRaw(ctx)
+	if err != nil {
+		return ResourceSample{}, err
+	}
+	var metrics podMetrics
+	if err := json.Unmarshal(raw, &metrics); err != nil {
+		return ResourceSample{}, err
+	}
+
+	var sample ResourceSample
+	for _, container := range metrics.Containers {
+		cpu := container.Usage[corev1.ResourceCPU]
+		memory := container.Usage[corev1.ResourceMemory]
+		sample.CpuMillicores += cpu.MilliValue()
+		sample.MemoryBytes += memory.Value()
+	}
+	return sample, nil
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+
+	"src/results"
+)
+
+// readingLocation is where the access point of a reading stands. Datasets may
+// carry it inline; otherwise the locations scenario looks it up by SSID in the
+// dimension dataset.
+type readingLocation struct {
+	Building    string `json:"building"`
+	FloorNumber int    `json:"floorNumber"`
+	// Latitude and Longitude are nil when the access point has no known
+	// coordinates; they are then stored as NULL.
+	Latitude  *float64 `json:"latitude,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty"`
+}
+
+// locatedTable is the table, or measurement, of the location-enriched readings.
+const locatedTable = "user_events_located"
+
+// locationDialect is the location-enriched layout of a backend: user_events
+// with the building, floor and coordinates of every reading's access point
+// denormalized into it, so location analytics need no join. finalize makes the
+// ingested rows visible on engines that refresh asynchronously.
+type locationDialect struct {
+	schema   []string
+	finalize []string
+	// queries renders the queries of locationQueryDescriptions; the spatial
+	// ones select the readings inside area.
+	queries func(area geoArea) []querySpec
+}
+
+// locatedIngester is implemented by backends that can write the location of
+// the readings along with them, to locatedTable.
+type locatedIngester interface {
+	ingestLocated(ctx context.Context, readings []Reading) error
+}
+
+var locationQueryDescriptions = []string{
+	1: "Records per building",
+	2: "Hourly distinct users per building over 24 hours from middle time",
+	3: "Records and average RSSI per building and floor",
+	4: "Records and distinct users within 250 m of the campus centre",
+	5: "Busiest 0.001 degree grid cells",
+}
+
+// geoArea is the box of the spatial queries: the access points' mean
+// coordinates, plus and minus areaRadius.
+type geoArea struct {
+	minLat, maxLat float64
+	minLon, maxLon float64
+}
+
+// areaRadius is the half width of the box of query 4, in meters.
+const areaRadius = 250
+
+// metersPerDegree is the length of a degree of latitude.
+const metersPerDegree = 111320.0
+
+// campusArea returns the box around the mean coordinates of the access
+// points; ok is false when none has coordinates.
+func campusArea(aps []accessPointDimension) (area geoArea, ok bool) {
+	var lat, lon float64
+	n := 0
+	for _, ap := range aps {
+		if ap.Latitude != nil && ap.Longitude != nil {
+			lat += *ap.Latitude
+			lon += *ap.Longitude
+			n++
+		}
+	}
+	if n == 0 {
+		return geoArea{}, false
+	}
+	lat /= float64(n)
+	lon /= float64(n)
+	latDelta := areaRadius / metersPerDegree
+	lonDelta := areaRadius / (metersPerDegree * math.Cos(lat*math.Pi/180))
+	return geoArea{minLat: lat - latDelta, maxLat: lat + latDelta, minLon: lon - lonDelta, maxLon: lon + lonDelta}, true
+}
+
+// locationValues returns the building, floor, latitude and longitude columns
+// of a reading; unknown coordinates are nil.
+func locationValues(reading *Reading) (string, int, any, any) {
+	location := reading.Location
+	if location == nil {
+		return unassignedBuilding, 0, nil, nil
+	}
+	var lat, lon any
+	if location.Latitude != nil && location.Longitude != nil {
+		lat, lon = *location.Latitude, *location.Longitude
+	}
+	return location.Building, location.FloorNumber, lat, lon
+}
+
+// locator enriches the readings with the location of their access point
+// before writing them to locatedTable, so the regular ingestion loop fills it.
+// It also tracks the time range, which the queries derive their arguments from.
+type locator struct {
+	backend
+	ingester   locatedIngester
+	dialect    locationDialect
+	locationOf map[string]*readingLocation
+	minTime    int
+	maxTime    int
+}
+
+func newLocator(b backend, ingester locatedIngester, dialect locationDialect, dims dimensions) *locator {
+	l := &locator{backend: b, ingester: ingester, dialect: dialect, locationOf: map[string]*readingLocation{}}
+	for _, ap := range dims.AccessPoints {
+		l.locationOf[ap.Ssid] = &readingLocation{
+			Building:    ap.Building,
+			FloorNumber: ap.FloorNumber,
+			Latitude:    ap.Latitude,
+			Longitude:   ap.Longitude,
+		}
+	}
+	return l
+}
+
+func (l *locator) createSchema(ctx context.Context) error {
+	for _, stmt := range l.dialect.schema {
+		if err := l.exec(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *locator) ingest(ctx context.Context, readings []Reading) error {
+	for i := range readings {
+		// A location that came with the dataset is kept.
+		if readings[i].Location == nil {
+			readings[i].Location = l.locationOf[readings[i].Connection.Ssid]
+		}
+		if l.minTime == 0 || readings[i].LastUpdatedTime < l.minTime {
+			l.minTime = readings[i].LastUpdatedTime
+		}
+		l.maxTime = max(l.maxTime, readings[i].LastUpdatedTime)
+	}
+	return l.ingester.ingestLocated(ctx, readings)
+}
+
+func (l *locator) bounds() queryBounds {
+	return newQueryBounds(readingTime(l.minTime), readingTime(l.maxTime))
+}
+
+// sqlLocationQueries renders the location queries of the SQL dialects. hour
+// truncates the timestamp to the hour, day selects the 24 hours from the
+// middle time with the arguments returned by args, and distinct counts the
+// distinct user ids.
+func sqlLocationQueries(hour string, day string, distinct string, args func(queryBounds) []any) func(area geoArea) []querySpec {
+	return func(area geoArea) []querySpec {
+		return []querySpec{
+			{id: 1, text: "SELECT building, COUNT(*) AS count FROM " + locatedTable + " GROUP BY building ORDER BY count DESC"},
+			{id: 2, text: "SELECT " + hour + " AS hour, building, " + distinct + " FROM " + locatedTable + " WHERE " + day + " GROUP BY hour, building ORDER BY hour, building", args: args},
+			{id: 3, text: "SELECT building, floor_number, COUNT(*), AVG(rssi) FROM " + locatedTable + " GROUP BY building, floor_number ORDER BY building, floor_number"},
+			{id: 4, text: fmt.Sprintf("SELECT COUNT(*), %s FROM %s WHERE latitude BETWEEN %f AND %f AND longitude BETWEEN %f AND %f",
+				distinct, locatedTable, area.minLat, area.maxLat, area.minLon, area.maxLon)},
+			{id: 5, text: "SELECT floor(latitude * 1000) AS cell_lat, floor(longitude * 1000) AS cell_lon, COUNT(*) AS count FROM " + locatedTable + " WHERE latitude IS NOT NULL GROUP BY cell_lat, cell_lon ORDER BY count DESC LIMIT 20"},
+		}
+	}
+}
+
+// benchmarkLocations ingests the dataset with the building, floor and
+// coordinates of every reading's access point, taken from the dimension
+// dataset, and times location analytics on it: per building and floor
+// breakdowns and spatial selections. They answer the join queries of -joins
+// without the join.
+func benchmarkLocations(info backendInfo, connStr string, outFile string, opts benchmarkOptions) error {
+	if info.locations.queries == nil {
+		return fmt.Errorf("locations scenario is not available for %s", info.name)
+	}
+	if opts.SchemaVariant != "" || opts.ChunkInterval != "" || opts.Shards > 0 {
+		return fmt.Errorf("the locations scenario uses its own schema")
+	}
+
+	dims, err := loadDimensions(opts)
+	if err != nil {
+		return err
+	}
+	area, spatial := campusArea(dims.AccessPoints)
+	if !spatial {
+		fmt.Println("[WARN] Locations: no access point has coordinates, the spatial queries are skipped")
+	}
+
+	raw, err := info.open(connStr)
+	if err != nil {
+		return err
+	}
+	defer raw.close()
+
+	ingester, ok := raw.(locatedIngester)
+	if !ok {
+		return fmt.Errorf("backend %s cannot write located readings", info.name)
+	}
+	if err := configureBackend(info, raw, opts); err != nil {
+		return err
+	}
+	b := newLocator(raw, ingester, info.locations, dims)
+	infof("Locations: enriching the readings with %d access point locations\n", len(b.locationOf))
+
+	ctx := context.Background()
+	scenario := ScenarioResult{Name: "locations"}
+	if err := loadScenarioData(ctx, info, b, opts, &scenario); err != nil {
+		return err
+	}
+	for _, stmt := range info.locations.finalize {
+		if err := b.exec(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	bounds := b.bounds()
+	queries := info.locations.queries(area)
+	for id := 1; id < len(locationQueryDescriptions); id++ {
+		idx := slices.IndexFunc(queries, func(q querySpec) bool { return q.id == id })
+		if idx < 0 || !spatial && id >= 4 {
+			scenario.Queries = append(scenario.Queries, QueryResult{
+				QueryId:     id,
+				DurationMs:  -1,
+				Description: locationQueryDescriptions[id],
+			})
+			continue
+		}
+		q := queries[idx]
+
+		infof("Running location query %d: %s\n", id, locationQueryDescriptions[id])
+		samples, err := repeatQuery(opts.QueryRepeats, func() error {
+			return b.query(ctx, q.text, q.arguments(bounds)...)
+		})
+		duration := results.MedianMs(samples)
+		if err != nil {
+			if !info.lenientQueries {
+				return err
+			}
+			duration = -1
+			samples = nil
+		}
+
+		result := QueryResult{
+			QueryId:     id,
+			DurationMs:  duration,
+			Description: locationQueryDescriptions[id],
+		}
+		if len(samples) > 1 {
+			result.SamplesMs = samples
+		}
+		scenario.Queries = append(scenario.Queries, result)
+	}
+
+	return writeResults(outFile, BenchmarkResults{
+		DbType:    info.name,
+		Seed:      opts.Seed,
+		Scenarios: []ScenarioResult{scenario},
+	})
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// maintenanceOp is a maintenance operation of the engine that -maintenance
+// triggers after the query catalog: statements to run, or a wait for
+// background work the engine schedules itself.
+type maintenanceOp struct {
+	name       string
+	statements []string
+	wait       func(ctx context.Context, b backend) error
+}
+
+type MaintenanceResult struct {
+	Operations []PhaseResult `json:"operations"`
+	// Queries is the query catalog run again after the maintenance, to
+	// compare with the queries of the run.
+	Queries []QueryResult `json:"queries"`
+	// Changes are the queries that ran both times, in a millisecond or more.
+	Changes []MaintenanceQueryChange `json:"changes,omitempty"`
+}
+
+type MaintenanceQueryChange struct {
+	QueryId  int   `json:"queryId"`
+	BeforeMs int64 `json:"beforeMs"`
+	AfterMs  int64 `json:"afterMs"`
+	// Speedup is BeforeMs over AfterMs; above 1 when the maintenance made
+	// the query faster.
+	Speedup float64 `json:"speedup"`
+}
+
+// maintenanceTimeout bounds the wait for background work; an engine still
+// busy after it is reported and the queries run regardless.
+const maintenanceTimeout = 10 * time.Minute
+
+// maintenanceQuietPolls is how many polls in a row must find no pending work,
+// since engines schedule their next compaction some time after the last one.
+const maintenanceQuietPolls = 5
+
+// waitUntilIdle polls pending, the amount of background work the engine
+// still has to do, once a second until it has been 0 for
+// maintenanceQuietPolls polls.
+func waitUntilIdle(ctx context.Context, what string, pending func(ctx context.Context) (int64, error)) error {
+	deadline := time.Now().Add(maintenanceTimeout)
+	for quiet := 0; quiet < maintenanceQuietPolls; {
+		n, err := pending(ctx)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			quiet = 0
+		} else {
+			quiet++
+		}
+		if time.Now().After(deadline) {
+			fmt.Printf("[WARN] Still %d %s pending after %s; running the queries regardless\n", n, what, maintenanceTimeout)
+			return nil
+		}
+		if err := interrupted(); err != nil {
+			return err
+		}
+		time.Sleep(time.Second)
+	}
+	return nil
+}
+
+// pendingRows returns a pending function for waitUntilIdle that reads the
+// count of the query.
+func pendingRows(b backend, query string) func(ctx context.Context) (int64, error) {
+	return func(ctx context.Context) (int64, error) {
+		scanner, ok := b.(rowScanner)
+		if !ok {
+			return 0, fmt.Errorf("backend cannot scan rows")
+		}
+		var n int64
+		err := scanner.scanRows(ctx, func(values []any) {
+			if len(values) > 0 {
+				n, _ = strconv.ParseInt(settingText(values[0]), 10, 64)
+			}
+		}, query)
+		return n, err
+	}
+}
+
+// runMaintenance times the maintenance operations of the backend one after
+// the other and runs the query catalog again, so that the queries of the run
+// show the latencies before the maintenance and Queries those after it.
+func runMaintenance(ctx context.Context, info backendInfo, b backend, before []QueryResult, opts benchmarkOptions) (*MaintenanceResult, error) {
+	result := &MaintenanceResult{}
+	for _, op := range info.maintenance {
+		infof("Maintenance: %s\n", op.name)
+		start := time.Now()
+		for _, stmt := range op.statements {
+			if err := b.exec(ctx, stmt); err != nil {
+				return nil, fmt.Errorf("maintenance %s: %w", op.name, err)
+			}
+		}
+		if op.wait != nil {
+			if err := op.wait(ctx, b); err != nil {
+				return nil, fmt.Errorf("maintenance %s: %w", op.name, err)
+			}
+		}
+		result.Operations = append(result.Operations, PhaseResult{Name: op.name, DurationMs: time.Since(start).Milliseconds()})
+		if err := interrupted(); err != nil {
+			return nil, err
+		}
+	}
+
+	// The catalog of the run is journaled already.
+	opts.Progress = nil
+	var err error
+	if result.Queries, _, err = runQueryCatalog(ctx, info, b, opts); err != nil {
+		return nil, err
+	}
+	for _, after := range result.Queries {
+		for _, q := range before {
+			if q.QueryId == after.QueryId && q.DurationMs > 0 && after.DurationMs > 0 {
+				result.Changes = append(result.Changes, MaintenanceQueryChange{
+					QueryId:  q.QueryId,
+					BeforeMs: q.DurationMs,
+					AfterMs:  after.DurationMs,
+					Speedup:  float64(q.DurationMs) / float64(after.DurationMs),
+				})
+			}
+		}
+	}
+	return result, nil
+}
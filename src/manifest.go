@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// RunManifest is the provenance of a run under -manifest: what was measured,
+// with which binary, against which server. Published numbers can be audited
+// by comparing the hashes with the dataset and the tree they claim to come
+// from.
+type RunManifest struct {
+	Binary        BinaryInfo      `json:"binary"`
+	ServerVersion string          `json:"serverVersion,omitempty"`
+	Dataset       []DatasetHash   `json:"dataset"`
+	Catalog       CatalogManifest `json:"catalog"`
+}
+
+// BinaryInfo is the build of the benchmark binary.
+type BinaryInfo struct {
+	GoVersion string `json:"goVersion"`
+	Module    string `json:"module,omitempty"`
+	// Revision and Modified are the VCS state the binary was built from;
+	// empty when it was not built inside a checkout.
+	Revision  string `json:"revision,omitempty"`
+	CommitAt  string `json:"commitAt,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+	BuildTags string `json:"buildTags,omitempty"`
+	// Sha256 is the hash of the executable itself.
+	Sha256 string `json:"sha256,omitempty"`
+}
+
+// DatasetHash covers one input directory or file of the run. Sha256 is the
+// hash of the sorted "<sha256>  <name>" lines of its files, like the output
+// of sha256sum, so one value identifies the whole dataset.
+type DatasetHash struct {
+	Role   string     `json:"role"`
+	Path   string     `json:"path"`
+	Sha256 string     `json:"sha256"`
+	Bytes  int64      `json:"bytes"`
+	Files  []FileHash `json:"files"`
+}
+
+type FileHash struct {
+	Name   string `json:"name"`
+	Bytes  int64  `json:"bytes"`
+	Sha256 string `json:"sha256"`
+}
+
+// CatalogManifest hashes the query texts the backend ran, so a change of the
+// catalog between two builds is visible even at the same revision, e.g. a
+// dialect file of custom-sql.
+type CatalogManifest struct {
+	Sha256  string      `json:"sha256"`
+	Queries []QueryHash `json:"queries"`
+}
+
+type QueryHash struct {
+	QueryId int    `json:"queryId"`
+	Sha256  string `json:"sha256"`
+}
+
+// versionReporter is implemented by backends that can ask the server for its
+// version.
+type versionReporter interface {
+	serverVersion(ctx context.Context) (string, error)
+}
+
+// buildManifest hashes the inputs of a completed run. It reads the whole
+// dataset again, so it runs after the measurements.
+func buildManifest(ctx context.Context, info backendInfo, b backend, opts benchmarkOptions) (*RunManifest, error) {
+	infof("Hashing the inputs of the run for the manifest\n")
+	manifest := &RunManifest{Binary: readBinaryInfo(), Catalog: hashCatalog(info)}
+	if reporter, ok := b.(versionReporter); ok {
+		version, err := reporter.serverVersion(ctx)
+		if err != nil {
+			fmt.Printf("[WARN] Failed to read the server version: %v\n", err)
+		} else {
+			manifest.ServerVersion = version
+		}
+	}
+
+	inputs := []struct{ role, path string }{{"readings", readingsDir}}
+	if opts.Warmup.Dir != "" {
+		inputs = append(inputs, struct{ role, path string }{"warmup", opts.Warmup.Dir})
+	}
+	if opts.DimensionsFile != "" {
+		inputs = append(inputs, struct{ role, path string }{"dimensions", opts.DimensionsFile})
+	}
+	for _, input := range inputs {
+		hash, err := hashDataset(input.role, input.path)
+		if err != nil {
+			return nil, err
+		}
+		manifest.Dataset = append(manifest.Dataset, hash)
+	}
+	return manifest, nil
+}
+
+func readBinaryInfo() BinaryInfo {
+	info := BinaryInfo{}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		info.GoVersion = build.GoVersion
+		info.Module = build.Main.Path
+		for _, setting := range build.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				info.Revision = setting.Value
+			case "vcs.time":
+				info.CommitAt = setting.Value
+			case "vcs.modified":
+				info.Modified, _ = strconv.ParseBool(setting.Value)
+			case "-tags":
+				info.BuildTags = setting.Value
+			}
+		}
+	}
+	if path, err := os.Executable(); err == nil {
+		if sum, _, err := hashFile(path); err == nil {
+			info.Sha256 = sum
+		}
+	}
+	return info
+}
+
+func hashCatalog(info backendInfo) CatalogManifest {
+	catalog := CatalogManifest{}
+	all := sha256.New()
+	for _, q := range info.queries {
+		sum := sha256.Sum256([]byte(q.text))
+		catalog.Queries = append(catalog.Queries, QueryHash{QueryId: q.id, Sha256: hex.EncodeToString(sum[:])})
+		fmt.Fprintf(all, "%d\t%s\n", q.id, q.text)
+	}
+	catalog.Sha256 = hex.EncodeToString(all.Sum(nil))
+	return catalog
+}
+
+// hashDataset hashes a directory of chunk files, or a single file.
+func hashDataset(role, path string) (DatasetHash, error) {
+	dataset := DatasetHash{Role: role, Path: path}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return dataset, err
+	}
+	var names []string
+	if stat.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return dataset, err
+		}
+		for _, entry := range entries {
+			if entry.Type().IsRegular() {
+				names = append(names, entry.Name())
+			}
+		}
+	} else {
+		path, names = filepath.Dir(path), []string{filepath.Base(path)}
+	}
+	slices.Sort(names)
+
+	var listing strings.Builder
+	for _, name := range names {
+		sum, size, err := hashFile(filepath.Join(path, name))
+		if err != nil {
+			return dataset, err
+		}
+		dataset.Files = append(dataset.Files, FileHash{Name: name, Bytes: size, Sha256: sum})
+		dataset.Bytes += size
+		fmt.Fprintf(&listing, "%s  %s\n", sum, name)
+	}
+	sum := sha256.Sum256([]byte(listing.String()))
+	dataset.Sha256 = hex.EncodeToString(sum[:])
+	return dataset, nil
+}
+
+func hashFile(path string) (string, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
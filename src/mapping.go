@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+// schemaMapping is the -schema-mapping file: where the readings and their
+// fields are in the JSON of a dataset that is not a SmartCampus export, and
+// which other fields become columns, or InfluxDB tags and fields. Paths are
+// dot separated keys of nested objects.
+//
+//	records: data.events
+//	fields:
+//	  userId: device.mac
+//	  time: observedAt
+//	  timeUnit: rfc3339
+//	  ssid: ap.name
+//	  rssi: signal.rssi
+//	columns:
+//	  - name: device_type
+//	    field: device.type
+//	    type: text
+//	    influx: tag
+type schemaMapping struct {
+	// Records is the path of the array of readings; "." for a file that is
+	// the array itself. It defaults to response.
+	Records string        `json:"records"`
+	Fields  readingFields `json:"fields"`
+	Columns []extraColumn `json:"columns"`
+	file    string
+}
+
+// readingFields are the paths of the fixed fields of a reading. Each defaults
+// to the field of the SmartCampus layout.
+type readingFields struct {
+	UserId string `json:"userId"`
+	Time   string `json:"time"`
+	// TimeUnit is how time is written: seconds or milliseconds since the
+	// epoch, or an RFC 3339 string. It defaults to seconds.
+	TimeUnit string `json:"timeUnit"`
+	Ssid     string `json:"ssid"`
+	Rssi     string `json:"rssi"`
+}
+
+const (
+	timeSeconds      = "seconds"
+	timeMilliseconds = "milliseconds"
+	timeRFC3339      = "rfc3339"
+)
+
+// readingMapping is set from -schema-mapping; nil when the datasets have the
+// SmartCampus layout, which the loader then decodes directly into Reading.
+var readingMapping *schemaMapping
+
+func readSchemaMapping(path string) (*schemaMapping, error) {
+	encoded, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m schemaMapping
+	if err := yaml.UnmarshalStrict(encoded, &m); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	m.file = filepath.Base(path)
+	if m.Records == "" {
+		m.Records = "response"
+	}
+	defaults := []struct {
+		path     *string
+		fallback string
+	}{
+		{&m.Fields.UserId, "userId"},
+		{&m.Fields.Time, "lastUpdatedTime"},
+		{&m.Fields.TimeUnit, timeSeconds},
+		{&m.Fields.Ssid, "connection.ssid"},
+		{&m.Fields.Rssi, "connection.rssi"},
+	}
+	for _, d := range defaults {
+		if *d.path == "" {
+			*d.path = d.fallback
+		}
+	}
+	switch m.Fields.TimeUnit {
+	case timeSeconds, timeMilliseconds, timeRFC3339:
+	default:
+		return nil, fmt.Errorf("%s: time unit %q, expected %s, %s or %s", path, m.Fields.TimeUnit, timeSeconds, timeMilliseconds, timeRFC3339)
+	}
+	if m.Columns, err = checkExtraColumns(path, m.Columns); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// jsonRecord is a JSON object whose nested objects are decoded on demand, once,
+// as the paths of a mapping reach into them.
+type jsonRecord struct {
+	fields map[string]json.RawMessage
+	nested map[string]*jsonRecord
+}
+
+// lookup returns the value at the dot separated path; ok is false when a key
+// is missing, a value on the way is not an object, or the value is null.
+func (r *jsonRecord) lookup(path string) (raw json.RawMessage, ok bool) {
+	head, rest, deeper := strings.Cut(path, ".")
+	raw, ok = r.fields[head]
+	if !ok || string(raw) == "null" {
+		return nil, false
+	}
+	if !deeper {
+		return raw, true
+	}
+	child, decoded := r.nested[head]
+	if !decoded {
+		var fields map[string]json.RawMessage
+		if json.Unmarshal(raw, &fields) == nil {
+			child = &jsonRecord{fields: fields}
+		}
+		if r.nested == nil {
+			r.nested = map[string]*jsonRecord{}
+		}
+		r.nested[head] = child
+	}
+	if child == nil {
+		return nil, false
+	}
+	return child.lookup(rest)
+}
+
+// decode decodes the readings of a chunk through the mapping, the extra
+// columns included.
+func (m *schemaMapping) decode(encoded []byte) ([]Reading, error) {
+	var records []map[string]json.RawMessage
+	if m.Records == "." {
+		if err := json.Unmarshal(encoded, &records); err != nil {
+			return nil, err
+		}
+	} else {
+		var top jsonRecord
+		if err := json.Unmarshal(encoded, &top.fields); err != nil {
+			return nil, err
+		}
+		raw, ok := top.lookup(m.Records)
+		if !ok {
+			return nil, fmt.Errorf("no %s array of readings", m.Records)
+		}
+		if err := json.Unmarshal(raw, &records); err != nil {
+			return nil, fmt.Errorf("%s: %w", m.Records, err)
+		}
+	}
+
+	readings := make([]Reading, len(records))
+	for i, fields := range records {
+		record := &jsonRecord{fields: fields}
+		if err := m.decodeReading(record, &readings[i]); err != nil {
+			return nil, fmt.Errorf("reading %d: %w", i, err)
+		}
+	}
+	return readings, nil
+}
+
+func (m *schemaMapping) decodeReading(record *jsonRecord, reading *Reading) error {
+	raw, ok := record.lookup(m.Fields.UserId)
+	if !ok {
+		return fmt.Errorf("no user id at %s", m.Fields.UserId)
+	}
+	reading.UserId = textValue(raw)
+
+	if raw, ok = record.lookup(m.Fields.Time); !ok {
+		return fmt.Errorf("no time at %s", m.Fields.Time)
+	}
+	epoch, err := m.epoch(raw)
+	if err != nil {
+		return fmt.Errorf("time at %s: %w", m.Fields.Time, err)
+	}
+	reading.LastUpdatedTime = epoch
+
+	if raw, ok = record.lookup(m.Fields.Ssid); ok {
+		reading.Connection.Ssid = textValue(raw)
+	}
+	if raw, ok = record.lookup(m.Fields.Rssi); ok {
+		if err := json.Unmarshal(raw, &reading.Connection.Rssi); err != nil {
+			return fmt.Errorf("rssi at %s: %w", m.Fields.Rssi, err)
+		}
+	}
+
+	if len(extraColumns) > 0 {
+		if reading.Extra, err = extraRecordValues(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// epoch converts a time of the mapping's unit to a dataset timestamp.
+func (m *schemaMapping) epoch(raw json.RawMessage) (int, error) {
+	if m.Fields.TimeUnit == timeRFC3339 {
+		var text string
+		if err := json.Unmarshal(raw, &text); err != nil {
+			return 0, err
+		}
+		t, err := time.Parse(time.RFC3339, text)
+		if err != nil {
+			return 0, err
+		}
+		return datasetEpoch(t), nil
+	}
+	var number float64
+	if err := json.Unmarshal(raw, &number); err != nil {
+		return 0, err
+	}
+	if m.Fields.TimeUnit == timeMilliseconds {
+		number /= 1000
+	}
+	return int(number), nil
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// matrixConfig is the JSON configuration of -matrix. Every combination of a
+// database, a scale, a concurrency level, one of the database's schema
+// variants and a durability level is run once per pass. Axes left empty have a
+// single default value: scale 1, concurrency 1, the default schema and the
+// engine's default durability.
+type matrixConfig struct {
+	OutputDir   string           `json:"outputDir"`
+	Passes      int              `json:"passes"`
+	Order       string           `json:"order"`
+	Databases   []matrixDatabase `json:"databases"`
+	Scales      []float64        `json:"scales"`
+	Concurrency []int            `json:"concurrency"`
+	Durability  []string         `json:"durability"`
+}
+
+type matrixDatabase struct {
+	Type           string   `json:"type"`
+	Conn           string   `json:"conn"`
+	SchemaVariants []string `json:"schemaVariants"`
+	// Pool overrides the -pool-* flags for the runs of this database.
+	Pool *matrixPool `json:"pool"`
+}
+
+type matrixPool struct {
+	MaxConns       int  `json:"maxConns"`
+	MinConns       int  `json:"minConns"`
+	StatementCache *int `json:"statementCache"`
+}
+
+// poolOptions are the pool flags with the database's overrides applied.
+func (db matrixDatabase) poolOptions(base poolOptions) poolOptions {
+	if db.Pool == nil {
+		return base
+	}
+	opts := poolOptions{MaxConns: db.Pool.MaxConns, MinConns: db.Pool.MinConns, StatementCache: base.StatementCache}
+	if db.Pool.StatementCache != nil {
+		opts.StatementCache = *db.Pool.StatementCache
+	}
+	return opts
+}
+
+// matrixRun is one cell of the expanded matrix.
+type matrixRun struct {
+	db            matrixDatabase
+	info          backendInfo
+	scale         float64
+	concurrency   int
+	schemaVariant string
+	durability    string
+	pass          int
+}
+
+// label names the result file of the run, e.g.
+// postgres_scale0.5_c1_brin_pass2, or postgres_scale1_c1_default_strict_pass1
+// with a durability level.
+func (r matrixRun) label() string {
+	variant := r.schemaVariant
+	if variant == "" {
+		variant = "default"
+	}
+	if r.durability != "" {
+		variant += "_" + r.durability
+	}
+	return fmt.Sprintf("%s_scale%s_c%d_%s_pass%d", r.db.Type, strconv.FormatFloat(r.scale, 'g', -1, 64), r.concurrency, variant, r.pass)
+}
+
+func readMatrixConfig(path string) (matrixConfig, error) {
+	encoded, err := os.ReadFile(path)
+	if err != nil {
+		return matrixConfig{}, err
+	}
+	if encoded, err = expandJSONEnv(encoded); err != nil {
+		return matrixConfig{}, fmt.Errorf("%s: %w", path, err)
+	}
+	var cfg matrixConfig
+	if err := json.Unmarshal(encoded, &cfg); err != nil {
+		return matrixConfig{}, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, db := range cfg.Databases {
+		registerConnSecrets(db.Conn)
+	}
+
+	if cfg.OutputDir == "" {
+		cfg.OutputDir = "."
+	}
+	if cfg.Passes == 0 {
+		cfg.Passes = 1
+	}
+	if cfg.Order == "" {
+		cfg.Order = "interleaved"
+	}
+	if len(cfg.Scales) == 0 {
+		cfg.Scales = []float64{1}
+	}
+	if len(cfg.Concurrency) == 0 {
+		cfg.Concurrency = []int{1}
+	}
+	if len(cfg.Durability) == 0 {
+		cfg.Durability = []string{""}
+	}
+	return cfg, nil
+}
+
+// expandMatrix lists the runs of the configuration and checks every one of
+// them against its backend before anything is started. With the interleaved
+// order every pass visits each database once, like benchmark.sh, so slow
+// drifts of the shared infrastructure hit all databases alike; sequential runs
+// every pass of a database back to back.
+func expandMatrix(cfg matrixConfig, base benchmarkOptions) ([]matrixRun, error) {
+	if len(cfg.Databases) == 0 {
+		return nil, fmt.Errorf("the matrix has no databases")
+	}
+	if cfg.Passes < 0 {
+		return nil, fmt.Errorf("the number of passes must be positive")
+	}
+	if cfg.Order != "interleaved" && cfg.Order != "sequential" {
+		return nil, fmt.Errorf("unknown order %q, want interleaved or sequential", cfg.Order)
+	}
+	for _, scale := range cfg.Scales {
+		if scale <= 0 {
+			return nil, fmt.Errorf("scale %v is not positive", scale)
+		}
+	}
+	for _, concurrency := range cfg.Concurrency {
+		// The ingestion and the queries run on a single client for now.
+		if concurrency != 1 {
+			return nil, fmt.Errorf("concurrency level %d is not supported, only 1", concurrency)
+		}
+	}
+	for _, level := range cfg.Durability {
+		if err := checkDurabilityLevel(level); err != nil {
+			return nil, err
+		}
+	}
+
+	cells := func(db matrixDatabase, pass int) ([]matrixRun, error) {
+		info, err := lookupBackend(db.Type)
+		if err != nil {
+			return nil, err
+		}
+		if info.container.image == "" {
+			return nil, fmt.Errorf("no container definition for database type %q", db.Type)
+		}
+		if err := db.poolOptions(poolTuning).validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", db.Type, err)
+		}
+		variants := db.SchemaVariants
+		if len(variants) == 0 {
+			variants = []string{""}
+		}
+
+		var runs []matrixRun
+		for _, scale := range cfg.Scales {
+			for _, concurrency := range cfg.Concurrency {
+				for _, variant := range variants {
+					for _, level := range cfg.Durability {
+						run := matrixRun{db: db, info: info, scale: scale, concurrency: concurrency, schemaVariant: variant, durability: level, pass: pass}
+						if err := run.options(base).checkBackend(info); err != nil {
+							return nil, fmt.Errorf("%s: %w", run.label(), err)
+						}
+						runs = append(runs, run)
+					}
+				}
+			}
+		}
+		return runs, nil
+	}
+
+	var runs []matrixRun
+	add := func(db matrixDatabase, pass int) error {
+		dbRuns, err := cells(db, pass)
+		runs = append(runs, dbRuns...)
+		return err
+	}
+	if cfg.Order == "interleaved" {
+		for pass := 1; pass <= cfg.Passes; pass++ {
+			for _, db := range cfg.Databases {
+				if err := add(db, pass); err != nil {
+					return nil, err
+				}
+			}
+		}
+	} else {
+		for _, db := range cfg.Databases {
+			for pass := 1; pass <= cfg.Passes; pass++ {
+				if err := add(db, pass); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return runs, nil
+}
+
+// options are the command line options with the run's cell applied.
+func (r matrixRun) options(base benchmarkOptions) benchmarkOptions {
+	opts := base
+	opts.Scale = r.scale
+	opts.SchemaVariant = r.schemaVariant
+	if r.durability != "" {
+		opts.Durability = r.durability
+	}
+	opts.Pass = r.pass
+	return opts
+}
+
+// runMatrix runs every cell of the matrix in a fresh managed container and
+// writes its results to <outputDir>/<label>.json. A failing run is reported
+// and the matrix goes on with the next one.
+func runMatrix(path string, base benchmarkOptions, limits containerLimits) error {
+	cfg, err := readMatrixConfig(path)
+	if err != nil {
+		return err
+	}
+	runs, err := expandMatrix(cfg, base)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(cfg.OutputDir, 0755); err != nil {
+		return err
+	}
+
+	infof("Matrix of %d runs\n", len(runs))
+	var failed, missed []string
+	for i, run := range runs {
+		infof("Matrix run %d/%d: %s\n", i+1, len(runs), run.label())
+		err := runMatrixCell(run, base, limits, cfg.OutputDir)
+		if errors.Is(err, errInterrupted) {
+			return fmt.Errorf("matrix run %d/%d: %w", i+1, len(runs), err)
+		}
+		var violation *sloViolation
+		if errors.As(err, &violation) {
+			fmt.Printf("[WARN] Matrix run %s %s\n", run.label(), violation)
+			missed = append(missed, run.label())
+		} else if err != nil {
+			fmt.Printf("[WARN] Matrix run %s failed: %s\n", run.label(), redact(err.Error()))
+			failed = append(failed, run.label())
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d matrix runs failed: %s", len(failed), len(runs), strings.Join(failed, ", "))
+	}
+	if len(missed) > 0 {
+		return &sloViolation{missed: []string{fmt.Sprintf("%d of %d matrix runs (%s)", len(missed), len(runs), strings.Join(missed, ", "))}}
+	}
+	infof("All %d matrix runs completed\n", len(runs))
+	return nil
+}
+
+func runMatrixCell(run matrixRun, base benchmarkOptions, limits containerLimits, outputDir string) error {
+	opts := run.options(base)
+	limits.Durability = opts.Durability
+	container, err := launchDatabase(run.db.Type, limits)
+	if err != nil {
+		return err
+	}
+	defer container.remove()
+
+	defer func(flags poolOptions) { poolTuning = flags }(poolTuning)
+	poolTuning = run.db.poolOptions(poolTuning)
+	defer func(params map[string]string) { durabilityParams = params }(durabilityParams)
+	durabilityParams = run.info.durability.params[opts.Durability]
+
+	opts.Container = container
+	if err := waitForDatabase(run.db.Type, run.db.Conn, opts.ReadyTimeout); err != nil {
+		return err
+	}
+	return runBenchmark(run.info, run.db.Conn, filepath.Join(outputDir, run.label()+".json"), opts)
+}
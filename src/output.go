@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"src/results"
+)
+
+// verbosity is how much of the progress a run logs. Warnings and errors are
+// logged at every level.
+type verbosity int
+
+const (
+	// verbosityQuiet leaves out the [INFO] progress lines.
+	verbosityQuiet verbosity = iota - 1
+	verbosityNormal
+	// verbosityVerbose adds [DEBUG] lines: the text and the run times of
+	// every query, and the throughput of every batch.
+	verbosityVerbose
+)
+
+var logLevel = verbosityNormal
+
+// infof logs a progress line unless the run is quiet.
+func infof(format string, args ...any) {
+	if logLevel >= verbosityNormal {
+		fmt.Printf("[INFO] "+format, args...)
+	}
+}
+
+// debugf logs a detail line of a verbose run.
+func debugf(format string, args ...any) {
+	if logLevel >= verbosityVerbose {
+		fmt.Printf("[DEBUG] "+format, args...)
+	}
+}
+
+// RunSummary is the last line a bench run prints on stdout, a JSON object
+// with the totals of the results it wrote and whether it passed, for scripts
+// that orchestrate runs without reading the result files. It is printed at
+// every verbosity, also when the run failed.
+type RunSummary struct {
+	// Status is passed for exit code 0 and failed for any other.
+	Status   string `json:"status"`
+	ExitCode int    `json:"exitCode"`
+	Error    string `json:"error,omitempty"`
+	// Outputs are the result files written, with the .gz a large one got.
+	Outputs       []string `json:"outputs"`
+	DbTypes       []string `json:"dbTypes,omitempty"`
+	Records       int      `json:"records"`
+	IngestionMs   int64    `json:"ingestionMs"`
+	RowsPerSec    float64  `json:"rowsPerSec"`
+	Queries       int      `json:"queries"`
+	FailedQueries int      `json:"failedQueries"`
+	// UnsupportedQueries are the catalog queries the backend does not
+	// implement, recorded as -1 without running.
+	UnsupportedQueries int   `json:"unsupportedQueries"`
+	DurationMs         int64 `json:"durationMs"`
+
+	start time.Time
+}
+
+// runSummary collects the totals of the results written by the run; nil
+// until a bench run starts, so that help and -list-backends print none.
+var runSummary *RunSummary
+
+func startRunSummary() {
+	runSummary = &RunSummary{Outputs: []string{}, start: time.Now()}
+}
+
+// addToRunSummary adds a result file written by the run to the totals.
+func addToRunSummary(benchmark BenchmarkResults) {
+	if runSummary == nil {
+		return
+	}
+	if !slices.Contains(runSummary.DbTypes, benchmark.DbType) {
+		runSummary.DbTypes = append(runSummary.DbTypes, benchmark.DbType)
+	}
+	for _, batch := range benchmark.Ingestion {
+		runSummary.IngestionMs += batch.DurationMs
+	}
+	if n := len(benchmark.Ingestion); n > 0 {
+		runSummary.Records += benchmark.Ingestion[n-1].NRecords
+	}
+	// The dialect backends record their name in the type, custom-sql:<name>,
+	// and are registered under the part before the colon.
+	backendName, _, _ := strings.Cut(benchmark.DbType, ":")
+	info, err := lookupBackend(backendName)
+	countQueries(benchmark.Queries, func(id int) bool {
+		_, ok := info.lookupQuery(id)
+		return err == nil && !ok
+	})
+	for _, scenario := range benchmark.Scenarios {
+		countQueries(scenario.Queries, func(int) bool { return false })
+	}
+}
+
+// countQueries adds queries to the totals; a query recorded as -1 is a
+// failure unless unsupported says the backend does not implement it.
+func countQueries(queries []QueryResult, unsupported func(id int) bool) {
+	for _, q := range queries {
+		runSummary.Queries++
+		switch {
+		case q.DurationMs >= 0:
+		case unsupported(q.QueryId):
+			runSummary.UnsupportedQueries++
+		default:
+			runSummary.FailedQueries++
+		}
+	}
+}
+
+// printRunSummary prints the summary line of a bench run that ended with err.
+func printRunSummary(err error) {
+	if runSummary == nil {
+		return
+	}
+	s := runSummary
+	s.ExitCode = exitCode(err)
+	s.Status = "passed"
+	if s.ExitCode != 0 {
+		s.Status = "failed"
+		s.Error = redact(err.Error())
+	}
+	s.DurationMs = time.Since(s.start).Milliseconds()
+	s.RowsPerSec = results.RowsPerSec(s.Records, time.Duration(s.IngestionMs)*time.Millisecond)
+	encoded, _ := json.Marshal(s)
+	fmt.Println(string(encoded))
+}
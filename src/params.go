@@ -0,0 +1,54 @@
+package main
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// paramAnchors is the number of readings the random query parameters are
+// drawn from.
+const paramAnchors = 1000
+
+// paramStream keeps the parameter draws apart from the other uses of the
+// seed.
+const paramStream = 0x706172616d73
+
+// paramSampler moves the anchor of the time range queries, the middle time,
+// to the timestamp of a random reading on every repetition. Anchors follow
+// the distribution of the data, so busy hours are picked more often than
+// quiet ones, and repetitions do not hit the same cached range. A nil sampler
+// keeps the middle time.
+type paramSampler struct {
+	rng     *rand.Rand
+	anchors []time.Time
+}
+
+func newParamSampler(opts benchmarkOptions) (*paramSampler, error) {
+	readings, err := sampleReadings(0, paramAnchors, opts)
+	if err != nil {
+		return nil, err
+	}
+	s := &paramSampler{rng: rand.New(rand.NewPCG(opts.Seed, paramStream))}
+	for _, reading := range readings {
+		s.anchors = append(s.anchors, readingTime(reading.LastUpdatedTime))
+	}
+	return s, nil
+}
+
+// bounds returns b with the middle time replaced by a random anchor.
+func (s *paramSampler) bounds(b queryBounds) queryBounds {
+	if s == nil || len(s.anchors) == 0 {
+		return b
+	}
+	b.middle = s.anchors[s.rng.IntN(len(s.anchors))]
+	return b
+}
+
+// withRand returns a sampler drawing from the same anchors with its own
+// generator, for a goroutine of its own.
+func (s *paramSampler) withRand(rng *rand.Rand) *paramSampler {
+	if s == nil {
+		return nil
+	}
+	return &paramSampler{rng: rng, anchors: s.anchors}
+}
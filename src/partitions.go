@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// nativePartitionsVariant is the schema variant of the native-partitions
+// scenario: a table partitioned by day with the engine's declarative
+// partitioning instead of an extension such as TimescaleDB.
+const nativePartitionsVariant = "partitioned"
+
+// partitionedBackend is implemented by backends that can describe the
+// partitions of user_events.
+type partitionedBackend interface {
+	partitionStats(ctx context.Context) (partitions int, size int64, err error)
+}
+
+// benchmarkNativePartitions ingests the full dataset into a table range
+// partitioned by day and runs the query catalog on it, recording the number
+// of partitions and their size. Compared with a TimescaleDB run on the same
+// data, it shows what the hypertable adds over native partitioning.
+func benchmarkNativePartitions(info backendInfo, connStr string, outFile string, opts benchmarkOptions) error {
+	variant, ok := info.lookupSchemaVariant(nativePartitionsVariant)
+	if !ok || !variant.daily {
+		return fmt.Errorf("native-partitions scenario is not available for %s", info.name)
+	}
+	if opts.SchemaVariant != "" || opts.ChunkInterval != "" || opts.Shards > 0 {
+		return fmt.Errorf("the native-partitions scenario uses its own schema")
+	}
+	opts.SchemaVariant = nativePartitionsVariant
+
+	b, err := info.open(connStr)
+	if err != nil {
+		return err
+	}
+	defer b.close()
+
+	partitioned, ok := b.(partitionedBackend)
+	if !ok {
+		return fmt.Errorf("backend %s cannot describe its partitions", info.name)
+	}
+
+	ctx := context.Background()
+	scenario := ScenarioResult{Name: "native-partitions"}
+	if err := configureBackend(info, b, opts); err != nil {
+		return err
+	}
+	if err := loadScenarioData(ctx, info, b, opts, &scenario); err != nil {
+		return err
+	}
+
+	if scenario.Partitions, scenario.SizeBytes, err = partitioned.partitionStats(ctx); err != nil {
+		return err
+	}
+	infof("Native partitions: %d daily partitions, %d bytes\n", scenario.Partitions, scenario.SizeBytes)
+
+	scenario.Queries, _, err = runQueryCatalog(ctx, info, b, opts)
+	if err != nil {
+		return err
+	}
+
+	return writeResults(outFile, BenchmarkResults{
+		DbType:        info.name,
+		SchemaVariant: opts.SchemaVariant,
+		IngestMethod:  opts.IngestMethod,
+		Scenarios:     []ScenarioResult{scenario},
+	})
+}
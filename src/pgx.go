@@ -0,0 +1,613 @@
+//go:build !nopostgres || !notimescaledb || !nocratedb || !noquestdb || !nocustomsql || !nocockroachdb || !nocitus
+
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// This file holds the pieces shared by the backends that talk the PostgreSQL
+// wire protocol through pgx.
+
+// drainRows reads a result set to the end so that the timing covers the whole
+// response and the connection goes back to the pool.
+func drainRows(rows pgx.Rows) error {
+	for rows.Next() {
+	}
+	rows.Close()
+	return rows.Err()
+}
+
+func pingPostgres(ctx context.Context, connStr string) error {
+	config, err := pgx.ParseConfig(connStr)
+	if err != nil {
+		return err
+	}
+	if err := applyPgSecurity(&config.Config); err != nil {
+		return err
+	}
+	conn, err := pgx.ConnectConfig(ctx, config)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+	return conn.Ping(ctx)
+}
+
+// isTransientPostgres reports whether a PostgreSQL error is safe to retry.
+func isTransientPostgres(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// Class 08 is connection exception, 53 insufficient resources, 57P01
+		// admin shutdown and 40001 serialization failure.
+		switch {
+		case pgErr.Code[:2] == "08", pgErr.Code[:2] == "53", pgErr.Code == "57P01", pgErr.Code == "40001":
+			return true
+		}
+	}
+
+	return pgconn.SafeToRetry(err)
+}
+
+type postgresBackend struct {
+	pool   *pgxpool.Pool
+	schema string
+
+	// daily is set for a table partitioned by range of timestamp, whose
+	// partitions of a day are created as its first readings arrive.
+	daily *dailyPartitions
+}
+
+type dailyPartitions struct {
+	mu      sync.Mutex
+	created map[time.Time]bool
+}
+
+// applyPgSecurity applies the credential and TLS flags to a parsed
+// connection string. With TLS enabled the plain-text fallbacks of
+// sslmode=prefer are dropped, so the connection fails instead of silently
+// going unencrypted.
+func applyPgSecurity(config *pgconn.Config) error {
+	if dbCredentials.User != "" {
+		config.User = dbCredentials.User
+	}
+	if dbCredentials.Password != "" {
+		config.Password = dbCredentials.Password
+	}
+	tlsConfig, err := tlsSettings.config(config.Host)
+	if err != nil || tlsConfig == nil {
+		return err
+	}
+	config.TLSConfig = tlsConfig
+	config.Fallbacks = nil
+	return nil
+}
+
+// parsePoolConfig parses a pgx connection string and applies the -pool-*,
+// credential and TLS flags on top of it.
+func parsePoolConfig(connStr string) (*pgxpool.Config, error) {
+	config, err := pgxpool.ParseConfig(connStr)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyPgSecurity(&config.ConnConfig.Config); err != nil {
+		return nil, err
+	}
+	if poolTuning.MaxConns > 0 {
+		config.MaxConns = int32(poolTuning.MaxConns)
+	}
+	if poolTuning.MinConns > 0 {
+		config.MinConns = int32(poolTuning.MinConns)
+	}
+	if poolTuning.StatementCache >= 0 {
+		config.ConnConfig.StatementCacheCapacity = poolTuning.StatementCache
+		// The default mode needs the statement cache; without it every query
+		// is described before it runs.
+		if poolTuning.StatementCache == 0 && config.ConnConfig.DefaultQueryExecMode == pgx.QueryExecModeCacheStatement {
+			config.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeDescribeExec
+		}
+	}
+	return config, nil
+}
+
+// newPgPool opens a pool with the -pool-* flags applied.
+func newPgPool(connStr string) (*pgxpool.Pool, error) {
+	config, err := parsePoolConfig(connStr)
+	if err != nil {
+		return nil, err
+	}
+	return pgxpool.NewWithConfig(context.Background(), config)
+}
+
+func newPostgresBackend(connStr string, schema string) (*postgresBackend, error) {
+	config, err := parsePoolConfig(connStr)
+	if err != nil {
+		return nil, err
+	}
+	// Hour-of-day functions on TIMESTAMP WITH TIME ZONE use the session time
+	// zone; UTC makes them bucket like the other engines.
+	config.ConnConfig.RuntimeParams["timezone"] = "UTC"
+	for name, value := range durabilityParams {
+		config.ConnConfig.RuntimeParams[name] = value
+	}
+	pool, err := pgxpool.NewWithConfig(context.Background(), config)
+	if err != nil {
+		return nil, err
+	}
+	return &postgresBackend{pool: pool, schema: schema}, nil
+}
+
+func (b *postgresBackend) poolSettings() PoolSettings {
+	config := b.pool.Config()
+	settings := PoolSettings{
+		MaxConns:      int(config.MaxConns),
+		MinConns:      int(config.MinConns),
+		QueryExecMode: config.ConnConfig.DefaultQueryExecMode.String(),
+	}
+	if config.ConnConfig.DefaultQueryExecMode == pgx.QueryExecModeCacheStatement {
+		settings.StatementCache = config.ConnConfig.StatementCacheCapacity
+	}
+	return settings
+}
+
+func (b *postgresBackend) serverVersion(ctx context.Context) (string, error) {
+	var version string
+	err := b.pool.QueryRow(ctx, "SELECT version()").Scan(&version)
+	return version, err
+}
+
+func (b *postgresBackend) createSchema(ctx context.Context) error {
+	_, err := b.pool.Exec(ctx, b.schema)
+	return err
+}
+
+func (b *postgresBackend) setSchema(ddl string) {
+	b.schema = ddl
+}
+
+func (b *postgresBackend) ingest(ctx context.Context, readings []Reading) error {
+	if b.daily != nil {
+		if err := b.createPartitions(ctx, readings); err != nil {
+			return err
+		}
+	}
+	return b.ingestInto(ctx, "user_events", readings)
+}
+
+func (b *postgresBackend) partitionDaily() {
+	b.daily = &dailyPartitions{created: make(map[time.Time]bool)}
+}
+
+// createPartitions creates the daily partitions of user_events that the
+// readings fall into and that do not exist yet. Their time is part of the
+// ingestion, as the engines that partition on their own pay for it too.
+func (b *postgresBackend) createPartitions(ctx context.Context, readings []Reading) error {
+	b.daily.mu.Lock()
+	defer b.daily.mu.Unlock()
+	for _, reading := range readings {
+		day := readingTime(reading.LastUpdatedTime).Truncate(24 * time.Hour)
+		if b.daily.created[day] {
+			continue
+		}
+		stmt := fmt.Sprintf("CREATE TABLE IF NOT EXISTS user_events_%s PARTITION OF user_events FOR VALUES FROM ('%s') TO ('%s')",
+			day.Format("20060102"), day.Format(time.RFC3339), day.AddDate(0, 0, 1).Format(time.RFC3339))
+		if _, err := b.pool.Exec(ctx, stmt); err != nil {
+			return err
+		}
+		b.daily.created[day] = true
+	}
+	return nil
+}
+
+// partitionStats counts the partitions of user_events and sums their on-disk
+// size, indexes included.
+func (b *postgresBackend) partitionStats(ctx context.Context) (int, int64, error) {
+	var partitions int
+	var size int64
+	err := b.pool.QueryRow(ctx, "SELECT COUNT(*), COALESCE(SUM(pg_total_relation_size(inhrelid)), 0) FROM pg_inherits WHERE inhparent = 'user_events'::regclass").Scan(&partitions, &size)
+	return partitions, size, err
+}
+
+// pgMaxParams are the bind parameters of the wire protocol, which a
+// multi-row INSERT must stay under.
+const pgMaxParams = 65535
+
+// multiRowWriter writes readings with multi-row INSERTs of up to batchSize
+// rows, for engines without COPY FROM STDIN or where INSERTs are compared
+// with it.
+type multiRowWriter struct {
+	batchSize int
+	// insert is the statement of the last table and batch size, reused
+	// while they hold.
+	insert      string
+	insertTable string
+	insertRows  int
+	args        []any
+}
+
+// write inserts the readings into table in batches; columns are the fixed
+// columns, the extra ones are appended.
+func (w *multiRowWriter) write(ctx context.Context, pool *pgxpool.Pool, table string, columns []string, readings []Reading) error {
+	columns = append(slices.Clip(columns), extraColumnNames()...)
+	size := min(w.batchSize, pgMaxParams/len(columns))
+	for start := 0; start < len(readings); start += size {
+		rows := readings[start:min(start+size, len(readings))]
+		w.args = w.args[:0]
+		for _, reading := range rows {
+			w.args = append(w.args, reading.UserId, readingTime(reading.LastUpdatedTime), reading.Connection.Rssi, reading.Connection.Ssid)
+			w.args = append(w.args, extraValues(&reading)...)
+		}
+		if _, err := pool.Exec(ctx, w.statement(table, columns, len(rows)), w.args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// statement returns the INSERT of the given number of rows.
+func (w *multiRowWriter) statement(table string, columns []string, rows int) string {
+	if table == w.insertTable && rows == w.insertRows {
+		return w.insert
+	}
+	var stmt strings.Builder
+	stmt.WriteString("INSERT INTO " + table + " (" + strings.Join(columns, ", ") + ") VALUES ")
+	for i := range rows {
+		if i > 0 {
+			stmt.WriteString(", ")
+		}
+		stmt.WriteString("(")
+		for j := range columns {
+			if j > 0 {
+				stmt.WriteString(", ")
+			}
+			fmt.Fprintf(&stmt, "$%d", len(columns)*i+j+1)
+		}
+		stmt.WriteString(")")
+	}
+	w.insert, w.insertTable, w.insertRows = stmt.String(), table, rows
+	return w.insert
+}
+
+func (b *postgresBackend) ingestInto(ctx context.Context, table string, readings []Reading) error {
+	rows := newReadingRows(readings)
+	defer rows.release()
+
+	_, err := b.pool.CopyFrom(
+		ctx,
+		pgx.Identifier{table},
+		append([]string{"user_id", "timestamp", "rssi", "ssid"}, extraColumnNames()...),
+		rows,
+	)
+	return err
+}
+
+// ingestLocated copies the readings with their location into
+// user_events_located.
+func (b *postgresBackend) ingestLocated(ctx context.Context, readings []Reading) error {
+	rows := newReadingRows(readings)
+	rows.located = true
+	defer rows.release()
+
+	_, err := b.pool.CopyFrom(
+		ctx,
+		pgx.Identifier{locatedTable},
+		[]string{"user_id", "timestamp", "rssi", "ssid", "building", "floor_number", "latitude", "longitude"},
+		rows,
+	)
+	return err
+}
+
+// upsert copies the readings into a staging table and moves them over with ON
+// CONFLICT DO NOTHING, as COPY itself fails on the first duplicate key.
+func (b *postgresBackend) upsert(ctx context.Context, readings []Reading) error {
+	tx, err := b.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "CREATE TEMP TABLE user_events_staging (user_id VARCHAR(255), timestamp TIMESTAMPTZ, rssi REAL, ssid VARCHAR(255)) ON COMMIT DROP"); err != nil {
+		return err
+	}
+
+	rows := newReadingRows(readings)
+	defer rows.release()
+	if _, err := tx.CopyFrom(
+		ctx,
+		pgx.Identifier{"user_events_staging"},
+		[]string{"user_id", "timestamp", "rssi", "ssid"},
+		rows,
+	); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, "INSERT INTO user_events (user_id, timestamp, rssi, ssid) SELECT user_id, timestamp, rssi, ssid FROM user_events_staging ON CONFLICT DO NOTHING"); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (b *postgresBackend) expireBefore(ctx context.Context, cutoff time.Time) error {
+	_, err := b.pool.Exec(ctx, "DELETE FROM user_events WHERE timestamp < $1", cutoff)
+	return err
+}
+
+func (b *postgresBackend) count(ctx context.Context, q string) (int64, error) {
+	var n int64
+	err := b.pool.QueryRow(ctx, q).Scan(&n)
+	return n, err
+}
+
+func (b *postgresBackend) dropTable(ctx context.Context, table string) error {
+	return b.exec(ctx, "DROP TABLE IF EXISTS "+table)
+}
+
+func (b *postgresBackend) exec(ctx context.Context, stmt string) error {
+	_, err := b.pool.Exec(ctx, stmt)
+	return err
+}
+
+func (b *postgresBackend) query(ctx context.Context, q string, args ...any) error {
+	rows, err := b.pool.Query(ctx, q, args...)
+	if err != nil {
+		return err
+	}
+	return drainRows(rows)
+}
+
+func (b *postgresBackend) scanRows(ctx context.Context, visit func(values []any), q string, args ...any) error {
+	rows, err := b.pool.Query(ctx, q, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return err
+		}
+		visit(values)
+	}
+	return rows.Err()
+}
+
+func (b *postgresBackend) timeBounds(ctx context.Context, q string) (time.Time, time.Time, error) {
+	var minTime, maxTime time.Time
+	err := b.pool.QueryRow(ctx, q).Scan(&minTime, &maxTime)
+	return minTime, maxTime, err
+}
+
+func (b *postgresBackend) plan(ctx context.Context, stmt string, args ...any) (string, error) {
+	rows, err := b.pool.Query(ctx, stmt, args...)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	var lines []string
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return "", err
+		}
+		lines = append(lines, formatPlanRow(values))
+	}
+	return strings.Join(lines, "\n"), rows.Err()
+}
+
+// export streams the table as CSV with COPY TO STDOUT. The query form also
+// covers hypertables, whose parent table is empty.
+func (b *postgresBackend) export(ctx context.Context, w io.Writer) error {
+	conn, err := b.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	_, err = conn.Conn().PgConn().CopyTo(ctx, w, "COPY (SELECT * FROM user_events) TO STDOUT WITH (FORMAT csv)")
+	return err
+}
+
+// exportRows streams the result of q as CSV for engines that speak the
+// PostgreSQL wire protocol but not COPY TO STDOUT.
+func exportRows(ctx context.Context, pool *pgxpool.Pool, q string, w io.Writer) error {
+	rows, err := pool.Query(ctx, q)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	out := csv.NewWriter(w)
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return err
+		}
+		record := make([]string, len(values))
+		for i, value := range values {
+			record[i] = fmt.Sprint(value)
+		}
+		if err := out.Write(record); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func (b *postgresBackend) close() {
+	b.pool.Close()
+}
+
+func (b *postgresBackend) lookupReading(ctx context.Context, userId string, at time.Time) ([]storedReading, error) {
+	return lookupPgReadings(ctx, b.pool, "SELECT rssi, ssid FROM user_events WHERE user_id = $1 AND timestamp = $2", userId, at)
+}
+
+// lookupPgReadings runs a fidelity lookup q that selects rssi and ssid.
+func lookupPgReadings(ctx context.Context, pool *pgxpool.Pool, q string, args ...any) ([]storedReading, error) {
+	rows, err := pool.Query(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (storedReading, error) {
+		var stored storedReading
+		err := row.Scan(&stored.Rssi, &stored.Ssid)
+		return stored, err
+	})
+}
+
+// countByHour reads the rows of an hour-of-day aggregation that selects the
+// hour as an integer and the count.
+func (b *postgresBackend) countByHour(ctx context.Context, q string) (map[int]int64, error) {
+	rows, err := b.pool.Query(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[int]int64{}
+	for rows.Next() {
+		var hour int
+		var count int64
+		if err := rows.Scan(&hour, &count); err != nil {
+			return nil, err
+		}
+		counts[hour] = count
+	}
+	return counts, rows.Err()
+}
+
+type pgProbeReader struct {
+	conn *pgxpool.Conn
+}
+
+// newProbeReader holds one pooled connection for the duration of the probe;
+// writes go through the pool and therefore use a different connection.
+func (b *postgresBackend) newProbeReader(ctx context.Context) (probeReader, error) {
+	conn, err := b.pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &pgProbeReader{conn: conn}, nil
+}
+
+func (r *pgProbeReader) visible(ctx context.Context, userId string) (bool, error) {
+	var count int
+	err := r.conn.QueryRow(ctx, "SELECT COUNT(*) FROM user_events WHERE user_id = $1", userId).Scan(&count)
+	return count > 0, err
+}
+
+func (r *pgProbeReader) release() {
+	r.conn.Release()
+}
+
+// markServerTime resets pg_stat_statements, which the server must preload
+// (shared_preload_libraries), so that it then only holds the runs of one query.
+func (b *postgresBackend) markServerTime(ctx context.Context) error {
+	if _, err := b.pool.Exec(ctx, "CREATE EXTENSION IF NOT EXISTS pg_stat_statements"); err != nil {
+		return err
+	}
+	_, err := b.pool.Exec(ctx, "SELECT pg_stat_statements_reset()")
+	return err
+}
+
+// serverTimeMs leaves out the statements reading and resetting the statistics.
+func (b *postgresBackend) serverTimeMs(ctx context.Context) (float64, int, error) {
+	var total float64
+	var calls int
+	err := b.pool.QueryRow(ctx, `SELECT COALESCE(SUM(total_exec_time), 0), COALESCE(SUM(calls), 0)::int
+		FROM pg_stat_statements
+		WHERE dbid = (SELECT oid FROM pg_database WHERE datname = current_database())
+		AND query NOT ILIKE '%pg_stat_statements%'`).Scan(&total, &calls)
+	if err != nil || calls == 0 {
+		return 0, 0, err
+	}
+	return total / float64(calls), calls, nil
+}
+
+// readingRows feeds the readings to COPY one row at a time through a single
+// row buffer, instead of a slice per reading that lives until the copy ends.
+// pgx encodes every row before it asks for the next one, so the buffer can be
+// reused. The sources are pooled across batches. located rows also hold the
+// building, floor and coordinates of the locations scenario.
+type readingRows struct {
+	readings []Reading
+	next     int
+	located  bool
+	row      []any
+}
+
+var readingRowsPool = sync.Pool{New: func() any {
+	return &readingRows{row: make([]any, 8)}
+}}
+
+func newReadingRows(readings []Reading) *readingRows {
+	rows := readingRowsPool.Get().(*readingRows)
+	if len(rows.row) < 4+len(extraColumns) {
+		rows.row = make([]any, 4+len(extraColumns))
+	}
+	rows.readings = readings
+	rows.next = 0
+	return rows
+}
+
+func (r *readingRows) Next() bool {
+	r.next++
+	return r.next <= len(r.readings)
+}
+
+func (r *readingRows) Values() ([]any, error) {
+	reading := &r.readings[r.next-1]
+	r.row[0] = reading.UserId
+	r.row[1] = readingTime(reading.LastUpdatedTime)
+	r.row[2] = reading.Connection.Rssi
+	r.row[3] = reading.Connection.Ssid
+	if !r.located {
+		return append(r.row[:4], extraValues(reading)...), nil
+	}
+	r.row[4], r.row[5], r.row[6], r.row[7] = locationValues(reading)
+	return r.row[:8], nil
+}
+
+func (r *readingRows) Err() error {
+	return nil
+}
+
+func (r *readingRows) release() {
+	r.readings = nil
+	r.located = false
+	clear(r.row)
+	readingRowsPool.Put(r)
+}
+
+// batchPool reuses the pgx batches of the INSERT-based ingestion, whose queue
+// otherwise grows from empty for every chunk.
+var batchPool = sync.Pool{New: func() any { return &pgx.Batch{} }}
+
+func getBatch(rows int) *pgx.Batch {
+	batch := batchPool.Get().(*pgx.Batch)
+	batch.QueuedQueries = slices.Grow(batch.QueuedQueries, rows)
+	return batch
+}
+
+// putBatch returns a batch once SendBatch has been closed.
+func putBatch(batch *pgx.Batch) {
+	clear(batch.QueuedQueries)
+	batch.QueuedQueries = batch.QueuedQueries[:0]
+	batchPool.Put(batch)
+}
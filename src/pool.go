@@ -0,0 +1,49 @@
+package main
+
+import "fmt"
+
+// poolOptions are the -pool-* flags. Zero connection counts and a negative
+// statement cache keep the driver's defaults, which are recorded in the results
+// either way, since they silently shape the comparison.
+type poolOptions struct {
+	MaxConns       int
+	MinConns       int
+	StatementCache int
+}
+
+var poolTuning = poolOptions{StatementCache: -1}
+
+func (p poolOptions) validate() error {
+	if p.MaxConns < 0 || p.MinConns < 0 {
+		return fmt.Errorf("connection counts must not be negative")
+	}
+	if p.MaxConns > 0 && p.MinConns > p.MaxConns {
+		return fmt.Errorf("-pool-min-conns %d exceeds -pool-max-conns %d", p.MinConns, p.MaxConns)
+	}
+	return nil
+}
+
+// PoolSettings are the connection pool settings in effect for a run.
+// MaxConns 0 means unlimited; StatementCache and QueryExecMode are only set by
+// drivers with a prepared statement cache.
+type PoolSettings struct {
+	MaxConns       int    `json:"maxConns"`
+	MinConns       int    `json:"minConns"`
+	StatementCache int    `json:"statementCache,omitempty"`
+	QueryExecMode  string `json:"queryExecMode,omitempty"`
+}
+
+// pooledBackend is implemented by backends that keep a pool of connections.
+// The HTTP clients of InfluxDB are not pooled by the benchmark.
+type pooledBackend interface {
+	poolSettings() PoolSettings
+}
+
+func poolSettingsOf(b backend) *PoolSettings {
+	pooled, ok := b.(pooledBackend)
+	if !ok {
+		return nil
+	}
+	settings := pooled.poolSettings()
+	return &settings
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"path/filepath"
+	"runtime"
+	rpprof "runtime/pprof"
+	"time"
+)
+
+// servePprof serves the profiles of the client on addr, e.g. :6060, for
+// go tool pprof http://localhost:6060/debug/pprof/profile while a run is in
+// progress. It returns once the address is listened on.
+func servePprof(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	go http.Serve(listener, mux)
+	infof("Serving the client profiles on http://%s/debug/pprof/\n", listener.Addr())
+	return nil
+}
+
+// ClientProfile is a CPU and a heap profile of the client taken over one
+// phase of a run. A client busy on all its CPUs for the phase, with a CPU
+// profile dominated by encoding and sending, is the bottleneck rather than
+// the database.
+type ClientProfile struct {
+	Phase      string `json:"phase"`
+	CPUFile    string `json:"cpuFile"`
+	HeapFile   string `json:"heapFile"`
+	DurationMs int64  `json:"durationMs"`
+	// CPUSeconds is the user and system CPU time of the client over the
+	// phase; it is only measured on Linux.
+	CPUSeconds float64 `json:"cpuSeconds,omitempty"`
+}
+
+// profilePhase starts a CPU profile of a phase into dir. The returned stop
+// function ends it, writes a heap profile next to it and returns both. The
+// files are named after prefix and the phase, e.g. postgres-ingestion.cpu.pprof.
+func profilePhase(dir string, prefix string, phase string) (stop func() (*ClientProfile, error), err error) {
+	profile := &ClientProfile{
+		Phase:    phase,
+		CPUFile:  filepath.Join(dir, fmt.Sprintf("%s-%s.cpu.pprof", prefix, phase)),
+		HeapFile: filepath.Join(dir, fmt.Sprintf("%s-%s.heap.pprof", prefix, phase)),
+	}
+	cpuFile, err := os.Create(profile.CPUFile)
+	if err != nil {
+		return nil, err
+	}
+	if err := rpprof.StartCPUProfile(cpuFile); err != nil {
+		cpuFile.Close()
+		return nil, err
+	}
+	start := time.Now()
+	cpuStart := processCPUTime()
+	return func() (*ClientProfile, error) {
+		rpprof.StopCPUProfile()
+		profile.DurationMs = time.Since(start).Milliseconds()
+		profile.CPUSeconds = (processCPUTime() - cpuStart).Seconds()
+		if err := cpuFile.Close(); err != nil {
+			return nil, err
+		}
+		heapFile, err := os.Create(profile.HeapFile)
+		if err != nil {
+			return nil, err
+		}
+		// The heap profile reflects the last garbage collection.
+		runtime.GC()
+		if err := rpprof.WriteHeapProfile(heapFile); err != nil {
+			heapFile.Close()
+			return nil, err
+		}
+		if err := heapFile.Close(); err != nil {
+			return nil, err
+		}
+		infof("Profiled the %s (%d ms, %.1f CPU seconds of the client) into %s\n",
+			phase, profile.DurationMs, profile.CPUSeconds, profile.CPUFile)
+		return profile, nil
+	}, nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// loadedChunk is a chunk of the measured dataset ready to be written, with the
+// replay shift and the cardinality multiplication applied.
+type loadedChunk struct {
+	readings   []Reading
+	decompress time.Duration
+	err        error
+}
+
+// loadChunks returns the chunks first to total-1 of the measured dataset, of
+// nFiles chunk files, one per call of next. With opts.PrefetchChunks above 0 a
+// goroutine reads and decodes up to that many chunks ahead while the current
+// one is written, so the database does not sit idle during the decode. stop
+// ends the prefetching when the ingestion returns early.
+func loadChunks(first, total, nFiles int, opts benchmarkOptions) (next func() loadedChunk, stop func()) {
+	// The shifter needs the chunks in order, which both modes keep.
+	var shifter replayShifter
+	load := func(chunk int) loadedChunk {
+		_, data, decompress, err := loadDataChunkTimed(readingsDir, chunk%nFiles)
+		if err != nil {
+			return loadedChunk{err: err}
+		}
+		shifter.shift(data.Response, chunk/nFiles)
+		multiplyCardinality(data.Response, opts.CardinalityFactor)
+		return loadedChunk{readings: data.Response, decompress: decompress}
+	}
+
+	if opts.PrefetchChunks <= 0 {
+		chunk := first
+		return func() loadedChunk {
+			loaded := load(chunk)
+			chunk++
+			return loaded
+		}, func() {}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	// One chunk waits in the send on top of the buffer.
+	loaded := make(chan loadedChunk, opts.PrefetchChunks-1)
+	go func() {
+		defer close(loaded)
+		for chunk := first; chunk < total; chunk++ {
+			c := load(chunk)
+			select {
+			case loaded <- c:
+			case <-ctx.Done():
+				return
+			}
+			if c.err != nil {
+				return
+			}
+		}
+	}()
+	return func() loadedChunk { return <-loaded }, cancel
+}
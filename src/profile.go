@@ -0,0 +1,289 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"src/results"
+)
+
+// DatasetProfile describes a readings directory, for the dataset section of a
+// paper and for sizing synthetic data: how much there is, over which time,
+// how many distinct users and access points, how the signal is distributed,
+// and how often readings arrive.
+type DatasetProfile struct {
+	Dir     string `json:"dir"`
+	Chunks  int    `json:"chunks"`
+	Records int64  `json:"records"`
+	// RecordsPerChunk is the min, mean and max of the chunk sizes.
+	RecordsPerChunk Distribution `json:"recordsPerChunk"`
+	First           time.Time    `json:"first"`
+	Last            time.Time    `json:"last"`
+	SpanHours       float64      `json:"spanHours"`
+	Users           int          `json:"users"`
+	Ssids           int          `json:"ssids"`
+	// RecordsPerUser and RecordsPerSsid show how skewed the activity is.
+	RecordsPerUser Distribution `json:"recordsPerUser"`
+	RecordsPerSsid Distribution `json:"recordsPerSsid"`
+	// TopSsids are the ten access points with the most readings.
+	TopSsids []SsidCount    `json:"topSsids"`
+	Rssi     Distribution   `json:"rssi"`
+	RssiBins []RssiBin      `json:"rssiBins"`
+	PerHour  [24]int64      `json:"perHourUtc"`
+	Arrivals ArrivalProfile `json:"arrivals"`
+}
+
+// Distribution summarizes a set of values; percentiles are nearest-rank.
+type Distribution struct {
+	Min    float64 `json:"min"`
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"stdDev"`
+	P5     float64 `json:"p5"`
+	P25    float64 `json:"p25"`
+	P50    float64 `json:"p50"`
+	P75    float64 `json:"p75"`
+	P95    float64 `json:"p95"`
+	P99    float64 `json:"p99"`
+	Max    float64 `json:"max"`
+}
+
+type SsidCount struct {
+	Ssid    string `json:"ssid"`
+	Records int64  `json:"records"`
+}
+
+// RssiBin counts the readings of a 10 dBm band, [From, From+10).
+type RssiBin struct {
+	From    int   `json:"from"`
+	Records int64 `json:"records"`
+}
+
+// ArrivalProfile holds the gaps between readings, in seconds: over the
+// whole stream in time order, and between two readings of the same user.
+// OutOfOrder is the share of readings older than the one before them in the
+// files, which engines that expect appends in time order pay for.
+type ArrivalProfile struct {
+	Gaps           Distribution `json:"gapsSeconds"`
+	SameSecond     float64      `json:"sameSecond"`
+	PerUserGaps    Distribution `json:"perUserGapsSeconds"`
+	OutOfOrder     float64      `json:"outOfOrder"`
+	MeanRatePerSec float64      `json:"meanRatePerSecond"`
+}
+
+// runProfile is the profile subcommand: it scans the chunks of a readings
+// directory and reports their statistics, without a database.
+func runProfile(flags *flag.FlagSet, args []string) error {
+	dir := flags.String("data", readingsDir, "Readings directory to profile (readings_N.json, .gz or .zst)")
+	outFile := flags.String("o", "", "Also write the profile as JSON to this file")
+	sourceTimezone := flags.String("source-timezone", "UTC", "Time zone whose wall clock the dataset's timestamps are in; UTC takes them as Unix times")
+	schemaMappingFile := flags.String("schema-mapping", "", "YAML file mapping the JSON of a dataset with another layout to the readings")
+	if err := parseCommandFlags(flags, args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return asConfigError(err)
+	}
+	location, err := time.LoadLocation(*sourceTimezone)
+	if err != nil {
+		return configErrorf("unknown time zone for -source-timezone: %s", *sourceTimezone)
+	}
+	sourceLocation = location
+	if *schemaMappingFile != "" {
+		if readingMapping, err = readSchemaMapping(*schemaMappingFile); err != nil {
+			return asConfigError(err)
+		}
+	}
+
+	profile, err := profileDataset(*dir)
+	if err != nil {
+		return err
+	}
+	profile.print()
+	if *outFile == "" {
+		return nil
+	}
+	encoded, err := json.MarshalIndent(profile, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(*outFile, append(encoded, '\n'), 0644)
+}
+
+func profileDataset(dir string) (*DatasetProfile, error) {
+	profile := &DatasetProfile{Dir: dir}
+	var chunkSizes, rssi []float64
+	perUser := map[string][]int64{}
+	perSsid := map[string]int64{}
+	var times []int64
+	var outOfOrder int64
+	previous := int64(math.MinInt64)
+	for chunk := 0; ; chunk++ {
+		if err := interrupted(); err != nil {
+			return nil, err
+		}
+		hasNext, data, err := loadDataChunk(dir, chunk)
+		if err != nil {
+			return nil, err
+		}
+		profile.Chunks++
+		chunkSizes = append(chunkSizes, float64(len(data.Response)))
+		for _, reading := range data.Response {
+			at := readingTime(reading.LastUpdatedTime)
+			unix := at.Unix()
+			if unix < previous {
+				outOfOrder++
+			}
+			previous = unix
+			times = append(times, unix)
+			perUser[reading.UserId] = append(perUser[reading.UserId], unix)
+			perSsid[reading.Connection.Ssid]++
+			rssi = append(rssi, reading.Connection.Rssi)
+			profile.PerHour[at.Hour()]++
+		}
+		if !hasNext {
+			break
+		}
+	}
+	profile.Records = int64(len(times))
+	if profile.Records == 0 {
+		return nil, fmt.Errorf("%s holds no readings", dir)
+	}
+
+	profile.RecordsPerChunk = distribution(chunkSizes)
+	slices.Sort(times)
+	profile.First = time.Unix(times[0], 0).UTC()
+	profile.Last = time.Unix(times[len(times)-1], 0).UTC()
+	profile.SpanHours = profile.Last.Sub(profile.First).Hours()
+	profile.Users = len(perUser)
+	profile.Ssids = len(perSsid)
+
+	var userCounts, ssidCounts []float64
+	for _, userTimes := range perUser {
+		userCounts = append(userCounts, float64(len(userTimes)))
+	}
+	for ssid, n := range perSsid {
+		ssidCounts = append(ssidCounts, float64(n))
+		profile.TopSsids = append(profile.TopSsids, SsidCount{Ssid: ssid, Records: n})
+	}
+	profile.RecordsPerUser = distribution(userCounts)
+	profile.RecordsPerSsid = distribution(ssidCounts)
+	slices.SortFunc(profile.TopSsids, func(a, b SsidCount) int {
+		if a.Records != b.Records {
+			return cmp.Compare(b.Records, a.Records)
+		}
+		return strings.Compare(a.Ssid, b.Ssid)
+	})
+	profile.TopSsids = profile.TopSsids[:min(len(profile.TopSsids), 10)]
+
+	profile.Rssi = distribution(rssi)
+	bins := map[int]int64{}
+	for _, value := range rssi {
+		bins[int(math.Floor(value/10))*10]++
+	}
+	for from, n := range bins {
+		profile.RssiBins = append(profile.RssiBins, RssiBin{From: from, Records: n})
+	}
+	slices.SortFunc(profile.RssiBins, func(a, b RssiBin) int { return a.From - b.From })
+
+	profile.Arrivals = arrivalProfile(times, perUser)
+	profile.Arrivals.OutOfOrder = float64(outOfOrder) / float64(profile.Records)
+	return profile, nil
+}
+
+// arrivalProfile computes the gaps of the sorted stream times and of the
+// readings of every user.
+func arrivalProfile(times []int64, perUser map[string][]int64) ArrivalProfile {
+	var arrivals ArrivalProfile
+	gaps := make([]float64, 0, len(times))
+	sameSecond := 0
+	for i := 1; i < len(times); i++ {
+		gap := times[i] - times[i-1]
+		if gap == 0 {
+			sameSecond++
+		}
+		gaps = append(gaps, float64(gap))
+	}
+	if len(gaps) > 0 {
+		arrivals.Gaps = distribution(gaps)
+		arrivals.SameSecond = float64(sameSecond) / float64(len(gaps))
+	}
+	if span := times[len(times)-1] - times[0]; span > 0 {
+		arrivals.MeanRatePerSec = float64(len(times)) / float64(span)
+	}
+
+	var userGaps []float64
+	for _, userTimes := range perUser {
+		slices.Sort(userTimes)
+		for i := 1; i < len(userTimes); i++ {
+			userGaps = append(userGaps, float64(userTimes[i]-userTimes[i-1]))
+		}
+	}
+	if len(userGaps) > 0 {
+		arrivals.PerUserGaps = distribution(userGaps)
+	}
+	return arrivals
+}
+
+func distribution(values []float64) Distribution {
+	if len(values) == 0 {
+		return Distribution{}
+	}
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	var sum float64
+	for _, v := range sorted {
+		sum += v
+	}
+	mean := sum / float64(len(sorted))
+	var squares float64
+	for _, v := range sorted {
+		squares += (v - mean) * (v - mean)
+	}
+	at := func(p float64) float64 { return sorted[results.NearestRank(len(sorted), p)] }
+	return Distribution{
+		Min:    sorted[0],
+		Mean:   mean,
+		StdDev: math.Sqrt(squares / float64(len(sorted))),
+		P5:     at(0.05),
+		P25:    at(0.25),
+		P50:    at(0.50),
+		P75:    at(0.75),
+		P95:    at(0.95),
+		P99:    at(0.99),
+		Max:    sorted[len(sorted)-1],
+	}
+}
+
+func (p *DatasetProfile) print() {
+	fmt.Printf("Dataset             %s\n", p.Dir)
+	fmt.Printf("Chunks              %d (%.0f to %.0f records, mean %.0f)\n", p.Chunks, p.RecordsPerChunk.Min, p.RecordsPerChunk.Max, p.RecordsPerChunk.Mean)
+	fmt.Printf("Records             %d\n", p.Records)
+	fmt.Printf("Time span           %s to %s (%.1f hours)\n", p.First.Format(time.RFC3339), p.Last.Format(time.RFC3339), p.SpanHours)
+	fmt.Printf("Users               %d (records per user: median %.0f, p95 %.0f, max %.0f)\n", p.Users, p.RecordsPerUser.P50, p.RecordsPerUser.P95, p.RecordsPerUser.Max)
+	fmt.Printf("SSIDs               %d (records per SSID: median %.0f, p95 %.0f, max %.0f)\n", p.Ssids, p.RecordsPerSsid.P50, p.RecordsPerSsid.P95, p.RecordsPerSsid.Max)
+	for _, top := range p.TopSsids {
+		fmt.Printf("                    %-24s %d\n", top.Ssid, top.Records)
+	}
+	fmt.Printf("RSSI (dBm)          min %g, p5 %g, median %g, mean %.1f, p95 %g, max %g, std dev %.1f\n", p.Rssi.Min, p.Rssi.P5, p.Rssi.P50, p.Rssi.Mean, p.Rssi.P95, p.Rssi.Max, p.Rssi.StdDev)
+	for _, bin := range p.RssiBins {
+		fmt.Printf("                    [%d, %d) %d\n", bin.From, bin.From+10, bin.Records)
+	}
+	fmt.Printf("Records per hour    ")
+	for hour, n := range p.PerHour {
+		fmt.Printf("%02d:%d ", hour, n)
+	}
+	fmt.Println("(UTC)")
+	a := p.Arrivals
+	fmt.Printf("Arrival rate        %.2f records/s on average\n", a.MeanRatePerSec)
+	fmt.Printf("Gaps (s)            median %g, p95 %g, p99 %g, max %g; %.1f%% in the same second\n", a.Gaps.P50, a.Gaps.P95, a.Gaps.P99, a.Gaps.Max, 100*a.SameSecond)
+	fmt.Printf("Per-user gaps (s)   median %g, p95 %g, p99 %g, max %g\n", a.PerUserGaps.P50, a.PerUserGaps.P95, a.PerUserGaps.P99, a.PerUserGaps.Max)
+	fmt.Printf("Out of order        %.2f%% of the records are older than the one before them\n", 100*a.OutOfOrder)
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// progressLog journals the results of a run while it goes: every phase, chunk
+// and query is appended as a JSON line to <out>.progress.jsonl as soon as it is
+// measured, so a crash late in the run does not lose the ingestion. The
+// journal is removed once the complete result file is written. A run that
+// fails writes the partial results it holds instead; when the process dies
+// before that, -recover-results assembles them from the journal.
+type progressLog struct {
+	path    string
+	outFile string
+	file    *os.File
+	// measured is set once a phase or an element was journaled, on top of
+	// the settings of the run.
+	measured bool
+}
+
+// progressEntry is one line of the journal. Value is the JSON of the result
+// field named by Field, or of one element of it when Append is set; the field
+// "run" holds the top-level settings of the run.
+type progressEntry struct {
+	Field  string          `json:"field"`
+	Append bool            `json:"append,omitempty"`
+	Value  json.RawMessage `json:"value"`
+}
+
+func progressPath(outFile string) string {
+	return outFile + ".progress.jsonl"
+}
+
+// openProgressLog starts the journal of a run, replacing the one of an
+// earlier run with the same output file. A journal that cannot be created
+// only costs the safety net, not the run.
+func openProgressLog(outFile string) *progressLog {
+	path := progressPath(outFile)
+	file, err := os.Create(path)
+	if err != nil {
+		fmt.Printf("[WARN] Failed to create the progress journal %s: %v\n", path, err)
+		return nil
+	}
+	return &progressLog{path: path, outFile: outFile, file: file}
+}
+
+// record journals the value of a result field; a nil log records nothing.
+func (p *progressLog) record(field string, value any) {
+	p.write(field, false, value)
+}
+
+// recordElement journals one element of a result list, e.g. a chunk of the
+// ingestion or a query of the catalog.
+func (p *progressLog) recordElement(field string, value any) {
+	p.write(field, true, value)
+}
+
+func (p *progressLog) write(field string, appendValue bool, value any) {
+	if p == nil {
+		return
+	}
+	if field != "run" {
+		p.measured = true
+	}
+	encoded, err := json.Marshal(value)
+	if err == nil {
+		encoded, err = json.Marshal(progressEntry{Field: field, Append: appendValue, Value: encoded})
+	}
+	if err == nil {
+		_, err = p.file.Write(append(encoded, '\n'))
+	}
+	if err == nil {
+		err = p.file.Sync()
+	}
+	if err != nil {
+		fmt.Printf("[WARN] Failed to journal %s to %s: %v\n", field, p.path, err)
+	}
+}
+
+// finish closes the journal. When the run failed, the results collected so far
+// are written to the result file, marked partial. A run that failed before
+// anything was measured, e.g. on a bad connection string, writes no result
+// file, which analyses would pick up as an empty run. The journal is removed
+// once a result file holds its content or there is none to hold.
+func (p *progressLog) finish(completed bool) {
+	if p == nil {
+		return
+	}
+	p.file.Close()
+	if !completed && !p.measured {
+		fmt.Printf("[WARN] The run failed before anything was measured, writing no result file\n")
+	} else if !completed {
+		fmt.Printf("[WARN] The run did not complete, writing its partial results to %s\n", p.outFile)
+		if err := recoverResults(p.path, p.outFile); err != nil {
+			fmt.Printf("[WARN] Failed to write the partial results, recover them with -recover-results %s: %v\n", p.path, err)
+			return
+		}
+	}
+	os.Remove(p.path)
+}
+
+// recoverResults assembles the result file of an interrupted run from its
+// journal. The results are marked partial.
+func recoverResults(journal string, outFile string) error {
+	file, err := os.Open(journal)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	fields := map[string]json.RawMessage{}
+	lists := map[string][]json.RawMessage{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 64<<20)
+	for line := 1; scanner.Scan(); line++ {
+		var entry progressEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// The last line is cut short when the process died while writing
+			// it.
+			fmt.Printf("[WARN] Skipping line %d of %s: %v\n", line, journal, err)
+			continue
+		}
+		switch {
+		case entry.Field == "run":
+			var run map[string]json.RawMessage
+			if err := json.Unmarshal(entry.Value, &run); err != nil {
+				return fmt.Errorf("%s:%d: %w", journal, line, err)
+			}
+			for name, value := range run {
+				fields[name] = value
+			}
+		case entry.Append:
+			lists[entry.Field] = append(lists[entry.Field], entry.Value)
+		default:
+			fields[entry.Field] = entry.Value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	for name, values := range lists {
+		encoded, err := json.Marshal(values)
+		if err != nil {
+			return err
+		}
+		fields[name] = encoded
+	}
+
+	encoded, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	var results BenchmarkResults
+	if err := json.Unmarshal(encoded, &results); err != nil {
+		return fmt.Errorf("%s: %w", journal, err)
+	}
+	results.Partial = true
+	infof("Recovered %d ingestion chunks and %d queries of %s\n", len(results.Ingestion), len(results.Queries), results.DbType)
+	// Partial results are not published to the result sinks, whose history
+	// holds complete runs only.
+	encoded, err = json.Marshal(results)
+	if err != nil {
+		return err
+	}
+	if err := writeResultFile(outFile, append(encoded, '\n')); err != nil {
+		return err
+	}
+	addToRunSummary(results)
+	return nil
+}
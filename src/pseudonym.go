@@ -0,0 +1,101 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Pseudonymization modes of -pseudonymize.
+const (
+	// pseudonymHMAC replaces a user id with the hex HMAC-SHA256 of it.
+	pseudonymHMAC = "hmac"
+	// pseudonymToken replaces a user id with a token of the same shape, cut
+	// from the HMAC: a MAC address stays a (locally administered) MAC
+	// address, any other id becomes hex of its length, but at least 16 digits
+	// so that distinct ids stay distinct. The stored data keeps about the size
+	// and the cardinality of the original, so engines compress and index it
+	// alike.
+	pseudonymToken = "token"
+)
+
+// macAddress matches user ids that are MAC addresses, with : or -.
+var macAddress = regexp.MustCompile(`^[0-9A-Fa-f]{2}([:-])[0-9A-Fa-f]{2}(?:[:-][0-9A-Fa-f]{2}){4}$`)
+
+// pseudonymizer replaces the user ids of the readings as the chunks are
+// loaded, so that a benchmark on real campus data never sends an identifying
+// id to the database, and its results and tables can be shared. The same id
+// always gets the same pseudonym under the same key, so every phase, the
+// fidelity audit and the join dimensions included, sees consistent ids. A nil
+// pseudonymizer keeps the ids.
+type pseudonymizer struct {
+	mode  string
+	key   []byte
+	cache sync.Map
+}
+
+// pseudonyms is set from -pseudonymize.
+var pseudonyms *pseudonymizer
+
+func newPseudonymizer(mode string, key string) (*pseudonymizer, error) {
+	if mode != pseudonymHMAC && mode != pseudonymToken {
+		return nil, fmt.Errorf("unknown -pseudonymize mode %q, expected %s or %s", mode, pseudonymHMAC, pseudonymToken)
+	}
+	if len(key) < 16 {
+		return nil, fmt.Errorf("-pseudonymize needs a -pseudonymize-key of at least 16 bytes")
+	}
+	return &pseudonymizer{mode: mode, key: []byte(key)}, nil
+}
+
+// apply replaces the user ids of the readings in place.
+func (p *pseudonymizer) apply(readings []Reading) {
+	if p == nil {
+		return
+	}
+	for i := range readings {
+		readings[i].UserId = p.userId(readings[i].UserId)
+	}
+}
+
+func (p *pseudonymizer) userId(id string) string {
+	if p == nil {
+		return id
+	}
+	if pseudonym, ok := p.cache.Load(id); ok {
+		return pseudonym.(string)
+	}
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write([]byte(id))
+	sum := mac.Sum(nil)
+
+	pseudonym := hex.EncodeToString(sum)
+	if p.mode == pseudonymToken {
+		pseudonym = shapedToken(id, sum)
+	}
+	p.cache.Store(id, pseudonym)
+	return pseudonym
+}
+
+// shapedToken cuts a token of the shape of id from the HMAC sum.
+func shapedToken(id string, sum []byte) string {
+	if match := macAddress.FindStringSubmatch(id); match != nil {
+		octets := sum[:6]
+		// Locally administered unicast, so a token never claims a vendor.
+		octets[0] = octets[0]&^0x01 | 0x02
+		parts := make([]string, len(octets))
+		for i, octet := range octets {
+			parts[i] = hex.EncodeToString([]byte{octet})
+		}
+		token := strings.Join(parts, match[1])
+		if strings.ToUpper(id) == id {
+			token = strings.ToUpper(token)
+		}
+		return token
+	}
+	token := hex.EncodeToString(sum)
+	return token[:min(max(len(id), 16), len(token))]
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// waitForDatabase pings the database with exponential backoff until it answers
+// or the deadline expires, so the tool can be started together with the
+// containers. Docker publishes ports before the server inside the container is
+// listening, so a plain TCP check is not enough.
+func waitForDatabase(dbType string, connStr string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	backoff := 250 * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		err := pingDatabase(dbType, connStr)
+		if err == nil {
+			return nil
+		}
+		if errors.Is(err, errUnsupportedDatabase) {
+			return err
+		}
+		if time.Now().Add(backoff).After(deadline) {
+			return fmt.Errorf("database not ready after %s (%d attempts): %s", timeout, attempt, redact(err.Error()))
+		}
+
+		infof("Database not ready (attempt %d): %s, retrying in %s\n", attempt, redact(err.Error()), backoff)
+		time.Sleep(backoff)
+		backoff = min(2*backoff, 10*time.Second)
+	}
+}
+
+func pingDatabase(dbType string, connStr string) error {
+	info, err := lookupBackend(dbType)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return info.ping(ctx, connStr)
+}
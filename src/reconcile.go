@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// reconciliationDialect counts the rows of user_events after the ingestion.
+// refresh makes the ingested rows visible on engines that refresh
+// asynchronously, and duplicates describes how the engine stores points that
+// share a timestamp and tags; it is copied into the results as the annotation.
+type reconciliationDialect struct {
+	refresh    []string
+	countQuery string
+	duplicates string
+}
+
+type ReconciliationResult struct {
+	// Expected is the number of readings written, warm-up included.
+	Expected int64 `json:"expected"`
+	Stored   int64 `json:"stored"`
+	// Collapsed is Expected minus Stored: points merged with an identical
+	// one, or negative when more rows are stored than were written.
+	Collapsed int64  `json:"collapsed"`
+	Note      string `json:"note,omitempty"`
+}
+
+// runReconciliation compares the number of readings written with the number
+// of rows the database reports. Engines that key points by timestamp and tags
+// store fewer rows than PostgreSQL for the same data, which their ingestion
+// throughput does not show.
+func runReconciliation(ctx context.Context, info backendInfo, b backend, expected int64) (*ReconciliationResult, error) {
+	counter, ok := b.(rowCounter)
+	if !ok {
+		return nil, fmt.Errorf("backend does not support counting rows")
+	}
+
+	for _, stmt := range info.reconciliation.refresh {
+		if err := b.exec(ctx, stmt); err != nil {
+			return nil, err
+		}
+	}
+	stored, err := counter.count(ctx, info.reconciliation.countQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ReconciliationResult{
+		Expected:  expected,
+		Stored:    stored,
+		Collapsed: expected - stored,
+		Note:      info.reconciliation.duplicates,
+	}
+	if result.Collapsed != 0 {
+		fmt.Printf("[WARN] %d readings were written but %d rows are stored (%s)\n", expected, stored, result.Note)
+	} else {
+		infof("All %d readings are stored\n", stored)
+	}
+	return result, nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"src/results"
+)
+
+// seriesLabel names the series of a result file: the database type plus the
+// schema and dataset options that were set, like the series_label of the
+// Python report, so both group the same runs.
+func seriesLabel(r BenchmarkResults) string {
+	label := r.DbType
+	if r.SchemaVariant != "" {
+		label += fmt.Sprintf(" [%s]", r.SchemaVariant)
+	}
+	if r.ChunkInterval != "" {
+		label += fmt.Sprintf(" [chunk %s]", r.ChunkInterval)
+	}
+	if r.Shards > 0 {
+		label += fmt.Sprintf(" [%d shards]", r.Shards)
+	}
+	if r.IngestMethod != "" {
+		label += fmt.Sprintf(" [%s]", r.IngestMethod)
+	}
+	if r.Scale != 0 {
+		label += fmt.Sprintf(" [x%g]", r.Scale)
+	}
+	if r.CardinalityFactor != 0 {
+		label += fmt.Sprintf(" [card x%d]", r.CardinalityFactor)
+	}
+	if r.ChunkStart != 0 || r.ChunkEnd != 0 {
+		end := "end"
+		if r.ChunkEnd != 0 {
+			end = fmt.Sprint(r.ChunkEnd)
+		}
+		label += fmt.Sprintf(" [chunks %d-%s]", r.ChunkStart, end)
+	}
+	return label
+}
+
+// readResultFiles reads result files, plain or gzip-compressed.
+func readResultFiles(paths []string) ([]BenchmarkResults, error) {
+	var all []BenchmarkResults
+	for _, path := range paths {
+		var r BenchmarkResults
+		if err := results.ReadFile(path, &r); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		all = append(all, r)
+	}
+	return all, nil
+}
+
+// runReport is the report subcommand: a plain-text summary of result files
+// for a look at a run without the Python tooling. Files of the same series
+// are taken as repetitions; the query times are the median over them.
+func runReport(flags *flag.FlagSet, args []string) error {
+	if err := parseCommandFlags(flags, args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return asConfigError(err)
+	}
+	if flags.NArg() == 0 {
+		return configErrorf("report needs one or more result files")
+	}
+	all, err := readResultFiles(flags.Args())
+	if err != nil {
+		return err
+	}
+
+	var labels []string
+	series := map[string][]BenchmarkResults{}
+	for _, r := range all {
+		label := seriesLabel(r)
+		if _, ok := series[label]; !ok {
+			labels = append(labels, label)
+		}
+		series[label] = append(series[label], r)
+	}
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(out, "Series\tFiles\tRecords\tIngestion (s)\tRecords/s\t")
+	for _, label := range labels {
+		var records int
+		var durationMs int64
+		for _, r := range series[label] {
+			for _, batch := range r.Ingestion {
+				durationMs += batch.DurationMs
+			}
+			if n := len(r.Ingestion); n > 0 {
+				records += r.Ingestion[n-1].NRecords
+			}
+		}
+		fmt.Fprintf(out, "%s\t%d\t%d\t%.1f\t%.0f\t\n", label, len(series[label]), records, float64(durationMs)/1000, results.RowsPerSec(records, time.Duration(durationMs)*time.Millisecond))
+	}
+	if err := out.Flush(); err != nil {
+		return err
+	}
+
+	ids := map[int]QueryResult{}
+	for _, r := range all {
+		for _, q := range r.Queries {
+			if _, ok := ids[q.QueryId]; !ok {
+				ids[q.QueryId] = q
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	fmt.Println()
+	out = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(out, "Query\t%s\t\n", strings.Join(labels, " (ms)\t")+" (ms)")
+	for _, id := range slices.Sorted(maps.Keys(ids)) {
+		row := fmt.Sprintf("%d %s", id, ids[id].Query)
+		for _, label := range labels {
+			var samples []int64
+			for _, r := range series[label] {
+				for _, q := range r.Queries {
+					if q.QueryId == id && q.DurationMs >= 0 {
+						samples = append(samples, q.DurationMs)
+					}
+				}
+			}
+			if len(samples) == 0 {
+				row += "\tn/a"
+			} else {
+				row += fmt.Sprintf("\t%d", results.MedianMs(samples))
+			}
+		}
+		fmt.Fprintln(out, row+"\t")
+	}
+	return out.Flush()
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// resourceSampler is implemented by managed databases that can report their
+// current CPU and memory usage, e.g. from the Kubernetes metrics API.
+type resourceSampler interface {
+	sampleResources(ctx context.Context) (ResourceSample, error)
+}
+
+// resourceInterval is how often the usage is sampled; metrics-server scrapes
+// the kubelets every 15 seconds by default.
+const resourceInterval = 15 * time.Second
+
+type ResourceSample struct {
+	// ElapsedMs is the time since the start of the run.
+	ElapsedMs     int64 `json:"elapsedMs"`
+	CpuMillicores int64 `json:"cpuMillicores"`
+	MemoryBytes   int64 `json:"memoryBytes"`
+}
+
+type ResourceUsage struct {
+	Samples           []ResourceSample `json:"samples"`
+	PeakCpuMillicores int64            `json:"peakCpuMillicores"`
+	PeakMemoryBytes   int64            `json:"peakMemoryBytes"`
+}
+
+// startResourceSampling samples the usage of the managed database in the
+// background until the returned function is first called, which returns the
+// samples, or nil when the database cannot be sampled.
+func startResourceSampling(db managedDatabase) func() *ResourceUsage {
+	sampler, ok := db.(resourceSampler)
+	if !ok {
+		return func() *ResourceUsage { return nil }
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan *ResourceUsage)
+	go func() {
+		usage := &ResourceUsage{}
+		start := time.Now()
+		ticker := time.NewTicker(resourceInterval)
+		defer ticker.Stop()
+		warned := false
+		for {
+			sample, err := sampler.sampleResources(ctx)
+			switch {
+			case err == nil:
+				sample.ElapsedMs = time.Since(start).Milliseconds()
+				usage.Samples = append(usage.Samples, sample)
+				usage.PeakCpuMillicores = max(usage.PeakCpuMillicores, sample.CpuMillicores)
+				usage.PeakMemoryBytes = max(usage.PeakMemoryBytes, sample.MemoryBytes)
+			case ctx.Err() == nil && !warned:
+				// Metrics lag behind a new pod; only the first failure is
+				// reported.
+				fmt.Printf("[WARN] Failed to sample the database's resource usage: %v\n", err)
+				warned = true
+			}
+
+			select {
+			case <-ctx.Done():
+				done <- usage
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	var once sync.Once
+	var usage *ResourceUsage
+	return func() *ResourceUsage {
+		once.Do(func() {
+			cancel()
+			usage = <-done
+		})
+		return usage
+	}
+}
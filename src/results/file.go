@@ -0,0 +1,68 @@
+package results
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Encode renders results as one line of JSON, the form of a result file.
+func Encode(results any) ([]byte, error) {
+	encoded, err := json.Marshal(results)
+	if err != nil {
+		return nil, err
+	}
+	return append(encoded, '\n'), nil
+}
+
+// WriteFile writes encoded results to path, or gzip-compressed to path.gz when
+// they are larger than compressOver bytes, so that raw samples and plans of
+// long campaigns stay manageable. A compressOver of 0 disables compression.
+// It returns the path written.
+func WriteFile(path string, encoded []byte, compressOver int64) (string, error) {
+	if compressOver <= 0 || int64(len(encoded)) <= compressOver {
+		return path, os.WriteFile(path, encoded, 0644)
+	}
+
+	path += ".gz"
+	out, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+
+	zw := gzip.NewWriter(out)
+	if _, err := zw.Write(encoded); err != nil {
+		return "", err
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	return path, out.Close()
+}
+
+// ReadFile reads a result file into results, decompressing it when it is
+// gzip-compressed.
+func ReadFile(path string, results any) error {
+	encoded, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if bytes.HasPrefix(encoded, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(bytes.NewReader(encoded))
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		defer zr.Close()
+		if encoded, err = io.ReadAll(zr); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if err := json.Unmarshal(encoded, results); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
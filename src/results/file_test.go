@@ -0,0 +1,120 @@
+package results
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testRun struct {
+	DbType    string     `json:"dbType"`
+	StartedAt time.Time  `json:"startedAt,omitzero"`
+	Samples   []int64    `json:"samplesMs,omitempty"`
+	Latencies *Latencies `json:"latencies,omitempty"`
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name string
+		run  testRun
+		want string
+	}{
+		{name: "empty", run: testRun{}, want: `{"dbType":""}`},
+		{name: "omits zero fields", run: testRun{DbType: "postgres"}, want: `{"dbType":"postgres"}`},
+		{
+			name: "full",
+			run: testRun{
+				DbType:    "clickhouse",
+				StartedAt: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+				Samples:   []int64{3, 1},
+				Latencies: &Latencies{MeanMs: 2, P50Ms: 1, P95Ms: 3, P99Ms: 3, MaxMs: 3},
+			},
+			want: `{"dbType":"clickhouse","startedAt":"2024-03-01T12:00:00Z","samplesMs":[3,1],` +
+				`"latencies":{"meanMs":2,"p50Ms":1,"p95Ms":3,"p99Ms":3,"maxMs":3}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := Encode(tt.run)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(encoded); got != tt.want+"\n" {
+				t.Errorf("Encode() = %q, want %q", got, tt.want+"\n")
+			}
+		})
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	run := testRun{DbType: "influxdb", Samples: []int64{5, 4, 3, 2, 1}}
+	encoded, err := Encode(run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name         string
+		compressOver int64
+		compressed   bool
+	}{
+		{name: "disabled", compressOver: 0, compressed: false},
+		{name: "below the threshold", compressOver: int64(len(encoded)), compressed: false},
+		{name: "above the threshold", compressOver: int64(len(encoded)) - 1, compressed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "run.json")
+			written, err := WriteFile(path, encoded, tt.compressOver)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := map[bool]string{false: path, true: path + ".gz"}[tt.compressed]; written != want {
+				t.Fatalf("WriteFile wrote %s, want %s", written, want)
+			}
+			stored, err := os.ReadFile(written)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.compressed {
+				zr, err := gzip.NewReader(bytes.NewReader(stored))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if stored, err = io.ReadAll(zr); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !bytes.Equal(stored, encoded) {
+				t.Errorf("stored %q, want %q", stored, encoded)
+			}
+
+			var read testRun
+			if err := ReadFile(written, &read); err != nil {
+				t.Fatal(err)
+			}
+			if read.DbType != run.DbType || len(read.Samples) != len(run.Samples) {
+				t.Errorf("ReadFile() = %+v, want %+v", read, run)
+			}
+		})
+	}
+}
+
+func TestReadFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	truncated := filepath.Join(dir, "truncated.json")
+	if err := os.WriteFile(truncated, []byte(`{"dbType":"post`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var run testRun
+	if err := ReadFile(filepath.Join(dir, "missing.json"), &run); err == nil {
+		t.Error("ReadFile of a missing file succeeded")
+	}
+	if err := ReadFile(truncated, &run); err == nil || !strings.Contains(err.Error(), truncated) {
+		t.Errorf("ReadFile of a truncated file = %v, want an error naming the file", err)
+	}
+}
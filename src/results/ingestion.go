@@ -0,0 +1,45 @@
+package results
+
+import "time"
+
+// IngestionResult is one batch of the measured ingestion, a chunk of the
+// dataset written in one go.
+type IngestionResult struct {
+	// ChunkId is the chunk of the batch, numbered from 0 as the readings_N
+	// files are and counting on through the replays of a scale above 1.
+	ChunkId    int   `json:"chunkId"`
+	DurationMs int64 `json:"durationMs"`
+	// BatchRows is the number of readings of the batch, written or not, and
+	// NRecords the readings written by the ingestion so far.
+	BatchRows int `json:"batchRows"`
+	NRecords  int `json:"nRecords"`
+	// RowsPerSec is the throughput of the batch over DurationMs; 0 when it
+	// failed.
+	RowsPerSec float64 `json:"rowsPerSec"`
+	Retries    int     `json:"retries,omitempty"`
+	// Errors is the number of failed attempts to write the batch: the
+	// retried ones, and the last one when it Failed.
+	Errors int `json:"errors,omitempty"`
+	// DecompressMs is the time spent decompressing the chunk file before the
+	// batch, which DurationMs does not include.
+	DecompressMs int64 `json:"decompressMs,omitempty"`
+	// LoadWaitMs is how long the writer waited for the chunk to be read and
+	// decoded; near 0 when prefetching keeps ahead of the database.
+	LoadWaitMs int64 `json:"loadWaitMs,omitempty"`
+	// Failed marks a batch that could not be written; NRecords does not
+	// include it.
+	Failed bool `json:"failed,omitempty"`
+	// StartedAt is when the batch began to be written and OffsetMs how long
+	// after the first batch of the ingestion, so that the throughput can be
+	// plotted over time; merge stalls and pauses show up as gaps.
+	StartedAt time.Time `json:"startedAt,omitzero"`
+	OffsetMs  int64     `json:"offsetMs"`
+}
+
+// RowsPerSec is the throughput of rows written in d, 0 for no time at all.
+func RowsPerSec(rows int, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(rows) / d.Seconds()
+}
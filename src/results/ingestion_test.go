@@ -0,0 +1,78 @@
+package results
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestRowsPerSec(t *testing.T) {
+	tests := []struct {
+		rows int
+		d    time.Duration
+		want float64
+	}{
+		{rows: 1000, d: time.Second, want: 1000},
+		{rows: 1000, d: 250 * time.Millisecond, want: 4000},
+		{rows: 0, d: time.Second, want: 0},
+		{rows: 1000, d: 0, want: 0},
+		{rows: 1000, d: -time.Second, want: 0},
+	}
+	for _, tt := range tests {
+		if got := RowsPerSec(tt.rows, tt.d); got != tt.want {
+			t.Errorf("RowsPerSec(%d, %s) = %g, want %g", tt.rows, tt.d, got, tt.want)
+		}
+	}
+}
+
+// TestIngestionResultJSON pins the fields of an ingestion batch in the result
+// files.
+func TestIngestionResultJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		batch IngestionResult
+		want  string
+	}{
+		{
+			name:  "written",
+			batch: IngestionResult{ChunkId: 3, DurationMs: 500, BatchRows: 1000, NRecords: 4000, RowsPerSec: 2000, OffsetMs: 1500},
+			want:  `{"chunkId":3,"durationMs":500,"batchRows":1000,"nRecords":4000,"rowsPerSec":2000,"offsetMs":1500}`,
+		},
+		{
+			name: "retried and failed",
+			batch: IngestionResult{
+				ChunkId:      7,
+				DurationMs:   120,
+				BatchRows:    1000,
+				NRecords:     6000,
+				Retries:      2,
+				Errors:       3,
+				DecompressMs: 4,
+				LoadWaitMs:   1,
+				Failed:       true,
+				StartedAt:    time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+				OffsetMs:     9000,
+			},
+			want: `{"chunkId":7,"durationMs":120,"batchRows":1000,"nRecords":6000,"rowsPerSec":0,"retries":2,"errors":3,` +
+				`"decompressMs":4,"loadWaitMs":1,"failed":true,"startedAt":"2024-03-01T12:00:00Z","offsetMs":9000}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := json.Marshal(tt.batch)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(encoded) != tt.want {
+				t.Errorf("got %s, want %s", encoded, tt.want)
+			}
+			var decoded IngestionResult
+			if err := json.Unmarshal(encoded, &decoded); err != nil {
+				t.Fatal(err)
+			}
+			if decoded != tt.batch {
+				t.Errorf("decoded %+v, want %+v", decoded, tt.batch)
+			}
+		})
+	}
+}
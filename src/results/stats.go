@@ -0,0 +1,74 @@
+// Package results holds what the benchmark records about its measurements
+// apart from the databases: the statistics the latencies are summarized with
+// and the encoding of the result files. The JSON field names are the schema
+// the plot and report scripts read, so changes to them are changes to the
+// output format.
+package results
+
+import (
+	"math"
+	"slices"
+	"time"
+)
+
+// Latencies summarizes the samples of an operation in milliseconds. It is
+// embedded in the results of the phases that repeat an operation, so its
+// fields appear inline in their JSON.
+type Latencies struct {
+	MeanMs float64 `json:"meanMs"`
+	P50Ms  float64 `json:"p50Ms"`
+	P95Ms  float64 `json:"p95Ms"`
+	P99Ms  float64 `json:"p99Ms"`
+	MaxMs  float64 `json:"maxMs"`
+}
+
+// Summarize sorts samples in place and summarizes them; no samples give the
+// zero Latencies.
+func Summarize(samples []time.Duration) Latencies {
+	if len(samples) == 0 {
+		return Latencies{}
+	}
+	slices.Sort(samples)
+	var sum time.Duration
+	for _, sample := range samples {
+		sum += sample
+	}
+	return Latencies{
+		MeanMs: DurationMs(sum / time.Duration(len(samples))),
+		P50Ms:  DurationMs(Percentile(samples, 0.50)),
+		P95Ms:  DurationMs(Percentile(samples, 0.95)),
+		P99Ms:  DurationMs(Percentile(samples, 0.99)),
+		MaxMs:  DurationMs(samples[len(samples)-1]),
+	}
+}
+
+// NearestRank is the index of the nearest-rank percentile p of n sorted
+// samples.
+func NearestRank(n int, p float64) int {
+	return max(int(math.Ceil(p*float64(n)))-1, 0)
+}
+
+// Percentile returns the nearest-rank percentile p of sorted samples.
+func Percentile(sorted []time.Duration, p float64) time.Duration {
+	return sorted[NearestRank(len(sorted), p)]
+}
+
+// DurationMs renders a duration in milliseconds with microsecond precision.
+func DurationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// MedianMs returns the median of the samples, the mean of the two middle ones
+// for an even count, or -1 when there are none.
+func MedianMs(samples []int64) int64 {
+	if len(samples) == 0 {
+		return -1
+	}
+	sorted := slices.Clone(samples)
+	slices.Sort(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		return sorted[middle]
+	}
+	return (sorted[middle-1] + sorted[middle]) / 2
+}
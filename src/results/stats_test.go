@@ -0,0 +1,129 @@
+package results
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+)
+
+func ms(values ...float64) []time.Duration {
+	samples := make([]time.Duration, len(values))
+	for i, v := range values {
+		samples[i] = time.Duration(v * float64(time.Millisecond))
+	}
+	return samples
+}
+
+func TestNearestRank(t *testing.T) {
+	tests := []struct {
+		n    int
+		p    float64
+		want int
+	}{
+		{n: 1, p: 0.50, want: 0},
+		{n: 1, p: 0.99, want: 0},
+		{n: 2, p: 0.50, want: 0},
+		{n: 4, p: 0.50, want: 1},
+		{n: 5, p: 0.50, want: 2},
+		{n: 10, p: 0.95, want: 9},
+		{n: 100, p: 0.95, want: 94},
+		{n: 100, p: 0.99, want: 98},
+		{n: 1000, p: 0.999, want: 998},
+		{n: 10, p: 0, want: 0},
+		{n: 10, p: 1, want: 9},
+	}
+	for _, tt := range tests {
+		if got := NearestRank(tt.n, tt.p); got != tt.want {
+			t.Errorf("NearestRank(%d, %g) = %d, want %d", tt.n, tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestDurationMs(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want float64
+	}{
+		{d: 0, want: 0},
+		{d: time.Millisecond, want: 1},
+		{d: 1500 * time.Microsecond, want: 1.5},
+		// Below a microsecond is dropped.
+		{d: 1234567 * time.Nanosecond, want: 1.234},
+		{d: 2 * time.Second, want: 2000},
+	}
+	for _, tt := range tests {
+		if got := DurationMs(tt.d); got != tt.want {
+			t.Errorf("DurationMs(%s) = %g, want %g", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestMedianMs(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples []int64
+		want    int64
+	}{
+		{name: "none", samples: nil, want: -1},
+		{name: "one", samples: []int64{7}, want: 7},
+		{name: "odd", samples: []int64{9, 1, 5}, want: 5},
+		{name: "even", samples: []int64{4, 1, 3, 2}, want: 2},
+		{name: "even rounds down", samples: []int64{1, 4}, want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			samples := slices.Clone(tt.samples)
+			if got := MedianMs(samples); got != tt.want {
+				t.Errorf("MedianMs(%v) = %d, want %d", tt.samples, got, tt.want)
+			}
+			if !slices.Equal(samples, tt.samples) {
+				t.Errorf("MedianMs reordered the samples to %v", samples)
+			}
+		})
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	hundred := make([]float64, 100)
+	for i := range hundred {
+		hundred[i] = float64(100 - i)
+	}
+	tests := []struct {
+		name    string
+		samples []time.Duration
+		want    Latencies
+	}{
+		{name: "none", samples: nil, want: Latencies{}},
+		{name: "one", samples: ms(2.5), want: Latencies{MeanMs: 2.5, P50Ms: 2.5, P95Ms: 2.5, P99Ms: 2.5, MaxMs: 2.5}},
+		{name: "unsorted", samples: ms(3, 1, 2), want: Latencies{MeanMs: 2, P50Ms: 2, P95Ms: 3, P99Ms: 3, MaxMs: 3}},
+		{name: "hundred", samples: ms(hundred...), want: Latencies{MeanMs: 50.5, P50Ms: 50, P95Ms: 95, P99Ms: 99, MaxMs: 100}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Summarize(tt.samples); got != tt.want {
+				t.Errorf("Summarize() = %+v, want %+v", got, tt.want)
+			}
+			if !slices.IsSorted(tt.samples) {
+				t.Errorf("Summarize left the samples unsorted")
+			}
+		})
+	}
+}
+
+// TestLatenciesInline pins the JSON of Latencies embedded in a result, which
+// the plot scripts read as fields of the result itself.
+func TestLatenciesInline(t *testing.T) {
+	step := struct {
+		Clients int `json:"clients"`
+		Latencies
+	}{Clients: 4, Latencies: Summarize(ms(1, 2))}
+	encoded, err := json.Marshal(step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"clients":4,"meanMs":1.5,"p50Ms":1,"p95Ms":2,"p99Ms":2,"maxMs":2}`
+	if string(encoded) != want {
+		t.Errorf("got %s, want %s", encoded, want)
+	}
+}
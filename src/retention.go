@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type RetentionResult struct {
+	Fraction   float64       `json:"fraction"`
+	Cutoff     time.Time     `json:"cutoff"`
+	DurationMs int64         `json:"durationMs"`
+	Queries    []QueryResult `json:"queries"`
+}
+
+// retentionBackend is implemented by backends that can expire old data, with
+// the mechanism the engine would use for a retention policy: dropping whole
+// chunks or partitions where it has them, deleting rows otherwise.
+type retentionBackend interface {
+	expireBefore(ctx context.Context, cutoff time.Time) error
+}
+
+// runRetentionPhase expires the oldest fraction of the time range and re-runs
+// the query catalog on the remaining data.
+func runRetentionPhase(ctx context.Context, info backendInfo, b backend, opts benchmarkOptions, bounds queryBounds) (*RetentionResult, error) {
+	expirer, ok := b.(retentionBackend)
+	if !ok {
+		return nil, fmt.Errorf("backend does not support expiring data")
+	}
+
+	fraction := opts.RetentionFraction
+	result := &RetentionResult{
+		Fraction: fraction,
+		Cutoff:   bounds.min.Add(time.Duration(float64(bounds.max.Sub(bounds.min)) * fraction)),
+	}
+
+	infof("Expiring data older than %s\n", result.Cutoff.Format(time.RFC3339))
+	start := time.Now()
+	if err := expirer.expireBefore(ctx, result.Cutoff); err != nil {
+		return nil, err
+	}
+	result.DurationMs = time.Since(start).Milliseconds()
+
+	queries, _, err := runQueryCatalog(ctx, info, b, opts)
+	if err != nil {
+		return nil, err
+	}
+	result.Queries = queries
+	return result, nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"time"
+)
+
+// retryPolicy controls how often a failed ingestion batch is retried. The wait
+// between attempts starts at Backoff and doubles up to maxRetryBackoff.
+type retryPolicy struct {
+	MaxRetries int
+	Backoff    time.Duration
+}
+
+const maxRetryBackoff = 30 * time.Second
+
+// ingestWithRetry ingests a batch, retrying transient failures according to the
+// policy. It returns the number of retries and the time spent waiting between
+// attempts, which callers exclude from the measured duration.
+func ingestWithRetry(ctx context.Context, b backend, readings []Reading, policy retryPolicy, driverTransient func(error) bool) (int, time.Duration, error) {
+	var waited time.Duration
+	backoff := policy.Backoff
+	for attempt := 0; ; attempt++ {
+		err := b.ingest(ctx, readings)
+		if err == nil {
+			return attempt, waited, nil
+		}
+		if attempt >= policy.MaxRetries || !isTransient(err, driverTransient) {
+			return attempt, waited, err
+		}
+
+		fmt.Printf("[WARN] Ingestion failed (attempt %d/%d): %v, retrying in %s\n", attempt+1, policy.MaxRetries+1, err, backoff)
+		time.Sleep(backoff)
+		waited += backoff
+		backoff = min(2*backoff, maxRetryBackoff)
+	}
+}
+
+// isTransient reports whether err is worth retrying: broken or refused
+// connections and timeouts, plus whatever the driver classifies as transient
+// (overloaded servers, retryable SQL errors).
+func isTransient(err error, driverTransient func(error) bool) bool {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+
+	return driverTransient != nil && driverTransient(err)
+}
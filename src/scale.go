@@ -0,0 +1,38 @@
+package main
+
+import "math"
+
+// scaledChunkCount is the number of chunks ingested for a dataset of n chunks at
+// the given scale: the leading chunks for scales below 1, whole and partial
+// replays of the dataset above it. At least one chunk is always ingested.
+func scaledChunkCount(n int, scale float64) int {
+	if scale <= 0 {
+		scale = 1
+	}
+	return max(1, int(math.Round(scale*float64(n))))
+}
+
+// replayShifter moves the readings of a replay past the end of the previous
+// one, so that replays extend the time range instead of piling up on the same
+// timestamps. The span of the dataset is learned while the first pass is
+// ingested.
+type replayShifter struct {
+	first, last int
+}
+
+func (s *replayShifter) shift(readings []Reading, replay int) {
+	if replay == 0 {
+		for _, reading := range readings {
+			if s.first == 0 || reading.LastUpdatedTime < s.first {
+				s.first = reading.LastUpdatedTime
+			}
+			s.last = max(s.last, reading.LastUpdatedTime)
+		}
+		return
+	}
+
+	offset := replay * (s.last - s.first + 1)
+	for i := range readings {
+		readings[i].LastUpdatedTime += offset
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// timeToInsightDialect is a backend's version of the time-to-insight scenario:
+// the statements that build the hourly per-access-point rollup, run in order,
+// and the dashboard bundle that reads from it.
+type timeToInsightDialect struct {
+	rollup    []string
+	dashboard []string
+}
+
+// sqlDashboardBundle is the set of queries a facilities dashboard issues when it
+// is opened: busiest access points, hourly activity, worst signal and the peak
+// hour.
+var sqlDashboardBundle = []string{
+	"SELECT ssid, SUM(users) AS users FROM user_events_hourly GROUP BY ssid ORDER BY users DESC LIMIT 10",
+	"SELECT hour, SUM(readings) AS readings FROM user_events_hourly GROUP BY hour ORDER BY hour",
+	"SELECT ssid, AVG(avg_rssi) AS rssi FROM user_events_hourly GROUP BY ssid ORDER BY rssi LIMIT 10",
+	"SELECT hour, SUM(users) AS users FROM user_events_hourly GROUP BY hour ORDER BY users DESC LIMIT 1",
+}
+
+// benchmarkTimeToInsight chains schema setup, ingestion of the first day of
+// data, building an hourly rollup and running the dashboard bundle on it. The
+// sum of the phases is the end-to-end "time to insight" of the engine. Reading
+// the chunk files is not part of the measured time.
+func benchmarkTimeToInsight(dbType string, connStr string, outFile string) error {
+	info, err := lookupBackend(dbType)
+	if err != nil {
+		return err
+	}
+	rollup, dashboard := info.timeToInsight.rollup, info.timeToInsight.dashboard
+	if len(rollup) == 0 {
+		return fmt.Errorf("time-to-insight scenario is not available for %s", dbType)
+	}
+
+	b, err := info.open(connStr)
+	if err != nil {
+		return err
+	}
+	defer b.close()
+
+	ctx := context.Background()
+	scenario := ScenarioResult{Name: "time-to-insight"}
+
+	infof("Time to insight: creating schema\n")
+	start := time.Now()
+	if err := b.createSchema(ctx); err != nil {
+		return err
+	}
+	scenario.addPhase("setup", time.Since(start))
+
+	infof("Time to insight: ingesting one day of data\n")
+	var ingestDuration time.Duration
+	var dayEnd time.Time
+	currentChunk := 0
+	for {
+		hasNext, data, err := loadDataChunk(readingsDir, currentChunk)
+		if err != nil {
+			return err
+		}
+
+		if dayEnd.IsZero() && len(data.Response) > 0 {
+			first := data.Response[0].LastUpdatedTime
+			for _, reading := range data.Response {
+				first = min(first, reading.LastUpdatedTime)
+			}
+			dayEnd = readingTime(first).Add(24 * time.Hour)
+		}
+
+		day := make([]Reading, 0, len(data.Response))
+		for _, reading := range data.Response {
+			if readingTime(reading.LastUpdatedTime).Before(dayEnd) {
+				day = append(day, reading)
+			}
+		}
+
+		start = time.Now()
+		if err := b.ingest(ctx, day); err != nil {
+			return err
+		}
+		ingestDuration += time.Since(start)
+		scenario.NRecords += len(day)
+
+		currentChunk++
+		if !hasNext || len(day) < len(data.Response) {
+			break
+		}
+	}
+	scenario.addPhase("ingest", ingestDuration)
+
+	infof("Time to insight: building hourly rollup\n")
+	start = time.Now()
+	for _, stmt := range rollup {
+		if err := b.exec(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	scenario.addPhase("rollup", time.Since(start))
+
+	infof("Time to insight: running dashboard bundle\n")
+	start = time.Now()
+	for _, q := range dashboard {
+		if err := b.query(ctx, q); err != nil {
+			return err
+		}
+	}
+	scenario.addPhase("dashboard", time.Since(start))
+
+	infof("Time to insight for %s: %d ms\n", dbType, scenario.DurationMs)
+	return writeResults(outFile, BenchmarkResults{
+		DbType:    dbType,
+		Scenarios: []ScenarioResult{scenario},
+	})
+}
+
+// loadScenarioData creates the schema and ingests the full dataset as the
+// "setup" and "ingest" phases of a scenario. The backend has to be configured
+// with configureBackend first.
+func loadScenarioData(ctx context.Context, info backendInfo, b backend, opts benchmarkOptions, scenario *ScenarioResult) error {
+	start := time.Now()
+	if err := b.createSchema(ctx); err != nil {
+		return err
+	}
+	scenario.addPhase("setup", time.Since(start))
+
+	ingestion, err := ingestChunks(ctx, info, b, 0, opts)
+	if err != nil {
+		return err
+	}
+	var ingestDuration time.Duration
+	for _, batch := range ingestion {
+		ingestDuration += time.Duration(batch.DurationMs) * time.Millisecond
+		scenario.NRecords = batch.NRecords
+	}
+	scenario.addPhase("ingest", ingestDuration)
+	return nil
+}
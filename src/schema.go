@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// schemaVariant is an alternative DDL for the user_events table, e.g. a
+// different index type or sort key, so schema tuning can be benchmarked with
+// the same workload. The first variant a backend registers is its default.
+type schemaVariant struct {
+	name string
+	ddl  string
+	// daily partitions the table by day, creating the partitions during
+	// the ingestion.
+	daily bool
+}
+
+// schemaSetter is implemented by backends whose DDL can be swapped before
+// createSchema runs.
+type schemaSetter interface {
+	setSchema(ddl string)
+}
+
+// dailyPartitioner is implemented by backends that create the daily
+// partitions of a partitioned table as the readings arrive.
+type dailyPartitioner interface {
+	partitionDaily()
+}
+
+// chunkIntervalSetter is implemented by backends that partition the table into
+// time chunks of a configurable width.
+type chunkIntervalSetter interface {
+	setChunkInterval(interval string)
+}
+
+// shardCountSetter is implemented by backends that shard the table into a
+// configurable number of shards.
+type shardCountSetter interface {
+	setShardCount(shards int)
+}
+
+// ingestMethodSetter is implemented by backends that can write the readings
+// in more than one way, e.g. with COPY or with multi-row INSERTs.
+type ingestMethodSetter interface {
+	setIngestMethod(method string)
+}
+
+// configureBackend applies the schema variant, chunk interval, shard count,
+// ingest method and write batch size of opts to the backend before createSchema runs.
+func configureBackend(info backendInfo, b backend, opts benchmarkOptions) error {
+	if opts.SchemaVariant != "" {
+		variant, _ := info.lookupSchemaVariant(opts.SchemaVariant)
+		b.(schemaSetter).setSchema(variant.ddl)
+		if variant.daily {
+			b.(dailyPartitioner).partitionDaily()
+		}
+	}
+	if opts.ChunkInterval != "" {
+		setter, ok := b.(chunkIntervalSetter)
+		if !ok {
+			return fmt.Errorf("chunk intervals are not supported for database type: %s", info.name)
+		}
+		setter.setChunkInterval(opts.ChunkInterval)
+	}
+	if opts.Shards > 0 {
+		setter, ok := b.(shardCountSetter)
+		if !ok {
+			return fmt.Errorf("shard counts are not supported for database type: %s", info.name)
+		}
+		setter.setShardCount(opts.Shards)
+	}
+	if opts.IngestMethod != "" {
+		setter, ok := b.(ingestMethodSetter)
+		if !ok {
+			return fmt.Errorf("ingest methods are not supported for database type: %s", info.name)
+		}
+		setter.setIngestMethod(opts.IngestMethod)
+	}
+	if opts.WriteBatchSize > 0 {
+		sizer, ok := b.(writeBatchSizer)
+		if !ok {
+			return fmt.Errorf("write batch sizes are not supported for database type: %s", info.name)
+		}
+		sizer.setWriteBatchSize(opts.WriteBatchSize)
+	}
+	return nil
+}
+
+func (info backendInfo) lookupSchemaVariant(name string) (schemaVariant, bool) {
+	for _, v := range info.schemaVariants {
+		if v.name == name {
+			return v, true
+		}
+	}
+	return schemaVariant{}, false
+}
+
+func (info backendInfo) schemaVariantNames() string {
+	names := make([]string, len(info.schemaVariants))
+	for i, v := range info.schemaVariants {
+		names[i] = v.name
+	}
+	return strings.Join(names, ", ")
+}
+
+// checkProvisionedSchema replaces createSchema under -no-create: the tables are
+// tuned by hand, so a missing one is reported before any data is ingested
+// rather than created with the default DDL.
+func checkProvisionedSchema(ctx context.Context, info backendInfo, b backend) error {
+	if err := b.query(ctx, info.schemaProbe); err != nil {
+		return fmt.Errorf("-no-create: the pre-provisioned schema of %s is missing or lacks a column the benchmark writes (%s): %w", info.name, info.schemaProbe, err)
+	}
+	infof("Using the pre-provisioned schema\n")
+	return nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// knownSecrets are the passwords and tokens of the connection strings and the
+// credential flags. redact blanks them in whatever is logged, since driver
+// errors may quote the connection string they failed on.
+var knownSecrets []string
+
+// connSecretParam matches the secrets of key=value connection strings, e.g.
+// password=... of the QuestDB ILP configuration or of a libpq DSN.
+var connSecretParam = regexp.MustCompile(`(?i)\b(password|token|token_x|token_y)=((?:[^;&\s]|;;)+)`)
+
+func registerSecret(secret string) {
+	if secret != "" {
+		knownSecrets = append(knownSecrets, secret)
+		// Longest first, so a secret containing another is blanked whole.
+		sort.Slice(knownSecrets, func(i, j int) bool { return len(knownSecrets[i]) > len(knownSecrets[j]) })
+	}
+}
+
+// registerConnSecrets registers the secrets of a connection string, which may
+// join several, like the ingest:::query form of QuestDB.
+func registerConnSecrets(connStr string) {
+	for _, part := range strings.Split(connStr, ":::") {
+		if u, err := url.Parse(part); err == nil && u.User != nil {
+			password, _ := u.User.Password()
+			registerSecret(password)
+		}
+		for _, match := range connSecretParam.FindAllStringSubmatch(part, -1) {
+			registerSecret(match[2])
+			registerSecret(strings.ReplaceAll(match[2], ";;", ";"))
+		}
+	}
+}
+
+// redact replaces the known secrets in s.
+func redact(s string) string {
+	for _, secret := range knownSecrets {
+		s = strings.ReplaceAll(s, secret, "xxxxx")
+	}
+	return s
+}
+
+// envPlaceholder is a ${NAME} reference in a configuration file.
+var envPlaceholder = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandJSONEnv replaces the ${NAME} references of a JSON configuration file
+// with the value of the environment variable, escaped for a JSON string, so
+// that passwords can be kept out of the file. An unset variable is an error
+// rather than an empty password.
+func expandJSONEnv(encoded []byte) ([]byte, error) {
+	var err error
+	expanded := envPlaceholder.ReplaceAllFunc(encoded, func(ref []byte) []byte {
+		name := string(envPlaceholder.FindSubmatch(ref)[1])
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			if err == nil {
+				err = fmt.Errorf("environment variable %s is not set", name)
+			}
+			return ref
+		}
+		quoted, _ := json.Marshal(value)
+		return quoted[1 : len(quoted)-1]
+	})
+	return expanded, err
+}
+
+// connFromEnv reads the connection string from the -conn-env variable or the
+// -conn-file file, so that it stays out of the command line and the shell
+// history.
+func connFromEnv(connStr string, envName string, file string) (string, error) {
+	given := 0
+	for _, source := range []string{connStr, envName, file} {
+		if source != "" {
+			given++
+		}
+	}
+	if given > 1 {
+		return "", fmt.Errorf("-conn, -conn-env and -conn-file are mutually exclusive")
+	}
+	switch {
+	case envName != "":
+		return resolveSecret("env:" + envName)
+	case file != "":
+		return resolveSecret("file:" + file)
+	default:
+		return connStr, nil
+	}
+}
+
+// exitOnPanic reports a panic of main with the secrets redacted and exits, in
+// place of the default crash report that would print them verbatim.
+func exitOnPanic() {
+	if r := recover(); r != nil {
+		fmt.Fprintf(os.Stderr, "panic: %s\n", redact(fmt.Sprint(r)))
+		os.Exit(exitPanic)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// tlsOptions are the -tls-* flags, applied to the connections of every
+// backend. TLS options in a connection string (e.g. sslmode of pgx) are
+// overridden when TLS is enabled with the flags.
+type tlsOptions struct {
+	Enabled    bool
+	CAFile     string
+	CertFile   string
+	KeyFile    string
+	ServerName string
+	SkipVerify bool
+}
+
+var tlsSettings tlsOptions
+
+// config builds the client TLS configuration, or nil when TLS is not enabled.
+// host is the server name sent with SNI and verified against the certificate
+// unless -tls-server-name is given; HTTP clients pass "" and let net/http fill
+// it in from the URL.
+func (o tlsOptions) config(host string) (*tls.Config, error) {
+	if !o.Enabled {
+		return nil, nil
+	}
+	cfg := &tls.Config{ServerName: host, InsecureSkipVerify: o.SkipVerify}
+	if o.ServerName != "" {
+		cfg.ServerName = o.ServerName
+	}
+	if o.CAFile != "" {
+		pem, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s: no PEM certificates found", o.CAFile)
+		}
+	}
+	if o.CertFile != "" || o.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// credentials are the -db-user, -db-password and -db-token flags. They replace
+// the credentials of the connection strings and the defaults of the local
+// containers, so that secrets need not be part of the command line. Org is
+// the InfluxDB 2.x organization of -influx-org.
+type credentials struct {
+	User     string
+	Password string
+	Token    string
+	Org      string
+}
+
+var dbCredentials credentials
+
+// resolveSecret reads a secret given as env:NAME from the environment or as
+// file:PATH from a file, without its trailing newline. Any other value is the
+// secret itself.
+func resolveSecret(ref string) (string, error) {
+	switch {
+	case strings.HasPrefix(ref, "env:"):
+		name := strings.TrimPrefix(ref, "env:")
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return value, nil
+	case strings.HasPrefix(ref, "file:"):
+		value, err := os.ReadFile(strings.TrimPrefix(ref, "file:"))
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(value), "\r\n"), nil
+	default:
+		return ref, nil
+	}
+}
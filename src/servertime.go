@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// serverTimer is implemented by backends whose engine logs how long it spent
+// executing each statement. The log is read around the repeats of a catalog
+// query, so the server-side time can be set against the client round trip,
+// which also includes the network, the driver and the transfer of the rows.
+type serverTimer interface {
+	// markServerTime starts a new window of the statement log.
+	markServerTime(ctx context.Context) error
+	// serverTimeMs returns the mean execution time of the statements the
+	// benchmark ran since the last mark, and how many it found.
+	serverTimeMs(ctx context.Context) (float64, int, error)
+}
+
+// timeOnServer runs the repeats of a catalog query between two reads of the
+// statement log and returns the mean server-side time of a run, 0 when it is
+// unknown. A log that cannot be read only loses the server time, it does not
+// fail the query; run is called exactly once either way.
+func timeOnServer(ctx context.Context, b backend, id int, enabled bool, run func() error) (float64, error) {
+	timer, ok := b.(serverTimer)
+	if !enabled || !ok {
+		return 0, run()
+	}
+	if err := timer.markServerTime(ctx); err != nil {
+		fmt.Printf("[WARN] Failed to read the server-side time of query %d: %v\n", id, err)
+		return 0, run()
+	}
+	if err := run(); err != nil {
+		return 0, err
+	}
+
+	ms, statements, err := timer.serverTimeMs(ctx)
+	switch {
+	case err != nil:
+		fmt.Printf("[WARN] Failed to read the server-side time of query %d: %v\n", id, err)
+	case statements == 0:
+		fmt.Printf("[WARN] The statement log holds no run of query %d\n", id)
+	default:
+		return ms, nil
+	}
+	return 0, nil
+}
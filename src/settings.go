@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// settingsReporter is implemented by backends that read the settings of the
+// server from an API instead of a query.
+type settingsReporter interface {
+	serverSettings(ctx context.Context) (map[string]string, error)
+}
+
+// pgSettings returns the pg_settings query of the memory, WAL and parallelism
+// settings, plus the extra ones of an extension, in their display units.
+func pgSettings(extra ...string) string {
+	names := append([]string{
+		"shared_buffers", "effective_cache_size", "work_mem", "maintenance_work_mem",
+		"wal_buffers", "max_wal_size", "checkpoint_timeout",
+		"max_worker_processes", "max_parallel_workers", "max_parallel_workers_per_gather",
+		"random_page_cost", "effective_io_concurrency", "jit",
+	}, extra...)
+	return "SELECT name, current_setting(name) FROM pg_settings WHERE name IN (" + sqlList(names) + ")"
+}
+
+// sqlList renders names as a list of SQL string literals.
+func sqlList(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = "'" + strings.ReplaceAll(name, "'", "''") + "'"
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// readServerSettings records the tuning of the server the run measured, so
+// that two result files of one engine show whether they ran with the same
+// memory and commit settings. The settings are read once the schema exists,
+// for the engines that keep some on the table. A failure only costs the
+// snapshot.
+func readServerSettings(ctx context.Context, info backendInfo, b backend) map[string]string {
+	settings := map[string]string{}
+	var err error
+	if reporter, ok := b.(settingsReporter); ok {
+		settings, err = reporter.serverSettings(ctx)
+	} else if scanner, ok := b.(rowScanner); ok && info.serverSettings != "" {
+		err = scanner.scanRows(ctx, func(values []any) {
+			if len(values) >= 2 && values[1] != nil {
+				settings[settingText(values[0])] = settingText(values[1])
+			}
+		}, info.serverSettings)
+	}
+	if err != nil {
+		fmt.Printf("[WARN] Could not read the server settings of %s: %v\n", info.name, err)
+		return nil
+	}
+	if len(settings) == 0 {
+		return nil
+	}
+	infof("Recorded %d server settings\n", len(settings))
+	return settings
+}
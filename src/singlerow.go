@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"src/results"
+)
+
+// SingleRowResult holds the latencies of the inserts of the single-row
+// scenario, in milliseconds, over the successful ones.
+type SingleRowResult struct {
+	Writers int `json:"writers"`
+	Inserts int `json:"inserts"`
+	Errors  int `json:"errors"`
+	// Throughput is the number of successful inserts per second.
+	Throughput float64 `json:"throughput"`
+	MeanMs     float64 `json:"meanMs"`
+	P50Ms      float64 `json:"p50Ms"`
+	P95Ms      float64 `json:"p95Ms"`
+	P99Ms      float64 `json:"p99Ms"`
+	P999Ms     float64 `json:"p999Ms"`
+	MaxMs      float64 `json:"maxMs"`
+}
+
+// benchmarkSingleRow writes the readings one at a time, each in a request of
+// its own, from writers concurrent connections, the way an API gateway that
+// writes every event it receives straight to the database does. It stops
+// after inserts readings, or at the end of the dataset, and reports the
+// latency percentiles of the inserts. Every connection uses the backend's
+// regular write path, so -ingest-method selects e.g. INSERT over COPY.
+func benchmarkSingleRow(info backendInfo, connStr string, outFile string, opts benchmarkOptions, writers int, inserts int) error {
+	if writers < 1 || inserts < 1 {
+		return fmt.Errorf("the single-row scenario needs at least one writer and one insert")
+	}
+
+	conns := make([]backend, 0, writers)
+	defer func() {
+		for _, b := range conns {
+			b.close()
+		}
+	}()
+	for range writers {
+		b, err := info.open(connStr)
+		if err != nil {
+			return err
+		}
+		conns = append(conns, b)
+		if err := configureBackend(info, b, opts); err != nil {
+			return err
+		}
+	}
+
+	ctx := context.Background()
+	scenario := ScenarioResult{Name: "single-row"}
+	start := time.Now()
+	if err := conns[0].createSchema(ctx); err != nil {
+		return err
+	}
+	scenario.addPhase("setup", time.Since(start))
+
+	// The readings are loaded before the writers start, so the measured time
+	// holds only the inserts.
+	var pending []Reading
+	for chunk := 0; len(pending) < inserts; chunk++ {
+		hasNext, data, err := loadDataChunk(readingsDir, chunk)
+		if err != nil {
+			return err
+		}
+		pending = append(pending, data.Response[:min(len(data.Response), inserts-len(pending))]...)
+		if !hasNext {
+			break
+		}
+	}
+	if len(pending) == 0 {
+		return fmt.Errorf("the dataset has no readings")
+	}
+	readings := make(chan Reading, writers)
+	go func() {
+		defer close(readings)
+		for _, reading := range pending {
+			if interrupted() != nil {
+				return
+			}
+			readings <- reading
+		}
+	}()
+
+	infof("Single-row: writing %d readings one at a time over %d connections\n", len(pending), writers)
+	samples := make([][]time.Duration, writers)
+	failed := make([]int, writers)
+	firstErr := make([]error, writers)
+	var wg sync.WaitGroup
+	start = time.Now()
+	for i, b := range conns {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for reading := range readings {
+				began := time.Now()
+				if err := b.ingest(ctx, []Reading{reading}); err != nil {
+					failed[i]++
+					if firstErr[i] == nil {
+						firstErr[i] = err
+					}
+					continue
+				}
+				samples[i] = append(samples[i], time.Since(began))
+			}
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	scenario.addPhase("ingest", elapsed)
+	if err := interrupted(); err != nil {
+		return err
+	}
+
+	result := &SingleRowResult{Writers: writers}
+	var all []time.Duration
+	var failure error
+	for i := range conns {
+		all = append(all, samples[i]...)
+		result.Errors += failed[i]
+		if failure == nil {
+			failure = firstErr[i]
+		}
+	}
+	if len(all) == 0 {
+		return failure
+	}
+	if failure != nil {
+		fmt.Printf("[WARN] Single-row: %d inserts failed, e.g. with: %v\n", result.Errors, failure)
+	}
+	slices.Sort(all)
+	var sum time.Duration
+	for _, sample := range all {
+		sum += sample
+	}
+	result.Inserts = len(all)
+	result.Throughput = float64(len(all)) / elapsed.Seconds()
+	result.MeanMs = results.DurationMs(sum / time.Duration(len(all)))
+	result.P50Ms = results.DurationMs(results.Percentile(all, 0.50))
+	result.P95Ms = results.DurationMs(results.Percentile(all, 0.95))
+	result.P99Ms = results.DurationMs(results.Percentile(all, 0.99))
+	result.P999Ms = results.DurationMs(results.Percentile(all, 0.999))
+	result.MaxMs = results.DurationMs(all[len(all)-1])
+	scenario.NRecords = len(all)
+	scenario.SingleRow = result
+
+	infof("Single-row for %s: %d inserts (%.1f/s), p50 %.3f ms, p99 %.3f ms, %d failed\n",
+		info.name, result.Inserts, result.Throughput, result.P50Ms, result.P99Ms, result.Errors)
+	return writeResults(outFile, BenchmarkResults{
+		DbType:       info.name,
+		IngestMethod: opts.IngestMethod,
+		Scenarios:    []ScenarioResult{scenario},
+	})
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// resultSink is a results store that accumulates runs next to the JSON result
+// files, so that trends across versions and hardware can be queried. Every
+// result file written is also published to the sinks of -result-sink.
+type resultSink interface {
+	publish(ctx context.Context, run runRecord) error
+	close()
+}
+
+var resultSinks []resultSink
+
+// runRecord is a result file together with where and when it was produced.
+type runRecord struct {
+	RecordedAt time.Time
+	Host       string
+	Platform   string
+	Cpus       int
+	Results    BenchmarkResults
+	// Encoded is the JSON of Results as written to the result file.
+	Encoded []byte
+}
+
+func newRunRecord(results BenchmarkResults, encoded []byte) runRecord {
+	host, _ := os.Hostname()
+	return runRecord{
+		RecordedAt: time.Now().UTC(),
+		Host:       host,
+		Platform:   runtime.GOOS + "/" + runtime.GOARCH,
+		Cpus:       runtime.NumCPU(),
+		Results:    results,
+		Encoded:    encoded,
+	}
+}
+
+// ingestionTotals returns the records of the measured ingestion and the sum of
+// its batch durations.
+func (r runRecord) ingestionTotals() (records int64, durationMs int64) {
+	for _, ingestion := range r.Results.Ingestion {
+		durationMs += ingestion.DurationMs
+		records = int64(ingestion.NRecords)
+	}
+	return records, durationMs
+}
+
+// openResultSink opens the sink of a -result-sink target: a PostgreSQL
+// connection string, jsonl:PATH for a file with one run per line,
+// pushgateway:URL for a Prometheus Pushgateway or influx:URL for the write
+// endpoint of an InfluxDB.
+func openResultSink(target string) (resultSink, error) {
+	switch {
+	case strings.HasPrefix(target, "postgres://"), strings.HasPrefix(target, "postgresql://"):
+		return newPostgresSink(target)
+	case strings.HasPrefix(target, "jsonl:"):
+		return &jsonlSink{path: strings.TrimPrefix(target, "jsonl:")}, nil
+	case strings.HasPrefix(target, "pushgateway:"):
+		return &pushgatewaySink{baseURL: strings.TrimSuffix(strings.TrimPrefix(target, "pushgateway:"), "/")}, nil
+	case strings.HasPrefix(target, "influx:"):
+		return newInfluxMetricsSink(strings.TrimPrefix(target, "influx:"))
+	default:
+		return nil, fmt.Errorf("unsupported result sink %q", target)
+	}
+}
+
+// publishResults hands a written result file to every sink. A failing sink
+// only logs a warning, since the result file is already on disk.
+func publishResults(results BenchmarkResults, encoded []byte) {
+	if len(resultSinks) == 0 {
+		return
+	}
+	run := newRunRecord(results, encoded)
+	for _, sink := range resultSinks {
+		if err := sink.publish(context.Background(), run); err != nil {
+			fmt.Printf("[WARN] Failed to publish the results: %s\n", redact(err.Error()))
+		}
+	}
+}
+
+// jsonlSink appends every run as one JSON line.
+type jsonlSink struct {
+	path string
+}
+
+func (s *jsonlSink) publish(ctx context.Context, run runRecord) error {
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	line, err := json.Marshal(struct {
+		RecordedAt time.Time       `json:"recordedAt"`
+		Host       string          `json:"host"`
+		Platform   string          `json:"platform"`
+		Cpus       int             `json:"cpus"`
+		Results    json.RawMessage `json:"results"`
+	}{run.RecordedAt, run.Host, run.Platform, run.Cpus, run.Encoded})
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+func (s *jsonlSink) close() {}
+
+// postgresSink stores every run in benchmark_runs, with the whole result file
+// as JSONB, and its catalog queries in benchmark_queries for plain SQL trend
+// queries.
+type postgresSink struct {
+	conn *pgx.Conn
+}
+
+const postgresSinkSchema = `
+	CREATE TABLE IF NOT EXISTS benchmark_runs (
+		id BIGSERIAL PRIMARY KEY,
+		recorded_at TIMESTAMP WITH TIME ZONE NOT NULL,
+		db_type TEXT NOT NULL,
+		pass INTEGER NOT NULL,
+		host TEXT NOT NULL,
+		platform TEXT NOT NULL,
+		cpus INTEGER NOT NULL,
+		records BIGINT NOT NULL,
+		ingestion_ms BIGINT NOT NULL,
+		results JSONB NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS benchmark_queries (
+		run_id BIGINT NOT NULL REFERENCES benchmark_runs (id) ON DELETE CASCADE,
+		query_id INTEGER NOT NULL,
+		description TEXT NOT NULL,
+		duration_ms BIGINT NOT NULL,
+		PRIMARY KEY (run_id, query_id)
+	);`
+
+func newPostgresSink(connStr string) (*postgresSink, error) {
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, connStr)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Exec(ctx, postgresSinkSchema); err != nil {
+		conn.Close(ctx)
+		return nil, err
+	}
+	return &postgresSink{conn: conn}, nil
+}
+
+func (s *postgresSink) publish(ctx context.Context, run runRecord) error {
+	records, ingestionMs := run.ingestionTotals()
+
+	tx, err := s.conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var runId int64
+	err = tx.QueryRow(ctx, `INSERT INTO benchmark_runs (recorded_at, db_type, pass, host, platform, cpus, records, ingestion_ms, results)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`,
+		run.RecordedAt, run.Results.DbType, run.Results.Pass, run.Host, run.Platform, run.Cpus, records, ingestionMs, string(run.Encoded)).Scan(&runId)
+	if err != nil {
+		return err
+	}
+
+	for _, query := range run.Results.Queries {
+		_, err := tx.Exec(ctx, "INSERT INTO benchmark_queries (run_id, query_id, description, duration_ms) VALUES ($1, $2, $3, $4)",
+			runId, query.QueryId, query.Description, query.DurationMs)
+		if err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	infof("Published the results as run %d\n", runId)
+	return nil
+}
+
+func (s *postgresSink) close() {
+	s.conn.Close(context.Background())
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// SizeSweepResult is the query catalog run at a checkpoint of the ingestion,
+// once Percent of the chunks are written.
+type SizeSweepResult struct {
+	Percent float64 `json:"percent"`
+	Chunks  int     `json:"chunks"`
+	// NRecords is the number of readings of the measured ingestion written
+	// so far, without the warm-up.
+	NRecords int           `json:"nRecords"`
+	Queries  []QueryResult `json:"queries"`
+}
+
+// parseSizeSweep parses the -size-sweep percentages, e.g. 10,25,50,100, in
+// ascending order. 100 is the catalog of the run after the full ingestion.
+func parseSizeSweep(list string) ([]float64, error) {
+	if list == "" {
+		return nil, nil
+	}
+	var percents []float64
+	for _, field := range strings.Split(list, ",") {
+		percent, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil || percent <= 0 || percent > 100 {
+			return nil, fmt.Errorf("size sweep checkpoint %q is not a percentage in (0, 100]", field)
+		}
+		percents = append(percents, percent)
+	}
+	slices.Sort(percents)
+	return slices.Compact(percents), nil
+}
+
+// sizeSweep pauses the ingestion at its checkpoints and runs the query
+// catalog on the data written so far, giving the latency of every query
+// against the data volume. The pauses are not part of the ingestion times.
+type sizeSweep struct {
+	percents []float64
+	// next is the index of the next checkpoint to reach.
+	next    int
+	results []SizeSweepResult
+}
+
+func newSizeSweep(percents []float64) *sizeSweep {
+	return &sizeSweep{percents: percents}
+}
+
+// reached runs the catalog for the checkpoints below 100% that chunks of
+// total chunks reach. Checkpoints that fall on the same chunk as the previous
+// one are skipped.
+func (s *sizeSweep) reached(ctx context.Context, info backendInfo, b backend, opts benchmarkOptions, chunks int, total int, nRecords int) error {
+	if s == nil {
+		return nil
+	}
+	for ; s.next < len(s.percents) && s.percents[s.next] < 100; s.next++ {
+		percent := s.percents[s.next]
+		if chunks < checkpointChunks(percent, total) {
+			return nil
+		}
+		if n := len(s.results); n > 0 && s.results[n-1].Chunks == chunks {
+			fmt.Printf("[WARN] Size sweep: %g%% of %d chunks is the same chunk as %g%%; skipping it\n", percent, total, s.results[n-1].Percent)
+			continue
+		}
+		infof("Size sweep: running the queries after %d of %d chunks (%g%%)\n", chunks, total, percent)
+		// The journal only holds the catalog of the full data.
+		opts.Progress = nil
+		queries, _, err := runQueryCatalog(ctx, info, b, opts)
+		if err != nil {
+			return err
+		}
+		s.results = append(s.results, SizeSweepResult{Percent: percent, Chunks: chunks, NRecords: nRecords, Queries: queries})
+	}
+	return nil
+}
+
+// finish adds the catalog of the full data as the 100% checkpoint.
+func (s *sizeSweep) finish(chunks int, nRecords int, queries []QueryResult) []SizeSweepResult {
+	if slices.Contains(s.percents, 100) {
+		s.results = append(s.results, SizeSweepResult{Percent: 100, Chunks: chunks, NRecords: nRecords, Queries: queries})
+	}
+	return s.results
+}
+
+// checkpointChunks is the number of chunks that make up percent of total,
+// at least one.
+func checkpointChunks(percent float64, total int) int {
+	return max(1, int(math.Ceil(percent/100*float64(total))))
+}
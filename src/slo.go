@@ -0,0 +1,275 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	"src/results"
+)
+
+// sloConfig is the -slo file: service level objectives a run must meet, e.g.
+// the p95 of query 8 at most 500 ms and an ingestion of at least 50k rows/s.
+// A run that misses one still writes its results, then exits with exitSLO, so
+// a pipeline can gate a database upgrade on it.
+type sloConfig struct {
+	Objectives []sloObjective `json:"slos"`
+}
+
+// sloObjective bounds one metric of the results; Min and Max are inclusive.
+// Metrics are named by path:
+//
+//	ingestion.rowsPerSecond, ingestion.durationMs, ingestion.failedBatches
+//	queries.totalMs
+//	query.<id>, query.<id>.p50|p95|p99|max   (ms; the catalog run, from its repeats)
+//	workload.throughput, workload.errors, workload.backlog
+//	workload.query.<id>.mean|p50|p95|p99|max (ms)
+//	workload.query.<id>.responseP50|responseP95|responseP99|responseMax (ms)
+type sloObjective struct {
+	Name   string   `json:"name"`
+	Metric string   `json:"metric"`
+	Min    *float64 `json:"min"`
+	Max    *float64 `json:"max"`
+}
+
+type SLOResult struct {
+	Passed bool       `json:"passed"`
+	Checks []SLOCheck `json:"checks"`
+}
+
+type SLOCheck struct {
+	Name   string   `json:"name,omitempty"`
+	Metric string   `json:"metric"`
+	Min    *float64 `json:"min,omitempty"`
+	Max    *float64 `json:"max,omitempty"`
+	// Value is missing when the run did not measure the metric, e.g. a query
+	// that failed or a workload that did not run; the objective is then
+	// missed.
+	Value  *float64 `json:"value,omitempty"`
+	Passed bool     `json:"passed"`
+}
+
+// sloViolation ends a run that completed but missed objectives.
+type sloViolation struct {
+	missed []string
+}
+
+func (e *sloViolation) Error() string {
+	return fmt.Sprintf("%d service level objectives missed: %s", len(e.missed), strings.Join(e.missed, ", "))
+}
+
+var workloadQueryStats = []string{"mean", "p50", "p95", "p99", "max", "responseP50", "responseP95", "responseP99", "responseMax"}
+
+func readSLOConfig(path string) (*sloConfig, error) {
+	encoded, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg sloConfig
+	if err := yaml.UnmarshalStrict(encoded, &cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(cfg.Objectives) == 0 {
+		return nil, fmt.Errorf("%s: no objectives under slos", path)
+	}
+	for _, o := range cfg.Objectives {
+		if o.Min == nil && o.Max == nil {
+			return nil, fmt.Errorf("%s: objective %s has neither min nor max", path, o.Metric)
+		}
+		// A metric read from empty results tells whether the name is known.
+		if _, known := sloMetric(&BenchmarkResults{}, o.Metric); !known {
+			return nil, fmt.Errorf("%s: unknown metric %q", path, o.Metric)
+		}
+	}
+	return &cfg, nil
+}
+
+// evaluate checks every objective against the results and prints the
+// summary.
+func (cfg *sloConfig) evaluate(results *BenchmarkResults) *SLOResult {
+	result := &SLOResult{Passed: true}
+	infof("Service level objectives:\n")
+	for _, o := range cfg.Objectives {
+		check := SLOCheck{Name: o.Name, Metric: o.Metric, Min: o.Min, Max: o.Max}
+		value, _ := sloMetric(results, o.Metric)
+		check.Value = value
+		check.Passed = value != nil &&
+			(o.Min == nil || *value >= *o.Min) &&
+			(o.Max == nil || *value <= *o.Max)
+		result.Passed = result.Passed && check.Passed
+		result.Checks = append(result.Checks, check)
+
+		verdict := "PASS"
+		if !check.Passed {
+			verdict = "FAIL"
+		}
+		measured := "not measured"
+		if value != nil {
+			measured = strconv.FormatFloat(*value, 'f', -1, 64)
+		}
+		infof("  %s %s %s: %s\n", verdict, check.label(), check.bounds(), measured)
+	}
+	return result
+}
+
+// violation returns the error of missed objectives, nil when all passed.
+func (r *SLOResult) violation() error {
+	if r == nil || r.Passed {
+		return nil
+	}
+	var missed []string
+	for _, check := range r.Checks {
+		if !check.Passed {
+			missed = append(missed, check.label())
+		}
+	}
+	return &sloViolation{missed: missed}
+}
+
+func (c SLOCheck) label() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return c.Metric
+}
+
+func (c SLOCheck) bounds() string {
+	var bounds []string
+	if c.Min != nil {
+		bounds = append(bounds, ">= "+strconv.FormatFloat(*c.Min, 'f', -1, 64))
+	}
+	if c.Max != nil {
+		bounds = append(bounds, "<= "+strconv.FormatFloat(*c.Max, 'f', -1, 64))
+	}
+	return strings.Join(bounds, " and ")
+}
+
+// sloMetric reads a metric from the results. known is false for a name that
+// is not a metric; the value is nil when the run did not measure it.
+func sloMetric(run *BenchmarkResults, metric string) (value *float64, known bool) {
+	parts := strings.Split(metric, ".")
+	of := func(v float64) *float64 { return &v }
+	switch {
+	case len(parts) == 2 && parts[0] == "ingestion":
+		var records, durationMs, failed int64
+		for _, chunk := range run.Ingestion {
+			records += int64(chunk.NRecords)
+			durationMs += chunk.DurationMs
+			if chunk.Failed {
+				failed++
+			}
+		}
+		if len(run.Ingestion) == 0 {
+			return nil, slices.Contains([]string{"rowsPerSecond", "durationMs", "failedBatches"}, parts[1])
+		}
+		switch parts[1] {
+		case "rowsPerSecond":
+			return of(float64(records) / (float64(max(durationMs, 1)) / 1000)), true
+		case "durationMs":
+			return of(float64(durationMs)), true
+		case "failedBatches":
+			return of(float64(failed)), true
+		}
+	case metric == "queries.totalMs":
+		if len(run.Queries) == 0 {
+			return nil, true
+		}
+		var total int64
+		for _, q := range run.Queries {
+			if q.DurationMs < 0 {
+				return nil, true
+			}
+			total += q.DurationMs
+		}
+		return of(float64(total)), true
+	case (len(parts) == 2 || len(parts) == 3) && parts[0] == "query":
+		id, stat, ok := sloQueryPath(parts[1:], []string{"p50", "p95", "p99", "max"})
+		if !ok {
+			return nil, false
+		}
+		for _, q := range run.Queries {
+			if q.QueryId != id || q.DurationMs < 0 {
+				continue
+			}
+			samples := q.SamplesMs
+			if len(samples) == 0 {
+				samples = []int64{q.DurationMs}
+			}
+			sorted := slices.Clone(samples)
+			slices.Sort(sorted)
+			switch stat {
+			case "":
+				return of(float64(q.DurationMs)), true
+			case "p50":
+				return of(float64(sorted[results.NearestRank(len(sorted), 0.50)])), true
+			case "p95":
+				return of(float64(sorted[results.NearestRank(len(sorted), 0.95)])), true
+			case "p99":
+				return of(float64(sorted[results.NearestRank(len(sorted), 0.99)])), true
+			case "max":
+				return of(float64(sorted[len(sorted)-1])), true
+			}
+		}
+		return nil, true
+	case len(parts) == 2 && parts[0] == "workload":
+		if !slices.Contains([]string{"throughput", "errors", "backlog"}, parts[1]) {
+			return nil, false
+		}
+		w := run.Workload
+		if w == nil {
+			return nil, true
+		}
+		switch parts[1] {
+		case "throughput":
+			return of(w.Throughput), true
+		case "errors":
+			return of(float64(w.Errors)), true
+		case "backlog":
+			return of(float64(w.Backlog)), true
+		}
+	case len(parts) == 4 && parts[0] == "workload" && parts[1] == "query":
+		id, stat, ok := sloQueryPath(parts[2:], workloadQueryStats)
+		if !ok || stat == "" {
+			return nil, false
+		}
+		if run.Workload == nil {
+			return nil, true
+		}
+		for _, q := range run.Workload.Queries {
+			if q.QueryId != id || q.Operations == 0 {
+				continue
+			}
+			stats := map[string]float64{
+				"mean": q.MeanMs, "p50": q.P50Ms, "p95": q.P95Ms, "p99": q.P99Ms, "max": q.MaxMs,
+				"responseP50": q.ResponseP50Ms, "responseP95": q.ResponseP95Ms,
+				"responseP99": q.ResponseP99Ms, "responseMax": q.ResponseMaxMs,
+			}
+			if strings.HasPrefix(stat, "response") && run.Workload.Model != loadOpen {
+				return nil, true
+			}
+			return of(stats[stat]), true
+		}
+		return nil, true
+	}
+	return nil, false
+}
+
+// sloQueryPath parses the "<id>[.<stat>]" tail of a query metric, where the
+// id is the number or the name of a catalog query.
+func sloQueryPath(parts []string, stats []string) (id int, stat string, ok bool) {
+	id, err := strconv.Atoi(parts[0])
+	if err != nil {
+		id, _ = queryIdByName(parts[0])
+	}
+	if id < 1 || id >= len(queryDescriptions) {
+		return 0, "", false
+	}
+	if len(parts) == 1 {
+		return id, "", true
+	}
+	return id, parts[1], slices.Contains(stats, parts[1])
+}
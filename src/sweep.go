@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// bucketWidth is one step of the aggregation sweep. name is the short form
+// used by QuestDB and Flux (1m), interval the SQL interval literal (1 minute).
+type bucketWidth struct {
+	name     string
+	interval string
+}
+
+var sweepWidths = []bucketWidth{
+	{"1m", "1 minute"},
+	{"5m", "5 minutes"},
+	{"1h", "1 hour"},
+	{"1d", "1 day"},
+}
+
+type BucketSweepResult struct {
+	Bucket     string `json:"bucket"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// runBucketSweep runs the occupancy aggregation (distinct users per access
+// point and bucket) once per bucket width, giving the latency versus
+// granularity curve of the engine.
+func runBucketSweep(ctx context.Context, info backendInfo, b backend) ([]BucketSweepResult, error) {
+	var results []BucketSweepResult
+	for _, width := range sweepWidths {
+		infof("Running occupancy aggregation with %s buckets\n", width.name)
+		start := time.Now()
+		err := b.query(ctx, info.bucketQuery(width))
+		duration := time.Since(start).Milliseconds()
+		if err != nil {
+			if !info.lenientQueries {
+				return nil, err
+			}
+			duration = -1
+		}
+		results = append(results, BucketSweepResult{Bucket: width.name, DurationMs: duration})
+	}
+	return results, nil
+}
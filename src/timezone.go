@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+)
+
+// sourceLocation is the time zone in which the dataset's timestamps are wall
+// clock times. With UTC they are taken as the Unix times they look like.
+var sourceLocation = time.UTC
+
+// readingTime converts a dataset timestamp to the UTC instant every backend
+// writes, so engines with and without time zone aware columns store the same
+// wall clock and agree on hour-of-day buckets.
+func readingTime(epoch int) time.Time {
+	t := time.Unix(int64(epoch), 0).UTC()
+	if sourceLocation == time.UTC {
+		return t
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, sourceLocation).UTC()
+}
+
+// datasetEpoch is the inverse of readingTime: the dataset timestamp of an
+// instant, for datasets whose times are written as dates.
+func datasetEpoch(t time.Time) int {
+	if sourceLocation == time.UTC {
+		return int(t.Unix())
+	}
+	wall := t.In(sourceLocation)
+	return int(time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), 0, time.UTC).Unix())
+}
+
+// hourCounter is implemented by backends that can read the result of an
+// hour-of-day aggregation as counts per hour.
+type hourCounter interface {
+	countByHour(ctx context.Context, q string) (map[int]int64, error)
+}
+
+type HourCount struct {
+	Hour     int   `json:"hour"`
+	Expected int64 `json:"expected"`
+	Stored   int64 `json:"stored"`
+}
+
+type HourCheckResult struct {
+	Hours []HourCount `json:"hours"`
+	// Mismatched is the number of hours of the day whose stored count differs
+	// from the one of the source readings.
+	Mismatched int `json:"mismatched"`
+}
+
+// expectedHourCounts counts the readings of the warm-up and of the measured
+// ingestion per UTC hour of the day, with the replays of opts.Scale.
+func expectedHourCounts(opts benchmarkOptions) ([24]int64, error) {
+	var counts [24]int64
+	add := func(readings []Reading) {
+		for _, reading := range readings {
+			counts[readingTime(reading.LastUpdatedTime).Hour()]++
+		}
+	}
+
+	if opts.Warmup.Dir != "" {
+		files, err := os.ReadDir(opts.Warmup.Dir)
+		if err != nil {
+			return counts, err
+		}
+		for chunk := range files {
+			_, data, err := loadDataChunk(opts.Warmup.Dir, chunk)
+			if err != nil {
+				return counts, err
+			}
+			add(data.Response)
+		}
+	}
+
+	files, err := os.ReadDir(readingsDir)
+	if err != nil {
+		return counts, err
+	}
+	var shifter replayShifter
+	for chunk := 0; chunk < scaledChunkCount(len(files), opts.Scale); chunk++ {
+		_, data, err := loadDataChunk(readingsDir, chunk%len(files))
+		if err != nil {
+			return counts, err
+		}
+		shifter.shift(data.Response, chunk/len(files))
+		add(data.Response)
+	}
+	return counts, nil
+}
+
+// runHourCheck compares the backend's hour-of-day aggregation with the source
+// readings. Every backend is held against the same expected counts, so a
+// backend that buckets in another time zone shows up as mismatched hours.
+func runHourCheck(ctx context.Context, info backendInfo, b backend, opts benchmarkOptions) (*HourCheckResult, error) {
+	counter, ok := b.(hourCounter)
+	if !ok {
+		return nil, fmt.Errorf("backend does not support counting readings per hour")
+	}
+
+	infof("Verifying the hour-of-day buckets\n")
+	expected, err := expectedHourCounts(opts)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := counter.countByHour(ctx, info.hourOfDayQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &HourCheckResult{}
+	for hour := range expected {
+		count := HourCount{Hour: hour, Expected: expected[hour], Stored: stored[hour]}
+		if count.Expected != count.Stored {
+			result.Mismatched++
+			fmt.Printf("[WARN] Hour %02d: %d readings in the source, %d stored\n", hour, count.Expected, count.Stored)
+		}
+		result.Hours = append(result.Hours, count)
+	}
+	infof("Hour-of-day buckets: %d of 24 hours differ from the source\n", result.Mismatched)
+	return result, nil
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// clusterDialect describes how a backend runs on several nodes; a backend
+// with an empty note cannot be benchmarked as a cluster.
+type clusterDialect struct {
+	// note describes how the engine distributes the data, and is recorded in
+	// the results.
+	note string
+	// schema returns the statements that create the tables on every node of
+	// the named cluster, run on the first node; nil when the backend's own
+	// DDL already applies to the whole cluster.
+	schema func(cluster string) []string
+	// writeTable is the table on every node the readings are written to,
+	// when it is not user_events.
+	writeTable string
+}
+
+// topologyConfig is the JSON file of -topology, describing a multi-node
+// target. The first node also creates the schema and answers the queries.
+type topologyConfig struct {
+	Name  string   `json:"name"`
+	Nodes []string `json:"nodes"`
+	// Cluster is the name the nodes know the cluster by, e.g. the ClickHouse
+	// cluster of remote_servers.
+	Cluster string `json:"cluster"`
+}
+
+type TopologyResult struct {
+	Name    string `json:"name"`
+	Nodes   int    `json:"nodes"`
+	Cluster string `json:"cluster,omitempty"`
+	// Distribution is the clusterDialect note of the backend.
+	Distribution string `json:"distribution"`
+	// NodeRecords are the readings written to each node, in the order of the
+	// topology.
+	NodeRecords []int `json:"nodeRecords"`
+}
+
+func readTopology(path string) (*topologyConfig, error) {
+	encoded, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if encoded, err = expandJSONEnv(encoded); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	var topology topologyConfig
+	if err := json.Unmarshal(encoded, &topology); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(topology.Nodes) == 0 {
+		return nil, fmt.Errorf("%s: the topology has no nodes", path)
+	}
+	for _, node := range topology.Nodes {
+		registerConnSecrets(node)
+	}
+	return &topology, nil
+}
+
+// check rejects a topology the backend cannot run on.
+func (t *topologyConfig) check(info backendInfo) error {
+	if info.cluster.note == "" {
+		return fmt.Errorf("multi-node topologies are not supported for database type: %s", info.name)
+	}
+	if info.cluster.schema != nil && t.Cluster == "" {
+		return fmt.Errorf("the topology of %s needs the cluster name", info.name)
+	}
+	return nil
+}
+
+// waitForNodes waits until every node of the topology answers a ping.
+func (t *topologyConfig) waitForNodes(dbType string, timeout time.Duration) error {
+	for i, node := range t.Nodes {
+		if err := waitForDatabase(dbType, node, timeout); err != nil {
+			return fmt.Errorf("node %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// rowIdSkipper is implemented by backends that number the rows they write. The
+// cluster advances the numbering of every other node past a batch, so row ids
+// stay unique across the cluster.
+type rowIdSkipper interface {
+	skipRowIds(n int)
+}
+
+// clusterBackend spreads the ingestion over the nodes of a topology, one
+// batch per node in turn, and sends everything else to the first node.
+type clusterBackend struct {
+	nodes       []backend
+	schema      []string
+	writeTable  string
+	next        int
+	nodeRecords []int
+}
+
+// openTarget opens the backend of the run: the single database of connStr, or
+// every node of the topology.
+func openTarget(info backendInfo, connStr string, opts benchmarkOptions) (backend, error) {
+	if opts.Topology == nil {
+		return info.open(connStr)
+	}
+
+	c := &clusterBackend{writeTable: info.cluster.writeTable, nodeRecords: make([]int, len(opts.Topology.Nodes))}
+	if info.cluster.schema != nil {
+		c.schema = info.cluster.schema(opts.Topology.Cluster)
+	}
+	for _, node := range opts.Topology.Nodes {
+		b, err := info.open(node)
+		if err != nil {
+			c.close()
+			return nil, err
+		}
+		if _, ok := b.(tableIngester); c.writeTable != "" && !ok {
+			b.close()
+			c.close()
+			return nil, fmt.Errorf("backend %s cannot write to other tables", info.name)
+		}
+		c.nodes = append(c.nodes, b)
+	}
+	infof("Writing to %d nodes of topology %s\n", len(c.nodes), opts.Topology.Name)
+	return c, nil
+}
+
+func (c *clusterBackend) createSchema(ctx context.Context) error {
+	if c.schema == nil {
+		return c.nodes[0].createSchema(ctx)
+	}
+	for _, stmt := range c.schema {
+		if err := c.nodes[0].exec(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ingest writes the batch to the next node. A failed batch is retried on the
+// same node.
+func (c *clusterBackend) ingest(ctx context.Context, readings []Reading) error {
+	node := c.nodes[c.next]
+	var err error
+	if c.writeTable != "" {
+		err = node.(tableIngester).ingestInto(ctx, c.writeTable, readings)
+	} else {
+		err = node.ingest(ctx, readings)
+	}
+	if err != nil {
+		return err
+	}
+
+	for i, other := range c.nodes {
+		if skipper, ok := other.(rowIdSkipper); ok && i != c.next {
+			skipper.skipRowIds(len(readings))
+		}
+	}
+	c.nodeRecords[c.next] += len(readings)
+	c.next = (c.next + 1) % len(c.nodes)
+	return nil
+}
+
+func (c *clusterBackend) exec(ctx context.Context, stmt string) error {
+	return c.nodes[0].exec(ctx, stmt)
+}
+
+func (c *clusterBackend) query(ctx context.Context, q string, args ...any) error {
+	return c.nodes[0].query(ctx, q, args...)
+}
+
+func (c *clusterBackend) scanRows(ctx context.Context, visit func(values []any), q string, args ...any) error {
+	scanner, ok := c.nodes[0].(rowScanner)
+	if !ok {
+		return fmt.Errorf("the backend cannot return result rows")
+	}
+	return scanner.scanRows(ctx, visit, q, args...)
+}
+
+func (c *clusterBackend) timeBounds(ctx context.Context, q string) (time.Time, time.Time, error) {
+	return c.nodes[0].timeBounds(ctx, q)
+}
+
+func (c *clusterBackend) count(ctx context.Context, q string) (int64, error) {
+	counter, ok := c.nodes[0].(rowCounter)
+	if !ok {
+		return 0, fmt.Errorf("the backend cannot count rows")
+	}
+	return counter.count(ctx, q)
+}
+
+func (c *clusterBackend) close() {
+	for _, node := range c.nodes {
+		node.close()
+	}
+}
+
+func (c *clusterBackend) result(info backendInfo, topology *topologyConfig) *TopologyResult {
+	return &TopologyResult{
+		Name:         topology.Name,
+		Nodes:        len(c.nodes),
+		Cluster:      topology.Cluster,
+		Distribution: info.cluster.note,
+		NodeRecords:  c.nodeRecords,
+	}
+}
+
+func (c *clusterBackend) markServerTime(ctx context.Context) error {
+	timer, ok := c.nodes[0].(serverTimer)
+	if !ok {
+		return fmt.Errorf("the backend cannot read its statement log")
+	}
+	return timer.markServerTime(ctx)
+}
+
+// serverTimeMs reads the log of the first node, which the queries are sent to.
+func (c *clusterBackend) serverTimeMs(ctx context.Context) (float64, int, error) {
+	timer, ok := c.nodes[0].(serverTimer)
+	if !ok {
+		return 0, 0, fmt.Errorf("the backend cannot read its statement log")
+	}
+	return timer.serverTimeMs(ctx)
+}
+
+// serverVersion reports the version of the first node; the nodes of a
+// topology are expected to run the same build.
+func (c *clusterBackend) serverVersion(ctx context.Context) (string, error) {
+	reporter, ok := c.nodes[0].(versionReporter)
+	if !ok {
+		return "", fmt.Errorf("the backend cannot report its version")
+	}
+	return reporter.serverVersion(ctx)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+)
+
+// runVerify is the verify subcommand: it recomputes the hashes of the
+// manifest of a result file, recorded under -manifest, from the dataset on
+// disk and the query catalog of this binary, to audit that a published run
+// can be reproduced with them.
+func runVerify(flags *flag.FlagSet, args []string) error {
+	dataDir := flags.String("data", "", "Readings directory to hash instead of the one recorded in the manifest")
+	if err := parseCommandFlags(flags, args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return asConfigError(err)
+	}
+	if flags.NArg() != 1 {
+		return configErrorf("verify needs one result file")
+	}
+	files, err := readResultFiles(flags.Args())
+	if err != nil {
+		return err
+	}
+	r := files[0]
+	if r.Manifest == nil {
+		return configErrorf("%s has no manifest; record one with -manifest", flags.Arg(0))
+	}
+
+	mismatches := 0
+	for _, recorded := range r.Manifest.Dataset {
+		path := recorded.Path
+		if recorded.Role == "readings" && *dataDir != "" {
+			path = *dataDir
+		}
+		current, err := hashDataset(recorded.Role, path)
+		if err != nil {
+			return err
+		}
+		if current.Sha256 == recorded.Sha256 {
+			infof("%s %s matches the manifest\n", recorded.Role, path)
+			continue
+		}
+		mismatches++
+		fmt.Printf("[WARN] %s %s differs from the manifest\n", recorded.Role, path)
+		hashes := map[string]string{}
+		for _, file := range current.Files {
+			hashes[file.Name] = file.Sha256
+		}
+		for _, file := range recorded.Files {
+			switch sum, ok := hashes[file.Name]; {
+			case !ok:
+				fmt.Printf("[WARN]   %s is missing\n", file.Name)
+			case sum != file.Sha256:
+				fmt.Printf("[WARN]   %s has changed\n", file.Name)
+			}
+			delete(hashes, file.Name)
+		}
+		for name := range hashes {
+			fmt.Printf("[WARN]   %s was added\n", name)
+		}
+	}
+
+	// The dialect backends record their name in the type, custom-sql:<name>,
+	// and their catalog is only known with the dialect.
+	if info, err := lookupBackend(r.DbType); err != nil {
+		fmt.Printf("[WARN] The catalog of %s is not built into this binary and is not checked\n", r.DbType)
+	} else {
+		catalog := hashCatalog(info)
+		if catalog.Sha256 == r.Manifest.Catalog.Sha256 {
+			infof("The query catalog of %s matches the manifest\n", r.DbType)
+		} else {
+			mismatches++
+			hashes := map[int]string{}
+			for _, q := range catalog.Queries {
+				hashes[q.QueryId] = q.Sha256
+			}
+			for _, q := range r.Manifest.Catalog.Queries {
+				if hashes[q.QueryId] != q.Sha256 {
+					fmt.Printf("[WARN] Query %d of %s differs from the manifest\n", q.QueryId, r.DbType)
+				}
+				delete(hashes, q.QueryId)
+			}
+			for id := range hashes {
+				fmt.Printf("[WARN] Query %d of %s is not in the manifest\n", id, r.DbType)
+			}
+		}
+	}
+
+	binary := readBinaryInfo()
+	if binary.Revision != r.Manifest.Binary.Revision {
+		infof("The run was built from revision %q, this binary from %q\n", r.Manifest.Binary.Revision, binary.Revision)
+	}
+	if mismatches > 0 {
+		return fmt.Errorf("%d of the inputs differ from the manifest of %s", mismatches, flags.Arg(0))
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+)
+
+// warmupOptions configures the unmeasured ingestion that runs before the
+// measured one, so that engines with adaptive behaviour (ClickHouse mark cache,
+// PostgreSQL autovacuum and statistics) are in a steady state when timing
+// starts. Dir points to a separate dataset; Fraction instead takes the leading
+// share of the measured dataset's chunks.
+type warmupOptions struct {
+	Dir      string
+	Fraction float64
+}
+
+func (w warmupOptions) enabled() bool {
+	return w.Dir != "" || w.Fraction > 0
+}
+
+type WarmupResult struct {
+	Source     string `json:"source"`
+	Chunks     int    `json:"chunks"`
+	NRecords   int    `json:"nRecords"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// runWarmup ingests the warm-up data and returns the chunk of the measured
+// dataset at which measurement has to start. Warm-up rows stay in the table and
+// are seen by the queries, but are not part of the ingestion results.
+func runWarmup(ctx context.Context, info backendInfo, b backend, opts warmupOptions, retry retryPolicy) (*WarmupResult, int, error) {
+	dir := opts.Dir
+	chunks := 0
+	if dir == "" {
+		dir = readingsDir
+		files, err := os.ReadDir(readingsDir)
+		if err != nil {
+			return nil, 0, err
+		}
+		chunks = int(opts.Fraction * float64(len(files)))
+		if chunks >= len(files) {
+			return nil, 0, fmt.Errorf("warm-up fraction %v leaves no chunks to measure", opts.Fraction)
+		}
+		if chunks == 0 {
+			return nil, 0, fmt.Errorf("warm-up fraction %v is less than one chunk", opts.Fraction)
+		}
+	}
+
+	result := &WarmupResult{Source: dir}
+	infof("Warming up with %s\n", dir)
+	var duration time.Duration
+	for {
+		if err := interrupted(); err != nil {
+			return nil, 0, err
+		}
+		hasNext, data, err := loadDataChunk(dir, result.Chunks)
+		if err != nil {
+			return nil, 0, err
+		}
+		start := time.Now()
+		_, waited, err := ingestWithRetry(ctx, b, data.Response, retry, info.isTransient)
+		if err != nil {
+			return nil, 0, err
+		}
+		duration += time.Since(start) - waited
+		result.NRecords += len(data.Response)
+		result.Chunks++
+
+		if !hasNext || result.Chunks == chunks {
+			break
+		}
+	}
+	result.DurationMs = duration.Milliseconds()
+	infof("Warm-up done: %d records in %d ms\n", result.NRecords, result.DurationMs)
+
+	if opts.Dir != "" {
+		return result, 0, nil
+	}
+	return result, result.Chunks, nil
+}
@@ -0,0 +1,447 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	"src/results"
+)
+
+// workloadStream keeps the draws of the workload clients apart from the other
+// uses of the seed; client i draws from workloadStream + i.
+const workloadStream = 0x776f726b6c64
+
+// workloadDefinition is a named mix of catalog queries run by concurrent
+// clients, e.g. a researcher running heavy aggregations now and then, or a
+// facilities dashboard refreshing short time windows. Under the closed-loop
+// model every client picks a query at random by weight, runs it, and waits
+// ThinkTime before the next one. Under the open-loop model queries arrive at
+// ArrivalRate per second whether the earlier ones are done or not, and
+// Clients bounds how many run at once. Durations are Go durations, e.g. "1s"
+// or "5m".
+type workloadDefinition struct {
+	Name        string          `json:"name"`
+	Model       string          `json:"model"`
+	Clients     int             `json:"clients"`
+	ThinkTime   string          `json:"thinkTime"`
+	ArrivalRate float64         `json:"arrivalRate"`
+	Duration    string          `json:"duration"`
+	Queries     []workloadQuery `json:"queries"`
+}
+
+// Load models of a workload.
+const (
+	loadClosed = "closed"
+	loadOpen   = "open"
+)
+
+// workloadQuery picks a catalog query by its number or by its name.
+type workloadQuery struct {
+	Id     int     `json:"id,omitempty"`
+	Query  string  `json:"query,omitempty"`
+	Weight float64 `json:"weight"`
+}
+
+// builtinWorkloads are the personas selectable by name with -workload. Their
+// arrival rates offer about the load of their clients and think times, as a
+// starting point for the open-loop model.
+var builtinWorkloads = []workloadDefinition{
+	{
+		// A researcher exploring the dataset: few sessions, long pauses,
+		// whole-range aggregations.
+		Name: "analytics", Clients: 2, ThinkTime: "10s", ArrivalRate: 0.2, Duration: "2m",
+		Queries: []workloadQuery{
+			{Id: 3, Weight: 1}, {Id: 9, Weight: 2}, {Id: 12, Weight: 1}, {Id: 13, Weight: 2},
+			{Id: 14, Weight: 1}, {Id: 17, Weight: 2}, {Id: 18, Weight: 1}, {Id: 19, Weight: 2},
+			{Id: 20, Weight: 1}, {Id: 21, Weight: 1}, {Id: 22, Weight: 1},
+		},
+	},
+	{
+		// Facilities dashboards: many screens refreshing occupancy and signal
+		// panels of the last hours every second.
+		Name: "dashboard", Clients: 8, ThinkTime: "1s", ArrivalRate: 8, Duration: "2m",
+		Queries: []workloadQuery{
+			{Id: 7, Weight: 4}, {Id: 8, Weight: 3}, {Id: 10, Weight: 1}, {Id: 11, Weight: 1},
+			{Id: 24, Weight: 4}, {Id: 25, Weight: 2},
+		},
+	},
+	{
+		// Both at once: every catalog query, the dashboard panels weighted up.
+		Name: "mixed", Clients: 4, ThinkTime: "2s", ArrivalRate: 2, Duration: "2m",
+		Queries: mixedWorkloadQueries(),
+	},
+}
+
+func mixedWorkloadQueries() []workloadQuery {
+	var queries []workloadQuery
+	for id := 2; id < len(queryDescriptions); id++ {
+		weight := 1.0
+		if slices.Contains([]int{7, 8, 24, 25}, id) {
+			weight = 3
+		}
+		queries = append(queries, workloadQuery{Id: id, Weight: weight})
+	}
+	return queries
+}
+
+func workloadNames() []string {
+	var names []string
+	for _, w := range builtinWorkloads {
+		names = append(names, w.Name)
+	}
+	return names
+}
+
+// workload is a checked workloadDefinition.
+type workload struct {
+	name        string
+	model       string
+	clients     int
+	thinkTime   time.Duration
+	arrivalRate float64
+	duration    time.Duration
+	queries     []workloadQuery
+}
+
+// loadWorkload returns the built-in workload of the given name, or reads a
+// YAML or JSON definition from the file of that name.
+func loadWorkload(nameOrPath string) (*workload, error) {
+	for _, def := range builtinWorkloads {
+		if def.Name == nameOrPath {
+			return def.check()
+		}
+	}
+	encoded, err := os.ReadFile(nameOrPath)
+	if err != nil {
+		return nil, fmt.Errorf("-workload is neither %s nor a readable file: %w", strings.Join(workloadNames(), ", "), err)
+	}
+	var def workloadDefinition
+	if err := yaml.UnmarshalStrict(encoded, &def); err != nil {
+		return nil, fmt.Errorf("%s: %w", nameOrPath, err)
+	}
+	if def.Name == "" {
+		base := filepath.Base(nameOrPath)
+		def.Name = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+	w, err := def.check()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", nameOrPath, err)
+	}
+	return w, nil
+}
+
+func (def workloadDefinition) check() (*workload, error) {
+	w := &workload{name: def.Name, model: def.Model, clients: max(def.Clients, 1), arrivalRate: def.ArrivalRate}
+	if w.model == "" {
+		w.model = loadClosed
+	}
+	var err error
+	if def.ThinkTime != "" {
+		if w.thinkTime, err = time.ParseDuration(def.ThinkTime); err != nil {
+			return nil, fmt.Errorf("thinkTime: %w", err)
+		}
+	}
+	w.duration = time.Minute
+	if def.Duration != "" {
+		if w.duration, err = time.ParseDuration(def.Duration); err != nil {
+			return nil, fmt.Errorf("duration: %w", err)
+		}
+	}
+	if w.thinkTime < 0 || w.duration <= 0 {
+		return nil, fmt.Errorf("thinkTime must not be negative and duration must be positive")
+	}
+	if len(def.Queries) == 0 {
+		return nil, fmt.Errorf("the workload has no queries")
+	}
+	w.queries = slices.Clone(def.Queries)
+	for i, q := range w.queries {
+		if q.Query != "" {
+			id, ok := queryIdByName(q.Query)
+			if !ok {
+				return nil, fmt.Errorf("unknown query %q", q.Query)
+			}
+			if q.Id != 0 && q.Id != id {
+				return nil, fmt.Errorf("query %s is id %d, not %d", q.Query, id, q.Id)
+			}
+			w.queries[i].Id = id
+			q.Id = id
+		}
+		if q.Id < 1 || q.Id >= len(queryDescriptions) {
+			return nil, fmt.Errorf("unknown query id %d, the catalog has queries 1 to %d", q.Id, len(queryDescriptions)-1)
+		}
+		if q.Weight <= 0 {
+			return nil, fmt.Errorf("the weight of query %d must be positive", q.Id)
+		}
+	}
+	return w, nil
+}
+
+// checkModel rejects a load model without the settings it needs, after the
+// flags have overridden the definition.
+func (w *workload) checkModel() error {
+	switch w.model {
+	case loadClosed:
+		return nil
+	case loadOpen:
+		if w.arrivalRate <= 0 {
+			return fmt.Errorf("the open-loop model needs a positive arrival rate")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown load model %q, expected %s or %s", w.model, loadClosed, loadOpen)
+	}
+}
+
+type WorkloadResult struct {
+	Name        string  `json:"name"`
+	Model       string  `json:"model"`
+	Clients     int     `json:"clients"`
+	ThinkTimeMs int64   `json:"thinkTimeMs,omitempty"`
+	ArrivalRate float64 `json:"arrivalRate,omitempty"`
+	DurationMs  int64   `json:"durationMs"`
+	Operations  int     `json:"operations"`
+	Errors      int     `json:"errors"`
+	// Arrivals is the number of queries the open loop offered; Backlog those
+	// of them still waiting for a client when the duration was over.
+	Arrivals int `json:"arrivals,omitempty"`
+	Backlog  int `json:"backlog,omitempty"`
+	// Throughput is the number of completed queries per second.
+	Throughput float64               `json:"throughput"`
+	Queries    []WorkloadQueryResult `json:"queries"`
+}
+
+// WorkloadQueryResult holds the latencies of the successful runs of one query
+// of the mix, in milliseconds: the time the query ran, and under the open-loop
+// model the response time from its arrival, queueing included.
+type WorkloadQueryResult struct {
+	QueryId     int     `json:"queryId"`
+	Query       string  `json:"query"`
+	Description string  `json:"description"`
+	Weight      float64 `json:"weight"`
+	Operations  int     `json:"operations"`
+	Errors      int     `json:"errors"`
+	results.Latencies
+	ResponseP50Ms float64 `json:"responseP50Ms,omitempty"`
+	ResponseP95Ms float64 `json:"responseP95Ms,omitempty"`
+	ResponseP99Ms float64 `json:"responseP99Ms,omitempty"`
+	ResponseMaxMs float64 `json:"responseMaxMs,omitempty"`
+}
+
+// workloadOp is one query of the mix as the backend runs it.
+type workloadOp struct {
+	spec   querySpec
+	weight float64
+}
+
+// workloadSamples collects the measurements of the queries of the mix, shared
+// by the clients.
+type workloadSamples struct {
+	mu sync.Mutex
+	// service is the time each query ran, response the time from its
+	// arrival under the open-loop model, the wait for a free client
+	// included.
+	service  [][]time.Duration
+	response [][]time.Duration
+	failed   []int
+}
+
+func (s *workloadSamples) record(op int, service, response time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.failed[op]++
+		return
+	}
+	s.service[op] = append(s.service[op], service)
+	if response > 0 {
+		s.response[op] = append(s.response[op], response)
+	}
+}
+
+// runWorkload runs the workload against the ingested data after the query
+// catalog. Failing queries are counted rather than aborting the phase, as a
+// loaded engine may time out now and then.
+func runWorkload(ctx context.Context, info backendInfo, b backend, w *workload, opts benchmarkOptions, bounds queryBounds) (*WorkloadResult, error) {
+	var ops []workloadOp
+	var total float64
+	for _, q := range w.queries {
+		spec, ok := info.lookupQuery(q.Id)
+		if !ok {
+			fmt.Printf("[WARN] Query %d of workload %s is not supported by %s, leaving it out\n", q.Id, w.name, info.name)
+			continue
+		}
+		ops = append(ops, workloadOp{spec: spec, weight: q.Weight})
+		total += q.Weight
+	}
+	if len(ops) == 0 {
+		return nil, fmt.Errorf("no query of workload %s is supported by %s", w.name, info.name)
+	}
+
+	var sampler *paramSampler
+	if opts.RandomParams {
+		var err error
+		if sampler, err = newParamSampler(opts); err != nil {
+			return nil, err
+		}
+	}
+
+	samples := &workloadSamples{
+		service:  make([][]time.Duration, len(ops)),
+		response: make([][]time.Duration, len(ops)),
+		failed:   make([]int, len(ops)),
+	}
+	// run executes one query of the mix and records it; arrived is the
+	// intended start of an open-loop arrival, zero in the closed loop.
+	run := func(op int, args []any, arrived time.Time) {
+		q := ops[op].spec
+		began := time.Now()
+		var err error
+		if q.id == 1 {
+			_, _, err = b.timeBounds(ctx, q.text)
+		} else {
+			err = b.query(ctx, q.text, args...)
+		}
+		var response time.Duration
+		if !arrived.IsZero() {
+			response = time.Since(arrived)
+		}
+		samples.record(op, time.Since(began), response, err)
+	}
+
+	result := &WorkloadResult{Name: w.name, Model: w.model, Clients: w.clients}
+	start := time.Now()
+	deadline := start.Add(w.duration)
+	switch w.model {
+	case loadOpen:
+		infof("Running workload %s open-loop: %g arrivals/s served by %d clients, for %s\n", w.name, w.arrivalRate, w.clients, w.duration)
+		result.ArrivalRate = w.arrivalRate
+		result.Arrivals, result.Backlog = runOpenLoop(ctx, w, deadline, opts.Seed, func(rng *rand.Rand) (int, []any) {
+			op := pickWorkloadOp(ops, total, rng)
+			return op, ops[op].spec.arguments(sampler.bounds(bounds))
+		}, run)
+	default:
+		infof("Running workload %s closed-loop: %d clients, %s think time, for %s\n", w.name, w.clients, w.thinkTime, w.duration)
+		result.ThinkTimeMs = w.thinkTime.Milliseconds()
+		var wg sync.WaitGroup
+		for client := range w.clients {
+			rng := rand.New(rand.NewPCG(opts.Seed, workloadStream+uint64(client)))
+			clientSampler := sampler.withRand(rng)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for time.Now().Before(deadline) && interrupted() == nil && ctx.Err() == nil {
+					op := pickWorkloadOp(ops, total, rng)
+					run(op, ops[op].spec.arguments(clientSampler.bounds(bounds)), time.Time{})
+					if w.thinkTime > 0 {
+						time.Sleep(min(w.thinkTime, time.Until(deadline)))
+					}
+				}
+			}()
+		}
+		wg.Wait()
+	}
+	elapsed := time.Since(start)
+	if err := interrupted(); err != nil {
+		return nil, err
+	}
+
+	result.DurationMs = elapsed.Milliseconds()
+	for i, op := range ops {
+		result.Queries = append(result.Queries, summarizeWorkloadQuery(op, samples.service[i], samples.response[i], samples.failed[i]))
+		result.Operations += len(samples.service[i])
+		result.Errors += samples.failed[i]
+	}
+	result.Throughput = float64(result.Operations) / elapsed.Seconds()
+	infof("Workload %s completed %d queries (%.1f/s), %d failed\n", w.name, result.Operations, result.Throughput, result.Errors)
+	// A few arrivals of the last moments always wait; more than 1% means
+	// the queue grew during the run.
+	if result.Backlog*100 > result.Arrivals {
+		fmt.Printf("[WARN] %d of %d arrivals were still waiting for a client when workload %s ended; the database did not keep up with %g arrivals/s\n", result.Backlog, result.Arrivals, w.name, w.arrivalRate)
+	}
+	return result, nil
+}
+
+// runOpenLoop schedules arrivals as a Poisson process of the workload's rate,
+// independently of how fast the database answers, and hands each to one of
+// the clients. An arrival that finds every client busy waits for one, and the
+// wait counts into its response time, so a database falling behind shows up
+// in the latencies instead of silently lowering the offered load, the
+// coordinated omission of a closed loop. Arrivals still waiting when the
+// duration is over are dropped and returned as the backlog.
+func runOpenLoop(ctx context.Context, w *workload, deadline time.Time, seed uint64, next func(rng *rand.Rand) (int, []any), run func(op int, args []any, arrived time.Time)) (arrivals int, backlog int) {
+	rng := rand.New(rand.NewPCG(seed, workloadStream))
+	clients := make(chan struct{}, w.clients)
+	var dropped sync.WaitGroup
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	arrival := time.Now()
+	for interrupted() == nil && ctx.Err() == nil {
+		arrival = arrival.Add(time.Duration(rng.ExpFloat64() / w.arrivalRate * float64(time.Second)))
+		if !arrival.Before(deadline) {
+			break
+		}
+		time.Sleep(time.Until(arrival))
+		op, args := next(rng)
+		arrivals++
+		wg.Add(1)
+		go func(arrived time.Time) {
+			defer wg.Done()
+			select {
+			case clients <- struct{}{}:
+			case <-time.After(time.Until(deadline)):
+				mu.Lock()
+				backlog++
+				mu.Unlock()
+				return
+			}
+			defer func() { <-clients }()
+			run(op, args, arrived)
+		}(arrival)
+	}
+	dropped.Wait()
+	wg.Wait()
+	return arrivals, backlog
+}
+
+func pickWorkloadOp(ops []workloadOp, total float64, rng *rand.Rand) int {
+	draw := rng.Float64() * total
+	for i, op := range ops {
+		if draw < op.weight {
+			return i
+		}
+		draw -= op.weight
+	}
+	return len(ops) - 1
+}
+
+func summarizeWorkloadQuery(op workloadOp, samples []time.Duration, responses []time.Duration, failed int) WorkloadQueryResult {
+	result := WorkloadQueryResult{
+		QueryId:     op.spec.id,
+		Query:       queryMetadata[op.spec.id].name,
+		Description: queryDescriptions[op.spec.id],
+		Weight:      op.weight,
+		Operations:  len(samples),
+		Errors:      failed,
+	}
+	if len(samples) == 0 {
+		return result
+	}
+	result.Latencies = results.Summarize(samples)
+	if len(responses) > 0 {
+		response := results.Summarize(responses)
+		result.ResponseP50Ms = response.P50Ms
+		result.ResponseP95Ms = response.P95Ms
+		result.ResponseP99Ms = response.P99Ms
+		result.ResponseMaxMs = response.MaxMs
+	}
+	return result
+}